@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+
+	"github.com/urfave/cli"
+
+	"github.com/docker/trailer/spec"
+)
+
+// junitProperties returns the properties linking a JUnit test to its
+// TestRail cases and, when tests maps the cases to the tests of a run, to
+// their results in the run.
+func junitProperties(baseURL string, runID int, tests map[int]int) func(name string) []spec.Property {
+	return func(name string) []spec.Property {
+		ids, err := spec.CaseIDs(name)
+		if err != nil || len(ids) == 0 {
+			return nil
+		}
+
+		properties := []spec.Property{}
+		if runID != 0 {
+			properties = append(properties, spec.Property{Name: "testrail_run", Value: fmt.Sprintf("%s/index.php?/runs/view/%d", baseURL, runID)})
+		}
+		for _, id := range ids {
+			properties = append(properties,
+				spec.Property{Name: "testrail_case_id", Value: strconv.Itoa(id)},
+				spec.Property{Name: "testrail_case", Value: fmt.Sprintf("%s/index.php?/cases/view/%d", baseURL, id)},
+			)
+			if testID, ok := tests[id]; ok {
+				properties = append(properties, spec.Property{Name: "testrail_result", Value: fmt.Sprintf("%s/index.php?/tests/view/%d", baseURL, testID)})
+			}
+		}
+		return properties
+	}
+}
+
+func annotateJUnitCommand() cli.Command {
+	return cli.Command{
+		Name:      "annotate-junit",
+		Usage:     "Add links to the TestRail cases and results to the tests of a JUnit report",
+		ArgsUsage: "[JUnit report]",
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "out, o",
+				Usage: "file to write the annotated report to, printed by default",
+			},
+			cli.IntFlag{
+				Name:  "run-id, r",
+				Usage: "TestRail run the results were uploaded to, to link them too",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			if c.NArg() != 1 {
+				return configErrorf("Must specify the JUnit report to annotate")
+			}
+			baseURL := instanceFromEnv()
+
+			runID := c.Int("run-id")
+			tests := map[int]int{}
+			if runID != 0 {
+				client, err := newClient()
+				if err != nil {
+					return err
+				}
+				list, err := freshTests(client, runID)
+				if err != nil {
+					return apiErrorf("Error getting tests of run %d: %s", runID, err)
+				}
+				for _, t := range list {
+					tests[t.CaseID] = t.ID
+				}
+			}
+
+			f, err := os.Open(c.Args().First())
+			if err != nil {
+				return configErrorf("Error opening report: %s", err)
+			}
+			defer f.Close()
+
+			var b bytes.Buffer
+			if err := spec.Annotate(f, &b, junitProperties(baseURL, runID, tests)); err != nil {
+				return parseErrorf("Error reading report %s: %s", c.Args().First(), err)
+			}
+
+			if c.String("out") == "" {
+				os.Stdout.Write(b.Bytes())
+				return nil
+			}
+			if err := ioutil.WriteFile(c.String("out"), b.Bytes(), 0644); err != nil {
+				return fmt.Errorf("Error writing annotated report: %s", err)
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/docker/trailer/spec"
+)
+
+func TestAnnotateJUnit(t *testing.T) {
+	s, stop := startFake(t)
+	defer stop()
+
+	dir, err := ioutil.TempDir("", "annotate")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	run1 := s.AddRun(1, 2, 11, 12)
+	report := writeReport(t, dir, `<testsuite name="accounts" tests="3">
+  <testcase name="TestRailC11 login" time="1"></testcase>
+  <testcase name="TestRailC13 refund" time="1"></testcase>
+  <testcase name="signup" time="1"></testcase>
+</testsuite>`)
+	out := filepath.Join(dir, "annotated.xml")
+
+	assert.NoError(t, run("annotate-junit", "-o", out, "--run-id", strconv.Itoa(run1.ID), report))
+	data, err := ioutil.ReadFile(out)
+	assert.NoError(t, err)
+	_, err = spec.ParseBytes(out, data)
+	assert.NoError(t, err)
+
+	var login int
+	for _, test := range s.Tests {
+		if test.RunID == run1.ID && test.CaseID == 11 {
+			login = test.ID
+		}
+	}
+	assert.Contains(t, string(data), fmt.Sprintf(`<testcase name="TestRailC11 login" time="1"><properties>`+
+		`<property name="testrail_run" value="%[1]s/index.php?/runs/view/%[2]d"></property>`+
+		`<property name="testrail_case_id" value="11"></property>`+
+		`<property name="testrail_case" value="%[1]s/index.php?/cases/view/11"></property>`+
+		`<property name="testrail_result" value="%[1]s/index.php?/tests/view/%[3]d"></property>`+
+		`</properties></testcase>`, s.URL, run1.ID, login))
+	// Cases not in the run are linked without a result.
+	assert.Contains(t, string(data), `<property name="testrail_case" value="`+s.URL+`/index.php?/cases/view/13"></property></properties></testcase>`)
+	assert.Contains(t, string(data), `<testcase name="signup" time="1"></testcase>`)
+
+	assert.Equal(t, exitConfig, exitCode(run("annotate-junit")))
+}
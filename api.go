@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli"
+)
+
+func apiCommand() cli.Command {
+	return cli.Command{
+		Name:      "api",
+		Usage:     "Perform a raw authenticated TestRail API call and print the JSON response",
+		ArgsUsage: "[method] [endpoint, e.g. get_cases/3&suite_id=33]",
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "data, d",
+				Usage: "JSON request body, @file to read it from a file or @- for stdin",
+			},
+			cli.IntFlag{
+				Name:  "retries, r",
+				Usage: "number of times to retry a GET call while TestRail is unreachable or overloaded",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			if c.NArg() != 2 {
+				return configErrorf("Must specify a method and an endpoint")
+			}
+			method := strings.ToUpper(c.Args().Get(0))
+			endpoint := strings.TrimPrefix(c.Args().Get(1), "/")
+			// Other calls may have been applied before they failed, so
+			// retrying them could add or change data twice.
+			if c.Int("retries") > 0 && method != "GET" {
+				return configErrorf("Can only retry GET calls, not %s", method)
+			}
+
+			var data interface{}
+			if body := c.String("data"); body != "" {
+				raw, err := readData(body)
+				if err != nil {
+					return parseErrorf("Error reading request body: %s", err)
+				}
+				if !json.Valid(raw) {
+					return parseErrorf("Request body is not valid JSON")
+				}
+				data = json.RawMessage(raw)
+			}
+
+			client, err := newClient()
+			if err != nil {
+				return err
+			}
+			var response json.RawMessage
+			for i := 0; i <= c.Int("retries"); i++ {
+				if i > 0 {
+					time.Sleep(time.Duration(i) * time.Second)
+				}
+				err = client.send(method, endpoint, data, &response)
+				if err == nil {
+					break
+				}
+				if _, ok := markUnavailable(err, err).(unavailableError); !ok {
+					break
+				}
+			}
+			if err != nil {
+				return apiErrorf("Error calling %s %s: %s", method, endpoint, err)
+			}
+
+			var out bytes.Buffer
+			if len(response) > 0 {
+				if err := json.Indent(&out, response, "", "  "); err != nil {
+					return fmt.Errorf("Error formatting response: %s", err)
+				}
+			}
+			fmt.Println(out.String())
+
+			return nil
+		},
+	}
+}
+
+// readData returns arg itself, or the contents of the named file when arg
+// starts with "@" ("@-" reads standard input).
+func readData(arg string) ([]byte, error) {
+	switch {
+	case arg == "@-":
+		return ioutil.ReadAll(os.Stdin)
+	case strings.HasPrefix(arg, "@"):
+		return ioutil.ReadFile(arg[1:])
+	default:
+		return []byte(arg), nil
+	}
+}
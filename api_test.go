@@ -0,0 +1,24 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPIRetries(t *testing.T) {
+	s, stop := startFake(t)
+	defer stop()
+
+	assert.NoError(t, run("api", "--retries", "2", "GET", "get_project/1"))
+	assert.Equal(t, exitConfig, exitCode(run("api", "--retries", "2", "POST", "add_result/1", "--data", "{}")))
+
+	// Rejected calls are not retried, only outages and rate limiting.
+	s.Lock()
+	s.Requests = nil
+	s.Unlock()
+	assert.Equal(t, exitAPI, exitCode(run("api", "--retries", "2", "GET", "get_nothing/1")))
+	s.Lock()
+	defer s.Unlock()
+	assert.Len(t, s.Requests, 1)
+}
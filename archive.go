@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli"
+
+	"github.com/docker/trailer/spec"
+)
+
+var archiveFlag = cli.StringFlag{
+	Name:  "archive",
+	Usage: "copy the reports and the TestRail payload to this s3://bucket/prefix, gs://bucket/prefix or directory, under the run ID",
+}
+
+// archiveUpload copies the reports and the payload of updates to dest, under
+// run-<ID>/<time> so every upload of a run is kept. S3 and GCS buckets are
+// written with the aws and gcloud CLIs, which bring their own credentials.
+// It returns where the files went.
+func archiveUpload(dest string, runID int, files []string, updates *spec.Updates) (string, error) {
+	stage, err := ioutil.TempDir("", "trailer-archive")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(stage)
+
+	if err := os.Mkdir(filepath.Join(stage, "reports"), 0755); err != nil {
+		return "", err
+	}
+	for i, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return "", err
+		}
+		// Reports of different directories often share a name.
+		name := fmt.Sprintf("%d-%s", i+1, filepath.Base(file))
+		if err := ioutil.WriteFile(filepath.Join(stage, "reports", name), data, 0644); err != nil {
+			return "", err
+		}
+	}
+
+	payload, err := updates.CreatePayload()
+	if err != nil {
+		return "", err
+	}
+	data, err := json.MarshalIndent(payload, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if err := ioutil.WriteFile(filepath.Join(stage, "payload.json"), data, 0644); err != nil {
+		return "", err
+	}
+
+	key := fmt.Sprintf("run-%d/%s", runID, time.Now().UTC().Format("20060102T150405.000Z"))
+	target := strings.TrimRight(dest, "/") + "/" + key
+	switch {
+	case strings.HasPrefix(dest, "s3://"):
+		err = archiveCommand("aws", "s3", "cp", "--recursive", "--only-show-errors", stage, target)
+	case strings.HasPrefix(dest, "gs://"):
+		err = archiveCommand("gcloud", "storage", "rsync", "--recursive", stage, target)
+	default:
+		target = filepath.Join(dest, filepath.FromSlash(key))
+		err = copyDir(stage, target)
+	}
+	return target, err
+}
+
+// archiveCommand runs a cloud storage CLI.
+func archiveCommand(name string, args ...string) error {
+	cmd := exec.Command(name, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s: %s: %s", name, err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// copyDir copies the files of the src tree to dst.
+func copyDir(src, dst string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return os.MkdirAll(filepath.Join(dst, rel), 0755)
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(filepath.Join(dst, rel), data, 0644)
+	})
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/docker/trailer/spec"
+)
+
+func TestArchiveUpload(t *testing.T) {
+	s, stop := startFake(t)
+	defer stop()
+
+	dir, err := ioutil.TempDir("", "archive")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	runID := s.AddRun(1, 2, 11, 12).ID
+	report := writeReport(t, dir, `<testsuite name="accounts" tests="1">
+  <testcase name="TestRailC11 login" time="1"></testcase>
+</testsuite>`)
+
+	archive := filepath.Join(dir, "archive")
+	assert.NoError(t, run("upload", "--run-id", strconv.Itoa(runID), "--archive", archive, report))
+
+	uploads, err := filepath.Glob(filepath.Join(archive, "run-"+strconv.Itoa(runID), "*"))
+	assert.NoError(t, err)
+	assert.Len(t, uploads, 1)
+	data, err := ioutil.ReadFile(filepath.Join(uploads[0], "reports", "1-report.xml"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "TestRailC11")
+	data, err = ioutil.ReadFile(filepath.Join(uploads[0], "payload.json"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"case_id": 11`)
+}
+
+func TestArchiveBuckets(t *testing.T) {
+	dir, err := ioutil.TempDir("", "archive")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// The fake CLIs record their arguments and the staged files.
+	log := filepath.Join(dir, "calls")
+	script := "#!/bin/sh\necho \"$0 $*\" >> " + log + "\nfor a in \"$@\"; do [ -d \"$a\" ] && ls -R \"$a\" >> " + log + "; done\nexit 0\n"
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "aws"), []byte(script), 0755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "gcloud"), []byte(script), 0755))
+	defer os.Setenv("PATH", os.Getenv("PATH"))
+	os.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	report := writeReport(t, dir, `<testsuite name="accounts" tests="0"></testsuite>`)
+	updates := &spec.Updates{ResultMap: map[int]spec.Update{11: {Status: spec.Passed}}}
+
+	location, err := archiveUpload("s3://audit/trailer/", 7, []string{report}, updates)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(location, "s3://audit/trailer/run-7/"), location)
+	location, err = archiveUpload("gs://audit", 7, []string{report}, updates)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(location, "gs://audit/run-7/"), location)
+
+	data, err := ioutil.ReadFile(log)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "aws s3 cp --recursive --only-show-errors ")
+	assert.Contains(t, string(data), "gcloud storage rsync --recursive ")
+	assert.Contains(t, string(data), "1-report.xml")
+	assert.Contains(t, string(data), "payload.json")
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+
+	"github.com/educlos/testrail"
+	"github.com/urfave/cli"
+
+	"github.com/docker/trailer/spec"
+)
+
+// ownershipMap assigns cases to users by section or case ID. The first rule
+// matching a case wins, and cases no rule matches go to Default.
+type ownershipMap struct {
+	Rules   []ownershipRule `yaml:"rules"`
+	Default string          `yaml:"default"`
+}
+
+// ownershipRule matches the cases of a section and its subsections, written
+// like "Accounts > Sessions", or cases by ID, written like "100-199, 250".
+// User is the email or name of a TestRail user.
+type ownershipRule struct {
+	Section string `yaml:"section"`
+	Cases   string `yaml:"cases"`
+	User    string `yaml:"user"`
+
+	ranges [][2]int
+}
+
+// loadOwnershipMap reads and checks an ownership map.
+func loadOwnershipMap(file string) (ownershipMap, error) {
+	var m ownershipMap
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return m, err
+	}
+	if err := yaml.Unmarshal(data, &m); err != nil {
+		return m, err
+	}
+
+	for i := range m.Rules {
+		r := &m.Rules[i]
+		if r.User == "" || (r.Section == "") == (r.Cases == "") {
+			return m, fmt.Errorf("rule %d must have a user and either a section or cases", i+1)
+		}
+		for _, part := range strings.Split(r.Cases, ",") {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			bounds := strings.SplitN(part, "-", 2)
+			if len(bounds) == 1 {
+				bounds = append(bounds, bounds[0])
+			}
+			low, err1 := strconv.Atoi(strings.TrimPrefix(strings.TrimSpace(bounds[0]), "C"))
+			high, err2 := strconv.Atoi(strings.TrimPrefix(strings.TrimSpace(bounds[1]), "C"))
+			if err1 != nil || err2 != nil || low > high {
+				return m, fmt.Errorf("rule %d has bad cases %q", i+1, part)
+			}
+			r.ranges = append(r.ranges, [2]int{low, high})
+		}
+	}
+	return m, nil
+}
+
+// owner returns the user of the first rule matching the case in section, or
+// the default user.
+func (m ownershipMap) owner(caseID int, section []string) string {
+	for _, r := range m.Rules {
+		for _, bounds := range r.ranges {
+			if caseID >= bounds[0] && caseID <= bounds[1] {
+				return r.User
+			}
+		}
+		if r.Section == "" {
+			continue
+		}
+		path := []string{}
+		for _, name := range strings.Split(r.Section, sectionSeparator) {
+			path = append(path, strings.TrimSpace(name))
+		}
+		if len(path) <= len(section) && sectionKey(path) == sectionKey(section[:len(path)]) {
+			return r.User
+		}
+	}
+	return m.Default
+}
+
+// assignment is a failed test of a run and the user it goes to.
+type assignment struct {
+	CaseID int    `json:"case_id"`
+	Title  string `json:"title"`
+	User   string `json:"user"`
+	UserID int    `json:"user_id"`
+}
+
+// assignFailures assigns the failed tests of the run nobody is assigned to
+// yet. Statuses other than passed and untested count as failed.
+func assignFailures(tests []testrail.Test, cases []testrail.Case, sections []testrail.Section, users []testrail.User, m ownershipMap) ([]assignment, error) {
+	passed := spec.DefaultStatusMap.ID(spec.Passed)
+
+	caseSections := map[int]int{}
+	for _, c := range cases {
+		caseSections[c.ID] = c.SectionID
+	}
+	userIDs := map[string]int{}
+	for _, u := range users {
+		userIDs[strings.ToLower(u.Email)] = u.ID
+		userIDs[strings.ToLower(u.Name)] = u.ID
+	}
+	paths := sectionPaths(sections)
+
+	assignments := []assignment{}
+	unknown := map[string]bool{}
+	for _, t := range tests {
+		if t.StatusID == passed || t.StatusID == untestedStatus || t.AssignedToID != 0 {
+			continue
+		}
+		user := m.owner(t.CaseID, paths[caseSections[t.CaseID]])
+		if user == "" {
+			continue
+		}
+		id, ok := userIDs[strings.ToLower(user)]
+		if !ok {
+			unknown[user] = true
+			continue
+		}
+		assignments = append(assignments, assignment{CaseID: t.CaseID, Title: t.Title, User: user, UserID: id})
+	}
+	if len(unknown) > 0 {
+		names := []string{}
+		for name := range unknown {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("no TestRail user is %s", strings.Join(names, ", "))
+	}
+	sort.Slice(assignments, func(i, j int) bool { return assignments[i].CaseID < assignments[j].CaseID })
+	return assignments, nil
+}
+
+func assignCommand() cli.Command {
+	return cli.Command{
+		Name:      "assign",
+		Usage:     "Assign the failed tests of a run nobody is assigned to, by an ownership map",
+		ArgsUsage: "--run-id N --owners owners.yaml",
+		Flags: []cli.Flag{
+			cli.IntFlag{
+				Name:  "run-id, r",
+				Usage: "TestRail run with the failed tests",
+			},
+			cli.StringFlag{
+				Name:  "owners",
+				Usage: "YAML file with rules assigning sections or case IDs to users",
+			},
+			cli.BoolFlag{
+				Name:  "dry, d",
+				Usage: "print the assignments without making them",
+			},
+			outputFlag,
+		},
+		Action: func(c *cli.Context) error {
+			runID := c.Int("run-id")
+			if runID == 0 || c.String("owners") == "" {
+				return configErrorf("Must set --run-id and --owners")
+			}
+			owners, err := loadOwnershipMap(c.String("owners"))
+			if err != nil {
+				return configErrorf("Error reading ownership map: %s", err)
+			}
+
+			client, err := newClient()
+			if err != nil {
+				return err
+			}
+			var run testrail.Run
+			if err := client.send("GET", fmt.Sprintf("get_run/%d", runID), nil, &run); err != nil {
+				return apiErrorf("Error getting run %d: %s", runID, err)
+			}
+			tests, err := freshTests(client, runID)
+			if err != nil {
+				return apiErrorf("Error getting tests of run %d: %s", runID, err)
+			}
+			cases, err := client.GetCases(run.ProjectID, run.SuiteID)
+			if err != nil {
+				return apiErrorf("Error getting cases: %s", err)
+			}
+			sections, err := client.GetSections(run.ProjectID, run.SuiteID)
+			if err != nil {
+				return apiErrorf("Error getting sections: %s", err)
+			}
+			users, err := client.GetUsers()
+			if err != nil {
+				return apiErrorf("Error getting users: %s", err)
+			}
+
+			assignments, err := assignFailures(tests, cases, sections, users, owners)
+			if err != nil {
+				return configErrorf("Error in ownership map: %s", err)
+			}
+			rows := [][]string{}
+			for _, a := range assignments {
+				rows = append(rows, []string{fmt.Sprintf("C%d", a.CaseID), a.Title, a.User})
+			}
+			if err := render(c.String("output"), assignments, []string{"CASE", "TITLE", "USER"}, rows); err != nil {
+				return fmt.Errorf("Error printing assignments: %s", err)
+			}
+			if c.Bool("dry") || len(assignments) == 0 {
+				return nil
+			}
+
+			results := testrail.SendableResultsForCase{}
+			for _, a := range assignments {
+				results.Results = append(results.Results, testrail.ResultsForCase{
+					CaseID:         a.CaseID,
+					SendableResult: testrail.SendableResult{AssignedToID: a.UserID, Comment: "Assigned to " + a.User + " by the ownership map"},
+				})
+			}
+			if _, err := client.AddResultsForCases(runID, results); err != nil {
+				return apiErrorf("Error assigning tests: %s", err)
+			}
+			return nil
+		},
+	}
+}
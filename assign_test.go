@@ -0,0 +1,102 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/educlos/testrail"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOwnershipMap(t *testing.T) {
+	dir, err := ioutil.TempDir("", "owners")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "owners.yaml")
+	assert.NoError(t, ioutil.WriteFile(file, []byte(`rules:
+  - cases: C100-199, 250
+    user: bob@example.com
+  - section: Accounts > Sessions
+    user: Alice
+default: carol@example.com
+`), 0644))
+	m, err := loadOwnershipMap(file)
+	assert.NoError(t, err)
+
+	for _, tc := range []struct {
+		id      int
+		section []string
+		owner   string
+	}{
+		{id: 150, section: []string{"Accounts", "Sessions"}, owner: "bob@example.com"},
+		{id: 250, owner: "bob@example.com"},
+		{id: 11, section: []string{"Accounts", "Sessions", "Expiry"}, owner: "Alice"},
+		{id: 12, section: []string{"Accounts"}, owner: "carol@example.com"},
+		{id: 13, section: []string{"Accounts", "SessionsOld"}, owner: "carol@example.com"},
+	} {
+		assert.Equal(t, tc.owner, m.owner(tc.id, tc.section), "C%d", tc.id)
+	}
+
+	for _, bad := range []string{
+		"rules:\n  - cases: 10-5\n    user: bob\n",
+		"rules:\n  - section: Accounts\n",
+		"rules:\n  - section: Accounts\n    cases: 1\n    user: bob\n",
+	} {
+		assert.NoError(t, ioutil.WriteFile(file, []byte(bad), 0644))
+		_, err := loadOwnershipMap(file)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestAssign(t *testing.T) {
+	s, stop := startFake(t)
+	defer stop()
+
+	dir, err := ioutil.TempDir("", "assign")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	s.Users = []testrail.User{{ID: 7, Name: "Alice", Email: "alice@example.com"}, {ID: 8, Name: "Bob", Email: "bob@example.com"}}
+	s.Cases = append(s.Cases, testrail.Case{ID: 13, SuiteID: 2, SectionID: 3, Title: "Refund"})
+	runID := strconv.Itoa(s.AddRun(1, 2, 11, 12, 13).ID)
+	report := writeReport(t, dir, `<testsuite name="accounts" tests="3">
+  <testcase name="TestRailC11 login" time="1"><failure message="boom">denied</failure></testcase>
+  <testcase name="TestRailC12 logout" time="1"></testcase>
+  <testcase name="TestRailC13 refund" time="1"><failure message="boom">no money</failure></testcase>
+</testsuite>`)
+	assert.NoError(t, run("upload", "--run-id", runID, report))
+
+	owners := filepath.Join(dir, "owners.yaml")
+	assert.NoError(t, ioutil.WriteFile(owners, []byte("rules:\n  - cases: 13\n    user: Bob\n  - section: Accounts\n    user: alice@example.com\n"), 0644))
+
+	assigned := func() map[int]int {
+		s.Lock()
+		defer s.Unlock()
+		m := map[int]int{}
+		for _, test := range s.Tests {
+			m[test.CaseID] = test.AssignedToID
+		}
+		return m
+	}
+	assert.NoError(t, run("assign", "--run-id", runID, "--owners", owners, "--dry"))
+	assert.Equal(t, map[int]int{11: 0, 12: 0, 13: 0}, assigned())
+
+	assert.NoError(t, run("assign", "--run-id", runID, "--owners", owners))
+	assert.Equal(t, map[int]int{11: 7, 12: 0, 13: 8}, assigned())
+	for _, test := range s.Tests {
+		assert.NotEqual(t, untestedStatus, test.StatusID)
+	}
+
+	// Assigned tests are left alone.
+	assert.NoError(t, ioutil.WriteFile(owners, []byte("default: Bob\n"), 0644))
+	assert.NoError(t, run("assign", "--run-id", runID, "--owners", owners))
+	assert.Equal(t, map[int]int{11: 7, 12: 0, 13: 8}, assigned())
+
+	assert.NoError(t, ioutil.WriteFile(owners, []byte("default: Dave\n"), 0644))
+	s.Tests[0].AssignedToID = 0
+	assert.Equal(t, exitConfig, exitCode(run("assign", "--run-id", runID, "--owners", owners)))
+}
@@ -0,0 +1,474 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/educlos/testrail"
+	"github.com/urfave/cli"
+
+	"github.com/docker/trailer/pkg/download"
+	"github.com/docker/trailer/pkg/upload"
+)
+
+// backupVersion is the version of the backup format, raised when a change
+// keeps restore from reading older backups.
+const backupVersion = 1
+
+// backupFile is the name of the backup in its directory.
+const backupFile = "backup.json"
+
+// projectBackup is everything trailer keeps of a project. Cases and results
+// are kept as TestRail returned them, so their custom fields survive.
+type projectBackup struct {
+	Version   int              `json:"version"`
+	CreatedAt time.Time        `json:"created_at"`
+	Project   testrail.Project `json:"project"`
+	Suites    []suiteBackup    `json:"suites"`
+	Runs      []runBackup      `json:"runs"`
+	Plans     []planBackup     `json:"plans"`
+}
+
+type suiteBackup struct {
+	Suite    testrail.Suite     `json:"suite"`
+	Sections []testrail.Section `json:"sections"`
+	Cases    []json.RawMessage  `json:"cases"`
+}
+
+type runBackup struct {
+	Run     testrail.Run      `json:"run"`
+	Tests   []testrail.Test   `json:"tests"`
+	Results []json.RawMessage `json:"results"`
+}
+
+// planBackup is a test plan with the runs of its entries, which TestRail
+// leaves out of the runs of the project.
+type planBackup struct {
+	Plan testrail.Plan `json:"plan"`
+	Runs []runBackup   `json:"runs"`
+}
+
+// backupRef holds the IDs restore needs from a case or result.
+type backupRef struct {
+	ID        int `json:"id"`
+	SectionID int `json:"section_id"`
+	TestID    int `json:"test_id"`
+}
+
+// Fields of cases and results TestRail sets itself and rejects or ignores
+// when they are added.
+var (
+	readOnlyCaseFields   = []string{"id", "section_id", "suite_id", "created_by", "created_on", "updated_by", "updated_on", "estimate_forecast", "display_order", "is_deleted"}
+	readOnlyResultFields = []string{"id", "test_id", "created_by", "created_on", "assignedto_id", "attachment_ids"}
+)
+
+// backupProject fetches the suites, sections, cases, runs and plans of a
+// project and the tests and results of the runs, counting suites and runs on
+// bar.
+func backupProject(client testrailAPI, projectID int, bar *progress) (projectBackup, error) {
+	b := projectBackup{Version: backupVersion, CreatedAt: time.Now().UTC()}
+
+	var err error
+	if b.Project, err = client.GetProject(projectID); err != nil {
+		return b, fmt.Errorf("getting project: %s", err)
+	}
+	suites, err := client.GetSuites(projectID)
+	if err != nil {
+		return b, fmt.Errorf("getting suites: %s", err)
+	}
+	bar.Set(0, len(suites))
+	for _, suite := range suites {
+		s, err := backupSuite(client, projectID, suite)
+		if err != nil {
+			return b, err
+		}
+		b.Suites = append(b.Suites, s)
+		bar.Add(1)
+	}
+
+	runs, err := client.GetRuns(projectID)
+	if err != nil {
+		return b, fmt.Errorf("getting runs: %s", err)
+	}
+	sort.Slice(runs, func(i, j int) bool { return runs[i].ID < runs[j].ID })
+	var plans []testrail.Plan
+	if err := client.send("GET", fmt.Sprintf("get_plans/%d", projectID), nil, &plans); err != nil {
+		return b, fmt.Errorf("getting plans: %s", err)
+	}
+	sort.Slice(plans, func(i, j int) bool { return plans[i].ID < plans[j].ID })
+	// get_plans leaves out the entries, get_plan has them with their runs.
+	total := len(suites) + len(runs)
+	for i, plan := range plans {
+		if err := client.send("GET", fmt.Sprintf("get_plan/%d", plan.ID), nil, &plans[i]); err != nil {
+			return b, fmt.Errorf("getting plan %d: %s", plan.ID, err)
+		}
+		for _, entry := range plans[i].Entries {
+			total += len(entry.Runs)
+		}
+	}
+
+	bar.Set(len(suites), total)
+	for _, run := range runs {
+		r, err := backupRun(client, run)
+		if err != nil {
+			return b, err
+		}
+		b.Runs = append(b.Runs, r)
+		bar.Add(1)
+	}
+	for _, plan := range plans {
+		p := planBackup{Plan: plan}
+		for _, entry := range plan.Entries {
+			for _, run := range entry.Runs {
+				r, err := backupRun(client, run)
+				if err != nil {
+					return b, err
+				}
+				p.Runs = append(p.Runs, r)
+				bar.Add(1)
+			}
+		}
+		b.Plans = append(b.Plans, p)
+	}
+	return b, nil
+}
+
+// backupRun fetches the tests and results of a run.
+func backupRun(client testrailAPI, run testrail.Run) (runBackup, error) {
+	r := runBackup{Run: run}
+	var err error
+	if r.Tests, err = freshTests(client, run.ID); err != nil {
+		return r, fmt.Errorf("getting tests of run %d: %s", run.ID, err)
+	}
+	if r.Results, err = rawRunResults(client, run.ID); err != nil {
+		return r, fmt.Errorf("getting results of run %d: %s", run.ID, err)
+	}
+	return r, nil
+}
+
+// rawRunResults returns every result of the run as TestRail sent it, so
+// custom fields are kept, a page at a time like runResults.
+func rawRunResults(client testrailAPI, runID int) ([]json.RawMessage, error) {
+	results := []json.RawMessage{}
+	for offset := 0; ; offset += resultsPageSize {
+		var page []json.RawMessage
+		if err := client.send("GET", fmt.Sprintf("get_results_for_run/%d&limit=%d&offset=%d", runID, resultsPageSize, offset), nil, &page); err != nil {
+			return nil, err
+		}
+		results = append(results, page...)
+		if len(page) < resultsPageSize {
+			return results, nil
+		}
+	}
+}
+
+// backupSuite fetches the sections and cases of a suite.
+func backupSuite(client testrailAPI, projectID int, suite testrail.Suite) (suiteBackup, error) {
+	s := suiteBackup{Suite: suite}
+	var err error
+	if s.Sections, err = client.GetSections(projectID, suite.ID); err != nil {
+		return s, fmt.Errorf("getting sections of suite %d: %s", suite.ID, err)
+	}
+	if err := client.send("GET", fmt.Sprintf("get_cases/%d&suite_id=%d", projectID, suite.ID), nil, &s.Cases); err != nil {
+		return s, fmt.Errorf("getting cases of suite %d: %s", suite.ID, err)
+	}
+	return s, nil
+}
+
+// restoreCounts counts what restore created.
+type restoreCounts struct {
+	Suites, Sections, Cases, Runs, Results int
+}
+
+// restoreBackup recreates the suites, sections, cases, runs and plans of a
+// backup in the project, and adds the results of each run again, oldest
+// first, counting cases and runs on bar. TestRail gives everything new IDs
+// and creation times. Each run of a plan comes back as an entry of its own,
+// without the configurations it was created for.
+func restoreBackup(client testrailAPI, projectID int, b projectBackup, bar *progress) (restoreCounts, error) {
+	var n restoreCounts
+	total := len(b.Runs)
+	for _, s := range b.Suites {
+		total += len(s.Cases)
+	}
+	for _, p := range b.Plans {
+		total += len(p.Runs)
+	}
+	bar.Set(0, total)
+	suiteIDs, caseIDs := map[int]int{}, map[int]int{}
+
+	for _, s := range b.Suites {
+		var suite testrail.Suite
+		if err := client.send("POST", fmt.Sprintf("add_suite/%d", projectID), testrail.SendableSuite{Name: s.Suite.Name, Description: s.Suite.Description}, &suite); err != nil {
+			return n, fmt.Errorf("adding suite %q: %s", s.Suite.Name, err)
+		}
+		suiteIDs[s.Suite.ID] = suite.ID
+		n.Suites++
+
+		// Parents come before their children, at a lower depth.
+		sections := append([]testrail.Section{}, s.Sections...)
+		sort.SliceStable(sections, func(i, j int) bool { return sections[i].Depth < sections[j].Depth })
+		sectionIDs := map[int]int{}
+		for _, section := range sections {
+			created, err := client.AddSection(projectID, testrail.SendableSection{
+				Name:        section.Name,
+				Description: section.Description,
+				SuiteID:     suite.ID,
+				ParentID:    sectionIDs[section.ParentID],
+			})
+			if err != nil {
+				return n, fmt.Errorf("adding section %q: %s", section.Name, err)
+			}
+			sectionIDs[section.ID] = created.ID
+			n.Sections++
+		}
+
+		for _, raw := range s.Cases {
+			var ref backupRef
+			fields := map[string]interface{}{}
+			if err := json.Unmarshal(raw, &ref); err != nil {
+				return n, err
+			}
+			if err := json.Unmarshal(raw, &fields); err != nil {
+				return n, err
+			}
+			for _, field := range readOnlyCaseFields {
+				delete(fields, field)
+			}
+			dropEmpty(fields)
+			var created testrail.Case
+			if err := client.send("POST", fmt.Sprintf("add_case/%d", sectionIDs[ref.SectionID]), fields, &created); err != nil {
+				return n, fmt.Errorf("adding case C%d: %s", ref.ID, err)
+			}
+			caseIDs[ref.ID] = created.ID
+			n.Cases++
+			bar.Add(1)
+		}
+	}
+
+	for _, r := range b.Runs {
+		includeAll := false
+		var run testrail.Run
+		if err := client.send("POST", fmt.Sprintf("add_run/%d", projectID), testrail.SendableRun{
+			SuiteID:     suiteIDs[r.Run.SuiteID],
+			Name:        r.Run.Name,
+			Description: r.Run.Description,
+			IncludeAll:  &includeAll,
+			CaseIDs:     runCases(r, caseIDs),
+		}, &run); err != nil {
+			return n, fmt.Errorf("adding run %q: %s", r.Run.Name, err)
+		}
+		n.Runs++
+		bar.Add(1)
+		if err := restoreResults(client, run.ID, r, caseIDs, &n); err != nil {
+			return n, err
+		}
+	}
+
+	for _, p := range b.Plans {
+		var plan testrail.Plan
+		if err := client.send("POST", fmt.Sprintf("add_plan/%d", projectID), testrail.SendablePlan{Name: p.Plan.Name, Description: p.Plan.Description}, &plan); err != nil {
+			return n, fmt.Errorf("adding plan %q: %s", p.Plan.Name, err)
+		}
+		for _, r := range p.Runs {
+			var entry testrail.Entry
+			if err := client.send("POST", fmt.Sprintf("add_plan_entry/%d", plan.ID), testrail.SendableEntry{
+				SuiteID: suiteIDs[r.Run.SuiteID],
+				Name:    r.Run.Name,
+				CaseIDs: runCases(r, caseIDs),
+			}, &entry); err != nil {
+				return n, fmt.Errorf("adding run %q to plan %q: %s", r.Run.Name, p.Plan.Name, err)
+			}
+			if len(entry.Runs) == 0 {
+				return n, fmt.Errorf("adding run %q to plan %q: TestRail created no run", r.Run.Name, p.Plan.Name)
+			}
+			n.Runs++
+			bar.Add(1)
+			if err := restoreResults(client, entry.Runs[0].ID, r, caseIDs, &n); err != nil {
+				return n, err
+			}
+		}
+	}
+	return n, nil
+}
+
+// runCases returns the restored cases of the tests of a run.
+func runCases(r runBackup, caseIDs map[int]int) []int {
+	cases := []int{}
+	for _, test := range r.Tests {
+		cases = append(cases, caseIDs[test.CaseID])
+	}
+	return cases
+}
+
+// restoreResults adds the results of a backed up run to the restored run,
+// oldest first, in batches of upload.BatchSize like upload sends them.
+func restoreResults(client testrailAPI, runID int, r runBackup, caseIDs map[int]int, n *restoreCounts) error {
+	testCases := map[int]int{}
+	for _, test := range r.Tests {
+		testCases[test.ID] = caseIDs[test.CaseID]
+	}
+
+	type result struct {
+		ref    backupRef
+		fields map[string]interface{}
+	}
+	results := []result{}
+	for _, raw := range r.Results {
+		res := result{fields: map[string]interface{}{}}
+		if err := json.Unmarshal(raw, &res.ref); err != nil {
+			return err
+		}
+		if err := json.Unmarshal(raw, &res.fields); err != nil {
+			return err
+		}
+		for _, field := range readOnlyResultFields {
+			delete(res.fields, field)
+		}
+		dropEmpty(res.fields)
+		res.fields["case_id"] = testCases[res.ref.TestID]
+		results = append(results, res)
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].ref.ID < results[j].ref.ID })
+
+	for start := 0; start < len(results); start += upload.BatchSize {
+		end := start + upload.BatchSize
+		if end > len(results) {
+			end = len(results)
+		}
+		payload := []map[string]interface{}{}
+		for _, res := range results[start:end] {
+			payload = append(payload, res.fields)
+		}
+		if err := client.send("POST", fmt.Sprintf("add_results_for_cases/%d", runID), map[string]interface{}{"results": payload}, nil); err != nil {
+			return fmt.Errorf("adding results of run %q: %s", r.Run.Name, err)
+		}
+		n.Results += len(payload)
+	}
+	return nil
+}
+
+// dropEmpty removes the unset fields, which TestRail returns as null or an
+// empty string whatever their type, and may reject when they are sent back.
+func dropEmpty(fields map[string]interface{}) {
+	for k, v := range fields {
+		if v == nil || v == "" {
+			delete(fields, k)
+		}
+	}
+}
+
+func backupCommand() cli.Command {
+	return cli.Command{
+		Name:      "backup",
+		Usage:     "Save the suites, sections, cases, runs, plans and results of a project to a directory",
+		ArgsUsage: "--project-id N --dir DIR",
+		Flags: []cli.Flag{
+			cli.IntFlag{
+				Name:  "project-id, p",
+				Usage: "TestRail project to back up",
+			},
+			cli.StringFlag{
+				Name:  "dir",
+				Usage: "directory to write the backup to",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			if c.Int("project-id") == 0 {
+				return configErrorf("Must set --project-id to a non-zero integer")
+			}
+			if c.String("dir") == "" {
+				return configErrorf("Must set --dir")
+			}
+
+			client, err := newClient()
+			if err != nil {
+				return err
+			}
+			bar := newProgress("Backing up", 0)
+			b, err := backupProject(client, c.Int("project-id"), bar)
+			bar.Finish()
+			if err != nil {
+				return apiErrorf("Error backing up project %d: %s", c.Int("project-id"), err)
+			}
+
+			data, err := json.MarshalIndent(b, "", "  ")
+			if err != nil {
+				return fmt.Errorf("Error encoding backup: %s", err)
+			}
+			if err := os.MkdirAll(c.String("dir"), 0755); err != nil {
+				return fmt.Errorf("Error creating backup directory: %s", err)
+			}
+			if err := download.WriteFile(filepath.Join(c.String("dir"), backupFile), data); err != nil {
+				return fmt.Errorf("Error writing backup: %s", err)
+			}
+
+			cases := 0
+			for _, s := range b.Suites {
+				cases += len(s.Cases)
+			}
+			runs := len(b.Runs)
+			for _, p := range b.Plans {
+				runs += len(p.Runs)
+			}
+			statusf("Backed up %d suites, %d cases, %d runs and %d plans of %s\n", len(b.Suites), cases, runs, len(b.Plans), b.Project.Name)
+			return nil
+		},
+	}
+}
+
+func restoreCommand() cli.Command {
+	return cli.Command{
+		Name:      "restore",
+		Usage:     "Recreate a backup in a project, which should be empty",
+		ArgsUsage: "--project-id N --dir DIR",
+		Flags: []cli.Flag{
+			cli.IntFlag{
+				Name:  "project-id, p",
+				Usage: "TestRail project to restore into",
+			},
+			cli.StringFlag{
+				Name:  "dir",
+				Usage: "directory of the backup",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			if c.Int("project-id") == 0 {
+				return configErrorf("Must set --project-id to a non-zero integer")
+			}
+			if c.String("dir") == "" {
+				return configErrorf("Must set --dir")
+			}
+
+			data, err := ioutil.ReadFile(filepath.Join(c.String("dir"), backupFile))
+			if err != nil {
+				return configErrorf("Error reading backup: %s", err)
+			}
+			var b projectBackup
+			if err := json.Unmarshal(data, &b); err != nil {
+				return parseErrorf("Error reading backup: %s", err)
+			}
+			if b.Version != backupVersion {
+				return parseErrorf("Backup is of version %d, this trailer reads version %d", b.Version, backupVersion)
+			}
+
+			client, err := newClient()
+			if err != nil {
+				return err
+			}
+			bar := newProgress("Restoring", 0)
+			n, err := restoreBackup(client, c.Int("project-id"), b, bar)
+			bar.Finish()
+			statusf("Restored %d suites, %d sections, %d cases, %d runs and %d results\n", n.Suites, n.Sections, n.Cases, n.Runs, n.Results)
+			if err != nil {
+				return apiErrorf("Error restoring backup: %s", err)
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/educlos/testrail"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/docker/trailer/internal/faketestrail"
+	"github.com/docker/trailer/pkg/upload"
+)
+
+func TestBackupRestore(t *testing.T) {
+	s, stop := startFake(t)
+	defer stop()
+
+	dir, err := ioutil.TempDir("", "backup")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	s.Sections = append(s.Sections, testrail.Section{ID: 4, SuiteID: 2, ParentID: 3, Depth: 1, Name: "Sessions"})
+	s.Cases = append(s.Cases, testrail.Case{ID: 13, SuiteID: 2, SectionID: 4, Title: "Expiry", PriorityID: 4, Refs: "ACC-1"})
+	runID := strconv.Itoa(s.AddRun(1, 2, 11, 12, 13).ID)
+	report := writeReport(t, dir, `<testsuite name="accounts" tests="2">
+  <testcase name="TestRailC11 login" time="1"></testcase>
+  <testcase name="TestRailC13 expiry" time="1"><failure message="boom">never expires</failure></testcase>
+</testsuite>`)
+	assert.NoError(t, run("upload", "--run-id", runID, report))
+
+	backup := filepath.Join(dir, "backup")
+	assert.NoError(t, run("backup", "--project-id", "1", "--dir", backup))
+	data, err := ioutil.ReadFile(filepath.Join(backup, backupFile))
+	assert.NoError(t, err)
+	var b projectBackup
+	assert.NoError(t, json.Unmarshal(data, &b))
+	assert.Equal(t, backupVersion, b.Version)
+	if assert.Len(t, b.Suites, 1) && assert.Len(t, b.Runs, 1) {
+		assert.Len(t, b.Suites[0].Sections, 2)
+		assert.Len(t, b.Suites[0].Cases, 3)
+		assert.Len(t, b.Runs[0].Tests, 3)
+		assert.Len(t, b.Runs[0].Results, 2)
+	}
+
+	s.Projects = append(s.Projects, testrail.Project{ID: 7, Name: "Restored"})
+	assert.NoError(t, run("restore", "--project-id", "7", "--dir", backup))
+
+	var suite testrail.Suite
+	for _, su := range s.Suites {
+		if su.ProjectID == 7 {
+			suite = su
+		}
+	}
+	assert.Equal(t, "Master", suite.Name)
+	sections := map[string]testrail.Section{}
+	for _, section := range s.Sections {
+		if section.SuiteID == suite.ID {
+			sections[section.Name] = section
+		}
+	}
+	assert.Equal(t, sections["Accounts"].ID, sections["Sessions"].ParentID)
+
+	cases := map[string]testrail.Case{}
+	for _, c := range s.Cases {
+		if c.SuiteID == suite.ID {
+			cases[c.Title] = c
+		}
+	}
+	assert.Len(t, cases, 3)
+	assert.Equal(t, sections["Sessions"].ID, cases["Expiry"].SectionID)
+	assert.Equal(t, 4, cases["Expiry"].PriorityID)
+	assert.Equal(t, "ACC-1", cases["Expiry"].Refs)
+
+	statuses := map[int]int{}
+	for _, test := range s.Tests {
+		if test.RunID != s.Runs[len(s.Runs)-1].ID {
+			continue
+		}
+		statuses[test.CaseID] = test.StatusID
+	}
+	assert.Equal(t, map[int]int{cases["Login"].ID: 1, cases["Logout"].ID: untestedStatus, cases["Expiry"].ID: 5}, statuses)
+
+	// Results beyond the first page TestRail returns are kept too.
+	for _, test := range s.Tests {
+		if strconv.Itoa(test.RunID) == runID && test.CaseID == 11 {
+			for i := 0; i < 300; i++ {
+				s.Results = append(s.Results, faketestrail.Result{ID: 10000 + i, TestID: test.ID, CaseID: 11, StatusID: 1})
+			}
+		}
+	}
+	paged := filepath.Join(dir, "paged")
+	assert.NoError(t, run("backup", "--project-id", "1", "--dir", paged))
+	data, err = ioutil.ReadFile(filepath.Join(paged, backupFile))
+	assert.NoError(t, err)
+	b = projectBackup{}
+	assert.NoError(t, json.Unmarshal(data, &b))
+	if assert.Len(t, b.Runs, 1) {
+		assert.Len(t, b.Runs[0].Results, 302)
+	}
+
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(backup, backupFile), []byte(`{"version": 2}`), 0644))
+	assert.Equal(t, exitParse, exitCode(run("restore", "--project-id", "7", "--dir", backup)))
+	assert.Equal(t, exitConfig, exitCode(run("backup", "--project-id", "1")))
+}
+
+func TestBackupRestorePlans(t *testing.T) {
+	s, stop := startFake(t)
+	defer stop()
+
+	dir, err := ioutil.TempDir("", "backup")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	plan := s.AddPlan(1, 2, 11, 12)
+	for i, test := range s.Tests {
+		if test.RunID == plan.Entries[0].Runs[0].ID && test.CaseID == 11 {
+			for j := 0; j < 3; j++ {
+				s.Results = append(s.Results, faketestrail.Result{ID: 10000 + j, TestID: test.ID, CaseID: 11, StatusID: 5 - j*2})
+			}
+			s.Tests[i].StatusID = 1
+		}
+	}
+
+	assert.NoError(t, run("backup", "--project-id", "1", "--dir", dir))
+	data, err := ioutil.ReadFile(filepath.Join(dir, backupFile))
+	assert.NoError(t, err)
+	var b projectBackup
+	assert.NoError(t, json.Unmarshal(data, &b))
+	assert.Len(t, b.Runs, 0)
+	if assert.Len(t, b.Plans, 1) && assert.Len(t, b.Plans[0].Runs, 1) {
+		assert.Len(t, b.Plans[0].Runs[0].Tests, 2)
+		assert.Len(t, b.Plans[0].Runs[0].Results, 3)
+	}
+
+	defer func(size int) { upload.BatchSize = size }(upload.BatchSize)
+	upload.BatchSize = 2
+	s.Projects = append(s.Projects, testrail.Project{ID: 7, Name: "Restored"})
+	s.Requests = nil
+	assert.NoError(t, run("restore", "--project-id", "7", "--dir", dir))
+
+	s.Lock()
+	defer s.Unlock()
+	if assert.Len(t, s.Plans, 2) {
+		restored := s.Plans[1]
+		assert.Equal(t, 7, restored.ProjectID)
+		var runID int
+		for _, r := range s.Runs {
+			if r.PlanID == restored.ID {
+				runID = r.ID
+			}
+		}
+		statuses := map[string]int{}
+		for _, test := range s.Tests {
+			if test.RunID == runID {
+				statuses[test.Title] = test.StatusID
+			}
+		}
+		assert.Equal(t, map[string]int{"Login": 1, "Logout": untestedStatus}, statuses)
+	}
+	batches := 0
+	for _, r := range s.Requests {
+		if strings.HasPrefix(r.Endpoint, "add_results_for_cases/") {
+			batches++
+		}
+	}
+	assert.Equal(t, 2, batches)
+}
+
+func TestSuitesClone(t *testing.T) {
+	s, stop := startFake(t)
+	defer stop()
+
+	s.Sections = append(s.Sections, testrail.Section{ID: 4, SuiteID: 2, ParentID: 3, Depth: 1, Name: "Sessions"})
+	s.Cases = append(s.Cases, testrail.Case{ID: 13, SuiteID: 2, SectionID: 4, Title: "Expiry"})
+
+	assert.NoError(t, run("suites", "clone", "--suite-id", "2", "--name", "Release"))
+	s.Lock()
+	defer s.Unlock()
+	if assert.Len(t, s.Suites, 2) {
+		suite := s.Suites[1]
+		assert.Equal(t, "Release", suite.Name)
+		assert.Equal(t, 1, suite.ProjectID)
+		sections := map[int]testrail.Section{}
+		for _, section := range s.Sections {
+			if section.SuiteID == suite.ID {
+				sections[section.ID] = section
+			}
+		}
+		titles := map[string]string{}
+		for _, c := range s.Cases {
+			if c.SuiteID == suite.ID {
+				titles[c.Title] = sections[c.SectionID].Name
+			}
+		}
+		assert.Equal(t, map[string]string{"Login": "Accounts", "Logout": "Accounts", "Expiry": "Sessions"}, titles)
+	}
+}
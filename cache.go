@@ -0,0 +1,271 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/educlos/testrail"
+	"github.com/urfave/cli"
+)
+
+var cacheFlags = []cli.Flag{
+	cli.DurationFlag{
+		Name:  "cache-ttl",
+		Usage: "reuse the cases, sections and tests fetched by earlier commands for this long, 0 disables the cache",
+	},
+	cli.StringFlag{
+		Name:  "cache-dir",
+		Usage: "directory of the API cache, the user cache directory by default",
+	},
+	cli.BoolFlag{
+		Name:  "refresh",
+		Usage: "fetch from TestRail even if the cache is fresh, and update it",
+	},
+}
+
+// cacheOptions are the API cache settings of the running command.
+type cacheOptions struct {
+	dir     string
+	ttl     time.Duration
+	refresh bool
+}
+
+// apiCache is set from the global flags before a command runs.
+var apiCache cacheOptions
+
+// defaultCacheDir returns the directory of the API cache.
+func defaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "trailer")
+}
+
+// cachedAPI keeps the cases, sections and tests it fetches in files, so CI
+// jobs uploading to the same run share them instead of each asking TestRail.
+// Writes through it drop the entries they make stale, writes made elsewhere
+// show up once the entries expire. Each instance and user has its own
+// entries, since IDs are only unique within an instance and users may not
+// see the same data.
+type cachedAPI struct {
+	testrailAPI
+	cacheOptions
+}
+
+// withCache wraps client in the API cache when it is enabled.
+func withCache(client *client, opts cacheOptions) testrailAPI {
+	if opts.ttl <= 0 {
+		return client
+	}
+	if opts.dir == "" {
+		opts.dir = defaultCacheDir()
+	}
+	opts.dir = filepath.Join(opts.dir, instanceCacheDir(client.baseURL, client.username))
+	return &cachedAPI{testrailAPI: client, cacheOptions: opts}
+}
+
+// instanceCacheDir names the directory of the entries of a user of an
+// instance.
+func instanceCacheDir(baseURL, username string) string {
+	sum := sha256.Sum256([]byte(baseURL + "\n" + username))
+	return hex.EncodeToString(sum[:8])
+}
+
+func (c *cachedAPI) GetCases(projectID, suiteID int, sectionID ...int) ([]testrail.Case, error) {
+	var cases []testrail.Case
+	err := c.cached(cacheKey("cases", append([]int{projectID, suiteID}, sectionID...)...), &cases, func() (interface{}, error) {
+		return c.testrailAPI.GetCases(projectID, suiteID, sectionID...)
+	})
+	return cases, err
+}
+
+func (c *cachedAPI) GetSections(projectID int, suiteID ...int) ([]testrail.Section, error) {
+	var sections []testrail.Section
+	err := c.cached(cacheKey("sections", append([]int{projectID}, suiteID...)...), &sections, func() (interface{}, error) {
+		return c.testrailAPI.GetSections(projectID, suiteID...)
+	})
+	return sections, err
+}
+
+// GetTests only caches the unfiltered tests of a run, since their statuses
+// change with every upload.
+func (c *cachedAPI) GetTests(runID int, statusID ...[]int) ([]testrail.Test, error) {
+	if len(statusID) > 0 {
+		return c.testrailAPI.GetTests(runID, statusID...)
+	}
+
+	var tests []testrail.Test
+	err := c.cached(cacheKey("tests", runID), &tests, func() (interface{}, error) {
+		return c.testrailAPI.GetTests(runID)
+	})
+	return tests, err
+}
+
+func (c *cachedAPI) AddSection(projectID int, s testrail.SendableSection) (testrail.Section, error) {
+	defer c.invalidate("sections")
+	return c.testrailAPI.AddSection(projectID, s)
+}
+
+func (c *cachedAPI) DeleteSection(sectionID int) error {
+	defer c.invalidate("sections")
+	defer c.invalidate("cases")
+	return c.testrailAPI.DeleteSection(sectionID)
+}
+
+func (c *cachedAPI) AddCase(sectionID int, newCase testrail.SendableCase) (testrail.Case, error) {
+	defer c.invalidate("cases")
+	return c.testrailAPI.AddCase(sectionID, newCase)
+}
+
+func (c *cachedAPI) UpdateCase(caseID int, updates testrail.SendableCase) (testrail.Case, error) {
+	defer c.invalidate("cases")
+	return c.testrailAPI.UpdateCase(caseID, updates)
+}
+
+func (c *cachedAPI) DeleteCase(caseID int) error {
+	defer c.invalidate("cases")
+	return c.testrailAPI.DeleteCase(caseID)
+}
+
+func (c *cachedAPI) AddResultsForCases(runID int, results testrail.SendableResultsForCase) ([]testrail.Result, error) {
+	defer c.remove(cacheKey("tests", runID))
+	return c.testrailAPI.AddResultsForCases(runID, results)
+}
+
+func (c *cachedAPI) send(method, uri string, data, v interface{}) error {
+	defer c.invalidateWrite(method, uri)
+	return c.testrailAPI.send(method, uri, data, v)
+}
+
+func (c *cachedAPI) request(method, uri string, data interface{}) (http.Header, []byte, error) {
+	defer c.invalidateWrite(method, uri)
+	return c.testrailAPI.request(method, uri, data)
+}
+
+// runWrites are the endpoints changing the tests of the run whose ID follows
+// them.
+var runWrites = map[string]bool{
+	"update_run":            true,
+	"close_run":             true,
+	"delete_run":            true,
+	"add_result_for_case":   true,
+	"add_results":           true,
+	"add_results_for_cases": true,
+}
+
+// invalidateWrite drops the entries a call to uri made stale.
+func (c *cachedAPI) invalidateWrite(method, uri string) {
+	if method == "GET" {
+		return
+	}
+	parts := strings.FieldsFunc(uri, func(r rune) bool { return r == '/' || r == '&' })
+	if len(parts) == 0 {
+		return
+	}
+	endpoint := parts[0]
+
+	switch {
+	case runWrites[endpoint] && len(parts) > 1:
+		c.remove("tests-" + parts[1])
+	case endpoint == "add_result" || strings.Contains(endpoint, "plan"):
+		// Results of a test and plan entries name no run the cache knows.
+		c.invalidate("tests")
+	case strings.Contains(endpoint, "section"):
+		c.invalidate("sections")
+		c.invalidate("cases")
+	case strings.Contains(endpoint, "case"):
+		c.invalidate("cases")
+	}
+}
+
+// cacheKey names the entry of the kind of data for the IDs.
+func cacheKey(kind string, ids ...int) string {
+	parts := []string{kind}
+	for _, id := range ids {
+		parts = append(parts, strconv.Itoa(id))
+	}
+	return strings.Join(parts, "-")
+}
+
+// cached decodes the entry key into v if it is fresh, and otherwise stores
+// the result of fetch in it first.
+func (c *cachedAPI) cached(key string, v interface{}, fetch func() (interface{}, error)) error {
+	file := filepath.Join(c.dir, key+".json")
+
+	if !c.refresh {
+		if info, err := os.Stat(file); err == nil && time.Since(info.ModTime()) < c.ttl {
+			data, err := ioutil.ReadFile(file)
+			if err == nil && json.Unmarshal(data, v) == nil {
+				slog.Debug("Using cached API response", "entry", key, "age", time.Since(info.ModTime()))
+				return nil
+			}
+		}
+	}
+
+	fresh, err := fetch()
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(fresh)
+	if err != nil {
+		return fmt.Errorf("encoding cache entry %s: %s", key, err)
+	}
+	if err := c.store(file, data); err != nil {
+		slog.Warn("Error writing the API cache", "entry", key, "error", err)
+	}
+	return json.Unmarshal(data, v)
+}
+
+// store writes an entry atomically, since other jobs may read it at the same
+// time.
+func (c *cachedAPI) store(file string, data []byte) error {
+	if err := os.MkdirAll(c.dir, 0700); err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(c.dir, ".entry")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), file)
+}
+
+// invalidate removes the entries of a kind of data.
+func (c *cachedAPI) invalidate(kind string) {
+	files, _ := filepath.Glob(filepath.Join(c.dir, kind+"-*.json"))
+	for _, file := range files {
+		c.removeFile(file)
+	}
+}
+
+// remove removes the entry key.
+func (c *cachedAPI) remove(key string) {
+	c.removeFile(filepath.Join(c.dir, key+".json"))
+}
+
+func (c *cachedAPI) removeFile(file string) {
+	if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+		slog.Warn("Error removing API cache entry", "file", file, "error", err)
+	}
+}
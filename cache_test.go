@@ -0,0 +1,92 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPICache(t *testing.T) {
+	s, stop := startFake(t)
+	defer stop()
+
+	dir, err := ioutil.TempDir("", "cache")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	runID := strconv.Itoa(s.AddRun(1, 2, 11, 12).ID)
+	report := writeReport(t, dir, `<testsuite name="accounts" tests="1">
+  <testcase name="TestRailC11 login" time="1"></testcase>
+</testsuite>`)
+
+	requests := func(endpoint string) int {
+		s.Lock()
+		defer s.Unlock()
+		n := 0
+		for _, r := range s.Requests {
+			if r.Endpoint == endpoint {
+				n++
+			}
+		}
+		return n
+	}
+
+	cache := []string{"--cache-ttl", "1m", "--cache-dir", dir}
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, run(append(cache, "validate", "--run-id", runID, report)...))
+	}
+	assert.Equal(t, 1, requests("get_tests/"+runID))
+
+	// Uploading results changes the statuses of the tests, so they are
+	// fetched again afterwards.
+	assert.NoError(t, run(append(cache, "upload", "--run-id", runID, report)...))
+	assert.Equal(t, 1, requests("get_tests/"+runID))
+	assert.NoError(t, run(append(cache, "validate", "--run-id", runID, report)...))
+	assert.Equal(t, 2, requests("get_tests/"+runID))
+
+	assert.NoError(t, run(append(cache, "--refresh", "validate", "--run-id", runID, report)...))
+	assert.Equal(t, 3, requests("get_tests/"+runID))
+
+	assert.NoError(t, run("validate", "--run-id", runID, report))
+	assert.Equal(t, 4, requests("get_tests/"+runID))
+
+	// Cases added to the run are seen by the same command.
+	partial := strconv.Itoa(s.AddRun(1, 2, 11).ID)
+	both := writeReport(t, dir, `<testsuite name="accounts" tests="2">
+  <testcase name="TestRailC11 login" time="1"></testcase>
+  <testcase name="TestRailC12 logout" time="1"></testcase>
+</testsuite>`)
+	assert.NoError(t, run(append(cache, "upload", "--run-id", partial, both)...))
+	assert.NoError(t, run(append(cache, "upload", "--strict", "--add-missing-to-run", "--run-id", partial, both)...))
+
+	// Creating a case drops the cached cases.
+	assert.NoError(t, run(append(cache, "sections", "list", "--project-id", "1", "--suite-id", "2")...))
+	assert.NoError(t, run(append(cache, "sections", "list", "--project-id", "1", "--suite-id", "2")...))
+	assert.Equal(t, 1, requests("get_sections/1&suite_id=2"))
+	assert.NoError(t, run(append(cache, "sections", "create", "--project-id", "1", "--suite-id", "2", "--name", "Billing")...))
+	assert.NoError(t, run(append(cache, "sections", "list", "--project-id", "1", "--suite-id", "2")...))
+	assert.Equal(t, 2, requests("get_sections/1&suite_id=2"))
+}
+
+func TestAPICacheInstances(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cache")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	opts := cacheOptions{dir: dir, ttl: time.Minute}
+	dirs := map[string]bool{}
+	for _, c := range []struct{ url, user string }{
+		{"https://a.testrail.io", "ci@example.com"},
+		{"https://b.testrail.io", "ci@example.com"},
+		{"https://a.testrail.io", "admin@example.com"},
+	} {
+		client, err := newClientFor(c.url, c.user, "token")
+		assert.NoError(t, err)
+		dirs[withCache(client, opts).(*cachedAPI).dir] = true
+	}
+	assert.Len(t, dirs, 3)
+}
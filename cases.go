@@ -0,0 +1,293 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+
+	"github.com/urfave/cli"
+
+	"github.com/docker/trailer/pkg/download"
+)
+
+type caseManifest struct {
+	Cases []download.Case `yaml:"cases"`
+}
+
+// loadCaseManifest reads case entries from a CSV file when file has a .csv
+// extension and from YAML otherwise.
+func loadCaseManifest(file string) ([]download.Case, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.EqualFold(filepath.Ext(file), ".csv") {
+		return parseCaseCSV(string(data))
+	}
+
+	var m caseManifest
+	if err := yaml.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return m.Cases, nil
+}
+
+// parseCaseCSV reads case entries from CSV data whose header row names the
+// columns using the same keys as the YAML manifest.
+func parseCaseCSV(data string) ([]download.Case, error) {
+	records, err := csv.NewReader(strings.NewReader(data)).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	columns := map[string]int{}
+	for i, name := range records[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["title"]; !ok {
+		if _, ok := columns["id"]; !ok {
+			return nil, fmt.Errorf("CSV header must contain a title or id column")
+		}
+	}
+
+	entries := []download.Case{}
+	for line, record := range records[1:] {
+		get := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		atoi := func(name string) (int, error) {
+			v := strings.TrimPrefix(get(name), "C")
+			if v == "" {
+				return 0, nil
+			}
+			i, err := strconv.Atoi(v)
+			if err != nil {
+				return 0, fmt.Errorf("line %d: invalid %s: %s", line+2, name, err)
+			}
+			return i, nil
+		}
+
+		var e download.Case
+		e.Title = get("title")
+		e.Section = get("section")
+		e.Estimate = get("estimate")
+		e.Refs = get("refs")
+		for name, dst := range map[string]*int{
+			"id":           &e.ID,
+			"section_id":   &e.SectionID,
+			"type_id":      &e.TypeID,
+			"priority_id":  &e.PriorityID,
+			"milestone_id": &e.MilestoneID,
+		} {
+			if *dst, err = atoi(name); err != nil {
+				return nil, err
+			}
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, nil
+}
+
+// caseEntries returns the entries from --manifest if it is set, or a single
+// entry built from the remaining flags otherwise.
+func caseEntries(c *cli.Context) ([]download.Case, error) {
+	if manifest := c.String("manifest"); manifest != "" {
+		entries, err := loadCaseManifest(manifest)
+		if err != nil {
+			return nil, parseErrorf("Error reading manifest: %s", err)
+		}
+		for i := range entries {
+			if entries[i].SectionID == 0 && c.IsSet("section-id") {
+				entries[i].SectionID = c.Int("section-id")
+			}
+		}
+		return entries, nil
+	}
+
+	return []download.Case{{
+		ID:          c.Int("case-id"),
+		SectionID:   c.Int("section-id"),
+		Title:       c.String("title"),
+		TypeID:      c.Int("type-id"),
+		PriorityID:  c.Int("priority-id"),
+		MilestoneID: c.Int("milestone-id"),
+		Estimate:    c.String("estimate"),
+		Refs:        c.String("refs"),
+	}}, nil
+}
+
+func casesCommand() cli.Command {
+	fieldFlags := []cli.Flag{
+		cli.StringFlag{
+			Name:  "manifest, m",
+			Usage: "YAML or CSV file describing the cases to act on",
+		},
+		cli.StringFlag{
+			Name:  "title, t",
+			Usage: "title of the case",
+		},
+		cli.IntFlag{
+			Name:  "type-id",
+			Usage: "TestRail case type ID",
+		},
+		cli.IntFlag{
+			Name:  "priority-id",
+			Usage: "TestRail priority ID",
+		},
+		cli.IntFlag{
+			Name:  "milestone-id",
+			Usage: "TestRail milestone ID",
+		},
+		cli.StringFlag{
+			Name:  "estimate",
+			Usage: "estimated duration of the case, e.g. 30s or 1m 45s",
+		},
+		cli.StringFlag{
+			Name:  "refs",
+			Usage: "comma separated list of references",
+		},
+	}
+
+	return cli.Command{
+		Name:  "cases",
+		Usage: "Create, update and delete TestRail cases",
+		Subcommands: []cli.Command{
+			{
+				Name:  "create",
+				Usage: "Create cases from flags or a manifest",
+				Flags: append(fieldFlags, cli.IntFlag{
+					Name:  "section-id, s",
+					Usage: "section to create the cases in, unless set per case in the manifest",
+				}),
+				Action: func(c *cli.Context) error {
+					entries, err := caseEntries(c)
+					if err != nil {
+						return err
+					}
+
+					client, err := newClient()
+					if err != nil {
+						return err
+					}
+					for _, e := range entries {
+						if e.SectionID == 0 || e.Title == "" {
+							return configErrorf("Every case needs a non-zero section ID and a title")
+						}
+
+						created, err := client.AddCase(e.SectionID, e.Sendable())
+						if err != nil {
+							return apiErrorf("Error creating case %q: %s", e.Title, err)
+						}
+
+						statusf("Created case C%d: %s\n", created.ID, created.Title)
+					}
+
+					return nil
+				},
+			},
+			{
+				Name:  "update",
+				Usage: "Update cases from flags or a manifest",
+				Flags: append(fieldFlags, cli.IntFlag{
+					Name:  "case-id, c",
+					Usage: "ID of the case to update",
+				}),
+				Action: func(c *cli.Context) error {
+					entries, err := caseEntries(c)
+					if err != nil {
+						return err
+					}
+
+					client, err := newClient()
+					if err != nil {
+						return err
+					}
+					for _, e := range entries {
+						if e.ID == 0 {
+							return configErrorf("Every case needs a non-zero case ID")
+						}
+
+						// The update payload always carries a title, so keep
+						// the current one unless a new title is given.
+						if e.Title == "" {
+							existing, err := client.GetCase(e.ID)
+							if err != nil {
+								return apiErrorf("Error getting case C%d: %s", e.ID, err)
+							}
+							e.Title = existing.Title
+						}
+
+						updated, err := client.UpdateCase(e.ID, e.Sendable())
+						if err != nil {
+							return apiErrorf("Error updating case C%d: %s", e.ID, err)
+						}
+
+						statusf("Updated case C%d: %s\n", updated.ID, updated.Title)
+					}
+
+					return nil
+				},
+			},
+			{
+				Name:      "delete",
+				Usage:     "Delete cases by ID or from a manifest",
+				ArgsUsage: "[case IDs...]",
+				Flags: []cli.Flag{
+					cli.StringFlag{
+						Name:  "manifest, m",
+						Usage: "YAML or CSV file listing the cases to delete",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					ids := []int{}
+					if c.String("manifest") != "" {
+						entries, err := caseEntries(c)
+						if err != nil {
+							return err
+						}
+						for _, e := range entries {
+							ids = append(ids, e.ID)
+						}
+					}
+					for _, arg := range c.Args() {
+						id, err := strconv.Atoi(strings.TrimPrefix(arg, "C"))
+						if err != nil {
+							return configErrorf("Cannot convert string to int: %s", err)
+						}
+						ids = append(ids, id)
+					}
+
+					if len(ids) == 0 {
+						return configErrorf("Must specify at least one case ID")
+					}
+
+					client, err := newClient()
+					if err != nil {
+						return err
+					}
+					for _, id := range ids {
+						if err := client.DeleteCase(id); err != nil {
+							return apiErrorf("Error deleting case C%d: %s", id, err)
+						}
+						statusf("Deleted case C%d\n", id)
+					}
+
+					return nil
+				},
+			},
+		},
+	}
+}
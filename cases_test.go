@@ -0,0 +1,47 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/docker/trailer/pkg/download"
+)
+
+func TestParseCaseCSV(t *testing.T) {
+	testcases := []struct {
+		data        string
+		shouldError bool
+		entries     []download.Case
+	}{
+		{
+			data: "Section_ID,Title,Priority_ID,Refs\n12,Login works,2,JIRA-1\n12,\"Logout, then login\",,\n",
+			entries: []download.Case{
+				{SectionID: 12, Title: "Login works", PriorityID: 2, Refs: "JIRA-1"},
+				{SectionID: 12, Title: "Logout, then login"},
+			},
+		},
+		{
+			data:    "id\nC42\n7\n",
+			entries: []download.Case{{ID: 42}, {ID: 7}},
+		},
+		{
+			data:        "name,section\nfoo,1\n",
+			shouldError: true,
+		},
+		{
+			data:        "title,section_id\nfoo,bar\n",
+			shouldError: true,
+		},
+	}
+
+	for _, testcase := range testcases {
+		entries, err := parseCaseCSV(testcase.data)
+		if testcase.shouldError {
+			assert.Error(t, err)
+		} else {
+			assert.NoError(t, err)
+			assert.Equal(t, testcase.entries, entries)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+
+	"github.com/urfave/cli"
+)
+
+var cassetteFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "record",
+		Usage: "save the TestRail API requests and responses to this cassette file",
+	},
+	cli.StringFlag{
+		Name:  "replay",
+		Usage: "answer TestRail API requests from this cassette file instead of the network",
+	},
+}
+
+// interaction is a request and the response TestRail gave to it. Requests
+// are stored without the host or credentials, so a cassette recorded against
+// one instance can be replayed anywhere and shared in bug reports.
+type interaction struct {
+	Method   string      `json:"method"`
+	URI      string      `json:"uri"`
+	Body     string      `json:"body,omitempty"`
+	Status   int         `json:"status"`
+	Header   http.Header `json:"header,omitempty"`
+	Response string      `json:"response"`
+}
+
+// cassette is an http.RoundTripper that records the TestRail API
+// interactions going through next, or replays recorded ones when next is nil.
+// Other requests, such as webhooks whose URLs are secrets, are sent through
+// direct and never recorded.
+type cassette struct {
+	mu           sync.Mutex
+	next         http.RoundTripper
+	direct       http.RoundTripper
+	Interactions []interaction `json:"interactions"`
+	used         []bool
+}
+
+// replaying is set when API requests are answered from a cassette, in which
+// case no credentials are needed.
+var replaying bool
+
+// setupCassette routes the HTTP requests of both TestRail clients through a
+// recording or replaying cassette. The returned function saves the recording
+// and restores the network transport, it must be called once the command is
+// done.
+func setupCassette(record, replay string) (func() error, error) {
+	transport := http.DefaultTransport
+	done := func() error {
+		http.DefaultTransport = transport
+		replaying = false
+		return nil
+	}
+
+	switch {
+	case record != "" && replay != "":
+		return done, configErrorf("--record and --replay cannot be used together")
+	case replay != "":
+		c, err := loadCassette(replay)
+		if err != nil {
+			return done, configErrorf("Error reading cassette: %s", err)
+		}
+		c.direct = transport
+		http.DefaultTransport = c
+		replaying = true
+	case record != "":
+		c := &cassette{next: transport, direct: transport}
+		http.DefaultTransport = c
+		done = func() error {
+			http.DefaultTransport = transport
+			if err := c.save(record); err != nil {
+				return fmt.Errorf("Error writing cassette: %s", err)
+			}
+			return nil
+		}
+	}
+
+	return done, nil
+}
+
+func loadCassette(file string) (*cassette, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &cassette{}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, err
+	}
+	c.used = make([]bool, len(c.Interactions))
+	return c, nil
+}
+
+func (c *cassette) save(file string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, append(data, '\n'), 0644)
+}
+
+func (c *cassette) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !isAPIRequest(req) {
+		return c.direct.RoundTrip(req)
+	}
+
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = ioutil.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	if c.next == nil {
+		return c.replay(req, string(body))
+	}
+
+	resp, err := c.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	content, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.Interactions = append(c.Interactions, interaction{
+		Method:   req.Method,
+		URI:      req.URL.RequestURI(),
+		Body:     string(body),
+		Status:   resp.StatusCode,
+		Header:   http.Header{"Content-Type": resp.Header["Content-Type"]},
+		Response: string(content),
+	})
+	c.mu.Unlock()
+
+	resp.Body = ioutil.NopCloser(bytes.NewReader(content))
+	return resp, nil
+}
+
+// replay answers req with the first unused interaction recorded for the same
+// request, so repeated requests get their responses in the recorded order.
+func (c *cassette) replay(req *http.Request, body string) (*http.Response, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	uri := req.URL.RequestURI()
+	for i, in := range c.Interactions {
+		if c.used[i] || in.Method != req.Method || in.URI != uri || in.Body != body {
+			continue
+		}
+		c.used[i] = true
+
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", in.Status, http.StatusText(in.Status)),
+			StatusCode:    in.Status,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        in.Header,
+			Body:          ioutil.NopCloser(bytes.NewReader([]byte(in.Response))),
+			ContentLength: int64(len(in.Response)),
+			Request:       req,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("no recorded interaction for %s %s", req.Method, uri)
+}
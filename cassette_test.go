@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCassetteRecordReplay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cassette")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	cassetteFile := filepath.Join(dir, "cassette.json")
+	report := writeReport(t, dir, `<testsuite name="accounts" tests="2">
+  <testcase name="TestRailC11 login" time="1"></testcase>
+  <testcase name="TestRailC12 logout" time="2"></testcase>
+</testsuite>`)
+
+	s, stop := startFake(t)
+	runID := strconv.Itoa(s.AddRun(1, 2, 11, 12).ID)
+	assert.NoError(t, run("--record", cassetteFile, "upload", "--run-id", runID, report))
+	recorded := len(s.Requests)
+	stop()
+
+	data, err := ioutil.ReadFile(cassetteFile)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, strings.Count(string(data), `"method"`))
+	assert.Equal(t, 2, recorded)
+	assert.NotContains(t, string(data), "token")
+
+	// The server is gone and there are no credentials, every request must
+	// be answered from the cassette.
+	os.Setenv("TESTRAIL_URL", "http://127.0.0.1:1")
+	defer os.Unsetenv("TESTRAIL_URL")
+	assert.NoError(t, run("--replay", cassetteFile, "upload", "--run-id", runID, report))
+
+	// Requests that were not recorded fail.
+	err = run("--replay", cassetteFile, "upload", "--run-id", "1", report)
+	assert.Equal(t, exitAPI, exitCode(err))
+	assert.Contains(t, err.Error(), "no recorded interaction")
+
+	assert.Equal(t, exitConfig, exitCode(run("--record", cassetteFile, "--replay", cassetteFile, "projects")))
+}
+
+func TestCassetteRecordsOnlyAPI(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "{}")
+	}))
+	defer srv.Close()
+
+	c := &cassette{next: http.DefaultTransport, direct: http.DefaultTransport}
+	client := &http.Client{Transport: c}
+	for _, url := range []string{
+		srv.URL + "/index.php?/api/v2/get_projects",
+		srv.URL + "/services/T000/B000/webhook-secret",
+	} {
+		resp, err := client.Post(url, "application/json", strings.NewReader(`{"text": "results"}`))
+		assert.NoError(t, err)
+		resp.Body.Close()
+	}
+
+	assert.Len(t, c.Interactions, 1)
+	assert.Equal(t, "/index.php?/api/v2/get_projects", c.Interactions[0].URI)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/urfave/cli"
+)
+
+var ciInfoFlag = cli.BoolTFlag{
+	Name:  "ci-info",
+	Usage: "add the CI build, branch and commit detected from the environment to the comment, set to false to turn off",
+}
+
+// ciBuild is the CI build trailer runs in.
+type ciBuild struct {
+	Provider string
+	Name     string
+	URL      string
+	Branch   string
+	Commit   string
+	// Number is the build number of the pipeline or job.
+	Number string
+}
+
+// detectCI reads the build from the variables GitHub Actions, GitLab CI and
+// Jenkins set, in that order.
+func detectCI() (ciBuild, bool) {
+	env := os.Getenv
+	switch {
+	case env("GITHUB_ACTIONS") == "true":
+		b := ciBuild{
+			Provider: "GitHub Actions",
+			Name:     strings.TrimSpace(fmt.Sprintf("%s %s #%s", env("GITHUB_REPOSITORY"), env("GITHUB_WORKFLOW"), env("GITHUB_RUN_NUMBER"))),
+			Branch:   firstOf(env("GITHUB_HEAD_REF"), env("GITHUB_REF_NAME")),
+			Commit:   env("GITHUB_SHA"),
+			Number:   env("GITHUB_RUN_NUMBER"),
+		}
+		if env("GITHUB_RUN_ID") != "" {
+			b.URL = fmt.Sprintf("%s/%s/actions/runs/%s", firstOf(env("GITHUB_SERVER_URL"), "https://github.com"), env("GITHUB_REPOSITORY"), env("GITHUB_RUN_ID"))
+		}
+		return b, true
+	case env("GITLAB_CI") == "true":
+		return ciBuild{
+			Provider: "GitLab CI",
+			Name:     strings.TrimSpace(fmt.Sprintf("%s pipeline #%s", env("CI_PROJECT_PATH"), env("CI_PIPELINE_ID"))),
+			URL:      firstOf(env("CI_PIPELINE_URL"), env("CI_JOB_URL")),
+			Branch:   firstOf(env("CI_MERGE_REQUEST_SOURCE_BRANCH_NAME"), env("CI_COMMIT_REF_NAME")),
+			Commit:   env("CI_COMMIT_SHA"),
+			Number:   env("CI_PIPELINE_IID"),
+		}, true
+	case env("JENKINS_URL") != "" || env("BUILD_URL") != "":
+		return ciBuild{
+			Provider: "Jenkins",
+			Name:     firstOf(env("BUILD_TAG"), strings.TrimSpace(fmt.Sprintf("%s #%s", env("JOB_NAME"), env("BUILD_NUMBER")))),
+			URL:      env("BUILD_URL"),
+			Branch:   firstOf(env("CHANGE_BRANCH"), env("BRANCH_NAME"), strings.TrimPrefix(env("GIT_BRANCH"), "origin/")),
+			Commit:   env("GIT_COMMIT"),
+			Number:   env("BUILD_NUMBER"),
+		}, true
+	}
+	return ciBuild{}, false
+}
+
+// String describes the build in one line, the same way for every provider.
+func (b ciBuild) String() string {
+	s := b.Provider
+	if b.Name != "" {
+		s += " " + b.Name
+	}
+	if b.Branch != "" {
+		s += " on " + b.Branch
+	}
+	if b.Commit != "" {
+		commit := b.Commit
+		if len(commit) > 12 {
+			commit = commit[:12]
+		}
+		s += " at " + commit
+	}
+	if b.URL != "" {
+		s += ": " + b.URL
+	}
+	return s
+}
+
+// withCIInfo adds the line describing the CI build to comment, unless
+// --ci-info is false or trailer does not run in a known CI.
+func withCIInfo(c *cli.Context, comment string) string {
+	if !c.BoolT("ci-info") {
+		return comment
+	}
+	b, ok := detectCI()
+	if !ok {
+		return comment
+	}
+	return strings.TrimSpace(comment + "\n" + b.String())
+}
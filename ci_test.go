@@ -0,0 +1,74 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetectCI(t *testing.T) {
+	testcases := []struct {
+		env      map[string]string
+		expected string
+	}{
+		{
+			env: map[string]string{
+				"GITHUB_ACTIONS": "true", "GITHUB_REPOSITORY": "docker/trailer", "GITHUB_WORKFLOW": "ci", "GITHUB_RUN_NUMBER": "7",
+				"GITHUB_RUN_ID": "123", "GITHUB_REF_NAME": "main", "GITHUB_SHA": "0123456789abcdef",
+			},
+			expected: "GitHub Actions docker/trailer ci #7 on main at 0123456789ab: https://github.com/docker/trailer/actions/runs/123",
+		},
+		{
+			env: map[string]string{
+				"GITLAB_CI": "true", "CI_PROJECT_PATH": "qa/trailer", "CI_PIPELINE_ID": "42",
+				"CI_PIPELINE_URL": "https://gitlab.example.com/qa/trailer/-/pipelines/42", "CI_COMMIT_REF_NAME": "release", "CI_COMMIT_SHA": "abc",
+			},
+			expected: "GitLab CI qa/trailer pipeline #42 on release at abc: https://gitlab.example.com/qa/trailer/-/pipelines/42",
+		},
+		{
+			env: map[string]string{
+				"JENKINS_URL": "https://jenkins.example.com/", "JOB_NAME": "trailer", "BUILD_NUMBER": "9",
+				"BUILD_URL": "https://jenkins.example.com/job/trailer/9/", "GIT_BRANCH": "origin/main",
+			},
+			expected: "Jenkins trailer #9 on main: https://jenkins.example.com/job/trailer/9/",
+		},
+	}
+
+	vars := []string{"GITHUB_ACTIONS", "GITLAB_CI", "JENKINS_URL", "BUILD_URL", "BUILD_TAG", "BRANCH_NAME", "CHANGE_BRANCH", "GITHUB_HEAD_REF", "GITHUB_SERVER_URL", "CI_MERGE_REQUEST_SOURCE_BRANCH_NAME"}
+	for _, testcase := range testcases {
+		for k := range testcase.env {
+			vars = append(vars, k)
+		}
+	}
+	old := map[string]string{}
+	for _, k := range vars {
+		if v, ok := os.LookupEnv(k); ok {
+			old[k] = v
+		}
+		os.Unsetenv(k)
+	}
+	defer func() {
+		for _, k := range vars {
+			os.Unsetenv(k)
+			if v, ok := old[k]; ok {
+				os.Setenv(k, v)
+			}
+		}
+	}()
+
+	_, ok := detectCI()
+	assert.False(t, ok)
+
+	for _, testcase := range testcases {
+		for k, v := range testcase.env {
+			os.Setenv(k, v)
+		}
+		b, ok := detectCI()
+		assert.True(t, ok)
+		assert.Equal(t, testcase.expected, b.String())
+		for k := range testcase.env {
+			os.Unsetenv(k)
+		}
+	}
+}
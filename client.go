@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/educlos/testrail"
+
+	"github.com/docker/trailer/pkg/config"
+)
+
+const defaultTestrailURL = "https://docker.testrail.com"
+
+// client wraps the testrail API client and adds raw access to the
+// endpoints and fields that the testrail package does not cover.
+type client struct {
+	*testrail.Client
+
+	baseURL    string
+	url        string
+	username   string
+	token      string
+	httpClient *http.Client
+}
+
+// testrailAPI is the part of the TestRail API the commands use. client
+// implements it against a TestRail instance, tests and middleware such as
+// retries or caching provide their own implementations.
+type testrailAPI interface {
+	GetProjects(isCompleted ...bool) ([]testrail.Project, error)
+	GetProject(projectID int) (testrail.Project, error)
+	GetSuites(projectID int) ([]testrail.Suite, error)
+	GetSections(projectID int, suiteID ...int) ([]testrail.Section, error)
+	AddSection(projectID int, newSection testrail.SendableSection) (testrail.Section, error)
+	DeleteSection(sectionID int) error
+	GetCases(projectID, suiteID int, sectionID ...int) ([]testrail.Case, error)
+	GetCase(caseID int) (testrail.Case, error)
+	AddCase(sectionID int, newCase testrail.SendableCase) (testrail.Case, error)
+	UpdateCase(caseID int, updates testrail.SendableCase) (testrail.Case, error)
+	DeleteCase(caseID int) error
+	DeleteMilestone(milestoneID int) error
+	GetStatuses() ([]testrail.Status, error)
+	GetUsers() ([]testrail.User, error)
+	GetUserByEmail(email string) (testrail.User, error)
+	GetRuns(projectID int, filters ...testrail.RequestFilterForRun) ([]testrail.Run, error)
+	GetTests(runID int, statusID ...[]int) ([]testrail.Test, error)
+	GetResultsForRun(runID int, filters ...testrail.RequestFilterForRunResults) ([]testrail.Result, error)
+	AddResultsForCases(runID int, newResult testrail.SendableResultsForCase) ([]testrail.Result, error)
+
+	// send and request call endpoints and fields the testrail package does
+	// not cover.
+	send(method, uri string, data, v interface{}) error
+	request(method, uri string, data interface{}) (http.Header, []byte, error)
+}
+
+// newClient builds a client from the environment or the config file,
+// returning a config error if the credentials are missing. It goes through
+// the API cache when --cache-ttl is set.
+func newClient() (testrailAPI, error) {
+	c, err := clientFromEnv()
+	if err != nil {
+		return nil, exitError{code: exitConfig, err: err}
+	}
+	return withCache(c, apiCache), nil
+}
+
+// clientFromEnv builds a client for the instance at TESTRAIL_URL, defaulting
+// to the Docker instance, using the TESTRAIL_USERNAME credentials and the
+// token found by resolveToken. Settings missing from the environment are
+// read from the config file.
+func clientFromEnv() (*client, error) {
+	cfg, err := config.Load(config.File())
+	if err != nil {
+		return nil, fmt.Errorf("Error reading config file: %s", err)
+	}
+
+	url := envOr("TESTRAIL_URL", cfg.URL)
+	username := envOr("TESTRAIL_USERNAME", cfg.Username)
+	token, err := resolveToken(url, username, cfg.Token)
+	if err != nil {
+		return nil, err
+	}
+
+	return newClientFor(url, username, token)
+}
+
+// instanceFromEnv returns the URL of the instance clientFromEnv connects
+// to, for links to its pages.
+func instanceFromEnv() string {
+	cfg, _ := config.Load(config.File())
+	return instanceURL(envOr("TESTRAIL_URL", cfg.URL))
+}
+
+// envOr returns the value of the environment variable key, or def if it is
+// not set.
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+// newClientFor builds a client for the instance at baseURL, defaulting to the
+// Docker instance.
+func newClientFor(baseURL, username, token string) (*client, error) {
+	baseURL = instanceURL(baseURL)
+
+	if (username == "" || token == "") && !replaying {
+		return nil, fmt.Errorf("Need to set TESTRAIL_USERNAME and TESTRAIL_TOKEN or run trailer init or trailer login")
+	}
+
+	return &client{
+		Client:     testrail.NewClient(baseURL, username, token),
+		baseURL:    baseURL,
+		url:        baseURL + "/index.php?/api/v2/",
+		username:   username,
+		token:      token,
+		httpClient: &http.Client{},
+	}, nil
+}
+
+// send performs an authenticated request against uri, encoding data as the
+// JSON body when it is non-nil and decoding the response into v when v is
+// non-nil.
+func (c *client) send(method, uri string, data, v interface{}) error {
+	_, content, err := c.request(method, uri, data)
+	if err != nil {
+		return err
+	}
+
+	if v != nil && len(content) > 0 {
+		if err := json.Unmarshal(content, v); err != nil {
+			return fmt.Errorf("unmarshaling response: %s", err)
+		}
+	}
+
+	return nil
+}
+
+// request performs an authenticated request against uri and returns the
+// response headers and body. Responses with an error status are returned as
+// errors.
+func (c *client) request(method, uri string, data interface{}) (http.Header, []byte, error) {
+	var body io.Reader
+	if data != nil {
+		payload, err := json.Marshal(data)
+		if err != nil {
+			return nil, nil, fmt.Errorf("marshaling data: %s", err)
+		}
+		body = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequest(method, c.url+uri, body)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.SetBasicAuth(c.username, c.token)
+	req.Header.Add("Accept", "application/json")
+	req.Header.Add("Content-Type", "application/json")
+
+	start := time.Now()
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	slog.Debug("TestRail API request", "method", method, "uri", uri, "status", resp.StatusCode, "duration", time.Since(start))
+
+	content, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return resp.Header, nil, fmt.Errorf("reading: %s", err)
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return resp.Header, content, fmt.Errorf("response: status: %q, body: %s", resp.Status, content)
+	}
+
+	return resp.Header, content, nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/educlos/testrail"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeAPI serves sections from memory. Calls to other methods panic on the
+// nil embedded interface.
+type fakeAPI struct {
+	testrailAPI
+	sections []testrail.Section
+}
+
+func (f *fakeAPI) GetSections(projectID int, suiteID ...int) ([]testrail.Section, error) {
+	return f.sections, nil
+}
+
+func (f *fakeAPI) AddSection(projectID int, s testrail.SendableSection) (testrail.Section, error) {
+	section := testrail.Section{ID: 100 + len(f.sections), Name: s.Name, ParentID: s.ParentID, SuiteID: s.SuiteID}
+	f.sections = append(f.sections, section)
+	return section, nil
+}
+
+func TestSectionTreeResolve(t *testing.T) {
+	api := &fakeAPI{sections: []testrail.Section{
+		{ID: 1, Name: "Accounts"},
+		{ID: 2, Name: "Login", ParentID: 1},
+	}}
+
+	tree, err := newSectionTree(api, 3, 33, false)
+	assert.NoError(t, err)
+
+	id, err := tree.resolve([]string{"Accounts", "Login"})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, id)
+
+	id, err = tree.resolve([]string{"Accounts", "Logout", "Timeout"})
+	assert.NoError(t, err)
+	assert.Len(t, api.sections, 4)
+	assert.Equal(t, testrail.Section{ID: 102, Name: "Logout", ParentID: 1, SuiteID: 33}, api.sections[2])
+	assert.Equal(t, testrail.Section{ID: 103, Name: "Timeout", ParentID: 102, SuiteID: 33}, api.sections[3])
+	assert.Equal(t, 103, id)
+
+	dry, err := newSectionTree(api, 3, 33, true)
+	assert.NoError(t, err)
+	id, err = dry.resolve([]string{"Billing"})
+	assert.NoError(t, err)
+	assert.Equal(t, -1, id)
+	assert.Len(t, api.sections, 4)
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/educlos/testrail"
+	"github.com/urfave/cli"
+)
+
+var noColorFlag = cli.BoolFlag{
+	Name:  "no-color",
+	Usage: "do not color the output, which is only colored when stdout is a terminal and NO_COLOR is not set",
+}
+
+// colorOutput is set when the output is colored.
+var colorOutput bool
+
+// setupColor turns colored output on for terminals, unless it is disabled
+// by --no-color or the NO_COLOR environment variable.
+func setupColor(disabled bool) {
+	colorOutput = !disabled && os.Getenv("NO_COLOR") == "" && isTerminal(os.Stdout)
+}
+
+// isTerminal reports whether f is a terminal rather than a file or pipe.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// ANSI colors of the output.
+const (
+	colorRed    = "31"
+	colorGreen  = "32"
+	colorYellow = "33"
+)
+
+// colored wraps s in the ANSI escape codes of color when the output is
+// colored.
+func colored(color, s string) string {
+	if !colorOutput {
+		return s
+	}
+	return "\x1b[" + color + "m" + s + "\x1b[0m"
+}
+
+// builtinStatuses names the statuses every TestRail instance has, with the
+// colors they are printed in.
+var builtinStatuses = map[int]struct{ name, color string }{
+	1: {"passed", colorGreen},
+	2: {"blocked", colorYellow},
+	3: {"untested", ""},
+	4: {"retest", colorYellow},
+	5: {"failed", colorRed},
+}
+
+// statusText names a status, in its color.
+func statusText(id int) string {
+	s, ok := builtinStatuses[id]
+	if !ok {
+		return "status " + strconv.Itoa(id)
+	}
+	if s.color == "" {
+		return s.name
+	}
+	return colored(s.color, s.name)
+}
+
+// printResults prints the results TestRail recorded in the global --output
+// format. The status comes last so its colors do not throw the columns of a
+// table out of line.
+func printResults(results []testrail.Result) error {
+	if len(results) == 0 {
+		return nil
+	}
+	rows := [][]string{}
+	for _, r := range results {
+		elapsed := ""
+		if r.Elapsed.Duration > 0 {
+			elapsed = r.Elapsed.String()
+		}
+		rows = append(rows, []string{strconv.Itoa(r.TestID), elapsed, statusText(r.StatusID)})
+	}
+	return render("", results, []string{"TEST", "ELAPSED", "STATUS"}, rows)
+}
+
+// countsLine summarizes the outcomes of an upload for the terminal, coloring
+// the counts that are not zero.
+func countsLine(s uploadSummary) string {
+	count := func(n int, label, color string) string {
+		text := fmt.Sprintf("%d %s", n, label)
+		if n == 0 {
+			return text
+		}
+		return colored(color, text)
+	}
+	line := fmt.Sprintf("%s, %s, %d skipped", count(s.Passed, "passed", colorGreen), count(s.Failed, "failed", colorRed), s.Skipped)
+	if s.NotUploaded > 0 {
+		line += ", " + count(s.NotUploaded, "not uploaded", colorYellow)
+	}
+	return line
+}
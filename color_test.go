@@ -0,0 +1,25 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestColored(t *testing.T) {
+	defer func(on bool) { colorOutput = on }(colorOutput)
+
+	summary := uploadSummary{Passed: 3, Failed: 1, NotUploaded: 2}
+
+	colorOutput = false
+	assert.Equal(t, "failed", statusText(5))
+	assert.Equal(t, "status 7", statusText(7))
+	assert.Equal(t, "3 passed, 1 failed, 0 skipped, 2 not uploaded", countsLine(summary))
+	assert.Equal(t, "0 passed, 0 failed, 0 skipped", countsLine(uploadSummary{}))
+
+	colorOutput = true
+	assert.Equal(t, "\x1b[31mfailed\x1b[0m", statusText(5))
+	assert.Equal(t, "untested", statusText(3))
+	assert.Equal(t, "\x1b[32m3 passed\x1b[0m, \x1b[31m1 failed\x1b[0m, 0 skipped, \x1b[33m2 not uploaded\x1b[0m", countsLine(summary))
+	assert.Equal(t, "0 passed, 0 failed, 0 skipped", countsLine(uploadSummary{}))
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/educlos/testrail"
+	"github.com/urfave/cli"
+
+	"github.com/docker/trailer/spec"
+)
+
+// Changes between the runs compared.
+const (
+	newlyFailing  = "newly failing"
+	newlyPassing  = "newly passing"
+	stillFailing  = "still failing"
+	missingResult = "missing result"
+)
+
+// changeOrder sorts the changes in the order they matter for a release.
+var changeOrder = map[string]int{newlyFailing: 0, stillFailing: 1, missingResult: 2, newlyPassing: 3}
+
+// caseChange is how the result of a case changed from the base to the head
+// run.
+type caseChange struct {
+	ID     int    `json:"id"`
+	Title  string `json:"title"`
+	Change string `json:"change"`
+	// Base and Head are the statuses of the case in the runs, empty if the
+	// case is not in the run.
+	Base string `json:"base"`
+	Head string `json:"head"`
+}
+
+// runComparison counts the changes between two runs and lists them.
+type runComparison struct {
+	Base    int            `json:"base"`
+	Head    int            `json:"head"`
+	Counts  map[string]int `json:"counts"`
+	Changes []caseChange   `json:"changes"`
+}
+
+// compareRuns lists the cases that fail in head, that pass in head after
+// failing in base, and that have a result in base but not in head. Other
+// statuses than passed and untested count as failing.
+func compareRuns(baseID, headID int, base, head []testrail.Test, statuses map[int]string) runComparison {
+	passed := spec.DefaultStatusMap.ID(spec.Passed)
+	untested := untestedStatus
+
+	name := func(t testrail.Test, ok bool) string {
+		if !ok {
+			return ""
+		}
+		if n, ok := statuses[t.StatusID]; ok {
+			return n
+		}
+		return fmt.Sprint(t.StatusID)
+	}
+	failing := func(t testrail.Test, ok bool) bool {
+		return ok && t.StatusID != passed && t.StatusID != untested
+	}
+
+	before := map[int]testrail.Test{}
+	for _, t := range base {
+		before[t.CaseID] = t
+	}
+	after := map[int]testrail.Test{}
+	for _, t := range head {
+		after[t.CaseID] = t
+	}
+
+	r := runComparison{Base: baseID, Head: headID, Counts: map[string]int{}, Changes: []caseChange{}}
+	add := func(id int, title, change string, b testrail.Test, bok bool, h testrail.Test, hok bool) {
+		r.Counts[change]++
+		r.Changes = append(r.Changes, caseChange{ID: id, Title: title, Change: change, Base: name(b, bok), Head: name(h, hok)})
+	}
+	for id, h := range after {
+		b, bok := before[id]
+		switch {
+		case failing(h, true) && failing(b, bok):
+			add(id, h.Title, stillFailing, b, bok, h, true)
+		case failing(h, true):
+			add(id, h.Title, newlyFailing, b, bok, h, true)
+		case h.StatusID == passed && failing(b, bok):
+			add(id, h.Title, newlyPassing, b, bok, h, true)
+		case h.StatusID == untested && bok && b.StatusID != untested:
+			add(id, h.Title, missingResult, b, bok, h, true)
+		}
+	}
+	for id, b := range before {
+		if _, ok := after[id]; !ok && b.StatusID != untested {
+			add(id, b.Title, missingResult, b, true, testrail.Test{}, false)
+		}
+	}
+
+	sort.Slice(r.Changes, func(i, j int) bool {
+		ci, cj := r.Changes[i], r.Changes[j]
+		if ci.Change != cj.Change {
+			return changeOrder[ci.Change] < changeOrder[cj.Change]
+		}
+		return ci.ID < cj.ID
+	})
+	return r
+}
+
+func compareCommand() cli.Command {
+	return cli.Command{
+		Name:      "compare",
+		Usage:     "Compare the results of two runs",
+		ArgsUsage: "--run-id BASE --run-id HEAD",
+		Flags: append([]cli.Flag{
+			cli.IntSliceFlag{
+				Name:  "run-id, r",
+				Usage: "TestRail run ID, set twice: first the base run, then the run to compare with it",
+			},
+			outputFlag,
+		}, emailFlags...),
+		Action: func(c *cli.Context) error {
+			ids := c.IntSlice("run-id")
+			if len(ids) != 2 {
+				return configErrorf("Must set --run-id twice, to the base run and the run to compare with it")
+			}
+
+			client, err := newClient()
+			if err != nil {
+				return err
+			}
+
+			statuses := map[int]string{}
+			list, err := client.GetStatuses()
+			if err != nil {
+				return apiErrorf("Error getting statuses: %s", err)
+			}
+			for _, s := range list {
+				statuses[s.ID] = s.Name
+			}
+
+			var runs [2][]testrail.Test
+			for i, id := range ids {
+				if runs[i], err = freshTests(client, id); err != nil {
+					return apiErrorf("Error getting tests of run %d: %s", id, err)
+				}
+			}
+
+			r := compareRuns(ids[0], ids[1], runs[0], runs[1], statuses)
+			rows := [][]string{}
+			for _, ch := range r.Changes {
+				rows = append(rows, []string{fmt.Sprintf("C%d", ch.ID), ch.Title, ch.Change, ch.Base, ch.Head})
+			}
+			header := []string{"CASE", "TITLE", "CHANGE", fmt.Sprintf("R%d", ids[0]), fmt.Sprintf("R%d", ids[1])}
+			if err := render(c.String("output"), r, header, rows); err != nil {
+				return fmt.Errorf("Error printing comparison: %s", err)
+			}
+
+			summaryf(c.String("output"), "\n%d newly failing, %d still failing, %d missing results, %d newly passing\n",
+				r.Counts[newlyFailing], r.Counts[stillFailing], r.Counts[missingResult], r.Counts[newlyPassing])
+			if err := sendEmail(c, compareEmail, r); err != nil {
+				return fmt.Errorf("Error sending email: %s", err)
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/educlos/testrail"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompareRuns(t *testing.T) {
+	statuses := map[int]string{1: "passed", 3: "untested", 5: "failed"}
+	base := []testrail.Test{
+		{CaseID: 1, Title: "Stable", StatusID: 1},
+		{CaseID: 2, Title: "Regressed", StatusID: 1},
+		{CaseID: 3, Title: "Fixed", StatusID: 5},
+		{CaseID: 4, Title: "Broken", StatusID: 5},
+		{CaseID: 5, Title: "Skipped", StatusID: 1},
+		{CaseID: 6, Title: "Removed", StatusID: 5},
+		{CaseID: 7, Title: "Never run", StatusID: 3},
+	}
+	head := []testrail.Test{
+		{CaseID: 1, Title: "Stable", StatusID: 1},
+		{CaseID: 2, Title: "Regressed", StatusID: 5},
+		{CaseID: 3, Title: "Fixed", StatusID: 1},
+		{CaseID: 4, Title: "Broken", StatusID: 5},
+		{CaseID: 5, Title: "Skipped", StatusID: 3},
+		{CaseID: 8, Title: "Added", StatusID: 5},
+	}
+
+	r := compareRuns(10, 20, base, head, statuses)
+	assert.Equal(t, []caseChange{
+		{ID: 2, Title: "Regressed", Change: newlyFailing, Base: "passed", Head: "failed"},
+		{ID: 8, Title: "Added", Change: newlyFailing, Head: "failed"},
+		{ID: 4, Title: "Broken", Change: stillFailing, Base: "failed", Head: "failed"},
+		{ID: 5, Title: "Skipped", Change: missingResult, Base: "passed", Head: "untested"},
+		{ID: 6, Title: "Removed", Change: missingResult, Base: "failed"},
+		{ID: 3, Title: "Fixed", Change: newlyPassing, Base: "failed", Head: "passed"},
+	}, r.Changes)
+	assert.Equal(t, map[string]int{newlyFailing: 2, stillFailing: 1, missingResult: 2, newlyPassing: 1}, r.Counts)
+}
+
+func TestCompareCommand(t *testing.T) {
+	s, stop := startFake(t)
+	defer stop()
+
+	base := strconv.Itoa(s.AddRun(1, 2, 11, 12).ID)
+	head := strconv.Itoa(s.AddRun(1, 2, 11, 12).ID)
+
+	assert.NoError(t, run("compare", "--run-id", base, "--run-id", head, "--output", "markdown"))
+	assert.Equal(t, exitConfig, exitCode(run("compare", "--run-id", base)))
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/urfave/cli"
+)
+
+var timeoutFlag = cli.DurationFlag{
+	Name:   "timeout",
+	Usage:  "give up on the whole operation after this long, 0 to wait forever",
+	EnvVar: "TRAILER_TIMEOUT",
+}
+
+// contextKey is the app metadata key of the context shared by the commands.
+const contextKey = "context"
+
+// setupContext returns a context that is cancelled on SIGINT or SIGTERM, or
+// once timeout has passed when it is non-zero. Requests made by both TestRail
+// clients are cancelled along with it, since the testrail package does not
+// take contexts. The returned function releases the context and restores the
+// network transport.
+func setupContext(timeout time.Duration) (context.Context, func()) {
+	ctx, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	cancel := func() {}
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	}
+
+	transport := http.DefaultTransport
+	http.DefaultTransport = contextTransport{ctx: ctx, next: transport}
+
+	return ctx, func() {
+		http.DefaultTransport = transport
+		cancel()
+		stopSignals()
+	}
+}
+
+// commandContext returns the context of the running command.
+func commandContext(c *cli.Context) context.Context {
+	if ctx, ok := c.App.Metadata[contextKey].(context.Context); ok {
+		return ctx
+	}
+	return context.Background()
+}
+
+// contextTransport cancels requests along with ctx.
+type contextTransport struct {
+	ctx  context.Context
+	next http.RoundTripper
+}
+
+func (t contextTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Context() == context.Background() {
+		req = req.WithContext(t.ctx)
+	}
+	return t.next.RoundTrip(req)
+}
+
+// interruptedError returns an error setting the interrupted exit code when
+// ctx is done, and nil otherwise.
+func interruptedError(ctx context.Context) error {
+	switch err := ctx.Err(); {
+	case errors.Is(err, context.DeadlineExceeded):
+		return exitError{code: exitInterrupted, err: fmt.Errorf("Timed out")}
+	case err != nil:
+		return exitError{code: exitInterrupted, err: fmt.Errorf("Interrupted")}
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUploadTimeoutCheckpoint(t *testing.T) {
+	dir, err := ioutil.TempDir("", "timeout")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// TestRail hangs until the test is done.
+	done := make(chan struct{})
+	hung := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer hung.Close()
+	defer close(done)
+
+	_, stop := startFake(t)
+	defer stop()
+	os.Setenv("TESTRAIL_URL", hung.URL)
+
+	report := writeReport(t, dir, `<testsuite name="accounts" tests="1">
+  <testcase name="TestRailC11 login" time="1"></testcase>
+</testsuite>`)
+	spool := filepath.Join(dir, "spool")
+
+	err = run("--timeout", "100ms", "upload", "--run-id", "5", "--spool", spool, report)
+	assert.Equal(t, exitInterrupted, exitCode(err))
+	assert.Equal(t, "Timed out", err.Error())
+
+	q, err := openQueue(spool)
+	assert.NoError(t, err)
+	names, err := q.list(queuePending)
+	assert.NoError(t, err)
+	assert.Len(t, names, 1)
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/urfave/cli"
+
+	"github.com/docker/trailer/pkg/download"
+	"github.com/docker/trailer/spec"
+)
+
+// caseCoverage is whether the reports have a result for a case.
+type caseCoverage struct {
+	ID    int    `json:"id"`
+	Title string `json:"title"`
+	// Automated is set for cases the cases file marks as automated.
+	Automated bool `json:"automated"`
+	Covered   bool `json:"covered"`
+	// Result is the outcome in the reports of covered cases.
+	Result string `json:"result,omitempty"`
+}
+
+// coverageReport is the automation coverage of the cases of a cases file.
+type coverageReport struct {
+	Total   int            `json:"total"`
+	Covered int            `json:"covered"`
+	Percent float64        `json:"percent"`
+	Cases   []caseCoverage `json:"cases"`
+	// Unknown lists the cases the reports have results for that are not in
+	// the cases file.
+	Unknown []int `json:"unknown"`
+}
+
+var outcomeNames = map[spec.TestStatus]string{
+	spec.Passed:  "passed",
+	spec.Failed:  "failed",
+	spec.Skipped: "skipped",
+}
+
+// coverage compares the cases of the suite with the results of the reports.
+func coverage(s download.Suite, results map[int]spec.Update) coverageReport {
+	r := coverageReport{Cases: []caseCoverage{}, Unknown: []int{}}
+	for id, e := range s.Cases {
+		c := caseCoverage{ID: id, Title: e.Title, Automated: e.Automated}
+		if u, ok := results[id]; ok {
+			c.Covered = true
+			c.Result = outcomeNames[u.Status]
+			r.Covered++
+		}
+		r.Cases = append(r.Cases, c)
+	}
+	for id := range results {
+		if _, ok := s.Cases[id]; !ok {
+			r.Unknown = append(r.Unknown, id)
+		}
+	}
+
+	r.Total = len(r.Cases)
+	if r.Total > 0 {
+		r.Percent = 100 * float64(r.Covered) / float64(r.Total)
+	}
+	sort.Slice(r.Cases, func(i, j int) bool { return r.Cases[i].ID < r.Cases[j].ID })
+	sort.Ints(r.Unknown)
+	return r
+}
+
+func coverageCommand() cli.Command {
+	return cli.Command{
+		Name:      "coverage",
+		Usage:     "Report which cases of a cases file have results in reports",
+		ArgsUsage: "[report files...]",
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "file, f",
+				Usage: "cases file with the cases to cover",
+			},
+			cli.StringSliceFlag{
+				Name:  "reports",
+				Usage: "report file to read results from, in addition to the arguments",
+			},
+			formatFlag,
+			outputFlag,
+		},
+		Action: func(c *cli.Context) error {
+			if c.String("file") == "" {
+				return configErrorf("Must specify an input cases file")
+			}
+			reports := append(c.StringSlice("reports"), c.Args()...)
+			if len(reports) == 0 {
+				return configErrorf("Must specify the report files")
+			}
+
+			s, err := download.Load(c.String("file"))
+			if err != nil {
+				return parseErrorf("Error reading cases file: %s", err)
+			}
+			updates, err := parseReports(commandContext(c), reports, c.String("format"), "", spec.StatusMap{}, nil)
+			if err != nil {
+				return err
+			}
+
+			r := coverage(s, updates.ResultMap)
+			rows := [][]string{}
+			for _, cc := range r.Cases {
+				covered := "no"
+				if cc.Covered {
+					covered = "yes"
+				}
+				automated := ""
+				if cc.Automated {
+					automated = "yes"
+				}
+				rows = append(rows, []string{fmt.Sprintf("C%d", cc.ID), cc.Title, automated, covered, cc.Result})
+			}
+			if err := render(c.String("output"), r, []string{"CASE", "TITLE", "AUTOMATED", "COVERED", "RESULT"}, rows); err != nil {
+				return fmt.Errorf("Error printing coverage: %s", err)
+			}
+
+			summaryf(c.String("output"), "\n%d of %d cases covered (%.1f%%)\n", r.Covered, r.Total, r.Percent)
+			if len(r.Unknown) > 0 {
+				ids := []string{}
+				for _, id := range r.Unknown {
+					ids = append(ids, "C"+strconv.Itoa(id))
+				}
+				summaryf(c.String("output"), "%d results for cases not in the cases file: %v\n", len(r.Unknown), ids)
+			}
+			return nil
+		},
+	}
+}
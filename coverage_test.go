@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/csv"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/docker/trailer/pkg/download"
+	"github.com/docker/trailer/spec"
+)
+
+func TestCoverage(t *testing.T) {
+	s := download.New(1, 2)
+	s.Cases[11] = download.Entry{Title: "Login", Automated: true}
+	s.Cases[12] = download.Entry{Title: "Logout", Automated: true}
+	s.Cases[13] = download.Entry{Title: "Refund"}
+	s.Cases[14] = download.Entry{Title: "Checkout"}
+
+	r := coverage(s, map[int]spec.Update{
+		11: {Status: spec.Passed},
+		13: {Status: spec.Failed},
+		99: {Status: spec.Passed},
+	})
+	assert.Equal(t, 4, r.Total)
+	assert.Equal(t, 2, r.Covered)
+	assert.Equal(t, 50.0, r.Percent)
+	assert.Equal(t, []caseCoverage{
+		{ID: 11, Title: "Login", Automated: true, Covered: true, Result: "passed"},
+		{ID: 12, Title: "Logout", Automated: true},
+		{ID: 13, Title: "Refund", Covered: true, Result: "failed"},
+		{ID: 14, Title: "Checkout"},
+	}, r.Cases)
+	assert.Equal(t, []int{99}, r.Unknown)
+}
+
+func TestCoverageCommand(t *testing.T) {
+	dir, err := ioutil.TempDir("", "coverage")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "cases.yml")
+	assert.NoError(t, download.Save(file, download.New(1, 2)))
+	report := writeReport(t, dir, `<testsuite name="accounts" tests="1"><testcase name="TestRailC11 login"></testcase></testsuite>`)
+
+	for _, output := range []string{"table", "json", "markdown"} {
+		assert.NoError(t, run("coverage", "--file", file, "--output", output, report))
+	}
+	// The summary does not end up in the CSV.
+	out := captureStdout(t, func() {
+		assert.NoError(t, run("coverage", "--file", file, "--output", "csv", report))
+	})
+	records, err := csv.NewReader(strings.NewReader(out)).ReadAll()
+	assert.NoError(t, err, out)
+	assert.Equal(t, [][]string{{"CASE", "TITLE", "AUTOMATED", "COVERED", "RESULT"}}, records)
+	assert.Equal(t, exitConfig, exitCode(run("coverage", "--file", file)))
+	assert.Equal(t, exitParse, exitCode(run("coverage", "--file", file, filepath.Join(dir, "missing.xml"))))
+}
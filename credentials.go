@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/urfave/cli"
+
+	"github.com/docker/trailer/pkg/config"
+	"github.com/docker/trailer/pkg/keyring"
+	"github.com/docker/trailer/pkg/secrets"
+)
+
+var tokenStdinFlag = cli.BoolFlag{
+	Name:  "token-stdin",
+	Usage: "read the API token from the first line of stdin",
+}
+
+// stdinToken is the token read by --token-stdin.
+var stdinToken string
+
+// readStdinToken reads the token given to --token-stdin.
+func readStdinToken(r io.Reader) error {
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return configErrorf("Error reading the API token from stdin: %s", err)
+	}
+	if stdinToken = strings.TrimSpace(line); stdinToken == "" {
+		return configErrorf("No API token on stdin")
+	}
+	return nil
+}
+
+// instanceURL returns the base URL of the TestRail instance at url,
+// defaulting to the Docker instance.
+func instanceURL(url string) string {
+	if url == "" {
+		url = defaultTestrailURL
+	}
+	return strings.TrimSuffix(url, "/")
+}
+
+// keyringService is the keyring service the tokens for the instance at url
+// are stored under, one per username.
+func keyringService(url string) string {
+	return "trailer " + instanceURL(url)
+}
+
+// resolveToken returns the API token of username on the instance at url. It
+// is read from, in order, TESTRAIL_TOKEN, the file named by
+// TESTRAIL_TOKEN_FILE, --token-stdin, the config file and the keyring. The
+// config file may hold a reference to a secrets manager instead of the token.
+func resolveToken(url, username, configToken string) (string, error) {
+	if token := os.Getenv("TESTRAIL_TOKEN"); token != "" {
+		return token, nil
+	}
+
+	if file := os.Getenv("TESTRAIL_TOKEN_FILE"); file != "" {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return "", fmt.Errorf("Error reading TESTRAIL_TOKEN_FILE: %s", err)
+		}
+		return strings.TrimSpace(string(data)), nil
+	}
+
+	if stdinToken != "" {
+		return stdinToken, nil
+	}
+
+	if configToken != "" {
+		token, err := secrets.Resolve(configToken)
+		if err != nil {
+			return "", fmt.Errorf("Error reading the API token from %s: %s", configToken, err)
+		}
+		return token, nil
+	}
+
+	if username == "" {
+		return "", nil
+	}
+	token, err := keyring.Get(keyringService(url), username)
+	if err != nil {
+		if err != keyring.ErrNotFound && err != keyring.ErrUnsupported {
+			slog.Warn("Error reading the API token from the keyring", "error", err)
+		}
+		return "", nil
+	}
+	return token, nil
+}
+
+func loginCommand() cli.Command {
+	return cli.Command{
+		Name:  "login",
+		Usage: "Check an API token and store it in the keyring of the system",
+		Action: func(c *cli.Context) error {
+			file := config.File()
+			cfg, err := config.Load(file)
+			if err != nil {
+				return configErrorf("Error reading config file: %s", err)
+			}
+
+			p := prompter{r: bufio.NewReader(os.Stdin), w: os.Stdout}
+			if cfg.URL, err = p.ask("TestRail URL", instanceURL(envOr("TESTRAIL_URL", cfg.URL))); err != nil {
+				return err
+			}
+			if cfg.Username, err = p.ask("Username", envOr("TESTRAIL_USERNAME", cfg.Username)); err != nil {
+				return err
+			}
+			token := stdinToken
+			if token == "" {
+				if token, err = p.ask("API token", ""); err != nil {
+					return err
+				}
+			}
+
+			client, err := newClientFor(cfg.URL, cfg.Username, token)
+			if err != nil {
+				return configErrorf("%s", err)
+			}
+			if _, err := client.GetProjects(); err != nil {
+				return apiErrorf("Error checking the API token: %s", err)
+			}
+
+			err = keyring.Set(keyringService(cfg.URL), cfg.Username, token)
+			if err == keyring.ErrUnsupported {
+				return configErrorf("%s, use TESTRAIL_TOKEN_FILE or --token-stdin instead", err)
+			}
+			if err != nil {
+				return fmt.Errorf("Error storing the API token: %s", err)
+			}
+
+			// The keyring replaces the plaintext token of the config file.
+			cfg.Token = ""
+			if err := config.Save(file, cfg); err != nil {
+				return fmt.Errorf("Error writing config file: %s", err)
+			}
+			statusf("Stored the API token of %s in the keyring\n", cfg.Username)
+
+			return nil
+		},
+	}
+}
+
+func logoutCommand() cli.Command {
+	return cli.Command{
+		Name:  "logout",
+		Usage: "Remove the API token stored by login",
+		Action: func(c *cli.Context) error {
+			cfg, err := config.Load(config.File())
+			if err != nil {
+				return configErrorf("Error reading config file: %s", err)
+			}
+
+			url := envOr("TESTRAIL_URL", cfg.URL)
+			username := envOr("TESTRAIL_USERNAME", cfg.Username)
+			if username == "" {
+				return configErrorf("Need to set TESTRAIL_USERNAME or run trailer login")
+			}
+
+			err = keyring.Delete(keyringService(url), username)
+			if err == keyring.ErrNotFound {
+				statusf("No API token stored for %s\n", username)
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("Error removing the API token: %s", err)
+			}
+			statusf("Removed the API token of %s\n", username)
+
+			return nil
+		},
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveToken(t *testing.T) {
+	dir, err := ioutil.TempDir("", "token")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "token")
+	assert.NoError(t, ioutil.WriteFile(file, []byte("from-file\n"), 0600))
+
+	defer os.Unsetenv("TESTRAIL_TOKEN")
+	defer os.Unsetenv("TESTRAIL_TOKEN_FILE")
+	defer func() { stdinToken = "" }()
+
+	// A user without a keyring entry, so the lookup finds nothing.
+	token, err := resolveToken("", "", "from-config")
+	assert.NoError(t, err)
+	assert.Equal(t, "from-config", token)
+
+	assert.NoError(t, readStdinToken(strings.NewReader("from-stdin\nrest")))
+	token, err = resolveToken("", "", "from-config")
+	assert.NoError(t, err)
+	assert.Equal(t, "from-stdin", token)
+
+	os.Setenv("TESTRAIL_TOKEN_FILE", file)
+	token, err = resolveToken("", "", "from-config")
+	assert.NoError(t, err)
+	assert.Equal(t, "from-file", token)
+
+	os.Setenv("TESTRAIL_TOKEN", "from-env")
+	token, err = resolveToken("", "", "from-config")
+	assert.NoError(t, err)
+	assert.Equal(t, "from-env", token)
+
+	os.Unsetenv("TESTRAIL_TOKEN")
+	os.Setenv("TESTRAIL_TOKEN_FILE", filepath.Join(dir, "missing"))
+	_, err = resolveToken("", "", "from-config")
+	assert.Error(t, err)
+
+	assert.Error(t, readStdinToken(strings.NewReader("\n")))
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/educlos/testrail"
+	"github.com/urfave/cli"
+
+	"github.com/docker/trailer/pkg/download"
+)
+
+// caseRename is a case whose title differs between the cases file and
+// TestRail.
+type caseRename struct {
+	ID     int    `json:"id"`
+	Local  string `json:"local"`
+	Remote string `json:"remote"`
+}
+
+// suiteDiff lists the differences between a cases file and TestRail.
+// Added cases exist only in TestRail, removed cases only in the file.
+type suiteDiff struct {
+	Added   map[int]string `json:"added"`
+	Removed map[int]string `json:"removed"`
+	Renamed []caseRename   `json:"renamed"`
+}
+
+func (d suiteDiff) empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Renamed) == 0
+}
+
+// diffSuite compares the cases of a cases file with the remote cases.
+func diffSuite(local map[int]string, remote []testrail.Case) suiteDiff {
+	d := suiteDiff{
+		Added:   map[int]string{},
+		Removed: map[int]string{},
+		Renamed: []caseRename{},
+	}
+
+	seen := map[int]struct{}{}
+	for _, c := range remote {
+		seen[c.ID] = struct{}{}
+		title, ok := local[c.ID]
+		if !ok {
+			d.Added[c.ID] = c.Title
+		} else if title != c.Title {
+			d.Renamed = append(d.Renamed, caseRename{ID: c.ID, Local: title, Remote: c.Title})
+		}
+	}
+
+	for id, title := range local {
+		if _, ok := seen[id]; !ok {
+			d.Removed[id] = title
+		}
+	}
+
+	sort.Slice(d.Renamed, func(i, j int) bool { return d.Renamed[i].ID < d.Renamed[j].ID })
+	return d
+}
+
+// sortedIDs returns the keys of cases in ascending order.
+func sortedIDs(cases map[int]string) []int {
+	ids := []int{}
+	for id := range cases {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+func diffCommand() cli.Command {
+	return cli.Command{
+		Name:  "diff",
+		Usage: "Compare a cases file with the current cases in TestRail",
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "file, f",
+				Usage: "cases file to compare",
+			},
+			cli.IntFlag{
+				Name:  "project-id, p",
+				Usage: "TestRail project ID, defaults to the one in the cases file",
+			},
+			cli.IntFlag{
+				Name:  "suite-id, s",
+				Usage: "TestRail suite ID, defaults to the one in the cases file",
+			},
+			outputFlag,
+		},
+		Action: func(c *cli.Context) error {
+			if c.String("file") == "" {
+				return configErrorf("Must specify an input cases file")
+			}
+
+			s, err := download.Load(c.String("file"))
+			if err != nil {
+				return parseErrorf("Error reading cases file: %s", err)
+			}
+			if c.Int("project-id") != 0 {
+				s.ProjectID = c.Int("project-id")
+			}
+			if c.Int("suite-id") != 0 {
+				s.SuiteID = c.Int("suite-id")
+			}
+			if s.ProjectID == 0 || s.SuiteID == 0 {
+				return configErrorf("Cases file has no project_id and suite_id, set --project-id and --suite-id")
+			}
+
+			client, err := newClient()
+			if err != nil {
+				return err
+			}
+			remote, err := client.GetCases(s.ProjectID, s.SuiteID)
+			if err != nil {
+				return apiErrorf("Error getting cases: %s", err)
+			}
+
+			d := diffSuite(s.Titles(), remote)
+
+			rows := [][]string{}
+			for _, id := range sortedIDs(d.Added) {
+				rows = append(rows, []string{"added", fmt.Sprintf("C%d", id), d.Added[id]})
+			}
+			for _, id := range sortedIDs(d.Removed) {
+				rows = append(rows, []string{"removed", fmt.Sprintf("C%d", id), d.Removed[id]})
+			}
+			for _, r := range d.Renamed {
+				rows = append(rows, []string{"renamed", fmt.Sprintf("C%d", r.ID), fmt.Sprintf("%s -> %s", r.Local, r.Remote)})
+			}
+
+			if err := render(c.String("output"), d, []string{"CHANGE", "CASE", "TITLE"}, rows); err != nil {
+				return fmt.Errorf("Error printing diff: %s", err)
+			}
+
+			if !d.empty() {
+				return fmt.Errorf("Cases file has drifted: %d added, %d removed, %d renamed", len(d.Added), len(d.Removed), len(d.Renamed))
+			}
+
+			return nil
+		},
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/educlos/testrail"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffSuite(t *testing.T) {
+	local := map[int]string{
+		1: "Login works",
+		2: "Logout works",
+		3: "Old case",
+	}
+	remote := []testrail.Case{
+		{ID: 1, Title: "Login works"},
+		{ID: 2, Title: "Logout clears the session"},
+		{ID: 4, Title: "New case"},
+	}
+
+	d := diffSuite(local, remote)
+
+	assert.Equal(t, map[int]string{4: "New case"}, d.Added)
+	assert.Equal(t, map[int]string{3: "Old case"}, d.Removed)
+	assert.Equal(t, []caseRename{{ID: 2, Local: "Logout works", Remote: "Logout clears the session"}}, d.Renamed)
+	assert.False(t, d.empty())
+
+	assert.True(t, diffSuite(map[int]string{1: "Login works"}, remote[:1]).empty())
+}
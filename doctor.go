@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli"
+)
+
+// doctorCheck is the outcome of one diagnostic performed by doctor.
+type doctorCheck struct {
+	Name   string `json:"name"`
+	OK     bool   `json:"ok"`
+	Detail string `json:"detail"`
+	Advice string `json:"advice,omitempty"`
+}
+
+// rateLimitHeaders are the headers TestRail and the proxies commonly put in
+// front of it use to report rate limits.
+var rateLimitHeaders = []string{
+	"X-RateLimit-Limit",
+	"X-RateLimit-Remaining",
+	"X-RateLimit-Reset",
+	"Retry-After",
+}
+
+func doctorCommand() cli.Command {
+	return cli.Command{
+		Name:  "doctor",
+		Usage: "Check connectivity, credentials and permissions against TestRail",
+		Flags: []cli.Flag{
+			cli.IntFlag{
+				Name:  "project-id, p",
+				Usage: "TestRail project ID to check access to",
+			},
+			cli.BoolFlag{
+				Name:  "check-write",
+				Usage: "check write access by creating and deleting a milestone in the project",
+			},
+			outputFlag,
+		},
+		Action: func(c *cli.Context) error {
+			checks := runDoctor(c.Int("project-id"), c.Bool("check-write"))
+
+			failed := 0
+			rows := [][]string{}
+			for i := range checks {
+				mark := "ok"
+				if checks[i].OK {
+					// Advice only helps with failed checks.
+					checks[i].Advice = ""
+				} else {
+					mark = "FAIL"
+					failed++
+				}
+				rows = append(rows, []string{mark, checks[i].Name, checks[i].Detail, checks[i].Advice})
+			}
+			if err := render(c.String("output"), checks, []string{"STATUS", "CHECK", "DETAIL", "ADVICE"}, rows); err != nil {
+				return fmt.Errorf("Error printing checks: %s", err)
+			}
+
+			if failed > 0 {
+				return fmt.Errorf("%d of %d checks failed", failed, len(checks))
+			}
+
+			return nil
+		},
+	}
+}
+
+// runDoctor performs the checks in order, stopping at the first failure that
+// makes the remaining checks meaningless.
+func runDoctor(projectID int, checkWrite bool) []doctorCheck {
+	checks := []doctorCheck{}
+
+	client, err := clientFromEnv()
+	if err != nil {
+		return append(checks, doctorCheck{
+			Name:   "credentials",
+			Detail: err.Error(),
+			Advice: "Export TESTRAIL_USERNAME (your login email) and TESTRAIL_TOKEN (an API key from My Settings).",
+		})
+	}
+
+	start := time.Now()
+	resp, err := http.Get(client.baseURL + "/index.php?/auth/login")
+	if err != nil {
+		return append(checks, doctorCheck{
+			Name:   "reachability",
+			Detail: fmt.Sprintf("%s: %s", client.baseURL, err),
+			Advice: "Check TESTRAIL_URL, DNS and any proxy between this machine and TestRail.",
+		})
+	}
+	resp.Body.Close()
+	checks = append(checks, doctorCheck{
+		Name:   "reachability",
+		OK:     resp.StatusCode < http.StatusInternalServerError,
+		Detail: fmt.Sprintf("%s answered %q in %s", client.baseURL, resp.Status, time.Since(start).Round(time.Millisecond)),
+		Advice: "TestRail is reachable but unhealthy, check its status page.",
+	})
+
+	header, _, err := client.request("GET", "get_user_by_email&email="+url.QueryEscape(client.username), nil)
+	if err != nil {
+		advice := "Make sure the API is enabled under Administration > Site Settings > API."
+		if strings.Contains(err.Error(), "401") {
+			advice = "TESTRAIL_USERNAME or TESTRAIL_TOKEN is wrong, or the API key was revoked."
+		}
+		return append(checks, doctorCheck{Name: "credentials", Detail: err.Error(), Advice: advice})
+	}
+	checks = append(checks, doctorCheck{Name: "credentials", OK: true, Detail: "authenticated as " + client.username})
+
+	limits := []string{}
+	for _, name := range rateLimitHeaders {
+		if v := header.Get(name); v != "" {
+			limits = append(limits, fmt.Sprintf("%s=%s", name, v))
+		}
+	}
+	rate := doctorCheck{Name: "rate limit", OK: true, Detail: "no rate limit reported"}
+	if len(limits) > 0 {
+		rate.Detail = strings.Join(limits, ", ")
+		if header.Get("X-RateLimit-Remaining") == "0" || header.Get("Retry-After") != "" {
+			rate.OK = false
+			rate.Advice = "The API rate limit is exhausted, spread uploads out or retry later."
+		}
+	}
+	checks = append(checks, rate)
+
+	if projectID == 0 {
+		return checks
+	}
+
+	project, err := client.GetProject(projectID)
+	if err != nil {
+		return append(checks, doctorCheck{
+			Name:   "project access",
+			Detail: err.Error(),
+			Advice: "Check the project ID with `trailer projects list` and that your user has access to it.",
+		})
+	}
+	checks = append(checks, doctorCheck{Name: "project access", OK: true, Detail: fmt.Sprintf("can read project %d: %s", project.ID, project.Name)})
+
+	if !checkWrite {
+		return checks
+	}
+
+	var probe milestone
+	name := fmt.Sprintf("trailer doctor probe %d", time.Now().Unix())
+	err = client.send("POST", fmt.Sprintf("add_milestone/%d", projectID), sendableMilestone{Name: name}, &probe)
+	if err != nil {
+		return append(checks, doctorCheck{
+			Name:   "project write access",
+			Detail: err.Error(),
+			Advice: "Your role needs permission to add results, runs and milestones in this project.",
+		})
+	}
+	if err := client.DeleteMilestone(probe.ID); err != nil {
+		return append(checks, doctorCheck{
+			Name:   "project write access",
+			Detail: fmt.Sprintf("created milestone %d but could not delete it: %s", probe.ID, err),
+			Advice: "Delete the probe milestone by hand.",
+		})
+	}
+
+	return append(checks, doctorCheck{Name: "project write access", OK: true, Detail: "created and deleted a probe milestone"})
+}
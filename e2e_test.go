@@ -0,0 +1,190 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/educlos/testrail"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/docker/trailer/internal/faketestrail"
+	"github.com/docker/trailer/pkg/download"
+)
+
+// startFake starts a fake TestRail with one project and suite, and points the
+// commands at it. Call the returned function when done.
+func startFake(t *testing.T) (*faketestrail.Server, func()) {
+	s := faketestrail.New()
+	s.Projects = []testrail.Project{{ID: 1, Name: "Trailer"}}
+	s.Suites = []testrail.Suite{{ID: 2, ProjectID: 1, Name: "Master"}}
+	s.Sections = []testrail.Section{{ID: 3, SuiteID: 2, Name: "Accounts"}}
+	s.Cases = []testrail.Case{
+		{ID: 11, SuiteID: 2, SectionID: 3, Title: "Login", UdpatedOn: 100},
+		{ID: 12, SuiteID: 2, SectionID: 3, Title: "Logout", UdpatedOn: 100},
+	}
+
+	dir, err := ioutil.TempDir("", "e2e")
+	assert.NoError(t, err)
+
+	env := map[string]string{
+		"TESTRAIL_URL":      s.URL,
+		"TESTRAIL_USERNAME": "user@example.com",
+		"TESTRAIL_TOKEN":    "token",
+		"TRAILER_CONFIG":    filepath.Join(dir, "missing.yml"),
+		"TRAILER_PROFILE":   "",
+	}
+	old := map[string]string{}
+	for k, v := range env {
+		if o, ok := os.LookupEnv(k); ok {
+			old[k] = o
+		}
+		os.Setenv(k, v)
+	}
+
+	return s, func() {
+		for k := range env {
+			if o, ok := old[k]; ok {
+				os.Setenv(k, o)
+			} else {
+				os.Unsetenv(k)
+			}
+		}
+		os.RemoveAll(dir)
+		s.Close()
+	}
+}
+
+// run runs trailer with the given arguments.
+func run(args ...string) error {
+	return newApp().Run(append([]string{"trailer"}, args...))
+}
+
+func writeReport(t *testing.T, dir, body string) string {
+	report := filepath.Join(dir, "report.xml")
+	assert.NoError(t, ioutil.WriteFile(report, []byte(body), 0644))
+	return report
+}
+
+func TestEndToEndUpload(t *testing.T) {
+	s, stop := startFake(t)
+	defer stop()
+
+	dir, err := ioutil.TempDir("", "upload")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	run1 := s.AddRun(1, 2, 11, 12)
+	report := writeReport(t, dir, `<testsuite name="accounts" tests="2">
+  <testcase name="TestRailC11 login" time="1"></testcase>
+  <testcase name="TestRailC12 logout" time="2"><failure message="still logged in"></failure></testcase>
+</testsuite>`)
+
+	err = run("upload", "--run-id", strconv.Itoa(run1.ID), report)
+	assert.NoError(t, err)
+
+	s.Lock()
+	defer s.Unlock()
+	assert.Len(t, s.Results, 2)
+	statuses := map[int]int{}
+	for _, r := range s.Results {
+		statuses[r.CaseID] = r.StatusID
+	}
+	assert.Equal(t, map[int]int{11: 1, 12: 5}, statuses)
+}
+
+func TestEndToEndUploadUnknownCase(t *testing.T) {
+	s, stop := startFake(t)
+	defer stop()
+
+	dir, err := ioutil.TempDir("", "upload")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	run1 := s.AddRun(1, 2, 11)
+	report := writeReport(t, dir, `<testsuite name="accounts" tests="2">
+  <testcase name="TestRailC11 login" time="1"></testcase>
+  <testcase name="TestRailC99 removed" time="1"></testcase>
+</testsuite>`)
+
+	err = run("upload", "--run-id", strconv.Itoa(run1.ID), report)
+	assert.NoError(t, err)
+
+	s.Lock()
+	defer s.Unlock()
+	assert.Len(t, s.Results, 1)
+	assert.Equal(t, 11, s.Results[0].CaseID)
+}
+
+func TestEndToEndDownloadAndPrune(t *testing.T) {
+	_, stop := startFake(t)
+	defer stop()
+
+	dir, err := ioutil.TempDir("", "download")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "cases.yml")
+
+	assert.NoError(t, run("download", "--project-id", "1", "--suite-id", "2", "--file", file))
+	s, err := download.Load(file)
+	assert.NoError(t, err)
+	assert.Len(t, s.Cases, 2)
+
+	assert.NoError(t, run("prune", "--file", file, "11"))
+	s, err = download.Load(file)
+	assert.NoError(t, err)
+	assert.Len(t, s.Cases, 1)
+	assert.Contains(t, s.Cases, 12)
+
+	assert.Equal(t, exitConfig, exitCode(run("prune", "--file", file, "C12")))
+}
+
+func TestEndToEndSectionsAndCases(t *testing.T) {
+	s, stop := startFake(t)
+	defer stop()
+
+	assert.NoError(t, run("sections", "create", "--project-id", "1", "--suite-id", "2", "--name", "Billing", "--parent-id", "3"))
+
+	s.Lock()
+	assert.Len(t, s.Sections, 2)
+	billing := s.Sections[1]
+	s.Unlock()
+	assert.Equal(t, "Billing", billing.Name)
+	assert.Equal(t, 3, billing.ParentID)
+
+	assert.NoError(t, run("cases", "create", "--section-id", strconv.Itoa(billing.ID), "--title", "Refund"))
+	assert.Equal(t, exitAPI, exitCode(run("cases", "create", "--section-id", "999", "--title", "Lost")))
+
+	s.Lock()
+	defer s.Unlock()
+	assert.Len(t, s.Cases, 3)
+	assert.Equal(t, "Refund", s.Cases[2].Title)
+	assert.Equal(t, billing.ID, s.Cases[2].SectionID)
+}
+
+func TestUploadStepResults(t *testing.T) {
+	s, stop := startFake(t)
+	defer stop()
+
+	dir, err := ioutil.TempDir("", "steps")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	r := s.AddRun(1, 2, 11)
+	report := writeReport(t, dir, `[{"name": "Accounts", "elements": [{"name": "Login TestRailC11", "type": "scenario", "steps": [
+  {"keyword": "Given ", "name": "a user", "result": {"status": "passed"}},
+  {"keyword": "When ", "name": "they log in", "result": {"status": "failed", "error_message": "timeout"}}
+]}]}]`)
+	assert.NoError(t, run("upload", "--step-results", "--run-id", strconv.Itoa(r.ID), report))
+
+	s.Lock()
+	defer s.Unlock()
+	assert.Len(t, s.Results, 1)
+	assert.Equal(t, 5, s.Results[0].StatusID)
+	assert.Equal(t, []testrail.CustomStepResult{
+		{Content: "Given a user", StatusID: 1},
+		{Content: "When they log in", Actual: "timeout", StatusID: 5},
+	}, s.Results[0].StepResults)
+}
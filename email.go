@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/smtp"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/urfave/cli"
+
+	"github.com/docker/trailer/pkg/config"
+	"github.com/docker/trailer/pkg/secrets"
+)
+
+var emailFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "email-to",
+		Usage: "email a summary to these comma-separated addresses, email.to in the config file by default",
+	},
+	cli.StringFlag{
+		Name:  "smtp",
+		Usage: "host:port of the SMTP server to send the email through, email.smtp in the config file by default",
+	},
+	cli.StringFlag{
+		Name:  "email-subject",
+		Usage: "Go template of the subject of the email",
+	},
+	cli.StringFlag{
+		Name:  "email-template",
+		Usage: "Go template file of the body of the email",
+	},
+}
+
+// emailTemplate is the default subject and body of the email of a command.
+type emailTemplate struct {
+	Subject, Body string
+}
+
+var uploadEmail = emailTemplate{
+	Subject: `Run {{.RunID}}: {{.Passed}} passed, {{.Failed}} failed`,
+	Body: `Run {{.RunID}}: {{.RunURL}}
+
+{{.Passed}} passed, {{.Failed}} failed, {{.Skipped}} skipped{{if .NotUploaded}}, {{.NotUploaded}} not uploaded{{end}}
+{{if .Failures}}
+Failures:
+{{range .Failures}}
+C{{.ID}} {{.Test}}: {{.Message}}
+{{.URL}}
+{{end}}{{if .MoreFailures}}
+and {{.MoreFailures}} more failures
+{{end}}{{end}}`,
+}
+
+var compareEmail = emailTemplate{
+	Subject: `Run {{.Head}} compared with run {{.Base}}: {{index .Counts "newly failing"}} newly failing`,
+	Body: `Run {{.Head}} compared with run {{.Base}}:
+
+{{index .Counts "newly failing"}} newly failing, {{index .Counts "still failing"}} still failing, {{index .Counts "missing result"}} missing results, {{index .Counts "newly passing"}} newly passing
+{{range .Changes}}
+C{{.ID}} {{.Title}}: {{.Change}}{{end}}
+`,
+}
+
+var trendEmail = emailTemplate{
+	Subject: `Pass rates of {{len .}} runs`,
+	Body: `{{range .}}R{{.RunID}} {{.Name}}: {{printf "%.1f" .PassRate}}% of {{.Passed}} passed, {{.Failed}} failed, {{.Other}} other
+{{end}}`,
+}
+
+// sendEmail emails the data rendered with the templates to the recipients
+// set with the flags or in the config file. It does nothing without
+// recipients.
+func sendEmail(c *cli.Context, tmpl emailTemplate, data interface{}) error {
+	cfg, err := config.Load(config.File())
+	if err != nil {
+		return err
+	}
+	e := cfg.Email
+
+	to := e.To
+	if addrs := c.String("email-to"); addrs != "" {
+		to = []string{}
+		for _, addr := range strings.Split(addrs, ",") {
+			if addr = strings.TrimSpace(addr); addr != "" {
+				to = append(to, addr)
+			}
+		}
+	}
+	if len(to) == 0 {
+		return nil
+	}
+	if c.String("smtp") != "" {
+		e.SMTP = c.String("smtp")
+	}
+	if e.SMTP == "" {
+		return fmt.Errorf("Must set --smtp or email.smtp in the config file to send email")
+	}
+	if e.From == "" {
+		e.From = "trailer@localhost"
+	}
+
+	subject := firstOf(c.String("email-subject"), e.Subject, tmpl.Subject)
+	body := tmpl.Body
+	if file := firstOf(c.String("email-template"), e.Template); file != "" {
+		text, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		body = string(text)
+	}
+
+	msg, err := emailMessage(e.From, to, subject, body, data)
+	if err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if e.Username != "" {
+		password, err := secrets.Resolve(e.Password)
+		if err != nil {
+			return err
+		}
+		host, _, _ := net.SplitHostPort(e.SMTP)
+		auth = smtp.PlainAuth("", e.Username, password, host)
+	}
+	return smtp.SendMail(e.SMTP, auth, e.From, to, msg)
+}
+
+// emailMessage renders the subject and body templates into a plain text
+// message.
+func emailMessage(from string, to []string, subject, body string, data interface{}) ([]byte, error) {
+	render := func(name, text string) (string, error) {
+		t, err := template.New(name).Parse(text)
+		if err != nil {
+			return "", err
+		}
+		var b bytes.Buffer
+		err = t.Execute(&b, data)
+		return b.String(), err
+	}
+
+	subject, err := render("subject", subject)
+	if err != nil {
+		return nil, err
+	}
+	if body, err = render("body", body); err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", oneLine(subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	b.WriteString(strings.Replace(body, "\n", "\r\n", -1))
+	return b.Bytes(), nil
+}
+
+// firstOf returns the first of values that is not empty.
+func firstOf(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// startSMTP accepts one message at a time and sends its recipients and
+// data to the channel.
+func startSMTP(t *testing.T) (string, chan string, func()) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+
+	messages := make(chan string, 1)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			r := bufio.NewReader(conn)
+			fmt.Fprint(conn, "220 localhost\r\n")
+			var msg strings.Builder
+			data := false
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					break
+				}
+				switch {
+				case data && line == ".\r\n":
+					data = false
+					fmt.Fprint(conn, "250 OK\r\n")
+				case data:
+					msg.WriteString(line)
+				case strings.HasPrefix(line, "RCPT TO:"):
+					msg.WriteString(line)
+					fmt.Fprint(conn, "250 OK\r\n")
+				case strings.HasPrefix(line, "DATA"):
+					data = true
+					fmt.Fprint(conn, "354 Go ahead\r\n")
+				case strings.HasPrefix(line, "QUIT"):
+					fmt.Fprint(conn, "221 Bye\r\n")
+				default:
+					fmt.Fprint(conn, "250 OK\r\n")
+				}
+			}
+			conn.Close()
+			messages <- msg.String()
+		}
+	}()
+	return l.Addr().String(), messages, func() { l.Close() }
+}
+
+func TestUploadEmail(t *testing.T) {
+	s, stop := startFake(t)
+	defer stop()
+
+	addr, messages, stopSMTP := startSMTP(t)
+	defer stopSMTP()
+
+	dir, err := ioutil.TempDir("", "email")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	runID := strconv.Itoa(s.AddRun(1, 2, 11, 12).ID)
+	report := writeReport(t, dir, `<testsuite name="accounts" tests="2">
+  <testcase name="TestRailC11 login" time="1"></testcase>
+  <testcase name="TestRailC12 logout" time="2"><failure>still logged in</failure></testcase>
+</testsuite>`)
+
+	assert.NoError(t, run("upload", "--run-id", runID, "--smtp", addr, "--email-to", "qa@example.com, release@example.com,", report))
+	msg := <-messages
+	assert.Contains(t, msg, "RCPT TO:<qa@example.com>")
+	assert.Contains(t, msg, "RCPT TO:<release@example.com>")
+	assert.Contains(t, msg, "Subject: Run "+runID+": 1 passed, 1 failed\r\n")
+	assert.Contains(t, msg, "C12 TestRailC12 logout: still logged in\r\n")
+
+	body := filepath.Join(dir, "body.tmpl")
+	assert.NoError(t, ioutil.WriteFile(body, []byte("{{range .Changes}}C{{.ID}} {{.Change}}\n{{end}}"), 0644))
+	base := strconv.Itoa(s.AddRun(1, 2, 11, 12).ID)
+	assert.NoError(t, run("compare", "--run-id", base, "--run-id", runID, "--smtp", addr, "--email-to", "qa@example.com",
+		"--email-subject", "Go/no-go for {{.Head}}", "--email-template", body))
+	msg = <-messages
+	assert.Contains(t, msg, "Subject: Go/no-go for "+runID+"\r\n")
+	assert.Contains(t, msg, "C12 newly failing\r\n")
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/urfave/cli"
+)
+
+// envPrefix is the prefix of the environment variables that set flags.
+const envPrefix = "TRAILER_"
+
+// sharedFlags are the command flags meaning the same in every command, which
+// share one environment variable such as TRAILER_RUN_ID.
+var sharedFlags = map[string]bool{
+	"case-map":        true,
+	"ci-info":         true,
+	"comment":         true,
+	"dry":             true,
+	"email-to":        true,
+	"ignore-failures": true,
+	"output":          true,
+	"project-id":      true,
+	"queue":           true,
+	"result-version":  true,
+	"run-id":          true,
+	"smtp":            true,
+	"spool":           true,
+	"status-map":      true,
+	"suite-id":        true,
+	"verbose":         true,
+}
+
+// flagEnvVar returns the environment variable for the flag named name of
+// command, which is empty for global flags. Shared and global flags use the
+// flag name alone, for example TRAILER_RUN_ID for "run-id, r", the others
+// also the command, like TRAILER_RERUN_FILTER_FORMAT for the format of
+// rerun-filter, so exporting them for one command does not change another.
+func flagEnvVar(command, name string) string {
+	name = strings.TrimSpace(strings.Split(name, ",")[0])
+	if command != "" && !sharedFlags[name] {
+		name = command + "-" + name
+	}
+	return envPrefix + strings.ToUpper(strings.NewReplacer("-", "_", " ", "_").Replace(name))
+}
+
+// withEnvVars sets the environment variable of every flag of command that
+// does not have one yet, so trailer can be configured through the
+// environment alone.
+func withEnvVars(command string, flags []cli.Flag) []cli.Flag {
+	out := make([]cli.Flag, len(flags))
+	for i, flag := range flags {
+		switch f := flag.(type) {
+		case cli.BoolFlag:
+			if f.EnvVar == "" {
+				f.EnvVar = flagEnvVar(command, f.Name)
+			}
+			flag = f
+		case cli.BoolTFlag:
+			if f.EnvVar == "" {
+				f.EnvVar = flagEnvVar(command, f.Name)
+			}
+			flag = f
+		case cli.DurationFlag:
+			if f.EnvVar == "" {
+				f.EnvVar = flagEnvVar(command, f.Name)
+			}
+			flag = f
+		case cli.IntFlag:
+			if f.EnvVar == "" {
+				f.EnvVar = flagEnvVar(command, f.Name)
+			}
+			flag = f
+		case cli.Float64Flag:
+			if f.EnvVar == "" {
+				f.EnvVar = flagEnvVar(command, f.Name)
+			}
+			flag = f
+		case cli.IntSliceFlag:
+			if f.EnvVar == "" {
+				f.EnvVar = flagEnvVar(command, f.Name)
+			}
+			flag = f
+		case cli.StringFlag:
+			if f.EnvVar == "" {
+				f.EnvVar = flagEnvVar(command, f.Name)
+			}
+			flag = f
+		case cli.StringSliceFlag:
+			if f.EnvVar == "" {
+				f.EnvVar = flagEnvVar(command, f.Name)
+			}
+			flag = f
+		}
+		out[i] = flag
+	}
+	return out
+}
+
+// commandsWithEnvVars applies withEnvVars to the flags of cmds, subcommands
+// of parent, and of their subcommands.
+func commandsWithEnvVars(parent string, cmds []cli.Command) []cli.Command {
+	out := make([]cli.Command, len(cmds))
+	for i, cmd := range cmds {
+		command := strings.TrimSpace(parent + " " + cmd.Name)
+		cmd.Flags = withEnvVars(command, cmd.Flags)
+		cmd.Subcommands = commandsWithEnvVars(command, cmd.Subcommands)
+		out[i] = cmd
+	}
+	return out
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/urfave/cli"
+)
+
+func TestFlagEnvVar(t *testing.T) {
+	testcases := []struct {
+		command string
+		name    string
+		envVar  string
+	}{
+		{command: "", name: "log-level", envVar: "TRAILER_LOG_LEVEL"},
+		{command: "upload", name: "run-id, r", envVar: "TRAILER_RUN_ID"},
+		{command: "upload", name: "comment", envVar: "TRAILER_COMMENT"},
+		{command: "watch", name: " ignore-failures, i", envVar: "TRAILER_IGNORE_FAILURES"},
+		{command: "rerun-filter", name: "format", envVar: "TRAILER_RERUN_FILTER_FORMAT"},
+		{command: "runs close", name: "name, n", envVar: "TRAILER_RUNS_CLOSE_NAME"},
+	}
+	for _, testcase := range testcases {
+		assert.Equal(t, testcase.envVar, flagEnvVar(testcase.command, testcase.name))
+	}
+}
+
+// TestEnvVarsDoNotCollide checks that the flags sharing an environment
+// variable are the same flag, in the meaning of sharedFlags or with the same
+// usage, so exporting it for one command does not change another.
+func TestEnvVarsDoNotCollide(t *testing.T) {
+	type use struct{ path, name, usage string }
+	uses := map[string][]use{}
+	var walk func(path string, flags []cli.Flag, cmds []cli.Command)
+	walk = func(path string, flags []cli.Flag, cmds []cli.Command) {
+		for _, flag := range flags {
+			v := reflect.ValueOf(flag)
+			if envVar := v.FieldByName("EnvVar"); envVar.IsValid() && envVar.String() != "" {
+				name := strings.TrimSpace(strings.Split(flag.GetName(), ",")[0])
+				uses[envVar.String()] = append(uses[envVar.String()], use{path: path, name: name, usage: v.FieldByName("Usage").String()})
+			}
+		}
+		for _, cmd := range cmds {
+			walk(path+" "+cmd.Name, cmd.Flags, cmd.Subcommands)
+		}
+	}
+	app := newApp()
+	walk(app.Name, app.Flags, app.Commands)
+
+	for envVar, flags := range uses {
+		for _, f := range flags[1:] {
+			assert.Equal(t, flags[0].name, f.name, "%s sets --%s of %s and --%s of %s", envVar, flags[0].name, flags[0].path, f.name, f.path)
+			if !sharedFlags[f.name] {
+				assert.Equal(t, flags[0].usage, f.usage, "%s sets --%s of %s and of %s", envVar, f.name, flags[0].path, f.path)
+			}
+		}
+	}
+}
+
+func TestEveryFlagHasEnvVar(t *testing.T) {
+	app := newApp()
+	var check func(path string, flags []cli.Flag, cmds []cli.Command)
+	check = func(path string, flags []cli.Flag, cmds []cli.Command) {
+		for _, flag := range flags {
+			// Help and version flags are added by cli itself.
+			if flag == cli.HelpFlag || flag == cli.VersionFlag {
+				continue
+			}
+			envVar := reflect.ValueOf(flag).FieldByName("EnvVar")
+			if assert.True(t, envVar.IsValid(), "%s --%s has no EnvVar field", path, flag.GetName()) {
+				assert.NotEmpty(t, envVar.String(), "%s --%s has no environment variable", path, flag.GetName())
+			}
+		}
+		for _, cmd := range cmds {
+			check(path+" "+cmd.Name, cmd.Flags, cmd.Subcommands)
+		}
+	}
+	check(app.Name, app.Flags, app.Commands)
+}
+
+func TestFlagsFromEnv(t *testing.T) {
+	s, stop := startFake(t)
+	defer stop()
+
+	dir, err := ioutil.TempDir("", "env")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	run1 := s.AddRun(1, 2, 11)
+	report := writeReport(t, dir, `<testsuite name="accounts" tests="1">
+  <testcase name="TestRailC11 login" time="1"><failure message="locked out"></failure></testcase>
+</testsuite>`)
+
+	defer os.Unsetenv("TRAILER_RUN_ID")
+	defer os.Unsetenv("TRAILER_COMMENT")
+	os.Setenv("TRAILER_RUN_ID", strconv.Itoa(run1.ID))
+	os.Setenv("TRAILER_COMMENT", "nightly")
+
+	assert.NoError(t, run("upload", report))
+
+	s.Lock()
+	defer s.Unlock()
+	assert.Len(t, s.Results, 1)
+	assert.Contains(t, s.Results[0].Comment, "nightly")
+}
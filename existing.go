@@ -0,0 +1,60 @@
+package main
+
+import (
+	"github.com/educlos/testrail"
+	"github.com/urfave/cli"
+
+	"github.com/docker/trailer/spec"
+)
+
+var resultVersionFlag = cli.StringFlag{
+	Name:  "result-version",
+	Usage: "build or version recorded with every result, such as the commit that was tested",
+}
+
+var updateExistingFlag = cli.BoolFlag{
+	Name:  "update-existing",
+	Usage: "skip results the run already has for --result-version, so retried jobs do not add them again",
+}
+
+// skipRecorded removes the results the run already has for the version of
+// updates from them and returns how many it removed. TestRail results cannot
+// be edited, so a result whose outcome changed since is still added, and
+// becomes the latest result of the test.
+func skipRecorded(client testrailAPI, runID int, updates *spec.Updates) (int, error) {
+	tests, err := client.GetTests(runID)
+	if err != nil {
+		return 0, err
+	}
+	caseIDs := map[int]int{}
+	for _, test := range tests {
+		caseIDs[test.ID] = test.CaseID
+	}
+
+	results, err := runResults(client, runID)
+	if err != nil {
+		return 0, err
+	}
+	// Only the latest result of each case for the version counts, it may
+	// have replaced an earlier one of a retried job.
+	recorded := map[int]testrail.Result{}
+	for _, result := range results {
+		caseID, ok := caseIDs[result.TestID]
+		if !ok || result.Version != updates.Version {
+			continue
+		}
+		if r, ok := recorded[caseID]; !ok || result.ID > r.ID {
+			recorded[caseID] = result
+		}
+	}
+
+	skipped := 0
+	for _, result := range updates.PayloadFor(updates.SortedCaseIDs()).Results {
+		r, ok := recorded[result.CaseID]
+		if ok && r.StatusID == result.StatusID && r.Comment == result.Comment {
+			updates.RemoveResult(result.CaseID)
+			skipped++
+		}
+	}
+	return skipped, nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/docker/trailer/internal/faketestrail"
+)
+
+func TestUploadUpdateExisting(t *testing.T) {
+	s, stop := startFake(t)
+	defer stop()
+
+	dir, err := ioutil.TempDir("", "existing")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	runID := strconv.Itoa(s.AddRun(1, 2, 11, 12).ID)
+	report := writeReport(t, dir, `<testsuite name="accounts" tests="2">
+  <testcase name="TestRailC11 login" time="1"></testcase>
+  <testcase name="TestRailC12 logout" time="2"><failure message="still logged in"></failure></testcase>
+</testsuite>`)
+	results := func() map[string]int {
+		s.Lock()
+		defer s.Unlock()
+		counts := map[string]int{}
+		for _, r := range s.Results {
+			counts[strconv.Itoa(r.CaseID)+"@"+r.Version]++
+		}
+		return counts
+	}
+
+	upload := []string{"upload", "--update-existing", "--run-id", runID}
+	assert.NoError(t, run(append(upload, "--result-version", "abc123", report)...))
+	assert.NoError(t, run(append(upload, "--result-version", "abc123", report)...))
+	assert.Equal(t, map[string]int{"11@abc123": 1, "12@abc123": 1}, results())
+
+	// The retry fixed the failure, so C12 gets its new outcome.
+	writeReport(t, dir, `<testsuite name="accounts" tests="2">
+  <testcase name="TestRailC11 login" time="1"></testcase>
+  <testcase name="TestRailC12 logout" time="2"></testcase>
+</testsuite>`)
+	assert.NoError(t, run(append(upload, "--result-version", "abc123", report)...))
+	assert.Equal(t, map[string]int{"11@abc123": 1, "12@abc123": 2}, results())
+
+	assert.NoError(t, run(append(upload, "--result-version", "def456", report)...))
+	assert.Equal(t, map[string]int{"11@abc123": 1, "12@abc123": 2, "11@def456": 1, "12@def456": 1}, results())
+
+	// Results beyond the first page TestRail returns are found too.
+	s.Lock()
+	for _, test := range s.Tests {
+		if strconv.Itoa(test.RunID) == runID && test.CaseID == 12 {
+			for i := 0; i < 300; i++ {
+				s.Results = append(s.Results, faketestrail.Result{ID: 10000 + i, TestID: test.ID, CaseID: 12, StatusID: 1, Version: "nightly"})
+			}
+		}
+	}
+	s.Unlock()
+	assert.NoError(t, run(append(upload, "--result-version", "def456", report)...))
+	assert.Equal(t, 1, results()["11@def456"])
+
+	err = run(append(upload, report)...)
+	assert.Equal(t, exitConfig, exitCode(err))
+}
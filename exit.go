@@ -0,0 +1,55 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/urfave/cli"
+)
+
+// Exit codes, so scripts can tell the kinds of failure apart.
+const (
+	exitFailure     = 1 // any other failure
+	exitConfig      = 2 // missing or invalid flags, config or credentials
+	exitParse       = 3 // unreadable reports, manifests or cases files
+	exitAPI         = 4 // TestRail API call failed
+	exitPartial     = 5 // some results were uploaded but others were dropped
+	exitInterrupted = 6 // cancelled by a signal or --timeout
+	exitMismatch    = 7 // TestRail does not match the uploaded results or annotations
+)
+
+// exitError is an error that sets the exit code of the command.
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e exitError) Error() string { return e.err.Error() }
+func (e exitError) Unwrap() error { return e.err }
+func (e exitError) ExitCode() int { return e.code }
+
+func configErrorf(format string, args ...interface{}) error {
+	return exitError{code: exitConfig, err: fmt.Errorf(format, args...)}
+}
+
+func parseErrorf(format string, args ...interface{}) error {
+	return exitError{code: exitParse, err: fmt.Errorf(format, args...)}
+}
+
+func apiErrorf(format string, args ...interface{}) error {
+	return exitError{code: exitAPI, err: fmt.Errorf(format, args...)}
+}
+
+// exitCode returns the exit code for err.
+func exitCode(err error) int {
+	// The cli package combines the errors of a command and of app.After.
+	if multi, ok := err.(cli.MultiError); ok && len(multi.Errors) > 0 {
+		return exitCode(multi.Errors[0])
+	}
+
+	var coder cli.ExitCoder
+	if errors.As(err, &coder) {
+		return coder.ExitCode()
+	}
+	return exitFailure
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExitCode(t *testing.T) {
+	testcases := []struct {
+		err      error
+		expected int
+	}{
+		{err: errors.New("failed"), expected: exitFailure},
+		{err: configErrorf("Must set --run-id"), expected: exitConfig},
+		{err: parseErrorf("Failed to parse file: %s", "EOF"), expected: exitParse},
+		{err: apiErrorf("Error getting cases: %s", "timeout"), expected: exitAPI},
+		{err: exitError{code: exitPartial, err: errors.New("dropped")}, expected: exitPartial},
+		{err: markUnavailable(apiErrorf("failed to upload"), errors.New(`response: status: "503 Service Unavailable", body: `)), expected: exitAPI},
+	}
+
+	for _, testcase := range testcases {
+		assert.Equal(t, testcase.expected, exitCode(testcase.err), testcase.err.Error())
+	}
+}
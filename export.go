@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/educlos/testrail"
+	"github.com/onsi/ginkgo/reporters"
+	"github.com/urfave/cli"
+
+	"github.com/docker/trailer/spec"
+)
+
+// runJUnit converts the tests of a run to a JUnit report with one testsuite.
+// Each testcase is named after its case the way trailer expects, so the
+// report can be uploaded again. Passed tests pass, untested tests are
+// skipped, and tests of any other status fail with the comment of their
+// latest result.
+func runJUnit(run testrail.Run, tests []testrail.Test, results []testrail.Result, statuses map[int]string) spec.JUnitTestSuites {
+	passed := spec.DefaultStatusMap.ID(spec.Passed)
+
+	latest := map[int]testrail.Result{}
+	for _, r := range results {
+		if l, ok := latest[r.TestID]; !ok || r.ID > l.ID {
+			latest[r.TestID] = r
+		}
+	}
+
+	suite := reporters.JUnitTestSuite{Name: run.Name}
+	for _, t := range tests {
+		r := latest[t.ID]
+		tc := reporters.JUnitTestCase{
+			Name:      fmt.Sprintf("TestRailC%d %s", t.CaseID, t.Title),
+			ClassName: run.Name,
+			Time:      r.Elapsed.Seconds(),
+		}
+		switch t.StatusID {
+		case passed:
+		case untestedStatus:
+			tc.Skipped = &reporters.JUnitSkipped{}
+		default:
+			status, ok := statuses[t.StatusID]
+			if !ok {
+				status = fmt.Sprint(t.StatusID)
+			}
+			tc.FailureMessage = &reporters.JUnitFailureMessage{Type: status, Message: strings.TrimSpace(r.Comment)}
+			suite.Failures++
+		}
+		suite.Tests++
+		suite.Time += tc.Time
+		suite.TestCases = append(suite.TestCases, tc)
+	}
+	return spec.JUnitTestSuites{Suites: []reporters.JUnitTestSuite{suite}}
+}
+
+func exportJUnitCommand() cli.Command {
+	return cli.Command{
+		Name:      "export-junit",
+		Usage:     "Convert the results of a run to a JUnit report",
+		ArgsUsage: "--run-id N",
+		Flags: []cli.Flag{
+			cli.IntFlag{
+				Name:  "run-id, r",
+				Usage: "TestRail run to export",
+			},
+			cli.StringFlag{
+				Name:  "out, o",
+				Usage: "file to write the report to, printed by default",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			runID := c.Int("run-id")
+			if runID == 0 {
+				return configErrorf("Must set --run-id")
+			}
+
+			client, err := newClient()
+			if err != nil {
+				return err
+			}
+
+			var run testrail.Run
+			if err := client.send("GET", fmt.Sprintf("get_run/%d", runID), nil, &run); err != nil {
+				return apiErrorf("Error getting run %d: %s", runID, err)
+			}
+			tests, err := freshTests(client, runID)
+			if err != nil {
+				return apiErrorf("Error getting tests of run %d: %s", runID, err)
+			}
+			results, err := runResults(client, runID)
+			if err != nil {
+				return apiErrorf("Error getting results of run %d: %s", runID, err)
+			}
+			statuses := map[int]string{}
+			list, err := client.GetStatuses()
+			if err != nil {
+				return apiErrorf("Error getting statuses: %s", err)
+			}
+			for _, s := range list {
+				statuses[s.ID] = s.Name
+			}
+
+			data, err := xml.MarshalIndent(runJUnit(run, tests, results, statuses), "", "  ")
+			if err != nil {
+				return fmt.Errorf("Error encoding report: %s", err)
+			}
+			data = append([]byte(xml.Header), append(data, '\n')...)
+
+			if c.String("out") == "" {
+				os.Stdout.Write(data)
+				return nil
+			}
+			if err := ioutil.WriteFile(c.String("out"), data, 0644); err != nil {
+				return fmt.Errorf("Error writing report: %s", err)
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/docker/trailer/internal/faketestrail"
+	"github.com/docker/trailer/spec"
+)
+
+func TestExportJUnit(t *testing.T) {
+	s, stop := startFake(t)
+	defer stop()
+
+	dir, err := ioutil.TempDir("", "export")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	runID := strconv.Itoa(s.AddRun(1, 2, 11, 12).ID)
+	report := writeReport(t, dir, `<testsuite name="accounts" tests="1">
+  <testcase name="TestRailC12 logout" time="1"><failure message="boom">still logged in</failure></testcase>
+</testsuite>`)
+	assert.NoError(t, run("upload", "--run-id", runID, report))
+
+	out := filepath.Join(dir, "run.xml")
+	assert.NoError(t, run("export-junit", "--run-id", runID, "-o", out))
+	data, err := ioutil.ReadFile(out)
+	assert.NoError(t, err)
+	suites, err := spec.ParseBytes(out, data)
+	assert.NoError(t, err)
+	if assert.Len(t, suites, 1) && assert.Len(t, suites[0].TestCases, 2) {
+		suite := suites[0]
+		assert.Equal(t, 2, suite.Tests)
+		assert.Equal(t, 1, suite.Failures)
+
+		login, logout := suite.TestCases[0], suite.TestCases[1]
+		assert.Equal(t, "TestRailC11 Login", login.Name)
+		assert.NotNil(t, login.Skipped)
+		assert.Equal(t, "TestRailC12 Logout", logout.Name)
+		if assert.NotNil(t, logout.FailureMessage) {
+			assert.Equal(t, "failed", logout.FailureMessage.Type)
+			assert.Equal(t, "still logged in", logout.FailureMessage.Message)
+		}
+	}
+
+	// The exported report uploads the same results again.
+	assert.NoError(t, run("upload", "--run-id", runID, out))
+	assert.NoError(t, run("export-junit", "--run-id", runID, "-o", out))
+	again, err := ioutil.ReadFile(out)
+	assert.NoError(t, err)
+	assert.Equal(t, string(data), string(again))
+
+	// Results beyond the first page TestRail returns are exported too.
+	s.Lock()
+	for _, test := range s.Tests {
+		if strconv.Itoa(test.RunID) == runID && test.CaseID == 11 {
+			for i := 0; i < 300; i++ {
+				s.Results = append(s.Results, faketestrail.Result{ID: 10000 + i, TestID: test.ID, CaseID: 11, StatusID: 1})
+			}
+		}
+	}
+	s.Unlock()
+	assert.NoError(t, run("export-junit", "--run-id", runID, "-o", out))
+	data, err = ioutil.ReadFile(out)
+	assert.NoError(t, err)
+	suites, err = spec.ParseBytes(out, data)
+	assert.NoError(t, err)
+	if assert.Len(t, suites, 1) {
+		assert.Equal(t, 1, suites[0].Failures)
+	}
+
+	assert.Equal(t, exitConfig, exitCode(run("export-junit")))
+	assert.Equal(t, exitAPI, exitCode(run("export-junit", "--run-id", "999")))
+}
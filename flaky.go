@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+
+	"github.com/educlos/testrail"
+	"github.com/urfave/cli"
+	yaml "gopkg.in/yaml.v2"
+
+	"github.com/docker/trailer/pkg/download"
+	"github.com/docker/trailer/spec"
+)
+
+// flakyCase is the pass and fail history of a case over recent runs.
+type flakyCase struct {
+	ID    int    `json:"id"`
+	Title string `json:"title"`
+	// Runs counts the runs the case passed or failed in.
+	Runs     int `json:"runs"`
+	Failures int `json:"failures"`
+	// Flips counts the changes from passed to failed and back.
+	Flips int `json:"flips"`
+	// Score is Flips out of the most possible flips, 1 for a case that
+	// alternates every run.
+	Score float64 `json:"score"`
+}
+
+// flakyCases scores how often the cases of the runs alternate between
+// passed and failed, returning those scoring at least minScore with the
+// flakiest first. Other statuses, such as untested, are skipped.
+func flakyCases(h runHistory, minScore float64) []flakyCase {
+	passed, failed := spec.DefaultStatusMap.ID(spec.Passed), spec.DefaultStatusMap.ID(spec.Failed)
+
+	cases := map[int]*flakyCase{}
+	last := map[int]int{}
+	for _, tests := range h.Tests {
+		for _, test := range tests {
+			if test.StatusID != passed && test.StatusID != failed {
+				continue
+			}
+			c, ok := cases[test.CaseID]
+			if !ok {
+				c = &flakyCase{ID: test.CaseID}
+				cases[test.CaseID] = c
+			}
+			c.Title = test.Title
+			c.Runs++
+			if test.StatusID == failed {
+				c.Failures++
+			}
+			if ok && last[test.CaseID] != test.StatusID {
+				c.Flips++
+			}
+			last[test.CaseID] = test.StatusID
+		}
+	}
+
+	flaky := []flakyCase{}
+	for _, c := range cases {
+		if c.Flips == 0 {
+			continue
+		}
+		c.Score = float64(c.Flips) / float64(c.Runs-1)
+		if c.Score >= minScore {
+			flaky = append(flaky, *c)
+		}
+	}
+	sort.Slice(flaky, func(i, j int) bool {
+		if flaky[i].Score != flaky[j].Score {
+			return flaky[i].Score > flaky[j].Score
+		}
+		return flaky[i].ID < flaky[j].ID
+	})
+	return flaky
+}
+
+// quarantineFile lists flaky cases, for CI jobs to skip or to not fail on.
+type quarantineFile struct {
+	Cases map[int]quarantinedCase `yaml:"cases"`
+}
+
+type quarantinedCase struct {
+	Title string  `yaml:"title"`
+	Score float64 `yaml:"score"`
+}
+
+// quarantine adds the cases to the quarantine file, keeping the cases it
+// already has.
+func quarantine(file string, cases []flakyCase) error {
+	q := quarantineFile{}
+	data, err := ioutil.ReadFile(file)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := yaml.Unmarshal(data, &q); err != nil {
+		return err
+	}
+	if q.Cases == nil {
+		q.Cases = map[int]quarantinedCase{}
+	}
+	for _, c := range cases {
+		q.Cases[c.ID] = quarantinedCase{Title: c.Title, Score: c.Score}
+	}
+
+	if data, err = yaml.Marshal(q); err != nil {
+		return err
+	}
+	return download.WriteFile(file, data)
+}
+
+func flakyCommand() cli.Command {
+	return cli.Command{
+		Name:  "flaky",
+		Usage: "Find cases that alternate between passed and failed in recent runs",
+		Flags: []cli.Flag{
+			cli.IntFlag{
+				Name:  "project-id, p",
+				Usage: "TestRail project ID of the runs",
+			},
+			cli.IntFlag{
+				Name:  "suite-id, s",
+				Usage: "only look at runs of this suite",
+			},
+			cli.IntFlag{
+				Name:  "last",
+				Usage: "number of recent runs to look at",
+				Value: 20,
+			},
+			cli.Float64Flag{
+				Name:  "min-score",
+				Usage: "report cases flipping between passed and failed in at least this share of their runs, from 0 to 1",
+				Value: 0.2,
+			},
+			cli.StringFlag{
+				Name:  "quarantine",
+				Usage: "add the flaky cases to this quarantine file",
+			},
+			outputFlag,
+		},
+		Action: func(c *cli.Context) error {
+			if c.Int("project-id") == 0 {
+				return configErrorf("Must set --project-id to a non-zero integer")
+			}
+			if c.Int("last") < 2 {
+				return configErrorf("Must set --last to at least 2 runs")
+			}
+
+			client, err := newClient()
+			if err != nil {
+				return err
+			}
+			last := c.Int("last")
+			filter := testrail.RequestFilterForRun{Limit: &last}
+			if c.Int("suite-id") != 0 {
+				filter.SuiteID = []int{c.Int("suite-id")}
+			}
+			h, err := recentRuns(client, c.Int("project-id"), filter)
+			if err != nil {
+				return apiErrorf("Error getting runs: %s", err)
+			}
+
+			flaky := flakyCases(h, c.Float64("min-score"))
+			rows := [][]string{}
+			for _, f := range flaky {
+				rows = append(rows, []string{fmt.Sprintf("C%d", f.ID), f.Title, fmt.Sprint(f.Runs), fmt.Sprint(f.Failures), fmt.Sprint(f.Flips), fmt.Sprintf("%.2f", f.Score)})
+			}
+			if err := render(c.String("output"), flaky, []string{"CASE", "TITLE", "RUNS", "FAILED", "FLIPS", "SCORE"}, rows); err != nil {
+				return fmt.Errorf("Error printing flaky cases: %s", err)
+			}
+
+			if file := c.String("quarantine"); file != "" && len(flaky) > 0 {
+				if err := quarantine(file, flaky); err != nil {
+					return fmt.Errorf("Error writing quarantine file: %s", err)
+				}
+				fmt.Fprintf(os.Stderr, "Quarantined %d cases in %s\n", len(flaky), file)
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/educlos/testrail"
+	"github.com/stretchr/testify/assert"
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestFlaky(t *testing.T) {
+	s, stop := startFake(t)
+	defer stop()
+
+	dir, err := ioutil.TempDir("", "flaky")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// C11 alternates between passed and failed, C12 fails from the third run.
+	statuses := map[int][]int{
+		11: {1, 5, 1, 5},
+		12: {1, 1, 5, 5},
+	}
+	for i := 0; i < 4; i++ {
+		run := s.AddRun(1, 2, 11, 12)
+		s.Lock()
+		for j := range s.Tests {
+			if s.Tests[j].RunID == run.ID {
+				s.Tests[j].StatusID = statuses[s.Tests[j].CaseID][i]
+			}
+		}
+		s.Unlock()
+	}
+
+	client, err := newClient()
+	assert.NoError(t, err)
+	last := 20
+	h, err := recentRuns(client, 1, testrail.RequestFilterForRun{Limit: &last, SuiteID: []int{2}})
+	assert.NoError(t, err)
+	assert.Len(t, h.Runs, 4)
+	assert.Equal(t, []flakyCase{
+		{ID: 11, Title: "Login", Runs: 4, Failures: 2, Flips: 3, Score: 1},
+		{ID: 12, Title: "Logout", Runs: 4, Failures: 2, Flips: 1, Score: 1.0 / 3},
+	}, flakyCases(h, 0.2))
+	assert.Len(t, flakyCases(h, 0.5), 1)
+
+	file := filepath.Join(dir, "quarantine.yml")
+	assert.NoError(t, ioutil.WriteFile(file, []byte("cases:\n  7:\n    title: Signup\n    score: 0.5\n"), 0644))
+	assert.NoError(t, run("flaky", "--project-id", "1", "--last", "3", "--min-score", "0.5", "--quarantine", file))
+
+	data, err := ioutil.ReadFile(file)
+	assert.NoError(t, err)
+	var q quarantineFile
+	assert.NoError(t, yaml.Unmarshal(data, &q))
+	assert.Equal(t, map[int]quarantinedCase{
+		7:  {Title: "Signup", Score: 0.5},
+		11: {Title: "Login", Score: 1},
+		12: {Title: "Logout", Score: 0.5},
+	}, q.Cases)
+
+	assert.Equal(t, exitConfig, exitCode(run("flaky", "--last", "3")))
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+)
+
+// minWordSimilarity is how close two words must be to count as a match.
+const minWordSimilarity = 0.5
+
+// similarity scores how well text matches query, from 0 to 1. Each word of
+// the query scores by the closest word of the text, so word order, case and
+// small typos matter little.
+func similarity(query, text string) float64 {
+	q, t := words(query), words(text)
+	if len(q) == 0 || len(t) == 0 {
+		return 0
+	}
+
+	total := 0.0
+	for _, qw := range q {
+		best := 0.0
+		for _, tw := range t {
+			var s float64
+			switch {
+			case qw == tw:
+				s = 1
+			case strings.HasPrefix(tw, qw):
+				s = 0.9
+			default:
+				longest := len(qw)
+				if len(tw) > longest {
+					longest = len(tw)
+				}
+				s = 1 - float64(levenshtein(qw, tw))/float64(longest)
+			}
+			if s > best {
+				best = s
+			}
+		}
+		if best >= minWordSimilarity {
+			total += best
+		}
+	}
+	return total / float64(len(q))
+}
+
+// words splits text into lower case words at spaces, punctuation and the
+// humps of camelCase names such as TestLoginTimeout.
+func words(text string) []string {
+	ws := []string{}
+	var w []rune
+	prev := rune(0)
+	for _, r := range text {
+		switch {
+		case !unicode.IsLetter(r) && !unicode.IsDigit(r):
+			if len(w) > 0 {
+				ws = append(ws, string(w))
+			}
+			w = nil
+		case unicode.IsUpper(r) && unicode.IsLower(prev):
+			ws = append(ws, string(w))
+			w = []rune{unicode.ToLower(r)}
+		default:
+			w = append(w, unicode.ToLower(r))
+		}
+		prev = r
+	}
+	if len(w) > 0 {
+		ws = append(ws, string(w))
+	}
+	return ws
+}
+
+// levenshtein returns the number of single character edits turning a into b.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	row := make([]int, len(rb)+1)
+	for j := range row {
+		row[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		diag := row[0]
+		row[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			next := diag + cost
+			if row[j]+1 < next {
+				next = row[j] + 1
+			}
+			if row[j-1]+1 < next {
+				next = row[j-1] + 1
+			}
+			diag, row[j] = row[j], next
+		}
+	}
+	return row[len(rb)]
+}
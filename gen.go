@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/urfave/cli"
+
+	"github.com/docker/trailer/pkg/download"
+)
+
+// genCase is a case to generate a test for.
+type genCase struct {
+	ID    int
+	Title string
+}
+
+// genData is what test templates are executed with.
+type genData struct {
+	// Package is the name of the output directory, for languages that
+	// need one.
+	Package string
+	Cases   []genCase
+}
+
+// genLanguage is the default template and file name of a language.
+type genLanguage struct {
+	file     string
+	template string
+}
+
+// genLanguages are the languages gen has templates for. The test names
+// embed TestRailC<ID>, so their results upload without a case mapping.
+var genLanguages = map[string]genLanguage{
+	"go": {"testrail_test.go", `package {{.Package}}
+
+import "testing"
+{{range .Cases}}
+// testrail: C{{.ID}}
+func TestRailC{{.ID}}{{camel .Title}}(t *testing.T) {
+	t.Skip({{quote (print "not automated yet: " .Title)}})
+}
+{{end}}`},
+	"python": {"test_testrail.py", `import pytest
+{{range .Cases}}
+
+# testrail: C{{.ID}}
+def test_TestRailC{{.ID}}_{{snake .Title}}():
+    pytest.skip({{quote (print "not automated yet: " .Title)}})
+{{end}}`},
+	"javascript": {"testrail.test.js", `{{range .Cases}}// testrail: C{{.ID}}
+test.todo({{quote (printf "TestRailC%d %s" .ID .Title)}});
+{{end}}`},
+}
+
+// genFuncs turn case titles into identifiers and strings.
+var genFuncs = template.FuncMap{
+	"camel": camelCase,
+	"snake": snakeCase,
+	"quote": strconv.Quote,
+}
+
+// camelCase joins the words of title, each capitalized.
+func camelCase(title string) string {
+	var b strings.Builder
+	for _, w := range words(title) {
+		r := []rune(w)
+		b.WriteString(string(unicode.ToUpper(r[0])) + string(r[1:]))
+	}
+	return b.String()
+}
+
+// snakeCase joins the words of title with underscores.
+func snakeCase(title string) string {
+	return strings.Join(words(title), "_")
+}
+
+// manualCases returns the cases of the cases file not marked automated.
+func manualCases(s download.Suite) []genCase {
+	cases := []genCase{}
+	for id, e := range s.Cases {
+		if !e.Automated {
+			cases = append(cases, genCase{ID: id, Title: e.Title})
+		}
+	}
+	sort.Slice(cases, func(i, j int) bool { return cases[i].ID < cases[j].ID })
+	return cases
+}
+
+// generateTests executes the template text with the cases.
+func generateTests(text string, data genData) ([]byte, error) {
+	t, err := template.New("tests").Funcs(genFuncs).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	if err := t.Execute(&b, data); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func genCommand() cli.Command {
+	languages := []string{}
+	for name := range genLanguages {
+		languages = append(languages, name)
+	}
+	sort.Strings(languages)
+
+	return cli.Command{
+		Name:      "gen",
+		Usage:     "Generate skipped test stubs for the cases of a cases file that are not automated",
+		ArgsUsage: "--file cases file --out DIR",
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "file, f",
+				Usage: "cases file with the cases to generate tests for",
+			},
+			cli.StringFlag{
+				Name:  "lang",
+				Usage: "language of the tests, one of " + strings.Join(languages, ", "),
+				Value: "go",
+			},
+			cli.StringFlag{
+				Name:  "out, o",
+				Usage: "directory to write the tests to",
+			},
+			cli.StringFlag{
+				Name:  "template",
+				Usage: "Go text/template file to generate the tests with instead of the one of --lang, with the camel, snake and quote functions",
+			},
+			cli.BoolFlag{
+				Name:  "force",
+				Usage: "replace the file of generated tests if it exists",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			if c.String("file") == "" || c.String("out") == "" {
+				return configErrorf("Must set --file and --out")
+			}
+			lang, ok := genLanguages[c.String("lang")]
+			if !ok {
+				return configErrorf("--lang must be one of %s", strings.Join(languages, ", "))
+			}
+			text := lang.template
+			if file := c.String("template"); file != "" {
+				data, err := ioutil.ReadFile(file)
+				if err != nil {
+					return configErrorf("Error reading template: %s", err)
+				}
+				text = string(data)
+			}
+
+			s, err := download.Load(c.String("file"))
+			if err != nil {
+				return parseErrorf("Error reading cases file: %s", err)
+			}
+			cases := manualCases(s)
+			if len(cases) == 0 {
+				statusf("Every case is automated\n")
+				return nil
+			}
+
+			out, err := filepath.Abs(c.String("out"))
+			if err != nil {
+				return configErrorf("Error in --out: %s", err)
+			}
+			data, err := generateTests(text, genData{Package: snakeCase(filepath.Base(out)), Cases: cases})
+			if err != nil {
+				return configErrorf("Error generating tests: %s", err)
+			}
+
+			file := filepath.Join(out, lang.file)
+			if _, err := os.Stat(file); err == nil && !c.Bool("force") {
+				return configErrorf("%s exists, rerun with --force to replace it", file)
+			}
+			if err := os.MkdirAll(out, 0755); err != nil {
+				return fmt.Errorf("Error creating %s: %s", out, err)
+			}
+			if err := ioutil.WriteFile(file, data, 0644); err != nil {
+				return fmt.Errorf("Error writing tests: %s", err)
+			}
+			statusf("Generated %d tests in %s\n", len(cases), file)
+			return nil
+		},
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gen")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "cases.yaml")
+	assert.NoError(t, ioutil.WriteFile(file, []byte(`project_id: 1
+suite_id: 2
+cases:
+  11:
+    title: Login works
+    automated: true
+  12: Logout "everywhere"
+  13: Refund in 2 days
+`), 0644))
+	out := filepath.Join(dir, "e2e")
+
+	assert.NoError(t, run("gen", "--file", file, "--out", out))
+	data, err := ioutil.ReadFile(filepath.Join(out, "testrail_test.go"))
+	assert.NoError(t, err)
+	assert.Equal(t, `package e2e
+
+import "testing"
+
+// testrail: C12
+func TestRailC12LogoutEverywhere(t *testing.T) {
+	t.Skip("not automated yet: Logout \"everywhere\"")
+}
+
+// testrail: C13
+func TestRailC13RefundIn2Days(t *testing.T) {
+	t.Skip("not automated yet: Refund in 2 days")
+}
+`, string(data))
+
+	// The generated tests are annotated for scan.
+	found, err := scanGoFile(filepath.Join(out, "testrail_test.go"))
+	assert.NoError(t, err)
+	assert.Len(t, found, 2)
+
+	assert.Equal(t, exitConfig, exitCode(run("gen", "--file", file, "--out", out)))
+	assert.NoError(t, run("gen", "--file", file, "--out", out, "--lang", "python"))
+	data, err = ioutil.ReadFile(filepath.Join(out, "test_testrail.py"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "def test_TestRailC13_refund_in_2_days():\n    pytest.skip(\"not automated yet: Refund in 2 days\")\n")
+
+	template := filepath.Join(dir, "template")
+	assert.NoError(t, ioutil.WriteFile(template, []byte("{{range .Cases}}{{.ID}} {{snake .Title}}\n{{end}}"), 0644))
+	assert.NoError(t, run("gen", "--file", file, "--out", out, "--lang", "javascript", "--template", template))
+	data, err = ioutil.ReadFile(filepath.Join(out, "testrail.test.js"))
+	assert.NoError(t, err)
+	assert.Equal(t, "12 logout_everywhere\n13 refund_in_2_days\n", string(data))
+
+	assert.Equal(t, exitConfig, exitCode(run("gen", "--file", file, "--out", out, "--lang", "cobol")))
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/urfave/cli"
+)
+
+var githubSummaryFlag = cli.BoolTFlag{
+	Name:  "github-summary",
+	Usage: "in GitHub Actions, annotate the failures and write a job summary, set to false to turn off",
+}
+
+// inGitHubActions reports whether trailer runs in a GitHub Actions job.
+func inGitHubActions() bool {
+	return os.Getenv("GITHUB_ACTIONS") == "true"
+}
+
+// githubAnnotations prints an error workflow command for every failure, which
+// GitHub shows on the summary page of the workflow run.
+func githubAnnotations(r uploadReport) {
+	for _, s := range r.Suites {
+		for _, c := range s.Cases {
+			if c.Status != "failed" {
+				continue
+			}
+			title := fmt.Sprintf("C%d %s", c.ID, c.Test)
+			fmt.Printf("::error title=%s::%s\n", escapeProperty(title), escapeData(c.Excerpt))
+		}
+	}
+}
+
+// escapeData escapes the message of a workflow command.
+func escapeData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+// escapeProperty escapes a property of a workflow command.
+func escapeProperty(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(s)
+}
+
+// githubJobSummary appends the report as Markdown to the job summary at
+// GITHUB_STEP_SUMMARY.
+func githubJobSummary(r uploadReport) error {
+	file := os.Getenv("GITHUB_STEP_SUMMARY")
+	if file == "" {
+		return nil
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "### TestRail [run %d](%s)\n\n", r.RunID, r.RunURL)
+	if r.Dry {
+		b.WriteString("Dry run, the results were not uploaded.\n\n")
+	}
+	b.WriteString(markdownRow([]string{"Suite", "Passed", "Failed", "Skipped", "Not uploaded"}) + "\n")
+	b.WriteString(markdownRow([]string{"---", "---", "---", "---", "---"}) + "\n")
+	for _, s := range r.Suites {
+		b.WriteString(markdownRow([]string{s.Name, fmt.Sprint(s.Counts.Passed), fmt.Sprint(s.Counts.Failed), fmt.Sprint(s.Counts.Skipped), fmt.Sprint(s.Counts.NotUploaded)}) + "\n")
+	}
+	b.WriteString(markdownRow([]string{"**Total**", fmt.Sprint(r.Totals.Passed), fmt.Sprint(r.Totals.Failed), fmt.Sprint(r.Totals.Skipped), fmt.Sprint(r.Totals.NotUploaded)}) + "\n")
+
+	failures := newUploadSummary(r)
+	if len(failures.Failures) > 0 {
+		b.WriteString("\n**Failures**\n\n")
+		for _, f := range failures.Failures {
+			fmt.Fprintf(&b, "- [C%d](%s) %s: %s\n", f.ID, f.URL, f.Test, f.Message)
+		}
+		if failures.MoreFailures > 0 {
+			fmt.Fprintf(&b, "- and %d more\n", failures.MoreFailures)
+		}
+	}
+
+	f, err := os.OpenFile(file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(b.String() + "\n"); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
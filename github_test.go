@@ -0,0 +1,49 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEscapeWorkflowCommand(t *testing.T) {
+	assert.Equal(t, "100%25 failed%0Aat line 3", escapeData("100% failed\nat line 3"))
+	assert.Equal(t, "C1 a%3A b%2C c", escapeProperty("C1 a: b, c"))
+}
+
+func TestGitHubSummary(t *testing.T) {
+	s, stop := startFake(t)
+	defer stop()
+
+	dir, err := ioutil.TempDir("", "github")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	summary := filepath.Join(dir, "summary.md")
+	defer os.Unsetenv("GITHUB_ACTIONS")
+	defer os.Unsetenv("GITHUB_STEP_SUMMARY")
+	os.Setenv("GITHUB_ACTIONS", "true")
+	os.Setenv("GITHUB_STEP_SUMMARY", summary)
+
+	runID := strconv.Itoa(s.AddRun(1, 2, 11, 12).ID)
+	report := writeReport(t, dir, `<testsuite name="accounts" tests="2">
+  <testcase name="TestRailC11 login" time="1"></testcase>
+  <testcase name="TestRailC12 logout" time="2"><failure>still logged in</failure></testcase>
+</testsuite>`)
+
+	assert.NoError(t, run("upload", "--run-id", runID, report))
+	data, err := ioutil.ReadFile(summary)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "/index.php?/runs/view/"+runID+")")
+	assert.Contains(t, string(data), "| accounts | 1 | 1 | 0 | 0 |")
+	assert.Contains(t, string(data), "TestRailC12 logout: still logged in")
+
+	assert.NoError(t, os.Remove(summary))
+	assert.NoError(t, run("upload", "--run-id", runID, "--github-summary=false", report))
+	_, err = os.Stat(summary)
+	assert.True(t, os.IsNotExist(err))
+}
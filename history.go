@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/educlos/testrail"
+)
+
+// untestedStatus is TestRail's built-in status of tests without results.
+const untestedStatus = 3
+
+// freshTests fetches the tests of a run past the API cache, which keeps the
+// statuses the tests had when they were first fetched.
+func freshTests(client testrailAPI, runID int) ([]testrail.Test, error) {
+	var tests []testrail.Test
+	err := client.send("GET", fmt.Sprintf("get_tests/%d", runID), nil, &tests)
+	return tests, err
+}
+
+// resultsPageSize is the most results TestRail returns for one request.
+const resultsPageSize = 250
+
+// runResults returns every result of the run, latest first, requesting them
+// a page at a time.
+func runResults(client testrailAPI, runID int) ([]testrail.Result, error) {
+	results := []testrail.Result{}
+	for offset := 0; ; offset += resultsPageSize {
+		limit, offset := resultsPageSize, offset
+		page, err := client.GetResultsForRun(runID, testrail.RequestFilterForRunResults{Limit: &limit, Offest: &offset})
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, page...)
+		if len(page) < resultsPageSize {
+			return results, nil
+		}
+	}
+}
+
+// runHistory is the statuses of the tests of recent runs, oldest run first.
+type runHistory struct {
+	Runs  []testrail.Run
+	Tests [][]testrail.Test
+}
+
+// recentRuns fetches the runs of the project matching filter with their
+// tests.
+func recentRuns(client testrailAPI, projectID int, filter testrail.RequestFilterForRun) (runHistory, error) {
+	runs, err := client.GetRuns(projectID, filter)
+	if err != nil {
+		return runHistory{}, err
+	}
+	sort.Slice(runs, func(i, j int) bool {
+		if runs[i].CreatedOn != runs[j].CreatedOn {
+			return runs[i].CreatedOn < runs[j].CreatedOn
+		}
+		return runs[i].ID < runs[j].ID
+	})
+
+	h := runHistory{Runs: runs}
+	for _, run := range runs {
+		tests, err := freshTests(client, run.ID)
+		if err != nil {
+			return runHistory{}, fmt.Errorf("run %d: %s", run.ID, err)
+		}
+		h.Tests = append(h.Tests, tests)
+	}
+	return h, nil
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/urfave/cli"
+
+	"github.com/docker/trailer/spec"
+)
+
+var htmlReportFlag = cli.StringFlag{
+	Name:  "html-report",
+	Usage: "write a summary page of the results to this HTML file, for CI systems to publish",
+}
+
+// excerptLines is how many lines of a failure message the HTML report shows.
+const excerptLines = 20
+
+// uploadReport is the data of the HTML report of an upload.
+type uploadReport struct {
+	RunID  int
+	RunURL string
+	// Dry is set when the results were parsed but not uploaded.
+	Dry    bool
+	Totals reportCounts
+	Suites []reportSuite
+}
+
+type reportCounts struct {
+	Passed, Failed, Skipped, NotUploaded int
+}
+
+type reportSuite struct {
+	Name   string
+	Counts reportCounts
+	Cases  []reportCase
+}
+
+type reportCase struct {
+	ID       int
+	URL      string
+	Test     string
+	Status   string
+	Excerpt  string
+	Uploaded bool
+}
+
+// newUploadReport groups the parsed results by JUnit test suite. The dropped
+// results, such as those for cases TestRail rejected, are marked as not
+// uploaded.
+func newUploadReport(baseURL string, runID int, parsed map[int]spec.Update, dropped map[int]bool, dry bool) uploadReport {
+	r := uploadReport{RunID: runID, RunURL: fmt.Sprintf("%s/index.php?/runs/view/%d", baseURL, runID), Dry: dry}
+
+	suites := map[string]*reportSuite{}
+	for id, u := range parsed {
+		s, ok := suites[u.Suite]
+		if !ok {
+			s = &reportSuite{Name: u.Suite}
+			suites[u.Suite] = s
+		}
+
+		c := reportCase{
+			ID:       id,
+			URL:      fmt.Sprintf("%s/index.php?/cases/view/%d", baseURL, id),
+			Test:     u.Test,
+			Status:   outcomeNames[u.Status],
+			Uploaded: !dropped[id] && !dry,
+		}
+		switch u.Status {
+		case spec.Passed:
+			s.Counts.Passed++
+		case spec.Failed:
+			s.Counts.Failed++
+			c.Excerpt = excerpt(u.Failure)
+		case spec.Skipped:
+			s.Counts.Skipped++
+		}
+		if dropped[id] {
+			s.Counts.NotUploaded++
+		}
+		s.Cases = append(s.Cases, c)
+	}
+
+	for _, s := range suites {
+		sort.Slice(s.Cases, func(i, j int) bool { return s.Cases[i].ID < s.Cases[j].ID })
+		r.Totals.Passed += s.Counts.Passed
+		r.Totals.Failed += s.Counts.Failed
+		r.Totals.Skipped += s.Counts.Skipped
+		r.Totals.NotUploaded += s.Counts.NotUploaded
+		r.Suites = append(r.Suites, *s)
+	}
+	sort.Slice(r.Suites, func(i, j int) bool { return r.Suites[i].Name < r.Suites[j].Name })
+	return r
+}
+
+// excerpt returns the start of a failure message.
+func excerpt(message string) string {
+	lines := strings.Split(strings.TrimSpace(message), "\n")
+	if len(lines) > excerptLines {
+		lines = append(lines[:excerptLines], "...")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// writeHTMLReport renders the report to a self-contained HTML file.
+func writeHTMLReport(file string, r uploadReport) error {
+	var b bytes.Buffer
+	if err := reportTemplate.Execute(&b, r); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, b.Bytes(), 0644)
+}
+
+var reportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Run {{.RunID}} results</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ddd; padding: 4px 8px; text-align: left; vertical-align: top; }
+.passed { color: #2e7d32; }
+.failed { color: #c62828; font-weight: bold; }
+.skipped { color: #757575; }
+pre { margin: 0; max-width: 60em; white-space: pre-wrap; font-size: 0.9em; }
+</style>
+</head>
+<body>
+<h1>Results of <a href="{{.RunURL}}">run {{.RunID}}</a></h1>
+{{if .Dry}}<p>Dry run, the results were not uploaded.</p>{{end}}
+<p>
+<span class="passed">{{.Totals.Passed}} passed</span>,
+<span class="failed">{{.Totals.Failed}} failed</span>,
+<span class="skipped">{{.Totals.Skipped}} skipped</span>{{if .Totals.NotUploaded}},
+{{.Totals.NotUploaded}} not uploaded{{end}}
+</p>
+<table>
+<tr><th>Suite</th><th>Passed</th><th>Failed</th><th>Skipped</th><th>Not uploaded</th></tr>
+{{range .Suites}}<tr><td><a href="#{{.Name}}">{{.Name}}</a></td><td>{{.Counts.Passed}}</td><td>{{.Counts.Failed}}</td><td>{{.Counts.Skipped}}</td><td>{{.Counts.NotUploaded}}</td></tr>
+{{end}}</table>
+{{range .Suites}}<h2 id="{{.Name}}">{{.Name}}</h2>
+<table>
+<tr><th>Case</th><th>Test</th><th>Result</th><th>Failure</th></tr>
+{{range .Cases}}<tr><td><a href="{{.URL}}">C{{.ID}}</a></td><td>{{.Test}}</td><td class="{{.Status}}">{{.Status}}{{if and (not .Uploaded) (not $.Dry)}} (not uploaded){{end}}</td><td>{{if .Excerpt}}<pre>{{.Excerpt}}</pre>{{end}}</td></tr>
+{{end}}</table>
+{{end}}</body>
+</html>
+`))
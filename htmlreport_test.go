@@ -0,0 +1,56 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/docker/trailer/spec"
+)
+
+func TestUploadReport(t *testing.T) {
+	parsed := map[int]spec.Update{
+		1: {Status: spec.Passed, Suite: "accounts", Test: "TestRailC1 login"},
+		2: {Status: spec.Failed, Suite: "accounts", Test: "TestRailC2 logout", Message: "nightly\n\nstill logged in", Failure: "still logged in"},
+		3: {Status: spec.Skipped, Suite: "billing", Test: "TestRailC3 refund"},
+	}
+
+	r := newUploadReport("https://testrail", 7, parsed, map[int]bool{3: true}, false)
+	assert.Equal(t, "https://testrail/index.php?/runs/view/7", r.RunURL)
+	assert.Equal(t, reportCounts{Passed: 1, Failed: 1, Skipped: 1, NotUploaded: 1}, r.Totals)
+	assert.Len(t, r.Suites, 2)
+	assert.Equal(t, "accounts", r.Suites[0].Name)
+	assert.Equal(t, []reportCase{
+		{ID: 1, URL: "https://testrail/index.php?/cases/view/1", Test: "TestRailC1 login", Status: "passed", Uploaded: true},
+		{ID: 2, URL: "https://testrail/index.php?/cases/view/2", Test: "TestRailC2 logout", Status: "failed", Excerpt: "still logged in", Uploaded: true},
+	}, r.Suites[0].Cases)
+	assert.False(t, r.Suites[1].Cases[0].Uploaded)
+}
+
+func TestUploadHTMLReport(t *testing.T) {
+	s, stop := startFake(t)
+	defer stop()
+
+	dir, err := ioutil.TempDir("", "html")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	runID := strconv.Itoa(s.AddRun(1, 2, 11, 12).ID)
+	report := writeReport(t, dir, `<testsuite name="accounts" tests="2">
+  <testcase name="TestRailC11 login" time="1"></testcase>
+  <testcase name="TestRailC12 logout" time="2"><failure>still &lt;logged&gt; in</failure></testcase>
+</testsuite>`)
+
+	page := filepath.Join(dir, "report.html")
+	assert.NoError(t, run("upload", "--run-id", runID, "--html-report", page, report))
+
+	data, err := ioutil.ReadFile(page)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "/index.php?/runs/view/"+runID)
+	assert.Contains(t, string(data), "/index.php?/cases/view/12")
+	assert.Contains(t, string(data), "still &lt;logged&gt; in")
+}
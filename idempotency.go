@@ -0,0 +1,72 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/urfave/cli"
+
+	"github.com/docker/trailer/spec"
+)
+
+var idempotentFlag = cli.BoolFlag{
+	Name:  "idempotent",
+	Usage: "mark the results with a fingerprint of the upload and skip those the run already has, so running the same upload again adds nothing",
+}
+
+// markerPrefix starts the line added to the comments of idempotent uploads.
+const markerPrefix = "trailer-upload: "
+
+// uploadFingerprint identifies an upload of updates to the run. The results
+// include their version, so uploads of the same report for different builds
+// differ.
+func uploadFingerprint(runID int, updates *spec.Updates) (string, error) {
+	payload, err := json.Marshal(updates.PayloadFor(updates.SortedCaseIDs()))
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%d\n", runID)
+	hash.Write(payload)
+	return hex.EncodeToString(hash.Sum(nil))[:16], nil
+}
+
+// markUpload sets the marker of updates to their fingerprint and removes the
+// results the run already has with it, so an upload that was cut short only
+// sends what is missing. It returns how many results it removed.
+func markUpload(client testrailAPI, runID int, updates *spec.Updates) (int, error) {
+	fingerprint, err := uploadFingerprint(runID, updates)
+	if err != nil {
+		return 0, err
+	}
+	updates.Marker = markerPrefix + fingerprint
+
+	tests, err := client.GetTests(runID)
+	if err != nil {
+		return 0, err
+	}
+	caseIDs := map[int]int{}
+	for _, test := range tests {
+		caseIDs[test.ID] = test.CaseID
+	}
+
+	results, err := runResults(client, runID)
+	if err != nil {
+		return 0, err
+	}
+	skipped := 0
+	for _, result := range results {
+		caseID, ok := caseIDs[result.TestID]
+		if !ok || !strings.Contains(result.Comment, updates.Marker) {
+			continue
+		}
+		if _, ok := updates.ResultMap[caseID]; ok {
+			updates.RemoveResult(caseID)
+			skipped++
+		}
+	}
+	return skipped, nil
+}
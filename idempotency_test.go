@@ -0,0 +1,67 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/docker/trailer/internal/faketestrail"
+)
+
+func TestUploadIdempotent(t *testing.T) {
+	s, stop := startFake(t)
+	defer stop()
+
+	dir, err := ioutil.TempDir("", "idempotent")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	runID := strconv.Itoa(s.AddRun(1, 2, 11, 12).ID)
+	report := writeReport(t, dir, `<testsuite name="accounts" tests="2">
+  <testcase name="TestRailC11 login" time="1"></testcase>
+  <testcase name="TestRailC12 logout" time="2"><failure>still logged in</failure></testcase>
+</testsuite>`)
+	results := func() []string {
+		s.Lock()
+		defer s.Unlock()
+		comments := []string{}
+		for _, r := range s.Results {
+			comments = append(comments, r.Comment)
+		}
+		return comments
+	}
+
+	upload := []string{"upload", "--idempotent", "--run-id", runID, "--comment", "nightly"}
+	assert.NoError(t, run(append(upload, report)...))
+	first := results()
+	assert.Len(t, first, 2)
+	assert.Regexp(t, `^trailer-upload: [0-9a-f]{16}$`, first[0])
+	assert.Regexp(t, `^nightly\n\nstill logged in\n\ntrailer-upload: [0-9a-f]{16}$`, first[1])
+
+	// An upload cut short after C11 only sends C12 again.
+	s.Lock()
+	s.Results = s.Results[:1]
+	s.Unlock()
+	assert.NoError(t, run(append(upload, report)...))
+	assert.NoError(t, run(append(upload, report)...))
+	assert.Equal(t, first, results())
+
+	assert.NoError(t, run(append(upload, "--result-version", "2", report)...))
+	assert.Len(t, results(), 4)
+
+	// Markers beyond the first page TestRail returns are found too.
+	s.Lock()
+	for _, test := range s.Tests {
+		if strconv.Itoa(test.RunID) == runID && test.CaseID == 12 {
+			for i := 0; i < 300; i++ {
+				s.Results = append(s.Results, faketestrail.Result{ID: 10000 + i, TestID: test.ID, CaseID: 12, StatusID: 1})
+			}
+		}
+	}
+	s.Unlock()
+	assert.NoError(t, run(append(upload, "--result-version", "2", report)...))
+	assert.Len(t, results(), 304)
+}
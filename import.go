@@ -0,0 +1,312 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/educlos/testrail"
+	"github.com/urfave/cli"
+
+	"github.com/docker/trailer/pkg/download"
+)
+
+// importedCase is a case read from an import source along with the path of
+// section names it belongs under.
+type importedCase struct {
+	Path  []string
+	Entry download.Case
+}
+
+// sectionSeparator splits section paths in CSV sources.
+const sectionSeparator = ">"
+
+// parseImportFile reads cases from a CSV, Markdown or Gherkin file, picking
+// the parser from the file extension.
+func parseImportFile(file string) ([]importedCase, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".csv":
+		return parseImportCSV(string(data))
+	case ".md", ".markdown":
+		return parseImportMarkdown(string(data)), nil
+	case ".feature":
+		return parseImportGherkin(string(data)), nil
+	default:
+		return nil, fmt.Errorf("unsupported import file type: %s", file)
+	}
+}
+
+// parseImportCSV reads cases from a CSV file that has the cases manifest
+// columns plus a section column holding paths like "Parent > Child".
+func parseImportCSV(data string) ([]importedCase, error) {
+	entries, err := parseCaseCSV(data)
+	if err != nil {
+		return nil, err
+	}
+
+	cases := []importedCase{}
+	for _, e := range entries {
+		path := []string{}
+		for _, name := range strings.Split(e.Section, sectionSeparator) {
+			if name = strings.TrimSpace(name); name != "" {
+				path = append(path, name)
+			}
+		}
+		cases = append(cases, importedCase{Path: path, Entry: e})
+	}
+
+	return cases, nil
+}
+
+// parseImportMarkdown reads an outline where headings are sections, nested
+// by heading level, and list items are case titles.
+func parseImportMarkdown(data string) []importedCase {
+	cases := []importedCase{}
+	path := []string{}
+
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(line, "#"):
+			level := len(line) - len(strings.TrimLeft(line, "#"))
+			name := strings.TrimSpace(line[level:])
+			if level > len(path) {
+				level = len(path) + 1
+			}
+			path = append(path[:level-1:level-1], name)
+		case strings.HasPrefix(line, "- "), strings.HasPrefix(line, "* "):
+			title := strings.TrimSpace(line[2:])
+			if title != "" {
+				cases = append(cases, importedCase{Path: path, Entry: download.Case{Title: title}})
+			}
+		}
+	}
+
+	return cases
+}
+
+// parseImportGherkin reads a feature file, turning the feature and any rules
+// into sections and each scenario into a case.
+func parseImportGherkin(data string) []importedCase {
+	cases := []importedCase{}
+	path := []string{}
+
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		keyword, value := line, ""
+		if i := strings.Index(line, ":"); i >= 0 {
+			keyword, value = strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+1:])
+		}
+
+		switch keyword {
+		case "Feature":
+			path = []string{value}
+		case "Rule":
+			if len(path) > 0 {
+				path = append(path[:1:1], value)
+			}
+		case "Scenario", "Example", "Scenario Outline", "Scenario Template":
+			cases = append(cases, importedCase{Path: path, Entry: download.Case{Title: value}})
+		}
+	}
+
+	return cases
+}
+
+// sectionTree resolves section paths to IDs within a suite, creating missing
+// sections unless it is a dry run.
+type sectionTree struct {
+	client    testrailAPI
+	projectID int
+	suiteID   int
+	dry       bool
+	ids       map[string]int
+	nextDryID int
+}
+
+func newSectionTree(client testrailAPI, projectID, suiteID int, dry bool) (*sectionTree, error) {
+	sections, err := client.GetSections(projectID, suiteID)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &sectionTree{
+		client:    client,
+		projectID: projectID,
+		suiteID:   suiteID,
+		dry:       dry,
+		ids:       map[string]int{},
+		nextDryID: -1,
+	}
+	for id, path := range sectionPaths(sections) {
+		t.ids[sectionKey(path)] = id
+	}
+
+	return t, nil
+}
+
+// sectionPaths returns the names of the sections and of their parents by
+// section ID, the top-level section first.
+func sectionPaths(sections []testrail.Section) map[int][]string {
+	byID := map[int]testrail.Section{}
+	for _, s := range sections {
+		byID[s.ID] = s
+	}
+
+	paths := map[int][]string{}
+	for _, s := range sections {
+		path := []string{s.Name}
+		for parent := s.ParentID; parent != 0; parent = byID[parent].ParentID {
+			path = append([]string{byID[parent].Name}, path...)
+		}
+		paths[s.ID] = path
+	}
+	return paths
+}
+
+// sectionKey joins a section path into a map key.
+func sectionKey(path []string) string {
+	return strings.Join(path, " "+sectionSeparator+" ")
+}
+
+// resolve returns the ID of the section at path, creating it and any missing
+// parents. Dry runs hand out negative placeholder IDs instead.
+func (t *sectionTree) resolve(path []string) (int, error) {
+	if len(path) == 0 {
+		return 0, fmt.Errorf("case has no section")
+	}
+
+	key := sectionKey(path)
+	if id, ok := t.ids[key]; ok {
+		return id, nil
+	}
+
+	parentID := 0
+	if len(path) > 1 {
+		var err error
+		if parentID, err = t.resolve(path[:len(path)-1]); err != nil {
+			return 0, err
+		}
+	}
+
+	if t.dry {
+		statusf("Would create section %s\n", key)
+		t.ids[key] = t.nextDryID
+		t.nextDryID--
+		return t.ids[key], nil
+	}
+
+	section, err := t.client.AddSection(t.projectID, testrail.SendableSection{
+		Name:     path[len(path)-1],
+		SuiteID:  t.suiteID,
+		ParentID: parentID,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("creating section %s: %s", key, err)
+	}
+
+	statusf("Created section %d: %s\n", section.ID, key)
+	t.ids[key] = section.ID
+	return section.ID, nil
+}
+
+func importCommand() cli.Command {
+	return cli.Command{
+		Name:  "import",
+		Usage: "Bulk import sections and cases from CSV, Markdown or Gherkin files",
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "file, f",
+				Usage: "file to import cases from (.csv, .md or .feature)",
+			},
+			cli.IntFlag{
+				Name:  "project-id, p",
+				Usage: "TestRail project ID to import cases into",
+			},
+			cli.IntFlag{
+				Name:  "suite-id, s",
+				Usage: "TestRail suite ID to import cases into",
+			},
+			cli.BoolFlag{
+				Name:  "dry, d",
+				Usage: "print the sections and cases that would be created without creating them",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			projectID, suiteID, err := requireSuite(c)
+			if err != nil {
+				return err
+			}
+			dry := c.Bool("dry")
+
+			if c.String("file") == "" {
+				return configErrorf("Must specify an input file")
+			}
+
+			cases, err := parseImportFile(c.String("file"))
+			if err != nil {
+				return parseErrorf("Error parsing import file: %s", err)
+			}
+
+			client, err := newClient()
+			if err != nil {
+				return err
+			}
+			tree, err := newSectionTree(client, projectID, suiteID, dry)
+			if err != nil {
+				return apiErrorf("Error getting sections: %s", err)
+			}
+
+			existing, err := client.GetCases(projectID, suiteID)
+			if err != nil {
+				return apiErrorf("Error getting cases: %s", err)
+			}
+			titles := map[string]bool{}
+			for _, e := range existing {
+				titles[fmt.Sprintf("%d/%s", e.SectionID, e.Title)] = true
+			}
+
+			created, skipped := 0, 0
+			for _, ic := range cases {
+				if ic.Entry.Title == "" {
+					continue
+				}
+
+				sectionID, err := tree.resolve(ic.Path)
+				if err != nil {
+					return apiErrorf("Error importing case %q: %s", ic.Entry.Title, err)
+				}
+
+				key := fmt.Sprintf("%d/%s", sectionID, ic.Entry.Title)
+				if titles[key] {
+					skipped++
+					continue
+				}
+				titles[key] = true
+
+				if dry {
+					statusf("Would create case %q in %s\n", ic.Entry.Title, sectionKey(ic.Path))
+				} else {
+					newCase, err := client.AddCase(sectionID, ic.Entry.Sendable())
+					if err != nil {
+						return apiErrorf("Error creating case %q: %s", ic.Entry.Title, err)
+					}
+					statusf("Created case C%d: %s\n", newCase.ID, newCase.Title)
+				}
+				created++
+			}
+
+			statusf("%d cases imported, %d already existed\n", created, skipped)
+			return nil
+		},
+	}
+}
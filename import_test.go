@@ -0,0 +1,52 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/docker/trailer/pkg/download"
+)
+
+func TestParseImportMarkdown(t *testing.T) {
+	cases := parseImportMarkdown(`# Accounts
+- Sign up with email
+## Login
+- Login works
+* Login fails with a bad password
+# Billing
+- Pay with card
+`)
+
+	assert.Equal(t, []importedCase{
+		{Path: []string{"Accounts"}, Entry: download.Case{Title: "Sign up with email"}},
+		{Path: []string{"Accounts", "Login"}, Entry: download.Case{Title: "Login works"}},
+		{Path: []string{"Accounts", "Login"}, Entry: download.Case{Title: "Login fails with a bad password"}},
+		{Path: []string{"Billing"}, Entry: download.Case{Title: "Pay with card"}},
+	}, cases)
+}
+
+func TestParseImportGherkin(t *testing.T) {
+	cases := parseImportGherkin(`Feature: Login
+  Scenario: Valid credentials
+    Given a user
+  Rule: Lockout
+    @slow
+    Scenario Outline: Repeated failures
+      Examples:
+        | attempts |
+`)
+
+	assert.Equal(t, []importedCase{
+		{Path: []string{"Login"}, Entry: download.Case{Title: "Valid credentials"}},
+		{Path: []string{"Login", "Lockout"}, Entry: download.Case{Title: "Repeated failures"}},
+	}, cases)
+}
+
+func TestParseImportCSV(t *testing.T) {
+	cases, err := parseImportCSV("section,title\nAccounts > Login,Login works\n")
+	assert.NoError(t, err)
+	assert.Equal(t, []importedCase{
+		{Path: []string{"Accounts", "Login"}, Entry: download.Case{Title: "Login works", Section: "Accounts > Login"}},
+	}, cases)
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/urfave/cli"
+
+	"github.com/docker/trailer/pkg/config"
+	"github.com/docker/trailer/pkg/download"
+	"github.com/docker/trailer/pkg/secrets"
+)
+
+// prompter asks questions on w and reads the answers from r.
+type prompter struct {
+	r *bufio.Reader
+	w io.Writer
+}
+
+// ask prints question and returns the answer, or def if the answer is empty.
+func (p prompter) ask(question, def string) (string, error) {
+	if def != "" {
+		fmt.Fprintf(p.w, "%s [%s]: ", question, def)
+	} else {
+		fmt.Fprintf(p.w, "%s: ", question)
+	}
+
+	line, err := p.r.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+
+	if answer := strings.TrimSpace(line); answer != "" {
+		return answer, nil
+	}
+	return def, nil
+}
+
+// askID asks for one of the IDs in valid until a valid one is given.
+func (p prompter) askID(question string, def int, valid map[int]bool) (int, error) {
+	d := ""
+	if valid[def] {
+		d = strconv.Itoa(def)
+	}
+
+	for {
+		answer, err := p.ask(question, d)
+		if err != nil {
+			return 0, err
+		}
+
+		id, err := strconv.Atoi(answer)
+		if err == nil && valid[id] {
+			return id, nil
+		}
+		fmt.Fprintf(p.w, "%q is not one of the listed IDs\n", answer)
+	}
+}
+
+// confirm asks a yes or no question, taking anything but yes as no.
+func (p prompter) confirm(question string) (bool, error) {
+	answer, err := p.ask(question+" [y/N]", "")
+	if err != nil {
+		return false, err
+	}
+	answer = strings.ToLower(answer)
+	return answer == "y" || answer == "yes", nil
+}
+
+func initCommand() cli.Command {
+	return cli.Command{
+		Name:  "init",
+		Usage: "Interactively write the config file and an initial cases file",
+		Action: func(c *cli.Context) error {
+			file := config.File()
+			cfg, err := config.Load(file)
+			if err != nil {
+				return configErrorf("Error reading config file: %s", err)
+			}
+
+			if err := runInit(prompter{r: bufio.NewReader(os.Stdin), w: os.Stdout}, &cfg); err != nil {
+				return err
+			}
+
+			if err := config.Save(file, cfg); err != nil {
+				return fmt.Errorf("Error writing config file: %s", err)
+			}
+			statusf("Wrote %s\n", file)
+
+			return nil
+		},
+	}
+}
+
+// runInit walks through the settings in cfg, using the current values as
+// defaults, and writes the cases file for the chosen suite.
+func runInit(p prompter, cfg *config.Config) error {
+	var err error
+
+	url := cfg.URL
+	if url == "" {
+		url = defaultTestrailURL
+	}
+	if cfg.URL, err = p.ask("TestRail URL", url); err != nil {
+		return err
+	}
+	if cfg.Username, err = p.ask("Username", cfg.Username); err != nil {
+		return err
+	}
+	token := ""
+	if cfg.Token != "" {
+		token = "keep current"
+	}
+	if token, err = p.ask("API token", token); err != nil {
+		return err
+	}
+	if token != "keep current" {
+		cfg.Token = token
+	}
+
+	if token, err = secrets.Resolve(cfg.Token); err != nil {
+		return configErrorf("Error reading the API token from %s: %s", cfg.Token, err)
+	}
+	client, err := newClientFor(cfg.URL, cfg.Username, token)
+	if err != nil {
+		return err
+	}
+
+	projects, err := client.GetProjects(false)
+	if err != nil {
+		return fmt.Errorf("Error getting projects: %s", err)
+	}
+	if len(projects) == 0 {
+		return fmt.Errorf("No active projects are visible to %s", cfg.Username)
+	}
+
+	valid := map[int]bool{}
+	for _, project := range projects {
+		fmt.Fprintf(p.w, "%6d  %s\n", project.ID, project.Name)
+		valid[project.ID] = true
+	}
+	if cfg.ProjectID, err = p.askID("Project ID", cfg.ProjectID, valid); err != nil {
+		return err
+	}
+
+	suites, err := client.GetSuites(cfg.ProjectID)
+	if err != nil {
+		return fmt.Errorf("Error getting suites: %s", err)
+	}
+	if len(suites) == 0 {
+		return fmt.Errorf("Project %d has no suites", cfg.ProjectID)
+	}
+
+	valid = map[int]bool{}
+	for _, suite := range suites {
+		fmt.Fprintf(p.w, "%6d  %s\n", suite.ID, suite.Name)
+		valid[suite.ID] = true
+	}
+	if len(suites) == 1 {
+		cfg.SuiteID = suites[0].ID
+	} else if cfg.SuiteID, err = p.askID("Suite ID", cfg.SuiteID, valid); err != nil {
+		return err
+	}
+
+	casesFile := cfg.CasesFile
+	if casesFile == "" {
+		casesFile = "cases.yml"
+	}
+	if cfg.CasesFile, err = p.ask("Cases file", casesFile); err != nil {
+		return err
+	}
+
+	cases, err := client.GetCases(cfg.ProjectID, cfg.SuiteID)
+	if err != nil {
+		return fmt.Errorf("Error getting cases: %s", err)
+	}
+
+	s := download.Suite{
+		ProjectID: cfg.ProjectID,
+		SuiteID:   cfg.SuiteID,
+		Cases:     map[int]download.Entry{},
+		Base:      map[int]string{},
+	}
+	for _, c := range cases {
+		s.SetTitle(c.ID, c.Title)
+		s.Base[c.ID] = c.Title
+	}
+
+	if err := download.Save(cfg.CasesFile, s); err != nil {
+		return fmt.Errorf("Error writing cases file: %s", err)
+	}
+	fmt.Fprintf(p.w, "Wrote %d cases to %s\n", len(cases), cfg.CasesFile)
+
+	return nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrompterAsk(t *testing.T) {
+	testcases := []struct {
+		input    string
+		def      string
+		expected string
+	}{
+		{input: "answer\n", def: "", expected: "answer"},
+		{input: "  answer  \n", def: "default", expected: "answer"},
+		{input: "\n", def: "default", expected: "default"},
+		{input: "answer", def: "", expected: "answer"},
+	}
+
+	for _, testcase := range testcases {
+		p := prompter{r: bufio.NewReader(strings.NewReader(testcase.input)), w: &bytes.Buffer{}}
+		answer, err := p.ask("Question", testcase.def)
+		assert.NoError(t, err)
+		assert.Equal(t, testcase.expected, answer)
+	}
+}
+
+func TestPrompterAskID(t *testing.T) {
+	valid := map[int]bool{3: true, 7: true}
+
+	out := &bytes.Buffer{}
+	p := prompter{r: bufio.NewReader(strings.NewReader("5\nseven\n7\n")), w: out}
+	id, err := p.askID("Project ID", 0, valid)
+	assert.NoError(t, err)
+	assert.Equal(t, 7, id)
+	assert.Equal(t, 2, strings.Count(out.String(), "is not one of the listed IDs"))
+
+	p = prompter{r: bufio.NewReader(strings.NewReader("\n")), w: &bytes.Buffer{}}
+	id, err = p.askID("Project ID", 3, valid)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, id)
+
+	p = prompter{r: bufio.NewReader(strings.NewReader("")), w: &bytes.Buffer{}}
+	_, err = p.askID("Project ID", 0, valid)
+	assert.Error(t, err)
+}
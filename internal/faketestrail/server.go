@@ -0,0 +1,597 @@
+// Package faketestrail is an in-memory TestRail API server for tests. It
+// implements the endpoints trailer uses with just enough behavior to run the
+// commands end to end without network access.
+package faketestrail
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/educlos/testrail"
+)
+
+// Result is a result recorded by add_results_for_cases.
+type Result struct {
+	ID       int           `json:"id"`
+	TestID   int           `json:"test_id"`
+	CaseID   int           `json:"-"`
+	StatusID int           `json:"status_id"`
+	Comment  string        `json:"comment"`
+	Version  string        `json:"version"`
+	Elapsed  time.Duration `json:"-"`
+
+	StepResults []testrail.CustomStepResult `json:"custom_step_results,omitempty"`
+}
+
+// Request is a request received by the server.
+type Request struct {
+	Method   string
+	Endpoint string
+	Body     string
+}
+
+// Server is a fake TestRail instance. Its fields may be set up before the
+// commands run and inspected afterwards, the server locks them while it
+// handles a request.
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	nextID   int
+	Projects []testrail.Project
+	Suites   []testrail.Suite
+	Sections []testrail.Section
+	Cases    []testrail.Case
+	Plans    []testrail.Plan
+	Runs     []testrail.Run
+	Tests    []testrail.Test
+	Results  []Result
+	Statuses []testrail.Status
+	Users    []testrail.User
+	Requests []Request
+
+	// Lose lists cases whose results add_results_for_cases answers with
+	// but does not record, like a silently partial upload.
+	Lose map[int]bool
+}
+
+// New starts a server with the default statuses and no other data. Close it
+// when done.
+func New() *Server {
+	s := &Server{
+		nextID: 1000,
+		Statuses: []testrail.Status{
+			{ID: 1, Name: "passed", Label: "Passed", IsSystem: true},
+			{ID: 2, Name: "blocked", Label: "Blocked", IsSystem: true},
+			{ID: 3, Name: "untested", Label: "Untested", IsSystem: true},
+			{ID: 4, Name: "retest", Label: "Retest", IsSystem: true},
+			{ID: 5, Name: "failed", Label: "Failed", IsSystem: true},
+		},
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// Lock and Unlock guard the fields while commands may be running.
+func (s *Server) Lock()   { s.mu.Lock() }
+func (s *Server) Unlock() { s.mu.Unlock() }
+
+// AddRun adds a run of the suite with a test for each of the cases.
+func (s *Server) AddRun(projectID, suiteID int, caseIDs ...int) testrail.Run {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.addRun(projectID, testrail.SendableRun{SuiteID: suiteID, CaseIDs: caseIDs})
+}
+
+// AddPlan adds a plan with one entry, whose run has a test for each of the
+// cases of the suite.
+func (s *Server) AddPlan(projectID, suiteID int, caseIDs ...int) testrail.Plan {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.addPlan(projectID, testrail.SendablePlan{Entries: []testrail.SendableEntry{{SuiteID: suiteID, CaseIDs: caseIDs}}})
+}
+
+func (s *Server) id() int {
+	s.nextID++
+	return s.nextID
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	endpoint := strings.TrimPrefix(r.URL.RawQuery, "/api/v2/")
+	s.Requests = append(s.Requests, Request{Method: r.Method, Endpoint: endpoint, Body: string(body)})
+
+	path, rawParams := endpoint, ""
+	if i := strings.Index(endpoint, "&"); i >= 0 {
+		path, rawParams = endpoint[:i], endpoint[i+1:]
+	}
+	params, _ := url.ParseQuery(rawParams)
+
+	name, id := path, 0
+	if i := strings.Index(path, "/"); i >= 0 {
+		name = path[:i]
+		id, _ = strconv.Atoi(path[i+1:])
+	}
+
+	// TestRail answers every invalid request with 400 Bad Request.
+	v, err := s.route(name, id, params, body)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if v != nil {
+		json.NewEncoder(w).Encode(v)
+	}
+}
+
+func (s *Server) route(name string, id int, params url.Values, body []byte) (interface{}, error) {
+	switch name {
+	case "get_projects":
+		return s.Projects, nil
+	case "get_project":
+		for _, p := range s.Projects {
+			if p.ID == id {
+				return p, nil
+			}
+		}
+		return nil, errors.New("Field :project_id is not a valid or accessible project.")
+	case "get_suites":
+		suites := []testrail.Suite{}
+		for _, suite := range s.Suites {
+			if suite.ProjectID == id {
+				suites = append(suites, suite)
+			}
+		}
+		return suites, nil
+	case "get_suite":
+		for _, suite := range s.Suites {
+			if suite.ID == id {
+				return suite, nil
+			}
+		}
+		return nil, errors.New("Field :suite_id is not a valid test suite.")
+	case "add_suite":
+		var in testrail.SendableSuite
+		if err := json.Unmarshal(body, &in); err != nil {
+			return nil, err
+		}
+		suite := testrail.Suite{ID: s.id(), Name: in.Name, Description: in.Description, ProjectID: id}
+		s.Suites = append(s.Suites, suite)
+		return suite, nil
+	case "get_sections":
+		suiteID, _ := strconv.Atoi(params.Get("suite_id"))
+		sections := []testrail.Section{}
+		for _, section := range s.Sections {
+			if section.SuiteID == suiteID {
+				sections = append(sections, section)
+			}
+		}
+		return sections, nil
+	case "add_section":
+		var in testrail.SendableSection
+		if err := json.Unmarshal(body, &in); err != nil {
+			return nil, err
+		}
+		section := testrail.Section{ID: s.id(), Name: in.Name, SuiteID: in.SuiteID, ParentID: in.ParentID, Description: in.Description}
+		s.Sections = append(s.Sections, section)
+		return section, nil
+	case "delete_section":
+		for i, section := range s.Sections {
+			if section.ID == id {
+				s.Sections = append(s.Sections[:i], s.Sections[i+1:]...)
+				return nil, nil
+			}
+		}
+		return nil, errors.New("Field :section_id is not a valid section.")
+	case "get_cases":
+		suiteID, _ := strconv.Atoi(params.Get("suite_id"))
+		cases := []testrail.Case{}
+		for _, c := range s.Cases {
+			if c.SuiteID == suiteID {
+				cases = append(cases, c)
+			}
+		}
+		return cases, nil
+	case "get_case":
+		if i := s.findCase(id); i >= 0 {
+			return s.Cases[i], nil
+		}
+		return nil, errors.New("Field :case_id is not a valid test case.")
+	case "add_case":
+		var in testrail.SendableCase
+		if err := json.Unmarshal(body, &in); err != nil {
+			return nil, err
+		}
+		suiteID := 0
+		for _, section := range s.Sections {
+			if section.ID == id {
+				suiteID = section.SuiteID
+			}
+		}
+		if suiteID == 0 {
+			return nil, errors.New("Field :section_id is not a valid section.")
+		}
+		c := testrail.Case{ID: s.id(), SectionID: id, SuiteID: suiteID, UdpatedOn: int(time.Now().Unix())}
+		applyCase(&c, in)
+		s.Cases = append(s.Cases, c)
+		return c, nil
+	case "update_case":
+		var in testrail.SendableCase
+		if err := json.Unmarshal(body, &in); err != nil {
+			return nil, err
+		}
+		i := s.findCase(id)
+		if i < 0 {
+			return nil, errors.New("Field :case_id is not a valid test case.")
+		}
+		applyCase(&s.Cases[i], in)
+		s.Cases[i].UdpatedOn = int(time.Now().Unix())
+		return s.Cases[i], nil
+	case "delete_case":
+		i := s.findCase(id)
+		if i < 0 {
+			return nil, errors.New("Field :case_id is not a valid test case.")
+		}
+		s.Cases = append(s.Cases[:i], s.Cases[i+1:]...)
+		return nil, nil
+	case "add_run":
+		var in testrail.SendableRun
+		if err := json.Unmarshal(body, &in); err != nil {
+			return nil, err
+		}
+		return s.addRun(id, in), nil
+	case "get_runs":
+		// Like TestRail, the latest runs come first.
+		runs := []testrail.Run{}
+		for i := len(s.Runs) - 1; i >= 0; i-- {
+			run := s.Runs[i]
+			// Like TestRail, the runs of plans are left out.
+			if run.PlanID != 0 || run.ProjectID != id || (params.Get("suite_id") != "" && params.Get("suite_id") != strconv.Itoa(run.SuiteID)) {
+				continue
+			}
+			if params.Get("milestone_id") != "" && params.Get("milestone_id") != strconv.Itoa(run.MilestoneID) {
+				continue
+			}
+			if after, err := strconv.Atoi(params.Get("created_after")); err == nil && run.CreatedOn <= after {
+				continue
+			}
+			if limit, err := strconv.Atoi(params.Get("limit")); err == nil && len(runs) == limit {
+				break
+			}
+			runs = append(runs, run)
+		}
+		return runs, nil
+	case "get_run":
+		for _, run := range s.Runs {
+			if run.ID == id {
+				return run, nil
+			}
+		}
+		return nil, errors.New("Field :run_id is not a valid test run.")
+	case "delete_run":
+		for i := range s.Runs {
+			if s.Runs[i].ID == id {
+				s.Runs = append(s.Runs[:i], s.Runs[i+1:]...)
+				tests := []testrail.Test{}
+				for _, test := range s.Tests {
+					if test.RunID != id {
+						tests = append(tests, test)
+					}
+				}
+				s.Tests = tests
+				return nil, nil
+			}
+		}
+		return nil, errors.New("Field :run_id is not a valid test run.")
+	case "update_run":
+		var in testrail.UpdatableRun
+		if err := json.Unmarshal(body, &in); err != nil {
+			return nil, err
+		}
+		return s.updateRun(id, in)
+	case "close_run":
+		for i := range s.Runs {
+			if s.Runs[i].ID == id {
+				s.Runs[i].IsCompleted = true
+				s.Runs[i].CompletedOn = int(time.Now().Unix())
+				return s.Runs[i], nil
+			}
+		}
+		return nil, errors.New("Field :run_id is not a valid test run.")
+	case "add_plan":
+		var in testrail.SendablePlan
+		if err := json.Unmarshal(body, &in); err != nil {
+			return nil, err
+		}
+		return s.addPlan(id, in), nil
+	case "add_plan_entry":
+		var in testrail.SendableEntry
+		if err := json.Unmarshal(body, &in); err != nil {
+			return nil, err
+		}
+		for _, plan := range s.Plans {
+			if plan.ID == id {
+				return s.addPlanEntry(plan, in), nil
+			}
+		}
+		return nil, errors.New("Field :plan_id is not a valid test plan.")
+	case "get_plans":
+		// Like TestRail, plans are listed without their entries.
+		plans := []testrail.Plan{}
+		for _, plan := range s.Plans {
+			if plan.ProjectID == id {
+				plans = append(plans, plan)
+			}
+		}
+		return plans, nil
+	case "get_plan":
+		for _, plan := range s.Plans {
+			if plan.ID == id {
+				return s.planWithEntries(plan), nil
+			}
+		}
+		return nil, errors.New("Field :plan_id is not a valid test plan.")
+	case "get_tests":
+		tests := []testrail.Test{}
+		for _, test := range s.Tests {
+			if test.RunID == id {
+				tests = append(tests, test)
+			}
+		}
+		return tests, nil
+	case "add_results_for_cases":
+		return s.addResults(id, body)
+	case "get_results_for_run":
+		// Like TestRail, the latest results come first.
+		tests := map[int]bool{}
+		for _, test := range s.Tests {
+			if test.RunID == id {
+				tests[test.ID] = true
+			}
+		}
+		results := []Result{}
+		for i := len(s.Results) - 1; i >= 0; i-- {
+			if tests[s.Results[i].TestID] {
+				results = append(results, s.Results[i])
+			}
+		}
+		return page(results, params), nil
+	case "get_statuses":
+		return s.Statuses, nil
+	case "get_users":
+		return s.Users, nil
+	case "get_user_by_email":
+		for _, u := range s.Users {
+			if u.Email == params.Get("email") {
+				return u, nil
+			}
+		}
+		return nil, errors.New("Field :email is not a valid email address.")
+	}
+
+	return nil, fmt.Errorf("Unknown method '%s'", name)
+}
+
+// pageLimit is how many entries TestRail returns when no limit is given.
+const pageLimit = 250
+
+// page returns the entries selected by the limit and offset parameters.
+func page(results []Result, params url.Values) []Result {
+	limit, err := strconv.Atoi(params.Get("limit"))
+	if err != nil || limit <= 0 || limit > pageLimit {
+		limit = pageLimit
+	}
+	offset, _ := strconv.Atoi(params.Get("offset"))
+	if offset >= len(results) {
+		return []Result{}
+	}
+	results = results[offset:]
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+func (s *Server) findCase(id int) int {
+	for i, c := range s.Cases {
+		if c.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func applyCase(c *testrail.Case, in testrail.SendableCase) {
+	if in.Title != "" {
+		c.Title = in.Title
+	}
+	if in.TypeID != 0 {
+		c.TypeID = in.TypeID
+	}
+	if in.PriorityID != 0 {
+		c.PriorityID = in.PriorityID
+	}
+	if in.MilestoneID != 0 {
+		c.MilestoneID = in.MilestoneID
+	}
+	if in.Estimate != "" {
+		c.Estimate = in.Estimate
+	}
+	if in.Refs != "" {
+		c.Refs = in.Refs
+	}
+}
+
+func (s *Server) addRun(projectID int, in testrail.SendableRun) testrail.Run {
+	// Runs added without include_all have every case when none are listed.
+	all := len(in.CaseIDs) == 0
+	if in.IncludeAll != nil {
+		all = *in.IncludeAll
+	}
+	run := testrail.Run{ID: s.id(), ProjectID: projectID, SuiteID: in.SuiteID, MilestoneID: in.MilestoneID, Name: in.Name, Description: in.Description, IncludeAll: all, CreatedOn: int(time.Now().Unix())}
+	s.Runs = append(s.Runs, run)
+	s.Tests = append(s.Tests, s.runTests(run, all, in.CaseIDs)...)
+	return run
+}
+
+func (s *Server) addPlan(projectID int, in testrail.SendablePlan) testrail.Plan {
+	plan := testrail.Plan{ID: s.id(), ProjectID: projectID, MilestoneID: in.MilestoneID, Name: in.Name, Description: in.Description, CreatedOn: int(time.Now().Unix())}
+	s.Plans = append(s.Plans, plan)
+	for _, entry := range in.Entries {
+		s.addPlanEntry(plan, entry)
+	}
+	return s.planWithEntries(plan)
+}
+
+// addPlanEntry adds an entry with a single run, configurations are not
+// supported.
+func (s *Server) addPlanEntry(plan testrail.Plan, in testrail.SendableEntry) testrail.Entry {
+	all := in.IncludeAll
+	run := s.addRun(plan.ProjectID, testrail.SendableRun{SuiteID: in.SuiteID, Name: in.Name, IncludeAll: &all, CaseIDs: in.CaseIDs})
+	run.PlanID, run.EntryID = plan.ID, strconv.Itoa(s.id())
+	s.Runs[len(s.Runs)-1] = run
+	return testrail.Entry{ID: run.EntryID, Name: run.Name, SuiteID: run.SuiteID, Runs: []testrail.Run{run}}
+}
+
+// planWithEntries returns the plan with an entry for each of its runs.
+func (s *Server) planWithEntries(plan testrail.Plan) testrail.Plan {
+	plan.Entries = []testrail.Entry{}
+	for _, run := range s.Runs {
+		if run.PlanID == plan.ID {
+			plan.Entries = append(plan.Entries, testrail.Entry{ID: run.EntryID, Name: run.Name, SuiteID: run.SuiteID, Runs: []testrail.Run{run}})
+		}
+	}
+	return plan
+}
+
+// runTests returns the tests of the cases of the run's suite, either all of
+// them or the listed ones.
+func (s *Server) runTests(run testrail.Run, all bool, caseIDs []int) []testrail.Test {
+	include := map[int]bool{}
+	for _, id := range caseIDs {
+		include[id] = true
+	}
+	tests := []testrail.Test{}
+	for _, c := range s.Cases {
+		if c.SuiteID == run.SuiteID && (all || include[c.ID]) {
+			tests = append(tests, testrail.Test{ID: s.id(), RunID: run.ID, CaseID: c.ID, StatusID: 3, Title: c.Title})
+		}
+	}
+	return tests
+}
+
+// updateRun replaces the case selection of the run as a whole, like
+// TestRail does: the tests of cases left out are deleted with their results
+// and the cases added get new untested tests.
+func (s *Server) updateRun(runID int, in testrail.UpdatableRun) (interface{}, error) {
+	i := -1
+	for j := range s.Runs {
+		if s.Runs[j].ID == runID {
+			i = j
+		}
+	}
+	if i < 0 {
+		return nil, errors.New("Field :run_id is not a valid test run.")
+	}
+	run := &s.Runs[i]
+	if in.Name != "" {
+		run.Name = in.Name
+	}
+	if in.Description != "" {
+		run.Description = in.Description
+	}
+	if in.IncludeAll == nil && in.CaseIDs == nil {
+		return *run, nil
+	}
+	if in.IncludeAll != nil {
+		run.IncludeAll = *in.IncludeAll
+	}
+
+	kept := map[int]testrail.Test{}
+	others := []testrail.Test{}
+	for _, test := range s.Tests {
+		if test.RunID == runID {
+			kept[test.CaseID] = test
+		} else {
+			others = append(others, test)
+		}
+	}
+	removed := map[int]bool{}
+	for _, test := range s.runTests(*run, run.IncludeAll, in.CaseIDs) {
+		if old, ok := kept[test.CaseID]; ok {
+			test = old
+			delete(kept, test.CaseID)
+		}
+		others = append(others, test)
+	}
+	for _, test := range kept {
+		removed[test.ID] = true
+	}
+	s.Tests = others
+
+	results := []Result{}
+	for _, r := range s.Results {
+		if !removed[r.TestID] {
+			results = append(results, r)
+		}
+	}
+	s.Results = results
+	return *run, nil
+}
+
+// addResults rejects the whole request if any case is not part of the run,
+// like TestRail does.
+func (s *Server) addResults(runID int, body []byte) (interface{}, error) {
+	var in testrail.SendableResultsForCase
+	if err := json.Unmarshal(body, &in); err != nil {
+		return nil, err
+	}
+
+	tests := map[int]int{}
+	for i, test := range s.Tests {
+		if test.RunID == runID {
+			tests[test.CaseID] = i
+		}
+	}
+	for _, r := range in.Results {
+		if _, ok := tests[r.CaseID]; !ok {
+			return nil, fmt.Errorf("Field :results cannot be parsed (case C%d unknown)", r.CaseID)
+		}
+	}
+
+	results := []Result{}
+	for _, r := range in.Results {
+		test := &s.Tests[tests[r.CaseID]]
+		result := Result{ID: s.id(), TestID: test.ID, CaseID: r.CaseID, StatusID: r.StatusID, Comment: r.Comment, Version: r.Version, Elapsed: r.Elapsed.Duration, StepResults: r.CustomStepResults}
+		results = append(results, result)
+		if s.Lose[r.CaseID] {
+			continue
+		}
+		// Like TestRail, results without a status only comment or assign.
+		if r.StatusID != 0 {
+			test.StatusID = r.StatusID
+		}
+		if r.AssignedToID != 0 {
+			test.AssignedToID = r.AssignedToID
+		}
+		s.Results = append(s.Results, result)
+	}
+	return results, nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+
+	"github.com/urfave/cli"
+)
+
+// logLevel is shared by every handler so the per-command verbose flags can
+// lower it after the global flags have been applied.
+var logLevel = new(slog.LevelVar)
+
+var logFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:   "log-level",
+		Usage:  "minimum level to log: debug, info, warn or error",
+		Value:  "info",
+		EnvVar: "TRAILER_LOG_LEVEL",
+	},
+	cli.StringFlag{
+		Name:   "log-format",
+		Usage:  "log format: text or json",
+		Value:  "text",
+		EnvVar: "TRAILER_LOG_FORMAT",
+	},
+}
+
+// setupLogging makes the default logger write to w at the given level and in
+// the given format. Output from the standard log package goes through the
+// same handler.
+func setupLogging(level, format string, w io.Writer) error {
+	if err := logLevel.UnmarshalText([]byte(level)); err != nil {
+		return configErrorf("invalid log level %q", level)
+	}
+
+	opts := &slog.HandlerOptions{Level: logLevel}
+
+	var handler slog.Handler
+	switch format {
+	case "text":
+		handler = slog.NewTextHandler(w, opts)
+	case "json":
+		handler = slog.NewJSONHandler(w, opts)
+	default:
+		return configErrorf("invalid log format %q", format)
+	}
+
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
+
+// setVerbose lowers the log level to debug when verbose is set.
+func setVerbose(verbose bool) {
+	if verbose {
+		logLevel.Set(slog.LevelDebug)
+	}
+}
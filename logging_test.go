@@ -0,0 +1,37 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetupLogging(t *testing.T) {
+	defer slog.SetDefault(slog.Default())
+
+	out := &bytes.Buffer{}
+	assert.NoError(t, setupLogging("warn", "json", out))
+
+	slog.Info("hidden")
+	slog.Warn("shown", "run", 5)
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Len(t, lines, 1)
+
+	var entry map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(lines[0]), &entry))
+	assert.Equal(t, "WARN", entry["level"])
+	assert.Equal(t, "shown", entry["msg"])
+	assert.Equal(t, float64(5), entry["run"])
+
+	setVerbose(true)
+	slog.Debug("debug")
+	assert.Contains(t, out.String(), `"msg":"debug"`)
+
+	assert.Error(t, setupLogging("loud", "text", out))
+	assert.Error(t, setupLogging("info", "xml", out))
+}
@@ -1,31 +1,33 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io/ioutil"
-	"log"
+	"log/slog"
 	"os"
-	"regexp"
 	"strconv"
 	"strings"
 	"time"
 
-	yaml "gopkg.in/yaml.v2"
-
-	"github.com/educlos/testrail"
 	"github.com/urfave/cli"
 
+	"github.com/docker/trailer/pkg/download"
+	"github.com/docker/trailer/pkg/upload"
 	"github.com/docker/trailer/spec"
 )
 
-type Suite struct {
-	ProjectID   int            `yaml:"project_id"`
-	SuiteID     int            `yaml:"suite_id"`
-	LastUpdated string         `yaml:"last_updated"`
-	Cases       map[int]string `yaml:"cases"`
+func main() {
+	if err := newApp().Run(os.Args); err != nil {
+		slog.Error(err.Error())
+		os.Exit(exitCode(err))
+	}
 }
 
-func main() {
+// newApp builds the command line application. Each call returns fresh flag
+// destinations, so tests can run several commands in one process.
+func newApp() *cli.App {
 	var (
 		verbose   bool
 		dry       bool
@@ -35,20 +37,89 @@ func main() {
 		projectID int
 		comment   string
 		file      string
+		statusMap string
+		spool     string
 	)
 
 	app := cli.NewApp()
 	app.HideHelp = true
-	app.HideVersion = true
 	app.Name = "trailer"
+	app.Version = version
+	app.Flags = []cli.Flag{timeoutFlag, tokenStdinFlag, metricsPushgatewayFlag, noProgressFlag, noColorFlag}
+	for _, flags := range [][]cli.Flag{outputFlags, logFlags, transportFlags, tlsFlags, traceFlags, cassetteFlags, cacheFlags} {
+		app.Flags = append(app.Flags, flags...)
+	}
+	stopTransport := func() {}
+	stopCassette := func() error { return nil }
+	stopTracing := func() {}
+	stopMetrics := func() {}
+	stopContext := func() {}
+	stopOTel := func() {}
+	stopOutput := func() {}
+	app.Before = func(c *cli.Context) error {
+		var err error
+		if stopOutput, err = setupOutput(c.String("output"), c.Bool("quiet")); err != nil {
+			return err
+		}
+		level := c.String("log-level")
+		if quiet {
+			level = "error"
+		}
+		if err := setupLogging(level, c.String("log-format"), os.Stderr); err != nil {
+			return err
+		}
+		setupProgress(c.Bool("no-progress") || quiet)
+		setupColor(c.Bool("no-color"))
+		apiCache = cacheOptions{dir: c.String("cache-dir"), ttl: c.Duration("cache-ttl"), refresh: c.Bool("refresh")}
+		stdinToken = ""
+		if c.Bool("token-stdin") {
+			if err := readStdinToken(os.Stdin); err != nil {
+				return err
+			}
+		}
+		stopTransport, err = setupTransport(transportOptions{
+			caCert:         c.String("ca-cert"),
+			insecure:       c.Bool("insecure-skip-verify"),
+			clientCert:     c.String("client-cert"),
+			clientKey:      c.String("client-key"),
+			requestTimeout: c.Duration("request-timeout"),
+			keepAlive:      c.Duration("keep-alive"),
+			maxIdleConns:   c.Int("max-idle-conns"),
+		})
+		if err != nil {
+			return err
+		}
+		if stopCassette, err = setupCassette(c.String("record"), c.String("replay")); err != nil {
+			return err
+		}
+		stopMetrics = setupMetrics()
+		stopTracing = setupTracing(c.Bool("trace-http"), c.Bool("trace-http-bodies"))
+		var ctx context.Context
+		ctx, stopContext = setupContext(c.Duration("timeout"))
+		ctx, stopOTel = setupOTel(ctx, c.Args().First())
+		app.Metadata = map[string]interface{}{contextKey: ctx}
+		return nil
+	}
+	app.After = func(c *cli.Context) error {
+		defer stopOutput()
+		stopOTel()
+		stopContext()
+		stopTracing()
+		pushMetrics(c.String("metrics-pushgateway"))
+		stopMetrics()
+		defer stopTransport()
+		return stopCassette()
+	}
 	app.Usage = "TestRail command line utility"
+	cli.VersionPrinter = func(c *cli.Context) {
+		fmt.Println(versionString())
+	}
 	app.Commands = []cli.Command{
 		{
 			Name:    "upload",
 			Aliases: []string{"u"},
 			Usage:   "Upload JUnit XML reports to TestRail",
-			Flags: []cli.Flag{
-				// TODO: Respect verbosity and use a proper logging library
+			Flags: append([]cli.Flag{
 				cli.BoolFlag{
 					Name:        "verbose, v",
 					Usage:       "turn on debug logs",
@@ -75,80 +146,296 @@ func main() {
 					Usage:       "prefix to use when commenting on TestRail updates",
 					Destination: &comment,
 				},
-			},
-			ArgsUsage: "[input *.xml files...]",
+				cli.StringFlag{
+					Name:        "status-map",
+					Usage:       "YAML file mapping test outcomes to TestRail status IDs",
+					Destination: &statusMap,
+				},
+				cli.BoolFlag{
+					Name:  "step-results",
+					Usage: "upload the steps of Cucumber and plugin reports as step results, for cases using the separated steps template",
+				},
+				caseMapFlag,
+				routesFlag,
+				cli.StringFlag{
+					Name:        "spool",
+					Usage:       "directory to save results to when TestRail is unreachable, see flush",
+					EnvVar:      "TRAILER_SPOOL",
+					Destination: &spool,
+				},
+				formatFlag,
+				verifyFlag,
+				strictFlag,
+				addMissingFlag,
+				resultVersionFlag,
+				updateExistingFlag,
+				idempotentFlag,
+				htmlReportFlag,
+				resultManifestFlag,
+				githubSummaryFlag,
+				ciInfoFlag,
+				archiveFlag,
+			}, append(append(createRunFlags, notifyFlags...), emailFlags...)...),
+			ArgsUsage: "[input report files...]",
 			Action: func(c *cli.Context) error {
-				username := os.Getenv("TESTRAIL_USERNAME")
-				token := os.Getenv("TESTRAIL_TOKEN")
-
-				if username == "" || token == "" {
-					log.Fatalf("Need to set TESTRAIL_USERNAME and TESTRAIL_TOKEN")
+				started := time.Now()
+				setVerbose(verbose)
+				targets := 0
+				for _, set := range []bool{runID != 0, c.String("routes") != "", c.Bool("create-run"), c.String("run-name") != ""} {
+					if set {
+						targets++
+					}
+				}
+				if targets == 0 {
+					return configErrorf("Must set --run-id to a non-zero integer")
+				}
+				if targets > 1 {
+					return configErrorf("Must set only one of --run-id, --routes, --create-run and --run-name")
 				}
 
-				if runID == 0 {
-					log.Fatalf("Must set --run-id to a non-zero integer")
+				statuses := spec.StatusMap{}
+				if statusMap != "" {
+					var err error
+					if statuses, err = spec.LoadStatusMap(statusMap); err != nil {
+						return configErrorf("Failed to load status map: %s", err)
+					}
 				}
 
-				updates := spec.Updates{
-					ResultMap: map[int]spec.Update{},
+				var caseMap spec.CaseMap
+				if file := c.String("case-map"); file != "" {
+					var err error
+					if caseMap, err = spec.LoadCaseMap(file); err != nil {
+						return configErrorf("Failed to load case map: %s", err)
+					}
 				}
 
-				suites := spec.JUnitTestSuites{}
-				for _, file := range c.Args() {
-					newSuites, err := spec.ParseFile(file)
+				ctx := commandContext(c)
+				if file := c.String("routes"); file != "" {
+					for _, flag := range []string{"idempotent", "update-existing", "verify", "archive", "html-report", "result-manifest", "add-missing-to-run"} {
+						if c.IsSet(flag) {
+							return configErrorf("Cannot set --%s with --routes", flag)
+						}
+					}
+					routes, err := loadRoutes(file)
 					if err != nil {
-						log.Fatalf(fmt.Sprintf("Failed to parse file: %s", err))
+						return configErrorf("Failed to load routes: %s", err)
 					}
-
-					suites.Suites = append(suites.Suites, newSuites...)
+					suites, err := upload.ParseSuites(ctx, c.Args(), c.String("format"))
+					if ctx.Err() != nil {
+						return interruptedError(ctx)
+					}
+					if err != nil {
+						return exitError{code: exitParse, err: err}
+					}
+					base := spec.Updates{Statuses: statuses, CaseMap: caseMap, Version: c.String("result-version"), StepResults: c.Bool("step-results")}
+					return uploadRoutes(ctx, routes, suites, withCIInfo(c, comment), base, retries, dry, c.Bool("strict"))
 				}
 
-				updates.AddSuites(comment, suites)
+				updates, err := parseReports(ctx, c.Args(), c.String("format"), withCIInfo(c, comment), statuses, caseMap)
+				if err != nil {
+					return err
+				}
+				updates.Version = c.String("result-version")
+				updates.StepResults = c.Bool("step-results")
+				if c.Bool("update-existing") && updates.Version == "" {
+					return configErrorf("Must set --result-version with --update-existing to tell the results of retried jobs apart")
+				}
 
-				if !dry {
-					client := testrail.NewClient("https://docker.testrail.com", username, token)
-					for i := 0; i < retries; i++ {
-						results, err := updates.CreatePayload()
+				if c.Bool("create-run") {
+					if dry {
+						name, err := runName(c.String("run-name-template"), newRunNameData(time.Now()))
+						if err != nil {
+							return configErrorf("Error in --run-name-template: %s", err)
+						}
+						statusf("Would create run %q\n", name)
+					} else {
+						client, err := newClient()
+						if err != nil {
+							return err
+						}
+						run, err := createRun(c, client, updates.SortedCaseIDs())
+						if err != nil {
+							return err
+						}
+						runID = run.ID
+						statusf("Created run %d: %s\n", run.ID, run.Name)
+					}
+				}
+				if c.String("run-name") != "" {
+					if dry {
+						name, err := runName(c.String("run-name"), newRunNameData(time.Now()))
 						if err != nil {
-							log.Fatalf(fmt.Sprintf("Failed to create results payload: %s", err))
+							return configErrorf("Error in --run-name: %s", err)
 						}
-						results, err = pruneResults(client, runID, results)
+						statusf("Would upload to the open run %q\n", name)
+					} else {
+						client, err := newClient()
 						if err != nil {
-							log.Fatalf("Failed to prune Test Results")
+							return err
 						}
-						r, err := client.AddResultsForCases(runID, results)
+						run, err := namedRun(c, client, updates.SortedCaseIDs())
 						if err != nil {
-							errString := err.Error()
-							if strings.Contains(errString, "400 Bad Request") {
-								regex, err := regexp.Compile("case C([\\d]+) unknown")
-								if err != nil {
-									log.Fatalf("failed to compile test case regex: %s", err)
-								}
-								ids := regex.FindAllStringSubmatch(errString, -1)
-								for _, id := range ids {
-									if len(id) != 2 {
-										log.Fatalf("failed to parse case ID")
-									}
-									caseID, err := strconv.Atoi(id[1])
-									if err != nil {
-										log.Fatalf("failed to convert case ID to integer: %s", err)
-									}
-									updates.RemoveResult(caseID)
-								}
-							} else {
-								log.Fatalf(fmt.Sprintf("Failed to upload test results to TestRail: %s", err))
-							}
+							return err
 						}
+						runID = run.ID
+						statusf("Uploading to run %d: %s\n", run.ID, run.Name)
+					}
+				}
 
-						if len(r) == 0 {
-							log.Print("No results uploaded")
-						} else {
-							for _, res := range r {
-								fmt.Printf("%+v\n", res)
-							}
-							break
+				parsedResults := map[int]spec.Update{}
+				for id, u := range updates.ResultMap {
+					parsedResults[id] = u
+				}
+				// report writes the summaries of the upload and posts them
+				// to the notification targets.
+				report := func(recorded, dropped map[int]bool) error {
+					r := newUploadReport(instanceFromEnv(), runID, parsedResults, dropped, dry)
+					if dry {
+						statusf("Parsed %s for run %d\n", countsLine(newUploadSummary(r)), runID)
+					} else {
+						statusf("Uploaded %s to run %d\n", countsLine(newUploadSummary(r)), runID)
+					}
+					if file := c.String("html-report"); file != "" {
+						if err := writeHTMLReport(file, r); err != nil {
+							return fmt.Errorf("Failed to write HTML report: %s", err)
 						}
 					}
+					if inGitHubActions() && c.BoolT("github-summary") {
+						githubAnnotations(r)
+						if err := githubJobSummary(r); err != nil {
+							slog.Warn("Failed to write the GitHub job summary", "error", err)
+						}
+					}
+					if !dry {
+						summary := newUploadSummary(r)
+						notify(notifications(c), summary)
+						if err := sendEmail(c, uploadEmail, summary); err != nil {
+							slog.Warn("Failed to email the upload summary", "error", err)
+						}
+					}
+					if file := c.String("result-manifest"); file != "" {
+						m := newUploadManifest(instanceFromEnv(), runID, parsedResults, recorded, dropped, dry, started)
+						if err := writeManifest(file, m); err != nil {
+							return fmt.Errorf("Failed to write result manifest: %s", err)
+						}
+					}
+					return nil
+				}
+
+				if dry {
+					if err := report(nil, nil); err != nil {
+						return err
+					}
+					if c.Bool("strict") {
+						return strictCheck(runID, &updates, 0)
+					}
+					return nil
+				}
+
+				client, err := newClient()
+				if err != nil {
+					return err
+				}
+
+				// The fingerprint covers every result of the reports, so it
+				// is taken before any are skipped.
+				if c.Bool("idempotent") {
+					skipped, err := markUpload(client, runID, &updates)
+					if err != nil {
+						return apiErrorf("Failed to fetch the results of run %d: %s", runID, err)
+					}
+					if skipped > 0 {
+						slog.Info("Skipping results the run already has from this upload", "results", skipped, "marker", updates.Marker)
+					}
+				}
+
+				if c.Bool("update-existing") {
+					skipped, err := skipRecorded(client, runID, &updates)
+					if err != nil {
+						return apiErrorf("Failed to fetch the results of run %d: %s", runID, err)
+					}
+					if skipped > 0 {
+						slog.Info("Skipping results the run already has", "results", skipped, "version", updates.Version)
+					}
+				}
+
+				recorded := map[int]bool{}
+				for id := range parsedResults {
+					if _, ok := updates.ResultMap[id]; !ok {
+						recorded[id] = true
+					}
+				}
+
+				if dest := c.String("archive"); dest != "" {
+					location, err := archiveUpload(dest, runID, c.Args(), &updates)
+					if err != nil {
+						slog.Warn("Failed to archive the reports and payload", "archive", dest, "error", err)
+					} else {
+						slog.Info("Archived the reports and payload", "location", location)
+					}
+				}
+
+				if c.Bool("add-missing-to-run") {
+					added, err := ensureRunCases(client, runID, updates.SortedCaseIDs())
+					if err != nil {
+						return apiErrorf("Failed to add cases to run %d: %s", runID, err)
+					}
+					if len(added) > 0 {
+						slog.Info("Added cases to the run", "run", runID, "cases", added)
+					}
+				}
+
+				parsed := len(updates.ResultMap)
+				sent := updates.SortedCaseIDs()
+				bar := newProgress("Uploading results", len(sent))
+				err = uploadResults(upload.WithProgress(ctx, bar.Set), client, runID, retries, &updates)
+				bar.Finish()
+				if exitCode(err) == exitInterrupted {
+					return checkpointUpload(err, spool, runID, updates)
+				}
+				if _, ok := err.(unavailableError); ok && spool != "" {
+					name, err := spoolUpload(spool, runID, updates)
+					if err != nil {
+						return fmt.Errorf("Failed to spool results: %s", err)
+					}
+					slog.Warn("TestRail is unavailable, spooled results", "results", len(updates.ResultMap), "entry", name)
+					return nil
+				}
+				if err != nil {
+					return err
+				}
+
+				dropped := map[int]bool{}
+				for _, id := range sent {
+					if _, ok := updates.ResultMap[id]; !ok {
+						dropped[id] = true
+					}
+				}
+				// Jobs sharing a run may overwrite each other's cases, which
+				// deletes the results of the cases they drop.
+				if c.String("run-name") != "" || c.Bool("add-missing-to-run") {
+					if err := reuploadLost(ctx, client, runID, retries, &updates, uploadedCases(sent, dropped, updates.Pruned)); err != nil {
+						return err
+					}
+				}
+				if err := report(recorded, dropped); err != nil {
+					return err
+				}
+
+				if c.Bool("verify") {
+					if err := verifyUpload(client, runID, &updates); err != nil {
+						return err
+					}
+				}
+
+				if c.Bool("strict") {
+					if err := strictCheck(runID, &updates, len(dropped)); err != nil {
+						return err
+					}
+				}
+
+				if dropped := parsed - len(updates.ResultMap); dropped > 0 {
+					return exitError{code: exitPartial, err: fmt.Errorf("Dropped %d of %d results for cases unknown to TestRail", dropped, parsed)}
 				}
 
 				return nil
@@ -181,75 +468,50 @@ func main() {
 				},
 			},
 			Action: func(c *cli.Context) error {
-				username := os.Getenv("TESTRAIL_USERNAME")
-				token := os.Getenv("TESTRAIL_TOKEN")
-
-				if username == "" || token == "" {
-					log.Fatalf("Need to set TESTRAIL_USERNAME and TESTRAIL_TOKEN")
-				}
-
+				setVerbose(verbose)
 				if projectID == 0 {
-					log.Fatalf("Must set --project-id to a non-zero integer")
+					return configErrorf("Must set --project-id to a non-zero integer")
 				}
 
 				if suiteID == 0 {
-					log.Fatalf("Must set --suite-id to a non-zero integer")
-				}
-
-				client := testrail.NewClient("https://docker.testrail.com", username, token)
-				cases, err := client.GetCases(projectID, suiteID)
-				if err != nil {
-					log.Fatalf("Error getting cases: %s", err)
-				}
-
-				s := Suite{
-					LastUpdated: time.Unix(0, 0).Format(time.RFC3339Nano),
-					ProjectID:   projectID,
-					SuiteID:     suiteID,
-					Cases:       map[int]string{},
+					return configErrorf("Must set --suite-id to a non-zero integer")
 				}
 
+				s := download.New(projectID, suiteID)
 				if file != "" {
-					if _, err = os.Stat(file); err == nil {
-						data, err := ioutil.ReadFile(file)
-						if err != nil {
-							log.Fatalf("Error reading file: %s", err)
-						}
+					unlock, err := download.Lock(file)
+					if err != nil {
+						return fmt.Errorf("Error locking cases file: %s", err)
+					}
+					defer unlock()
 
-						err = yaml.Unmarshal(data, &s)
-						if err != nil {
-							log.Fatalf("Error unmarshaling suite data: %s", err)
+					if _, err := os.Stat(file); err == nil {
+						if s, err = download.Load(file); err != nil {
+							return parseErrorf("Error reading file: %s", err)
 						}
+						s.ProjectID, s.SuiteID = projectID, suiteID
 					}
 				}
 
-				lastUpdated, err := time.Parse(time.RFC3339Nano, s.LastUpdated)
+				client, err := newClient()
 				if err != nil {
-					log.Fatalf("Error parsing last_updated time: %s", err)
+					return err
 				}
-
-				updated := false
-				for _, c := range cases {
-					if lastUpdated.Before(time.Unix(int64(c.UdpatedOn), 0)) {
-						s.Cases[c.ID] = c.Title
-						updated = true
-					}
+				bar := newProgress("Downloading cases", 0)
+				updated, err := download.Update(client, &s)
+				bar.Set(len(s.Cases), 0)
+				bar.Finish()
+				var parseErr *time.ParseError
+				if errors.As(err, &parseErr) {
+					return parseErrorf("Error parsing last_updated time: %s", err)
+				}
+				if err != nil {
+					return apiErrorf("Error getting cases: %s", err)
 				}
 
 				if updated {
-					s.LastUpdated = time.Now().Format(time.RFC3339Nano)
-					data, err := yaml.Marshal(&s)
-					if err != nil {
-						log.Fatalf("Error marshaling suite data: %s", err)
-					}
-
-					if file != "" {
-						err = ioutil.WriteFile(file, data, 0644)
-						if err != nil {
-							log.Fatalf("Error writing suite data to output file: %s", err)
-						}
-					} else {
-						log.Print(string(data))
+					if err := writeSuite(file, s); err != nil {
+						return err
 					}
 				}
 
@@ -271,91 +533,184 @@ func main() {
 					Usage:       "File to write downloaded cases to",
 					Destination: &file,
 				},
+				matchFlag,
+				notUpdatedSinceFlag,
+				orphansFlag,
+				cli.BoolFlag{
+					Name:  "dry-run",
+					Usage: "print the cases that would be pruned without changing the file",
+				},
+				cli.BoolFlag{
+					Name:  "interactive, i",
+					Usage: "print the cases that would be pruned and ask before changing the file",
+				},
 			},
 			ArgsUsage: "[input case IDs...]",
 			Action: func(c *cli.Context) error {
+				setVerbose(verbose)
 				if file == "" {
-					log.Fatal("Must specify an input cases file")
+					return configErrorf("Must specify an input cases file")
 				}
 
-				s := Suite{
-					LastUpdated: time.Unix(0, 0).Format(time.RFC3339Nano),
-					ProjectID:   projectID,
-					SuiteID:     suiteID,
-					Cases:       map[int]string{},
-				}
-
-				data, err := ioutil.ReadFile(file)
+				unlock, err := download.Lock(file)
 				if err != nil {
-					log.Fatalf("Error reading file: %s", err)
+					return fmt.Errorf("Error locking cases file: %s", err)
 				}
+				defer unlock()
 
-				err = yaml.Unmarshal(data, &s)
+				s, err := download.Load(file)
 				if err != nil {
-					log.Fatalf("Error unmarshaling suite data: %s", err)
+					return parseErrorf("Error reading file: %s", err)
 				}
 
 				caseIDsToPrune := []int{}
 				for _, iString := range c.Args() {
 					i, err := strconv.Atoi(iString)
 					if err != nil {
-						log.Fatalf("Cannot convert string to int: %s", err)
+						return configErrorf("Cannot convert string to int: %s", err)
 					}
 					caseIDsToPrune = append(caseIDsToPrune, i)
 				}
 
-				updated := false
-				for _, i := range caseIDsToPrune {
-					if _, ok := s.Cases[i]; ok {
-						delete(s.Cases, i)
-						updated = true
-					}
+				selected, err := selectCases(c, s)
+				if err != nil {
+					return err
 				}
+				caseIDsToPrune = append(caseIDsToPrune, selected...)
 
-				if updated {
-					s.LastUpdated = time.Now().Format(time.RFC3339Nano)
-					data, err := yaml.Marshal(&s)
-					if err != nil {
-						log.Fatalf("Error marshaling suite data: %s", err)
-					}
+				ok, err := previewPrune(c, s, caseIDsToPrune)
+				if err != nil || !ok {
+					return err
+				}
 
-					if file != "" {
-						err = ioutil.WriteFile(file, data, 0644)
-						if err != nil {
-							log.Fatalf("Error writing suite data to output file: %s", err)
-						}
-					} else {
-						log.Print(string(data))
+				if download.Remove(&s, caseIDsToPrune...) {
+					if err := writeSuite(file, s); err != nil {
+						return err
 					}
 				}
 
 				return nil
 			},
 		},
+		milestonesCommand(),
+		runsCommand(),
+		projectsCommand(),
+		suitesCommand(),
+		sectionsCommand(),
+		casesCommand(),
+		importCommand(),
+		statusesCommand(),
+		usersCommand(),
+		groupsCommand(),
+		apiCommand(),
+		validateCommand(),
+		doctorCommand(),
+		diffCommand(),
+		syncCommand(),
+		mergeCommand(),
+		annotateJUnitCommand(),
+		exportJUnitCommand(),
+		importResultsCommand(),
+		backupCommand(),
+		restoreCommand(),
+		openCommand(),
+		searchCommand(),
+		suggestCommand(),
+		scanCommand(),
+		genCommand(),
+		assignCommand(),
+		rerunFilterCommand(),
+		coverageCommand(),
+		flakyCommand(),
+		compareCommand(),
+		trendCommand(),
+		staleCommand(),
+		watchCommand(),
+		serveCommand(),
+		enqueueCommand(),
+		workerCommand(),
+		flushCommand(),
+		initCommand(),
+		loginCommand(),
+		logoutCommand(),
+		versionCommand(),
 	}
 
-	app.Run(os.Args)
+	app.Flags = withEnvVars("", app.Flags)
+	app.Commands = commandsWithEnvVars("", app.Commands)
+
+	// Errors are logged and mapped to exit codes by main instead of by the
+	// cli package.
+	cli.OsExiter = func(int) {}
+	cli.ErrWriter = ioutil.Discard
+
+	return app
 }
 
-// We only want to send the results if they are applicable for a given runID or the API will throw an error.
-func pruneResults(client *testrail.Client, runID int, results testrail.SendableResultsForCase) (testrail.SendableResultsForCase, error) {
-	// First gather all the cases of the runID
-	tests, err := client.GetTests(runID)
+// writeSuite writes the cases file, or prints it if file is empty.
+func writeSuite(file string, s download.Suite) error {
+	data, err := download.Marshal(&s)
 	if err != nil {
-		return testrail.SendableResultsForCase{}, err
+		return fmt.Errorf("Error marshaling suite data: %s", err)
 	}
 
-	// Create a map of these test case IDs
-	includedTests := make(map[int]struct{})
-	for _, test := range tests {
-		includedTests[test.CaseID] = struct{}{}
+	if file == "" {
+		fmt.Print(string(data))
+		return nil
 	}
 
-	var applicableResults testrail.SendableResultsForCase
-	for _, result := range results.Results {
-		if _, exists := includedTests[result.CaseID]; exists {
-			applicableResults.Results = append(applicableResults.Results, result)
-		}
+	if err := download.WriteFile(file, data); err != nil {
+		return fmt.Errorf("Error writing suite data to output file: %s", err)
+	}
+	return nil
+}
+
+// formatFlag overrides the report format detected from the content.
+var formatFlag = cli.StringFlag{
+	Name:   "format",
+	Usage:  fmt.Sprintf("report format, one of %s or the name of a %s<format> plugin on PATH, detected from the content by default", strings.Join(spec.Formats(), ", "), spec.PluginPrefix),
+	EnvVar: "TRAILER_FORMAT",
+}
+
+// parseReports reads the results of the given reports.
+func parseReports(ctx context.Context, files []string, format, comment string, statuses spec.StatusMap, caseMap spec.CaseMap) (spec.Updates, error) {
+	ctx, span := startSpan(ctx, "parse reports", map[string]interface{}{"reports": len(files)})
+	updates, err := upload.ParseReports(ctx, files, format, comment, statuses, caseMap)
+	span.SetAttr("results", len(updates.ResultMap))
+	span.Finish(err)
+	if ctx.Err() != nil {
+		return updates, interruptedError(ctx)
+	}
+	if err != nil {
+		return updates, exitError{code: exitParse, err: err}
+	}
+	return updates, nil
+}
+
+// uploadResults uploads the results in updates to the run and prints the
+// results TestRail recorded. When it fails, only the results that were not
+// sent are left in updates, for callers to save or retry.
+func uploadResults(ctx context.Context, client testrailAPI, runID, retries int, updates *spec.Updates) error {
+	ctx, span := startSpan(ctx, "upload results", map[string]interface{}{"testrail.run_id": runID, "results": len(updates.ResultMap)})
+	results, unsent, err := upload.Upload(ctx, client, runID, retries, updates)
+	span.Finish(err)
+	resultsUploaded.Add(float64(len(results)))
+	if err != nil {
+		uploadFailures.Inc()
+		updates.KeepResults(unsent)
 	}
-	return applicableResults, nil
+
+	var apiErr *upload.APIError
+	switch {
+	case err != nil && ctx.Err() != nil:
+		return interruptedError(ctx)
+	case errors.As(err, &apiErr):
+		return markUnavailable(exitError{code: exitAPI, err: err}, apiErr.Err)
+	case err == upload.ErrRejected:
+		return apiErrorf("%s %d times, raise --ignore-failures to drop more unknown cases", err, retries)
+	case err != nil:
+		return err
+	}
+
+	return printResults(results)
 }
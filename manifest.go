@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"time"
+
+	"github.com/urfave/cli"
+
+	"github.com/docker/trailer/spec"
+)
+
+var resultManifestFlag = cli.StringFlag{
+	Name:  "result-manifest",
+	Usage: "write what was uploaded to this JSON file, for later pipeline steps",
+}
+
+// What happened to the result of a case in an upload.
+const (
+	caseUploaded = "uploaded"
+	// caseRecorded results were skipped since the run already has them.
+	caseRecorded = "already recorded"
+	// caseDropped results were pruned since TestRail does not know their
+	// case.
+	caseDropped   = "dropped"
+	caseNotUpload = "not uploaded"
+)
+
+// uploadManifest describes an upload for the steps of a pipeline after it.
+type uploadManifest struct {
+	RunID      int            `json:"run_id"`
+	RunURL     string         `json:"run_url"`
+	Dry        bool           `json:"dry"`
+	StartedAt  time.Time      `json:"started_at"`
+	FinishedAt time.Time      `json:"finished_at"`
+	Duration   float64        `json:"duration_seconds"`
+	Counts     map[string]int `json:"counts"`
+	Cases      []manifestCase `json:"cases"`
+	// Pruned lists the cases whose results were dropped.
+	Pruned []int `json:"pruned"`
+}
+
+type manifestCase struct {
+	ID      int     `json:"id"`
+	URL     string  `json:"url"`
+	Suite   string  `json:"suite"`
+	Test    string  `json:"test"`
+	Outcome string  `json:"outcome"`
+	Elapsed float64 `json:"elapsed_seconds"`
+	Status  string  `json:"status"`
+}
+
+// newUploadManifest lists the parsed results with what happened to them.
+// Those in recorded were skipped and those in dropped pruned, the others
+// were uploaded unless it is a dry run.
+func newUploadManifest(baseURL string, runID int, parsed map[int]spec.Update, recorded, dropped map[int]bool, dry bool, started time.Time) uploadManifest {
+	finished := time.Now()
+	m := uploadManifest{
+		RunID:      runID,
+		RunURL:     fmt.Sprintf("%s/index.php?/runs/view/%d", baseURL, runID),
+		Dry:        dry,
+		StartedAt:  started.UTC(),
+		FinishedAt: finished.UTC(),
+		Duration:   finished.Sub(started).Seconds(),
+		Counts:     map[string]int{},
+		Cases:      []manifestCase{},
+		Pruned:     []int{},
+	}
+
+	for id, u := range parsed {
+		status := caseUploaded
+		switch {
+		case dry:
+			status = caseNotUpload
+		case recorded[id]:
+			status = caseRecorded
+		case dropped[id]:
+			status = caseDropped
+			m.Pruned = append(m.Pruned, id)
+		}
+		m.Counts[status]++
+		m.Cases = append(m.Cases, manifestCase{
+			ID:      id,
+			URL:     fmt.Sprintf("%s/index.php?/cases/view/%d", baseURL, id),
+			Suite:   u.Suite,
+			Test:    u.Test,
+			Outcome: outcomeNames[u.Status],
+			Elapsed: u.Elapsed.Seconds(),
+			Status:  status,
+		})
+	}
+	sort.Slice(m.Cases, func(i, j int) bool { return m.Cases[i].ID < m.Cases[j].ID })
+	sort.Ints(m.Pruned)
+	return m
+}
+
+// writeManifest writes the manifest as indented JSON.
+func writeManifest(file string, m uploadManifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, append(data, '\n'), 0644)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/docker/trailer/spec"
+)
+
+func TestUploadManifest(t *testing.T) {
+	parsed := map[int]spec.Update{
+		1: {Status: spec.Passed, Suite: "accounts", Test: "TestRailC1 login", Elapsed: time.Second},
+		2: {Status: spec.Failed, Suite: "accounts", Test: "TestRailC2 logout"},
+		3: {Status: spec.Passed, Suite: "billing", Test: "TestRailC3 refund"},
+	}
+
+	m := newUploadManifest("https://testrail", 7, parsed, map[int]bool{2: true}, map[int]bool{3: true}, false, time.Now())
+	assert.Equal(t, "https://testrail/index.php?/runs/view/7", m.RunURL)
+	assert.Equal(t, map[string]int{caseUploaded: 1, caseRecorded: 1, caseDropped: 1}, m.Counts)
+	assert.Equal(t, []int{3}, m.Pruned)
+	assert.Equal(t, manifestCase{
+		ID: 1, URL: "https://testrail/index.php?/cases/view/1", Suite: "accounts", Test: "TestRailC1 login", Outcome: "passed", Elapsed: 1, Status: caseUploaded,
+	}, m.Cases[0])
+
+	m = newUploadManifest("https://testrail", 7, parsed, nil, nil, true, time.Now())
+	assert.Equal(t, map[string]int{caseNotUpload: 3}, m.Counts)
+}
+
+func TestUploadResultManifest(t *testing.T) {
+	s, stop := startFake(t)
+	defer stop()
+
+	dir, err := ioutil.TempDir("", "manifest")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	runID := strconv.Itoa(s.AddRun(1, 2, 11, 12).ID)
+	report := writeReport(t, dir, `<testsuite name="accounts" tests="2">
+  <testcase name="TestRailC11 login" time="1"></testcase>
+  <testcase name="TestRailC12 logout" time="2"><failure>still logged in</failure></testcase>
+</testsuite>`)
+
+	file := filepath.Join(dir, "manifest.json")
+	read := func() uploadManifest {
+		data, err := ioutil.ReadFile(file)
+		assert.NoError(t, err)
+		var m uploadManifest
+		assert.NoError(t, json.Unmarshal(data, &m))
+		return m
+	}
+
+	assert.NoError(t, run("upload", "--idempotent", "--run-id", runID, "--result-manifest", file, report))
+	assert.Equal(t, map[string]int{caseUploaded: 2}, read().Counts)
+
+	assert.NoError(t, run("upload", "--idempotent", "--run-id", runID, "--result-manifest", file, report))
+	assert.Equal(t, map[string]int{caseRecorded: 2}, read().Counts)
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/urfave/cli"
+
+	"github.com/docker/trailer/pkg/download"
+)
+
+// resolveConflict returns the index of the entry of the conflict to keep
+// with the strategy prefer, or false if prefer is empty.
+func resolveConflict(conflict download.Conflict, suites []download.Suite, prefer string) (int, bool) {
+	switch prefer {
+	case "first":
+		return 0, true
+	case "last":
+		return len(conflict.Entries) - 1, true
+	case "newest":
+		newest, newestTime := 0, time.Time{}
+		for i, s := range conflict.Suites {
+			updated, err := time.Parse(time.RFC3339Nano, suites[s].LastUpdated)
+			if err == nil && updated.After(newestTime) {
+				newest, newestTime = i, updated
+			}
+		}
+		return newest, true
+	}
+	return 0, false
+}
+
+func mergeCommand() cli.Command {
+	return cli.Command{
+		Name:      "merge",
+		Usage:     "Combine cases files into one",
+		ArgsUsage: "[cases files...]",
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "out, o",
+				Usage: "file to write the combined cases to, printed by default",
+			},
+			cli.StringFlag{
+				Name:  "prefer",
+				Usage: "resolve cases the files disagree on with the entry of the first or last file listed, or of the newest file, instead of failing",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			if c.NArg() == 0 {
+				return configErrorf("Must specify the cases files to merge")
+			}
+			prefer := c.String("prefer")
+			if prefer != "" && prefer != "first" && prefer != "last" && prefer != "newest" {
+				return configErrorf("--prefer must be first, last or newest")
+			}
+
+			files := c.Args()
+			suites := []download.Suite{}
+			for _, file := range files {
+				s, err := download.Load(file)
+				if err != nil {
+					return parseErrorf("Error reading cases file %s: %s", file, err)
+				}
+				suites = append(suites, s)
+			}
+
+			merged, conflicts := download.Merge(suites)
+			unresolved := 0
+			for _, conflict := range conflicts {
+				keep, ok := resolveConflict(conflict, suites, prefer)
+				if !ok {
+					unresolved++
+					for i, s := range conflict.Suites {
+						fmt.Printf("conflict C%d: %s has %q\n", conflict.ID, files[s], conflict.Entries[i].Title)
+					}
+					continue
+				}
+				merged.Cases[conflict.ID] = conflict.Entries[keep]
+				if title, ok := suites[conflict.Suites[keep]].Base[conflict.ID]; ok {
+					merged.Base[conflict.ID] = title
+				}
+			}
+			if unresolved > 0 {
+				return fmt.Errorf("%d cases differ between the files, make them agree or rerun with --prefer", unresolved)
+			}
+			if merged.ProjectID == 0 {
+				slog.Warn("The files are of different projects or suites, the merged file has none")
+			}
+
+			data, err := download.Encode(merged)
+			if err != nil {
+				return fmt.Errorf("Error marshaling suite data: %s", err)
+			}
+			if c.String("out") == "" {
+				fmt.Print(string(data))
+				return nil
+			}
+			if err := download.WriteFile(c.String("out"), data); err != nil {
+				return fmt.Errorf("Error writing merged cases file: %s", err)
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/docker/trailer/pkg/download"
+)
+
+func TestMerge(t *testing.T) {
+	dir, err := ioutil.TempDir("", "merge")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	write := func(name, body string) string {
+		file := filepath.Join(dir, name)
+		assert.NoError(t, ioutil.WriteFile(file, []byte(body), 0644))
+		return file
+	}
+	accounts := write("accounts.yaml", `project_id: 1
+suite_id: 2
+last_updated: "2020-01-02T00:00:00Z"
+cases:
+  11: Login works
+  12: Logout works
+`)
+	billing := write("billing.yaml", `project_id: 1
+suite_id: 2
+last_updated: "2020-01-03T00:00:00Z"
+cases:
+  12: Logout always works
+  13: Refund works
+`)
+	out := filepath.Join(dir, "combined.yaml")
+
+	assert.Error(t, run("merge", "-o", out, accounts, billing))
+	_, err = os.Stat(out)
+	assert.True(t, os.IsNotExist(err))
+
+	for prefer, title := range map[string]string{"first": "Logout works", "last": "Logout always works", "newest": "Logout always works"} {
+		assert.NoError(t, run("merge", "--prefer", prefer, "-o", out, accounts, billing))
+		s, err := download.Load(out)
+		assert.NoError(t, err)
+		assert.Equal(t, map[int]string{11: "Login works", 12: title, 13: "Refund works"}, s.Titles(), prefer)
+		assert.Equal(t, "2020-01-02T00:00:00Z", s.LastUpdated)
+		assert.Equal(t, 1, s.ProjectID)
+	}
+
+	assert.Equal(t, exitConfig, exitCode(run("merge", "--prefer", "random", accounts, billing)))
+	assert.Equal(t, exitParse, exitCode(run("merge", accounts, filepath.Join(dir, "missing.yaml"))))
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli"
+
+	"github.com/docker/trailer/pkg/metrics"
+)
+
+var metricsPushgatewayFlag = cli.StringFlag{
+	Name:   "metrics-pushgateway",
+	Usage:  "push the metrics of the command to this Prometheus Pushgateway URL when it ends",
+	EnvVar: "TRAILER_METRICS_PUSHGATEWAY",
+}
+
+// metricsJob is the Pushgateway job the metrics are pushed as.
+const metricsJob = "trailer"
+
+var (
+	metricsRegistry = metrics.NewRegistry()
+
+	resultsUploaded = metricsRegistry.Counter("trailer_results_uploaded_total",
+		"Results TestRail recorded.")
+	uploadFailures = metricsRegistry.Counter("trailer_upload_failures_total",
+		"Uploads that failed.")
+	apiRequestDuration = metricsRegistry.Histogram("trailer_api_request_duration_seconds",
+		"Duration of TestRail API requests, by endpoint and status code.", metrics.DefaultBuckets, "endpoint", "code")
+)
+
+// setupMetrics times the TestRail API requests of both clients. The returned
+// function restores the network transport.
+func setupMetrics() func() {
+	transport := http.DefaultTransport
+	http.DefaultTransport = metricsTransport{next: transport}
+	return func() { http.DefaultTransport = transport }
+}
+
+// metricsTransport observes the duration of the API requests going through
+// next.
+type metricsTransport struct {
+	next http.RoundTripper
+}
+
+func (t metricsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+
+	endpoint := apiEndpoint(req.URL.RawQuery)
+	if endpoint == "" {
+		return resp, err
+	}
+	code := "error"
+	if err == nil {
+		code = strconv.Itoa(resp.StatusCode)
+	}
+	apiRequestDuration.Observe(time.Since(start).Seconds(), endpoint, code)
+	return resp, err
+}
+
+// apiEndpoint returns the name of the API endpoint in the query of a request
+// URL, such as get_tests for /index.php?/api/v2/get_tests/1&status_id=5,
+// without the IDs that would make every run a separate series.
+func apiEndpoint(query string) string {
+	i := strings.Index(query, "api/v2/")
+	if i < 0 {
+		return ""
+	}
+	endpoint := query[i+len("api/v2/"):]
+	if j := strings.IndexAny(endpoint, "/&"); j >= 0 {
+		endpoint = endpoint[:j]
+	}
+	return endpoint
+}
+
+// isAPIRequest reports whether req calls the TestRail API, rather than a
+// webhook or another service trailer talks to.
+func isAPIRequest(req *http.Request) bool {
+	return strings.HasSuffix(req.URL.Path, "/index.php") && apiEndpoint(req.URL.RawQuery) != ""
+}
+
+// pushMetrics pushes the metrics to the Pushgateway if one is set. Failing
+// to does not fail the command.
+func pushMetrics(gateway string) {
+	if gateway == "" {
+		return
+	}
+	if err := metricsRegistry.Push(gateway, metricsJob); err != nil {
+		slog.Warn("Failed to push metrics", "pushgateway", gateway, "error", err)
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPIEndpoint(t *testing.T) {
+	for query, expected := range map[string]string{
+		"/api/v2/get_tests/12&status_id=5": "get_tests",
+		"/api/v2/add_results_for_cases/3":  "add_results_for_cases",
+		"/api/v2/get_statuses":             "get_statuses",
+		"/auth/login":                      "",
+	} {
+		assert.Equal(t, expected, apiEndpoint(query), query)
+	}
+}
+
+func TestPushMetrics(t *testing.T) {
+	s, stop := startFake(t)
+	defer stop()
+
+	dir, err := ioutil.TempDir("", "metrics")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	pushed := make(chan string, 1)
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := ioutil.ReadAll(r.Body)
+		pushed <- string(data)
+	}))
+	defer gateway.Close()
+
+	runID := strconv.Itoa(s.AddRun(1, 2, 11, 12).ID)
+	report := writeReport(t, dir, `<testsuite name="accounts" tests="1">
+  <testcase name="TestRailC11 login" time="1"></testcase>
+</testsuite>`)
+
+	before := resultsUploaded.Value()
+	assert.NoError(t, run("--metrics-pushgateway", gateway.URL, "upload", "--run-id", runID, report))
+	assert.Equal(t, before+1, resultsUploaded.Value())
+
+	body := <-pushed
+	assert.Contains(t, body, "trailer_results_uploaded_total ")
+	assert.Contains(t, body, `trailer_api_request_duration_seconds_count{endpoint="add_results_for_cases",code="200"}`)
+}
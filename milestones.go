@@ -0,0 +1,197 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/urfave/cli"
+)
+
+// milestone mirrors testrail.Milestone but keeps the parent/child fields
+// needed to work with sub-milestones.
+type milestone struct {
+	ID          int         `json:"id"`
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	ProjectID   int         `json:"project_id"`
+	ParentID    int         `json:"parent_id"`
+	DueOn       int         `json:"due_on"`
+	IsCompleted bool        `json:"is_completed"`
+	CompletedOn int         `json:"completed_on"`
+	URL         string      `json:"url"`
+	Milestones  []milestone `json:"milestones"`
+}
+
+type sendableMilestone struct {
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description,omitempty"`
+	DueOn       int    `json:"due_on,omitempty"`
+	ParentID    int    `json:"parent_id,omitempty"`
+	IsCompleted bool   `json:"is_completed,omitempty"`
+}
+
+func milestonesCommand() cli.Command {
+	return cli.Command{
+		Name:  "milestones",
+		Usage: "Manage the milestones of a TestRail project",
+		Subcommands: []cli.Command{
+			{
+				Name:  "list",
+				Usage: "List milestones and their sub-milestones",
+				Flags: []cli.Flag{
+					cli.IntFlag{
+						Name:  "project-id, p",
+						Usage: "TestRail project ID to list milestones from",
+					},
+					cli.BoolFlag{
+						Name:  "all, a",
+						Usage: "include completed milestones",
+					},
+					outputFlag,
+				},
+				Action: func(c *cli.Context) error {
+					projectID := c.Int("project-id")
+					if projectID == 0 {
+						return configErrorf("Must set --project-id to a non-zero integer")
+					}
+
+					uri := fmt.Sprintf("get_milestones/%d", projectID)
+					if !c.Bool("all") {
+						uri += "&is_completed=0"
+					}
+
+					var milestones []milestone
+					client, err := newClient()
+					if err != nil {
+						return err
+					}
+					if err := client.send("GET", uri, nil, &milestones); err != nil {
+						return apiErrorf("Error getting milestones: %s", err)
+					}
+
+					err = render(c.String("output"), milestones, []string{"ID", "NAME", "DUE", "COMPLETED"}, milestoneRows(milestones, 0))
+					if err != nil {
+						return fmt.Errorf("Error printing milestones: %s", err)
+					}
+
+					return nil
+				},
+			},
+			{
+				Name:  "create",
+				Usage: "Create a milestone or sub-milestone",
+				Flags: []cli.Flag{
+					cli.IntFlag{
+						Name:  "project-id, p",
+						Usage: "TestRail project ID to create the milestone in",
+					},
+					cli.StringFlag{
+						Name:  "name, n",
+						Usage: "name of the milestone",
+					},
+					cli.StringFlag{
+						Name:  "description",
+						Usage: "description of the milestone",
+					},
+					cli.StringFlag{
+						Name:  "due-on",
+						Usage: "due date of the milestone (YYYY-MM-DD)",
+					},
+					cli.IntFlag{
+						Name:  "parent-id",
+						Usage: "ID of the milestone to create this one under",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					projectID := c.Int("project-id")
+					if projectID == 0 {
+						return configErrorf("Must set --project-id to a non-zero integer")
+					}
+
+					m := sendableMilestone{
+						Name:        c.String("name"),
+						Description: c.String("description"),
+						ParentID:    c.Int("parent-id"),
+					}
+					if m.Name == "" {
+						return configErrorf("Must set --name")
+					}
+
+					if dueOn := c.String("due-on"); dueOn != "" {
+						due, err := time.Parse("2006-01-02", dueOn)
+						if err != nil {
+							return configErrorf("Error parsing --due-on: %s", err)
+						}
+						m.DueOn = int(due.Unix())
+					}
+
+					var created milestone
+					client, err := newClient()
+					if err != nil {
+						return err
+					}
+					err = client.send("POST", fmt.Sprintf("add_milestone/%d", projectID), m, &created)
+					if err != nil {
+						return apiErrorf("Error creating milestone: %s", err)
+					}
+
+					statusf("Created milestone %d: %s\n", created.ID, created.Name)
+					return nil
+				},
+			},
+			{
+				Name:      "complete",
+				Usage:     "Mark milestones as completed",
+				ArgsUsage: "[milestone IDs...]",
+				Action: func(c *cli.Context) error {
+					if c.NArg() == 0 {
+						return configErrorf("Must specify at least one milestone ID")
+					}
+
+					client, err := newClient()
+					if err != nil {
+						return err
+					}
+					for _, arg := range c.Args() {
+						id, err := strconv.Atoi(arg)
+						if err != nil {
+							return configErrorf("Cannot convert string to int: %s", err)
+						}
+
+						var updated milestone
+						err = client.send("POST", fmt.Sprintf("update_milestone/%d", id), sendableMilestone{IsCompleted: true}, &updated)
+						if err != nil {
+							return apiErrorf("Error completing milestone %d: %s", id, err)
+						}
+
+						statusf("Completed milestone %d: %s\n", updated.ID, updated.Name)
+					}
+
+					return nil
+				},
+			},
+		},
+	}
+}
+
+// milestoneRows returns one row per milestone, indenting sub-milestones
+// beneath their parent.
+func milestoneRows(milestones []milestone, depth int) [][]string {
+	rows := [][]string{}
+	for _, m := range milestones {
+		due := "-"
+		if m.DueOn != 0 {
+			due = time.Unix(int64(m.DueOn), 0).Format("2006-01-02")
+		}
+
+		name := m.Name
+		for i := 0; i < depth; i++ {
+			name = "  " + name
+		}
+
+		rows = append(rows, []string{strconv.Itoa(m.ID), name, due, strconv.FormatBool(m.IsCompleted)})
+		rows = append(rows, milestoneRows(m.Milestones, depth+1)...)
+	}
+	return rows
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/urfave/cli"
+
+	"github.com/docker/trailer/pkg/config"
+)
+
+var notifyFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "slack-webhook",
+		Usage: "post a summary of the upload to this Slack incoming webhook URL, slack_webhook in the config file or its TRAILER_PROFILE profile by default",
+	},
+	cli.StringFlag{
+		Name:  "teams-webhook",
+		Usage: "post a summary of the upload to this Microsoft Teams incoming webhook URL",
+	},
+	cli.StringFlag{
+		Name:  "webhook",
+		Usage: "post a summary of the upload as JSON to this URL",
+	},
+	cli.StringFlag{
+		Name:  "webhook-template",
+		Usage: "Go template file rendering the payload of --webhook from the summary",
+	},
+}
+
+// topFailures is how many failures notifications list.
+const topFailures = 5
+
+// notifyTimeout bounds posting a notification, which should not hold up the
+// pipeline it reports on.
+const notifyTimeout = 30 * time.Second
+
+// uploadSummary is what notifications tell about an upload, and the data of
+// notification templates.
+type uploadSummary struct {
+	RunID       int    `json:"run_id"`
+	RunURL      string `json:"run_url"`
+	Passed      int    `json:"passed"`
+	Failed      int    `json:"failed"`
+	Skipped     int    `json:"skipped"`
+	NotUploaded int    `json:"not_uploaded"`
+	// Failures are the first failed cases, MoreFailures counts the others.
+	Failures     []summaryFailure `json:"failures"`
+	MoreFailures int              `json:"more_failures"`
+}
+
+type summaryFailure struct {
+	ID      int    `json:"id"`
+	URL     string `json:"url"`
+	Test    string `json:"test"`
+	Message string `json:"message"`
+}
+
+// newUploadSummary summarizes the report, keeping the first topFailures
+// failures.
+func newUploadSummary(r uploadReport) uploadSummary {
+	s := uploadSummary{
+		RunID:       r.RunID,
+		RunURL:      r.RunURL,
+		Passed:      r.Totals.Passed,
+		Failed:      r.Totals.Failed,
+		Skipped:     r.Totals.Skipped,
+		NotUploaded: r.Totals.NotUploaded,
+		Failures:    []summaryFailure{},
+	}
+	for _, suite := range r.Suites {
+		for _, c := range suite.Cases {
+			if c.Status != "failed" {
+				continue
+			}
+			if len(s.Failures) == topFailures {
+				s.MoreFailures++
+				continue
+			}
+			s.Failures = append(s.Failures, summaryFailure{ID: c.ID, URL: c.URL, Test: c.Test, Message: firstLine(c.Excerpt)})
+		}
+	}
+	return s
+}
+
+// countsText summarizes the outcomes of the results.
+func (s uploadSummary) countsText() string {
+	text := fmt.Sprintf("%d passed, %d failed, %d skipped", s.Passed, s.Failed, s.Skipped)
+	if s.NotUploaded > 0 {
+		text += fmt.Sprintf(", %d not uploaded", s.NotUploaded)
+	}
+	return text
+}
+
+// slackMessage formats the summary as a Slack message, in Slack's mrkdwn.
+func slackMessage(s uploadSummary) map[string]interface{} {
+	lines := []string{fmt.Sprintf("*<%s|Run %d>*: %s", s.RunURL, s.RunID, s.countsText())}
+	for _, f := range s.Failures {
+		lines = append(lines, fmt.Sprintf("• <%s|C%d> %s: %s", f.URL, f.ID, slackEscape(f.Test), slackEscape(f.Message)))
+	}
+	if s.MoreFailures > 0 {
+		lines = append(lines, fmt.Sprintf("and %d more failures", s.MoreFailures))
+	}
+	return map[string]interface{}{"text": strings.Join(lines, "\n")}
+}
+
+// slackEscape escapes the characters Slack uses for links and mentions.
+func slackEscape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
+
+// teamsMessage formats the summary as a Microsoft Teams message card.
+func teamsMessage(s uploadSummary) map[string]interface{} {
+	color := "2E7D32"
+	if s.Failed > 0 {
+		color = "C62828"
+	}
+
+	facts := []map[string]string{}
+	for _, f := range s.Failures {
+		facts = append(facts, map[string]string{"name": fmt.Sprintf("[C%d](%s) %s", f.ID, f.URL, f.Test), "value": f.Message})
+	}
+	if s.MoreFailures > 0 {
+		facts = append(facts, map[string]string{"name": "More failures", "value": fmt.Sprint(s.MoreFailures)})
+	}
+
+	title := fmt.Sprintf("Run %d: %s", s.RunID, s.countsText())
+	return map[string]interface{}{
+		"@type":      "MessageCard",
+		"@context":   "https://schema.org/extensions",
+		"summary":    title,
+		"title":      title,
+		"themeColor": color,
+		"sections":   []map[string]interface{}{{"facts": facts}},
+		"potentialAction": []map[string]interface{}{{
+			"@type":   "OpenUri",
+			"name":    "Open run",
+			"targets": []map[string]string{{"os": "default", "uri": s.RunURL}},
+		}},
+	}
+}
+
+// firstLine returns the first non-empty line of s.
+func firstLine(s string) string {
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			return line
+		}
+	}
+	return ""
+}
+
+// notifications returns the targets set with flags followed by those of the
+// config file, which are those of the TRAILER_PROFILE profile when it sets
+// any.
+func notifications(c *cli.Context) []config.Notification {
+	cfg, _ := config.Load(config.File())
+
+	targets := []config.Notification{}
+	slack := c.String("slack-webhook")
+	if slack == "" {
+		slack = cfg.SlackWebhook
+	}
+	if slack != "" {
+		targets = append(targets, config.Notification{Type: "slack", URL: slack})
+	}
+	if url := c.String("teams-webhook"); url != "" {
+		targets = append(targets, config.Notification{Type: "teams", URL: url})
+	}
+	if url := c.String("webhook"); url != "" {
+		targets = append(targets, config.Notification{Type: "webhook", URL: url, Template: c.String("webhook-template")})
+	}
+	return append(targets, cfg.Notifications...)
+}
+
+// payload renders the summary for the target.
+func payload(n config.Notification, s uploadSummary) ([]byte, error) {
+	if n.Template != "" {
+		text, err := ioutil.ReadFile(n.Template)
+		if err != nil {
+			return nil, err
+		}
+		t, err := template.New(n.Template).Funcs(template.FuncMap{"json": toJSON}).Parse(string(text))
+		if err != nil {
+			return nil, err
+		}
+		var b bytes.Buffer
+		if err := t.Execute(&b, s); err != nil {
+			return nil, err
+		}
+		return b.Bytes(), nil
+	}
+
+	switch n.Type {
+	case "slack":
+		return json.Marshal(slackMessage(s))
+	case "teams":
+		return json.Marshal(teamsMessage(s))
+	case "webhook":
+		return json.Marshal(s)
+	}
+	return nil, fmt.Errorf("unknown notification type %q", n.Type)
+}
+
+// toJSON encodes v for templates to embed values in JSON payloads.
+func toJSON(v interface{}) (string, error) {
+	data, err := json.Marshal(v)
+	return string(data), err
+}
+
+// notify posts the summary to every target, logging the ones that fail
+// since the upload itself succeeded.
+func notify(targets []config.Notification, s uploadSummary) {
+	for _, n := range targets {
+		data, err := payload(n, s)
+		if err == nil {
+			err = postJSON(n.URL, data)
+		}
+		if err != nil {
+			slog.Warn("Failed to post the upload summary", "type", n.Type, "error", err)
+		}
+	}
+}
+
+// postJSON posts a JSON payload to a webhook.
+func postJSON(url string, data []byte) error {
+	client := http.Client{Timeout: notifyTimeout}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook answered %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/docker/trailer/pkg/config"
+	"github.com/docker/trailer/spec"
+)
+
+func TestSlackMessage(t *testing.T) {
+	parsed := map[int]spec.Update{1: {Status: spec.Passed, Suite: "accounts", Test: "login"}}
+	for i := 2; i < 9; i++ {
+		parsed[i] = spec.Update{Status: spec.Failed, Suite: "accounts", Test: "<logout>", Failure: "\nstill logged in\nat line 3"}
+	}
+
+	summary := newUploadSummary(newUploadReport("https://testrail", 7, parsed, nil, false))
+	assert.Len(t, summary.Failures, 5)
+	assert.Equal(t, 2, summary.MoreFailures)
+
+	text := slackMessage(summary)["text"]
+	assert.Equal(t, `*<https://testrail/index.php?/runs/view/7|Run 7>*: 1 passed, 7 failed, 0 skipped
+• <https://testrail/index.php?/cases/view/2|C2> &lt;logout&gt;: still logged in
+• <https://testrail/index.php?/cases/view/3|C3> &lt;logout&gt;: still logged in
+• <https://testrail/index.php?/cases/view/4|C4> &lt;logout&gt;: still logged in
+• <https://testrail/index.php?/cases/view/5|C5> &lt;logout&gt;: still logged in
+• <https://testrail/index.php?/cases/view/6|C6> &lt;logout&gt;: still logged in
+and 2 more failures`, text)
+}
+
+func TestNotificationPayloads(t *testing.T) {
+	dir, err := ioutil.TempDir("", "notify")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	summary := uploadSummary{RunID: 7, RunURL: "https://testrail/index.php?/runs/view/7", Passed: 1, Failed: 1, Failures: []summaryFailure{
+		{ID: 2, URL: "https://testrail/index.php?/cases/view/2", Test: "logout", Message: "still \"logged\" in"},
+	}}
+
+	data, err := payload(config.Notification{Type: "teams"}, summary)
+	assert.NoError(t, err)
+	var card map[string]interface{}
+	assert.NoError(t, json.Unmarshal(data, &card))
+	assert.Equal(t, "MessageCard", card["@type"])
+	assert.Equal(t, "Run 7: 1 passed, 1 failed, 0 skipped", card["title"])
+	assert.Equal(t, "C62828", card["themeColor"])
+
+	data, err = payload(config.Notification{Type: "webhook"}, summary)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"run_url":"https://testrail/index.php?/runs/view/7"`)
+
+	tmpl := filepath.Join(dir, "payload.tmpl")
+	assert.NoError(t, ioutil.WriteFile(tmpl, []byte(`{"run": {{.RunID}}{{range .Failures}}, "C{{.ID}}": {{json .Message}}{{end}}}`), 0644))
+	data, err = payload(config.Notification{Type: "webhook", Template: tmpl}, summary)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"run": 7, "C2": "still \"logged\" in"}`, string(data))
+
+	_, err = payload(config.Notification{Type: "pager"}, summary)
+	assert.Error(t, err)
+}
+
+func TestUploadNotify(t *testing.T) {
+	s, stop := startFake(t)
+	defer stop()
+
+	dir, err := ioutil.TempDir("", "slack")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	messages := make(chan map[string]interface{}, 2)
+	slack := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var m map[string]interface{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&m))
+		messages <- m
+	}))
+	defer slack.Close()
+
+	runID := strconv.Itoa(s.AddRun(1, 2, 11, 12).ID)
+	report := writeReport(t, dir, `<testsuite name="accounts" tests="1">
+  <testcase name="TestRailC11 login" time="1"></testcase>
+</testsuite>`)
+
+	assert.NoError(t, run("upload", "--run-id", runID, "--slack-webhook", slack.URL, "--webhook", slack.URL, report))
+	assert.Contains(t, (<-messages)["text"], "1 passed, 0 failed, 0 skipped")
+	assert.Equal(t, float64(1), (<-messages)["passed"])
+
+	// The upload does not fail when Slack does.
+	slack.Close()
+	assert.NoError(t, run("upload", "--run-id", runID, "--slack-webhook", slack.URL, report))
+}
+
+func TestProfileNotify(t *testing.T) {
+	s, stop := startFake(t)
+	defer stop()
+
+	dir, err := ioutil.TempDir("", "notify")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	posted := make(chan string, 2)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		posted <- r.URL.Path
+	}))
+	defer webhook.Close()
+
+	file := filepath.Join(dir, "trailer.yml")
+	assert.NoError(t, ioutil.WriteFile(file, []byte(`notifications:
+- type: webhook
+  url: `+webhook.URL+`/main
+profiles:
+  staging:
+    notifications:
+    - type: webhook
+      url: `+webhook.URL+`/staging
+`), 0600))
+	os.Setenv("TRAILER_CONFIG", file)
+
+	runID := strconv.Itoa(s.AddRun(1, 2, 11, 12).ID)
+	report := writeReport(t, dir, `<testsuite name="accounts" tests="1">
+  <testcase name="TestRailC11 login" time="1"></testcase>
+</testsuite>`)
+
+	assert.NoError(t, run("upload", "--run-id", runID, report))
+	assert.Equal(t, "/main", <-posted)
+
+	os.Setenv("TRAILER_PROFILE", "staging")
+	assert.NoError(t, run("upload", "--run-id", runID, report))
+	assert.Equal(t, "/staging", <-posted)
+	assert.Empty(t, posted)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/urfave/cli"
+)
+
+// entityPages are the TestRail pages of the kinds of entities open knows,
+// with the letter TestRail shows before their IDs.
+var entityPages = map[string]struct {
+	prefix, page string
+}{
+	"case":      {"C", "cases/view"},
+	"milestone": {"M", "milestones/view"},
+	"plan":      {"R", "plans/view"},
+	"project":   {"P", "projects/overview"},
+	"run":       {"R", "runs/view"},
+	"suite":     {"S", "suites/view"},
+	"test":      {"T", "tests/view"},
+}
+
+// entityURL returns the URL of the page of an entity, such as case C11.
+func entityURL(baseURL, kind, id string) (string, error) {
+	p, ok := entityPages[kind]
+	if !ok {
+		kinds := []string{}
+		for k := range entityPages {
+			kinds = append(kinds, k)
+		}
+		sort.Strings(kinds)
+		return "", fmt.Errorf("unknown kind %q, one of %s", kind, strings.Join(kinds, ", "))
+	}
+	n, err := strconv.Atoi(strings.TrimPrefix(strings.ToUpper(id), p.prefix))
+	if err != nil || n <= 0 {
+		return "", fmt.Errorf("bad %s ID %q", kind, id)
+	}
+	return fmt.Sprintf("%s/index.php?/%s/%d", baseURL, p.page, n), nil
+}
+
+// openBrowser opens url in the browser set in $BROWSER, or the default one.
+func openBrowser(url string) error {
+	var cmd *exec.Cmd
+	switch browser := os.Getenv("BROWSER"); {
+	case browser != "":
+		cmd = exec.Command(browser, url)
+	case runtime.GOOS == "darwin":
+		cmd = exec.Command("open", url)
+	case runtime.GOOS == "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+	return cmd.Run()
+}
+
+func openCommand() cli.Command {
+	return cli.Command{
+		Name:      "open",
+		Usage:     "Open the TestRail page of a case, run, test, suite, plan, milestone or project",
+		ArgsUsage: "KIND ID, such as case C11 or run 42",
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "print",
+				Usage: "print the URL instead of opening it",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			if c.NArg() != 2 {
+				return configErrorf("Must specify the kind and ID of what to open, such as case C11")
+			}
+			url, err := entityURL(instanceFromEnv(), c.Args().Get(0), c.Args().Get(1))
+			if err != nil {
+				return configErrorf("Error opening %s: %s", strings.Join(c.Args(), " "), err)
+			}
+			if c.Bool("print") {
+				fmt.Println(url)
+				return nil
+			}
+			if err := openBrowser(url); err != nil {
+				return fmt.Errorf("Error opening %s, rerun with --print to get the URL: %s", url, err)
+			}
+			return nil
+		},
+	}
+}
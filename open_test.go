@@ -0,0 +1,63 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEntityURL(t *testing.T) {
+	for _, tc := range []struct {
+		kind, id string
+		url      string
+		err      string
+	}{
+		{kind: "case", id: "C5678", url: "https://testrail.example.com/index.php?/cases/view/5678"},
+		{kind: "case", id: "c12", url: "https://testrail.example.com/index.php?/cases/view/12"},
+		{kind: "run", id: "1234", url: "https://testrail.example.com/index.php?/runs/view/1234"},
+		{kind: "run", id: "R7", url: "https://testrail.example.com/index.php?/runs/view/7"},
+		{kind: "test", id: "T9", url: "https://testrail.example.com/index.php?/tests/view/9"},
+		{kind: "project", id: "1", url: "https://testrail.example.com/index.php?/projects/overview/1"},
+		{kind: "case", id: "R7", err: `bad case ID "R7"`},
+		{kind: "report", id: "1", err: `unknown kind "report", one of case, milestone, plan, project, run, suite, test`},
+	} {
+		url, err := entityURL("https://testrail.example.com", tc.kind, tc.id)
+		if tc.err != "" {
+			assert.EqualError(t, err, tc.err)
+			continue
+		}
+		assert.NoError(t, err)
+		assert.Equal(t, tc.url, url)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	s, stop := startFake(t)
+	defer stop()
+
+	dir, err := ioutil.TempDir("", "open")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	opened := filepath.Join(dir, "opened")
+	browser := filepath.Join(dir, "browser")
+	assert.NoError(t, ioutil.WriteFile(browser, []byte("#!/bin/sh\necho \"$1\" > "+opened+"\n"), 0755))
+	defer os.Setenv("BROWSER", os.Getenv("BROWSER"))
+	os.Setenv("BROWSER", browser)
+
+	assert.NoError(t, run("open", "case", "C11"))
+	data, err := ioutil.ReadFile(opened)
+	assert.NoError(t, err)
+	assert.Equal(t, s.URL+"/index.php?/cases/view/11\n", string(data))
+
+	assert.NoError(t, os.Remove(opened))
+	assert.NoError(t, run("open", "--print", "run", "42"))
+	_, err = os.Stat(opened)
+	assert.True(t, os.IsNotExist(err))
+
+	assert.Equal(t, exitConfig, exitCode(run("open", "case")))
+	assert.Equal(t, exitConfig, exitCode(run("open", "case", "Cx")))
+}
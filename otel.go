@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"github.com/docker/trailer/pkg/tracing"
+)
+
+// tracer records the spans of the running command when OpenTelemetry
+// tracing is configured, and is nil otherwise.
+var tracer *tracing.Tracer
+
+// setupOTel starts the span of the command in ctx and traces the TestRail
+// API requests of both clients under it when an OTLP endpoint is set. The
+// returned function ends the span, exports the spans and restores the
+// network transport.
+func setupOTel(ctx context.Context, command string) (context.Context, func()) {
+	tracer = tracing.FromEnv()
+	if tracer == nil {
+		return ctx, func() {}
+	}
+
+	ctx, root := tracer.Start(ctx, "trailer "+command, tracing.KindInternal)
+	transport := http.DefaultTransport
+	http.DefaultTransport = otelTransport{root: root, next: transport}
+
+	return ctx, func() {
+		http.DefaultTransport = transport
+		root.Finish(nil)
+		if err := tracer.Export(); err != nil {
+			slog.Warn("Failed to export traces", "error", err)
+		}
+		tracer = nil
+	}
+}
+
+// otelTransport traces the API requests going through next, under the span
+// of the request context or else root.
+type otelTransport struct {
+	root *tracing.Span
+	next http.RoundTripper
+}
+
+func (t otelTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	endpoint := apiEndpoint(req.URL.RawQuery)
+	if endpoint == "" {
+		return t.next.RoundTrip(req)
+	}
+
+	ctx := req.Context()
+	if tracing.SpanFromContext(ctx) == nil {
+		ctx = tracing.ContextWithSpan(ctx, t.root)
+	}
+	_, span := tracer.Start(ctx, "TestRail "+endpoint, tracing.KindClient)
+	span.SetAttr("http.method", req.Method)
+	span.SetAttr("testrail.endpoint", endpoint)
+
+	req = req.Clone(req.Context())
+	req.Header.Set("traceparent", span.Context().Traceparent())
+	resp, err := t.next.RoundTrip(req)
+	if err == nil {
+		span.SetAttr("http.status_code", resp.StatusCode)
+		if resp.StatusCode >= 400 {
+			span.Finish(httpStatusError(resp.Status))
+			return resp, err
+		}
+	}
+	span.Finish(err)
+	return resp, err
+}
+
+// httpStatusError marks spans of requests that failed with a status.
+type httpStatusError string
+
+func (e httpStatusError) Error() string { return string(e) }
+
+// startSpan starts a span of the command's work in ctx.
+func startSpan(ctx context.Context, name string, attrs map[string]interface{}) (context.Context, *tracing.Span) {
+	ctx, span := tracer.Start(ctx, name, tracing.KindInternal)
+	for k, v := range attrs {
+		span.SetAttr(k, v)
+	}
+	return ctx, span
+}
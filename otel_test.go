@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOTelTracing(t *testing.T) {
+	s, stop := startFake(t)
+	defer stop()
+
+	dir, err := ioutil.TempDir("", "otel")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	exported := make(chan []byte, 1)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/traces", r.URL.Path)
+		assert.Equal(t, "secret", r.Header.Get("Api-Key"))
+		data, _ := ioutil.ReadAll(r.Body)
+		exported <- data
+	}))
+	defer collector.Close()
+
+	for k, v := range map[string]string{
+		"OTEL_EXPORTER_OTLP_ENDPOINT": collector.URL,
+		"OTEL_EXPORTER_OTLP_HEADERS":  "api-key=secret",
+		"TRACEPARENT":                 "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01",
+	} {
+		defer os.Unsetenv(k)
+		os.Setenv(k, v)
+	}
+
+	runID := strconv.Itoa(s.AddRun(1, 2, 11, 12).ID)
+	report := writeReport(t, dir, `<testsuite name="accounts" tests="1">
+  <testcase name="TestRailC11 login" time="1"></testcase>
+</testsuite>`)
+	assert.NoError(t, run("upload", "--run-id", runID, report))
+
+	var payload struct {
+		ResourceSpans []struct {
+			ScopeSpans []struct {
+				Spans []struct {
+					TraceID string `json:"traceId"`
+					Name    string `json:"name"`
+				} `json:"spans"`
+			} `json:"scopeSpans"`
+		} `json:"resourceSpans"`
+	}
+	assert.NoError(t, json.Unmarshal(<-exported, &payload))
+	names := []string{}
+	for _, span := range payload.ResourceSpans[0].ScopeSpans[0].Spans {
+		assert.Equal(t, "0af7651916cd43dd8448eb211c80319c", span.TraceID)
+		names = append(names, span.Name)
+	}
+	assert.Contains(t, names, "trailer upload")
+	assert.Contains(t, names, "parse reports")
+	assert.Contains(t, names, "upload results")
+	assert.Contains(t, names, "TestRail add_results_for_cases")
+}
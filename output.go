@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/urfave/cli"
+)
+
+var outputFlag = cli.StringFlag{
+	Name:  "output",
+	Usage: "output format, one of table, json, markdown or csv, defaults to the global --output",
+}
+
+// outputFlags set the output of every command.
+var outputFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "output",
+		Usage: "output format of the commands that print tables, one of table, json, markdown or csv; with json, other messages go to stderr",
+		Value: "table",
+	},
+	cli.BoolFlag{
+		Name:  "quiet, q",
+		Usage: "print nothing but errors",
+	},
+}
+
+// defaultOutput is the global --output format, used by the commands whose
+// own --output is not set.
+var defaultOutput string
+
+// quiet is set by --quiet.
+var quiet bool
+
+// setupOutput applies the output flags. With --quiet, stdout is discarded
+// until the returned function restores it.
+func setupOutput(format string, silent bool) (func(), error) {
+	switch format {
+	case "table", "json", "markdown", "csv":
+	default:
+		return func() {}, configErrorf("unknown output format %q", format)
+	}
+	defaultOutput, quiet = format, silent
+	if !quiet {
+		return func() {}, nil
+	}
+	null, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		return func() {}, err
+	}
+	stdout := os.Stdout
+	os.Stdout = null
+	return func() {
+		os.Stdout = stdout
+		null.Close()
+	}, nil
+}
+
+// outputFormat returns the format of a command's --output flag, falling back
+// to the global one.
+func outputFormat(format string) string {
+	if format == "" {
+		format = defaultOutput
+	}
+	if format == "" {
+		return "table"
+	}
+	return format
+}
+
+// statusf prints a message about what a command did. With JSON output the
+// messages go to stderr, so stdout holds nothing but JSON.
+func statusf(format string, args ...interface{}) {
+	if quiet {
+		return
+	}
+	w := os.Stdout
+	if defaultOutput == "json" {
+		w = os.Stderr
+	}
+	fmt.Fprintf(w, format, args...)
+}
+
+// summaryf prints the summary following the table of a command with the
+// given --output. JSON and CSV have no room for it, so it goes to stderr
+// with them.
+func summaryf(output, format string, args ...interface{}) {
+	if quiet {
+		return
+	}
+	w := os.Stdout
+	switch outputFormat(output) {
+	case "json", "csv":
+		w = os.Stderr
+	}
+	fmt.Fprintf(w, format, args...)
+}
+
+// render prints v as indented JSON when format is "json", header and rows
+// as a Markdown table when it is "markdown" or as CSV when it is "csv", and
+// otherwise as an aligned table. An empty format is the global --output.
+func render(format string, v interface{}, header []string, rows [][]string) error {
+	switch outputFormat(format) {
+	case "json":
+		data, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+	case "table":
+		w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(w, strings.Join(header, "\t"))
+		for _, row := range rows {
+			fmt.Fprintln(w, strings.Join(row, "\t"))
+		}
+		return w.Flush()
+	case "markdown":
+		fmt.Println(markdownRow(header))
+		separator := make([]string, len(header))
+		for i := range separator {
+			separator[i] = "---"
+		}
+		fmt.Println(markdownRow(separator))
+		for _, row := range rows {
+			fmt.Println(markdownRow(row))
+		}
+	case "csv":
+		w := csv.NewWriter(os.Stdout)
+		w.Write(header)
+		w.WriteAll(rows)
+		return w.Error()
+	default:
+		return fmt.Errorf("unknown output format %q", format)
+	}
+
+	return nil
+}
+
+// oneLine collapses multi-line descriptions so they fit in a table cell.
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// markdownRow formats the cells of a Markdown table row.
+func markdownRow(cells []string) string {
+	escaped := make([]string, len(cells))
+	for i, cell := range cells {
+		escaped[i] = strings.Replace(oneLine(cell), "|", "\\|", -1)
+	}
+	return "| " + strings.Join(escaped, " | ") + " |"
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/educlos/testrail"
+	"github.com/stretchr/testify/assert"
+)
+
+// captureStdout returns what f prints to stdout.
+func captureStdout(t *testing.T, f func()) string {
+	file, err := ioutil.TempFile("", "stdout")
+	assert.NoError(t, err)
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	stdout := os.Stdout
+	os.Stdout = file
+	defer func() { os.Stdout = stdout }()
+	f()
+
+	data, err := ioutil.ReadFile(file.Name())
+	assert.NoError(t, err)
+	return string(data)
+}
+
+func TestOutputFormat(t *testing.T) {
+	defer func(format string) { defaultOutput = format }(defaultOutput)
+
+	defaultOutput = ""
+	assert.Equal(t, "table", outputFormat(""))
+	defaultOutput = "json"
+	assert.Equal(t, "json", outputFormat(""))
+	assert.Equal(t, "csv", outputFormat("csv"))
+}
+
+func TestQuietAndJSONOutput(t *testing.T) {
+	_, stop := startFake(t)
+	defer stop()
+
+	out := captureStdout(t, func() {
+		assert.NoError(t, run("--output", "json", "suites", "list", "--project-id", "1"))
+	})
+	var suites []testrail.Suite
+	assert.NoError(t, json.Unmarshal([]byte(out), &suites), out)
+	assert.Len(t, suites, 1)
+
+	// The command's own --output wins over the global one.
+	out = captureStdout(t, func() {
+		assert.NoError(t, run("--output", "json", "suites", "list", "--project-id", "1", "--output", "csv"))
+	})
+	assert.Equal(t, "ID,NAME,DESCRIPTION\n2,Master,\n", out)
+
+	// Messages about what was done are not JSON.
+	out = captureStdout(t, func() {
+		assert.NoError(t, run("--output", "json", "runs", "close", "--project-id", "1", "--older-than", "1d"))
+	})
+	assert.Empty(t, out)
+
+	out = captureStdout(t, func() {
+		assert.NoError(t, run("--quiet", "suites", "list", "--project-id", "1"))
+	})
+	assert.Empty(t, out)
+
+	assert.Equal(t, exitConfig, exitCode(run("--output", "yaml", "suites", "list", "--project-id", "1")))
+}
+
+func TestUploadJSONOutput(t *testing.T) {
+	s, stop := startFake(t)
+	defer stop()
+
+	dir, err := ioutil.TempDir("", "output")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	runID := strconv.Itoa(s.AddRun(1, 2, 11, 12).ID)
+	report := writeReport(t, dir, `<testsuite name="accounts" tests="2">
+  <testcase name="TestRailC11 login" time="1"></testcase>
+  <testcase name="TestRailC12 logout" time="1"><failure message="boom"></failure></testcase>
+</testsuite>`)
+
+	out := captureStdout(t, func() {
+		assert.NoError(t, run("--output", "json", "upload", "--run-id", runID, report))
+	})
+	var results []map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(out), &results), out)
+	assert.Len(t, results, 2)
+
+	out = captureStdout(t, func() {
+		assert.NoError(t, run("--output", "csv", "upload", "--run-id", runID, report))
+	})
+	assert.True(t, strings.HasPrefix(out, "TEST,ELAPSED,STATUS\n"), out)
+}
+
+func TestDoctorJSONOutput(t *testing.T) {
+	s, stop := startFake(t)
+	defer stop()
+	s.Users = []testrail.User{{ID: 1, Email: "user@example.com"}}
+
+	out := captureStdout(t, func() {
+		assert.NoError(t, run("--output", "json", "doctor", "--project-id", "1"))
+	})
+	var checks []doctorCheck
+	assert.NoError(t, json.Unmarshal([]byte(out), &checks), out)
+	names := []string{}
+	for _, check := range checks {
+		assert.True(t, check.OK, check.Name)
+		assert.Empty(t, check.Advice, check.Name)
+		names = append(names, check.Name)
+	}
+	assert.Equal(t, []string{"reachability", "credentials", "rate limit", "project access"}, names)
+}
+
+func TestMilestoneRows(t *testing.T) {
+	milestones := []milestone{
+		{ID: 1, Name: "1.0", DueOn: 129600, Milestones: []milestone{{ID: 2, Name: "beta", IsCompleted: true}}},
+		{ID: 3, Name: "2.0"},
+	}
+	assert.Equal(t, [][]string{
+		{"1", "1.0", "1970-01-02", "false"},
+		{"2", "  beta", "-", "true"},
+		{"3", "2.0", "-", "false"},
+	}, milestoneRows(milestones, 0))
+}
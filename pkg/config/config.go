@@ -0,0 +1,166 @@
+// Package config reads and writes the trailer config file, which holds the
+// TestRail instance, credentials and default project and suite, and named
+// profiles replacing any of them.
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// DefaultFile is the config file used when TRAILER_CONFIG is not set.
+const DefaultFile = ".trailer.yml"
+
+// Config holds the settings written by trailer init. The environment
+// variables take precedence over the values read from the config file.
+// Token may be a secrets manager reference such as vault://secret/testrail#token,
+// see package secrets.
+type Config struct {
+	// Version is the version of the file format, see Version.
+	Version   int    `yaml:"version,omitempty"`
+	URL       string `yaml:"url,omitempty"`
+	Username  string `yaml:"username,omitempty"`
+	Token     string `yaml:"token,omitempty"`
+	ProjectID int    `yaml:"project_id,omitempty"`
+	SuiteID   int    `yaml:"suite_id,omitempty"`
+	CasesFile string `yaml:"cases_file,omitempty"`
+	// SlackWebhook is the Slack incoming webhook upload posts summaries
+	// to.
+	SlackWebhook string `yaml:"slack_webhook,omitempty"`
+	// Notifications are the other targets upload posts summaries to.
+	Notifications []Notification `yaml:"notifications,omitempty"`
+	// Email is the SMTP server and the recipients of email summaries.
+	Email Email `yaml:"email,omitempty"`
+	// Profiles are named settings, such as those of a staging instance,
+	// replacing the ones above when selected with TRAILER_PROFILE.
+	Profiles map[string]Config `yaml:"profiles,omitempty"`
+
+	// profile is the profile Load selected, which Save writes to.
+	profile string
+}
+
+// Email configures email summaries. Password may be a secrets manager
+// reference like Token.
+type Email struct {
+	// SMTP is the host:port of the SMTP server.
+	SMTP     string   `yaml:"smtp,omitempty"`
+	Username string   `yaml:"username,omitempty"`
+	Password string   `yaml:"password,omitempty"`
+	From     string   `yaml:"from,omitempty"`
+	To       []string `yaml:"to,omitempty"`
+	// Subject is a Go text/template of the subject, and Template a
+	// text/template file of the body, replacing those of the command.
+	Subject  string `yaml:"subject,omitempty"`
+	Template string `yaml:"template,omitempty"`
+}
+
+// Notification is a webhook upload posts a summary of its results to.
+type Notification struct {
+	// Type is slack, teams or webhook, which posts the summary as JSON.
+	Type string `yaml:"type"`
+	URL  string `yaml:"url"`
+	// Template is a Go text/template file rendering the payload from the
+	// summary instead of the format of the type.
+	Template string `yaml:"template,omitempty"`
+}
+
+// File returns the path of the config file, TRAILER_CONFIG if it is set and
+// DefaultFile in the working directory otherwise.
+func File() string {
+	if file := os.Getenv("TRAILER_CONFIG"); file != "" {
+		return file
+	}
+	return DefaultFile
+}
+
+// Profile returns the profile Load selects, TRAILER_PROFILE if it is set and
+// none otherwise.
+func Profile() string {
+	return os.Getenv("TRAILER_PROFILE")
+}
+
+// Load reads the config file with the settings of Profile, see LoadProfile.
+func Load(file string) (Config, error) {
+	return LoadProfile(file, Profile())
+}
+
+// LoadProfile reads the config file, upgrading files of older versions,
+// returning an empty config if it does not exist. The settings of the
+// profile, if any, replace those they set.
+func LoadProfile(file, profile string) (Config, error) {
+	var cfg Config
+
+	data, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) && profile == "" {
+		return cfg, nil
+	}
+	if os.IsNotExist(err) {
+		return cfg, fmt.Errorf("profile %s not found, %s does not exist", profile, file)
+	}
+	if err != nil {
+		return cfg, err
+	}
+
+	if data, err = migrate(data); err != nil {
+		return cfg, err
+	}
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return cfg, err
+	}
+	if profile == "" {
+		return cfg, nil
+	}
+
+	// The profile is decoded again over the settings of the file, which
+	// only replaces the ones it sets.
+	var raw struct {
+		Profiles map[string]yaml.MapSlice `yaml:"profiles"`
+	}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return cfg, err
+	}
+	settings, ok := raw.Profiles[profile]
+	if !ok {
+		return cfg, fmt.Errorf("profile %s not found in %s", profile, file)
+	}
+	if data, err = yaml.Marshal(settings); err != nil {
+		return cfg, err
+	}
+	version, profiles := cfg.Version, cfg.Profiles
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("profile %s: %s", profile, err)
+	}
+	cfg.Version, cfg.Profiles, cfg.profile = version, profiles, profile
+	return cfg, nil
+}
+
+// Save writes the config file at the current Version. A config loaded with a
+// profile is written to that profile in full, leaving the other settings of
+// the file alone. The file is only readable by the current user since it
+// holds the API token.
+func Save(file string, cfg Config) error {
+	if cfg.profile != "" {
+		base, err := LoadProfile(file, "")
+		if err != nil {
+			return err
+		}
+		if base.Profiles == nil {
+			base.Profiles = map[string]Config{}
+		}
+		name := cfg.profile
+		cfg.Version, cfg.Profiles, cfg.profile = 0, nil, ""
+		base.Profiles[name] = cfg
+		cfg = base
+	}
+	cfg.Version = Version
+
+	data, err := yaml.Marshal(&cfg)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(file, data, 0600)
+}
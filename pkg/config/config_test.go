@@ -0,0 +1,227 @@
+package config
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const profilesFile = `version: 1
+url: https://testrail.example.com
+username: ci@example.com
+project_id: 3
+notifications:
+- type: slack
+  url: https://hooks.slack.com/services/main
+email:
+  smtp: smtp.example.com:587
+  to: [qa@example.com]
+profiles:
+  staging:
+    url: https://staging.testrail.example.com
+    project_id: 4
+    notifications:
+    - type: teams
+      url: https://example.webhook.office.com/staging
+    email:
+      to: [staging@example.com]
+  empty: {}
+`
+
+func TestLoadProfile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "trailer.yml")
+	assert.NoError(t, ioutil.WriteFile(file, []byte(profilesFile), 0600))
+	base, err := LoadProfile(file, "")
+	assert.NoError(t, err)
+
+	testcases := []struct {
+		name    string
+		file    string
+		profile string
+		want    Config
+		err     bool
+	}{
+		{
+			name: "missing file",
+			file: filepath.Join(dir, "missing.yml"),
+			want: Config{},
+		},
+		{
+			name:    "missing file with a profile",
+			file:    filepath.Join(dir, "missing.yml"),
+			profile: "staging",
+			err:     true,
+		},
+		{
+			name: "no profile",
+			file: file,
+			want: Config{
+				Version:       1,
+				URL:           "https://testrail.example.com",
+				Username:      "ci@example.com",
+				ProjectID:     3,
+				Notifications: []Notification{{Type: "slack", URL: "https://hooks.slack.com/services/main"}},
+				Email:         Email{SMTP: "smtp.example.com:587", To: []string{"qa@example.com"}},
+				Profiles:      base.Profiles,
+			},
+		},
+		{
+			name:    "profile replaces the settings it sets",
+			file:    file,
+			profile: "staging",
+			want: Config{
+				Version:       1,
+				URL:           "https://staging.testrail.example.com",
+				Username:      "ci@example.com",
+				ProjectID:     4,
+				Notifications: []Notification{{Type: "teams", URL: "https://example.webhook.office.com/staging"}},
+				Email:         Email{SMTP: "smtp.example.com:587", To: []string{"staging@example.com"}},
+				Profiles:      base.Profiles,
+				profile:       "staging",
+			},
+		},
+		{
+			name:    "empty profile",
+			file:    file,
+			profile: "empty",
+			want: Config{
+				Version:       1,
+				URL:           "https://testrail.example.com",
+				Username:      "ci@example.com",
+				ProjectID:     3,
+				Notifications: []Notification{{Type: "slack", URL: "https://hooks.slack.com/services/main"}},
+				Email:         Email{SMTP: "smtp.example.com:587", To: []string{"qa@example.com"}},
+				Profiles:      base.Profiles,
+				profile:       "empty",
+			},
+		},
+		{
+			name:    "unknown profile",
+			file:    file,
+			profile: "production",
+			err:     true,
+		},
+	}
+	for _, testcase := range testcases {
+		cfg, err := LoadProfile(testcase.file, testcase.profile)
+		if testcase.err {
+			assert.Error(t, err, testcase.name)
+			continue
+		}
+		assert.NoError(t, err, testcase.name)
+		assert.Equal(t, testcase.want, cfg, testcase.name)
+	}
+}
+
+func TestLoadSelectsProfile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "trailer.yml")
+	assert.NoError(t, ioutil.WriteFile(file, []byte(profilesFile), 0600))
+
+	defer os.Setenv("TRAILER_PROFILE", os.Getenv("TRAILER_PROFILE"))
+	testcases := []struct {
+		profile string
+		url     string
+	}{
+		{profile: "", url: "https://testrail.example.com"},
+		{profile: "staging", url: "https://staging.testrail.example.com"},
+	}
+	for _, testcase := range testcases {
+		os.Setenv("TRAILER_PROFILE", testcase.profile)
+		cfg, err := Load(file)
+		assert.NoError(t, err)
+		assert.Equal(t, testcase.url, cfg.URL)
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "trailer.yml")
+
+	testcases := []struct {
+		name    string
+		data    string
+		want    Config
+		version int
+		err     bool
+	}{
+		{
+			name: "empty file",
+			data: "",
+			want: Config{Version: Version},
+		},
+		{
+			name: "version 0",
+			data: "url: https://testrail.example.com\nslack_webhook: https://hooks.slack.com/services/main\n",
+			want: Config{Version: Version, URL: "https://testrail.example.com", SlackWebhook: "https://hooks.slack.com/services/main"},
+		},
+		{
+			name: "current version",
+			data: "version: 1\nurl: https://testrail.example.com\n",
+			want: Config{Version: Version, URL: "https://testrail.example.com"},
+		},
+		{
+			name:    "newer version",
+			data:    "version: 99\nurl: https://testrail.example.com\n",
+			version: 99,
+			err:     true,
+		},
+		{
+			name: "version is not a number",
+			data: "version: one\n",
+			err:  true,
+		},
+	}
+	for _, testcase := range testcases {
+		assert.NoError(t, ioutil.WriteFile(file, []byte(testcase.data), 0600))
+		cfg, err := LoadProfile(file, "")
+		if testcase.err {
+			assert.Error(t, err, testcase.name)
+			var versionErr *VersionError
+			assert.Equal(t, testcase.version != 0, errors.As(err, &versionErr), testcase.name)
+			continue
+		}
+		assert.NoError(t, err, testcase.name)
+		assert.Equal(t, testcase.want, cfg, testcase.name)
+	}
+}
+
+func TestSaveProfile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "trailer.yml")
+	assert.NoError(t, ioutil.WriteFile(file, []byte("url: https://testrail.example.com\nusername: ci@example.com\n"), 0600))
+
+	cfg, err := LoadProfile(file, "")
+	assert.NoError(t, err)
+	cfg.Profiles = map[string]Config{"staging": {URL: "https://staging.testrail.example.com"}}
+	assert.NoError(t, Save(file, cfg))
+
+	staging, err := LoadProfile(file, "staging")
+	assert.NoError(t, err)
+	staging.Username = "qa@example.com"
+	assert.NoError(t, Save(file, staging))
+
+	// Only the profile changed.
+	saved, err := LoadProfile(file, "")
+	assert.NoError(t, err)
+	assert.Equal(t, Version, saved.Version)
+	assert.Equal(t, "https://testrail.example.com", saved.URL)
+	assert.Equal(t, "ci@example.com", saved.Username)
+	assert.Equal(t, Config{URL: "https://staging.testrail.example.com", Username: "qa@example.com"}, saved.Profiles["staging"])
+}
@@ -0,0 +1,59 @@
+package config
+
+import (
+	"fmt"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Version is the config file format Save writes. Config files from before
+// profiles have no version field and are version 0.
+const Version = 1
+
+// migrations rewrite the raw settings of an older config file, the one at
+// index i taking a file of version i to version i+1.
+var migrations = []func(raw map[interface{}]interface{}) error{
+	// Profiles are new in version 1 and the top level settings kept their
+	// meaning, as the settings used when no profile is selected.
+	func(raw map[interface{}]interface{}) error { return nil },
+}
+
+// VersionError is returned by Load for a config file with a version above
+// Version, whose settings might not mean what this trailer expects.
+type VersionError struct {
+	Version int
+}
+
+func (e *VersionError) Error() string {
+	return fmt.Sprintf("config file version %d is newer than the supported version %d, upgrade trailer", e.Version, Version)
+}
+
+// migrate returns the content of a config file rewritten to Version, or the
+// content itself when it is already at Version.
+func migrate(data []byte) ([]byte, error) {
+	raw := map[interface{}]interface{}{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	version := 0
+	if v, ok := raw["version"]; ok {
+		if version, ok = v.(int); !ok {
+			return nil, fmt.Errorf("config file version %v is not a number", v)
+		}
+	}
+	if version > Version {
+		return nil, &VersionError{Version: version}
+	}
+	if version == Version {
+		return data, nil
+	}
+
+	for ; version < Version; version++ {
+		if err := migrations[version](raw); err != nil {
+			return nil, fmt.Errorf("upgrading config file from version %d: %s", version, err)
+		}
+	}
+	raw["version"] = Version
+	return yaml.Marshal(raw)
+}
@@ -0,0 +1,199 @@
+// Package download keeps a cases file, a local snapshot of the case titles of
+// a TestRail suite, up to date with TestRail.
+package download
+
+import (
+	"io/ioutil"
+	"time"
+
+	yaml "gopkg.in/yaml.v2"
+
+	"github.com/educlos/testrail"
+)
+
+// Suite is the content of a cases file.
+type Suite struct {
+	// Version is the version of the file format, see Version.
+	Version     int           `yaml:"version"`
+	ProjectID   int           `yaml:"project_id"`
+	SuiteID     int           `yaml:"suite_id"`
+	LastUpdated string        `yaml:"last_updated"`
+	Cases       map[int]Entry `yaml:"cases"`
+	// Base holds the titles as of the last sync, so sync can tell local
+	// edits from remote ones.
+	Base map[int]string `yaml:"base,omitempty"`
+	// New lists cases to be created in TestRail by the next sync.
+	New []Case `yaml:"new_cases,omitempty"`
+}
+
+// Entry is a case of a cases file. Entries with only a title are written as
+// the title alone, like in files of version 1.
+type Entry struct {
+	Title string `yaml:"title"`
+	// Automated marks cases covered by automated tests.
+	Automated bool     `yaml:"automated,omitempty"`
+	Owner     string   `yaml:"owner,omitempty"`
+	Refs      string   `yaml:"refs,omitempty"`
+	Tags      []string `yaml:"tags,omitempty"`
+}
+
+// entryFields has the fields of Entry without its YAML methods.
+type entryFields Entry
+
+func (e *Entry) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var title string
+	if err := unmarshal(&title); err == nil {
+		*e = Entry{Title: title}
+		return nil
+	}
+	return unmarshal((*entryFields)(e))
+}
+
+func (e Entry) MarshalYAML() (interface{}, error) {
+	if !e.Automated && e.Owner == "" && e.Refs == "" && len(e.Tags) == 0 {
+		return e.Title, nil
+	}
+	return entryFields(e), nil
+}
+
+// Case describes a case in a cases manifest or in the new cases of a cases
+// file. ID is only used by update and delete, SectionID only by create and
+// Section only by import.
+type Case struct {
+	ID          int    `yaml:"id,omitempty"`
+	SectionID   int    `yaml:"section_id,omitempty"`
+	Section     string `yaml:"section,omitempty"`
+	Title       string `yaml:"title,omitempty"`
+	TypeID      int    `yaml:"type_id,omitempty"`
+	PriorityID  int    `yaml:"priority_id,omitempty"`
+	MilestoneID int    `yaml:"milestone_id,omitempty"`
+	Estimate    string `yaml:"estimate,omitempty"`
+	Refs        string `yaml:"refs,omitempty"`
+}
+
+// Sendable returns the payload to create or update the case with.
+func (c Case) Sendable() testrail.SendableCase {
+	return testrail.SendableCase{
+		Title:       c.Title,
+		TypeID:      c.TypeID,
+		PriorityID:  c.PriorityID,
+		MilestoneID: c.MilestoneID,
+		Estimate:    c.Estimate,
+		Refs:        c.Refs,
+	}
+}
+
+// Client is the part of the TestRail API needed to download cases.
+// *testrail.Client implements it.
+type Client interface {
+	GetCases(projectID, suiteID int, sectionID ...int) ([]testrail.Case, error)
+}
+
+// New returns an empty suite that has never been updated.
+func New(projectID, suiteID int) Suite {
+	return Suite{
+		Version:     Version,
+		ProjectID:   projectID,
+		SuiteID:     suiteID,
+		LastUpdated: time.Unix(0, 0).Format(time.RFC3339Nano),
+		Cases:       map[int]Entry{},
+	}
+}
+
+// Titles returns the titles of the cases of the suite.
+func (s *Suite) Titles() map[int]string {
+	titles := make(map[int]string, len(s.Cases))
+	for id, e := range s.Cases {
+		titles[id] = e.Title
+	}
+	return titles
+}
+
+// SetTitle sets the title of a case, adding it if it is not in the suite.
+func (s *Suite) SetTitle(id int, title string) {
+	e := s.Cases[id]
+	e.Title = title
+	s.Cases[id] = e
+}
+
+// Load reads a cases file, upgrading files written in an older version of
+// the format. Files of a newer version are reported as a *VersionError.
+func Load(file string) (Suite, error) {
+	s := New(0, 0)
+
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return s, err
+	}
+	if data, err = migrate(data); err != nil {
+		return s, err
+	}
+
+	err = yaml.Unmarshal(data, &s)
+	return s, err
+}
+
+// Marshal stamps the suite with the current time and format version and
+// encodes it.
+func Marshal(s *Suite) ([]byte, error) {
+	s.Version = Version
+	s.LastUpdated = time.Now().Format(time.RFC3339Nano)
+	return yaml.Marshal(s)
+}
+
+// Encode encodes the suite in the current format version, keeping its
+// last_updated time.
+func Encode(s Suite) ([]byte, error) {
+	s.Version = Version
+	return yaml.Marshal(s)
+}
+
+// Save stamps the suite with the current time and writes it to file
+// atomically.
+func Save(file string, s Suite) error {
+	data, err := Marshal(&s)
+	if err != nil {
+		return err
+	}
+
+	return WriteFile(file, data)
+}
+
+// Update copies the titles of the cases changed in TestRail since the suite
+// was last updated, returning whether any changed. A malformed last_updated
+// time is reported as a *time.ParseError.
+func Update(client Client, s *Suite) (bool, error) {
+	lastUpdated, err := time.Parse(time.RFC3339Nano, s.LastUpdated)
+	if err != nil {
+		return false, err
+	}
+
+	cases, err := client.GetCases(s.ProjectID, s.SuiteID)
+	if err != nil {
+		return false, err
+	}
+
+	updated := false
+	for _, c := range cases {
+		if lastUpdated.Before(time.Unix(int64(c.UdpatedOn), 0)) {
+			s.SetTitle(c.ID, c.Title)
+			updated = true
+		}
+	}
+
+	return updated, nil
+}
+
+// Remove deletes the given cases from the suite, returning whether any of
+// them were present.
+func Remove(s *Suite, ids ...int) bool {
+	removed := false
+	for _, id := range ids {
+		if _, ok := s.Cases[id]; ok {
+			delete(s.Cases, id)
+			removed = true
+		}
+	}
+
+	return removed
+}
@@ -0,0 +1,184 @@
+package download
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSaveLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "download")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "cases.yml")
+	s := New(3, 33)
+	s.SetTitle(1, "Login works")
+	s.New = []Case{{SectionID: 7, Title: "Logout works"}}
+	assert.NoError(t, Save(file, s))
+
+	loaded, err := Load(file)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, loaded.ProjectID)
+	assert.Equal(t, 33, loaded.SuiteID)
+	assert.Equal(t, map[int]string{1: "Login works"}, loaded.Titles())
+	assert.Equal(t, s.New, loaded.New)
+	assert.NotEqual(t, New(0, 0).LastUpdated, loaded.LastUpdated)
+
+	assert.NoError(t, ioutil.WriteFile(file, []byte("cases:\n  1: Login works\n"), 0644))
+	loaded, err = Load(file)
+	assert.NoError(t, err)
+	assert.Equal(t, New(0, 0).LastUpdated, loaded.LastUpdated)
+}
+
+func TestLoadVersions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "download")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "cases.yml")
+
+	assert.NoError(t, ioutil.WriteFile(file, []byte("project_id: 3\ncases:\n  1: Login works\n"), 0644))
+	loaded, err := Load(file)
+	assert.NoError(t, err)
+	assert.Equal(t, Version, loaded.Version)
+	assert.Equal(t, 3, loaded.ProjectID)
+	assert.Equal(t, map[int]string{1: "Login works"}, loaded.Titles())
+
+	assert.NoError(t, ioutil.WriteFile(file, []byte("version: 99\ncases:\n  1: Login works\n"), 0644))
+	_, err = Load(file)
+	var versionErr *VersionError
+	assert.True(t, errors.As(err, &versionErr))
+	assert.Equal(t, 99, versionErr.Version)
+
+	assert.NoError(t, ioutil.WriteFile(file, []byte("version: one\n"), 0644))
+	_, err = Load(file)
+	assert.Error(t, err)
+}
+
+func TestEntries(t *testing.T) {
+	dir, err := ioutil.TempDir("", "download")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "cases.yml")
+
+	assert.NoError(t, ioutil.WriteFile(file, []byte(`version: 2
+cases:
+  1: Login works
+  2:
+    title: Logout works
+    automated: true
+    owner: accounts-team
+    tags: [smoke]
+`), 0644))
+	s, err := Load(file)
+	assert.NoError(t, err)
+	assert.Equal(t, Entry{Title: "Login works"}, s.Cases[1])
+	assert.Equal(t, Entry{Title: "Logout works", Automated: true, Owner: "accounts-team", Tags: []string{"smoke"}}, s.Cases[2])
+
+	s.SetTitle(2, "Logout always works")
+	assert.True(t, s.Cases[2].Automated)
+	assert.NoError(t, Save(file, s))
+
+	data, err := ioutil.ReadFile(file)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "  1: Login works\n")
+	assert.Contains(t, string(data), "    title: Logout always works\n")
+	loaded, err := Load(file)
+	assert.NoError(t, err)
+	assert.Equal(t, s.Cases, loaded.Cases)
+}
+
+func TestMerge(t *testing.T) {
+	a := New(3, 33)
+	a.LastUpdated = "2020-01-02T00:00:00Z"
+	a.SetTitle(1, "Login works")
+	a.SetTitle(2, "Logout works")
+	a.Base = map[int]string{2: "Logout"}
+	a.New = []Case{{SectionID: 7, Title: "Refund"}}
+
+	b := New(3, 33)
+	b.LastUpdated = "2020-01-01T00:00:00Z"
+	b.SetTitle(1, "Login works")
+	b.SetTitle(2, "Logout always works")
+	b.SetTitle(3, "Checkout works")
+	b.New = []Case{{SectionID: 7, Title: "Refund"}}
+
+	merged, conflicts := Merge([]Suite{a, b})
+	assert.Equal(t, map[int]string{1: "Login works", 2: "Logout works", 3: "Checkout works"}, merged.Titles())
+	assert.Equal(t, map[int]string{2: "Logout"}, merged.Base)
+	assert.Equal(t, []Case{{SectionID: 7, Title: "Refund"}}, merged.New)
+	assert.Equal(t, b.LastUpdated, merged.LastUpdated)
+	assert.Equal(t, 3, merged.ProjectID)
+	assert.Equal(t, []Conflict{{ID: 2, Suites: []int{0, 1}, Entries: []Entry{{Title: "Logout works"}, {Title: "Logout always works"}}}}, conflicts)
+
+	merged, _ = Merge([]Suite{a, New(4, 44)})
+	assert.Equal(t, 0, merged.ProjectID)
+	assert.Equal(t, 0, merged.SuiteID)
+}
+
+func TestRemove(t *testing.T) {
+	s := New(3, 33)
+	s.SetTitle(1, "a")
+	s.SetTitle(2, "b")
+
+	assert.False(t, Remove(&s, 3))
+	assert.True(t, Remove(&s, 1, 3))
+	assert.Equal(t, map[int]string{2: "b"}, s.Titles())
+}
+
+func TestWriteFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "download")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "cases.yaml")
+	assert.NoError(t, ioutil.WriteFile(file, []byte("old"), 0600))
+	link := filepath.Join(dir, "link.yaml")
+	assert.NoError(t, os.Symlink(file, link))
+
+	assert.NoError(t, WriteFile(link, []byte("new")))
+	data, err := ioutil.ReadFile(file)
+	assert.NoError(t, err)
+	assert.Equal(t, "new", string(data))
+	info, err := os.Stat(file)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	info, err = os.Lstat(link)
+	assert.NoError(t, err)
+	assert.True(t, info.Mode()&os.ModeSymlink != 0)
+
+	files, err := filepath.Glob(filepath.Join(dir, ".*.tmp*"))
+	assert.NoError(t, err)
+	assert.Empty(t, files)
+}
+
+func TestLock(t *testing.T) {
+	dir, err := ioutil.TempDir("", "download")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "cases.yaml")
+
+	unlock, err := Lock(file)
+	assert.NoError(t, err)
+
+	locked := make(chan struct{})
+	go func() {
+		unlock, err := Lock(file)
+		assert.NoError(t, err)
+		close(locked)
+		unlock()
+	}()
+
+	select {
+	case <-locked:
+		t.Fatal("lock taken twice")
+	case <-time.After(50 * time.Millisecond):
+	}
+	unlock()
+	<-locked
+}
@@ -0,0 +1,62 @@
+package download
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// WriteFile replaces file with data atomically, so an interrupted run or a
+// job reading the cases file at the same time never sees it half written.
+func WriteFile(file string, data []byte) error {
+	// Replace the target of a symlinked cases file, not the link.
+	if target, err := filepath.EvalSymlinks(file); err == nil {
+		file = target
+	}
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(file); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(file), "."+filepath.Base(file)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), file)
+}
+
+// Lock takes the advisory lock of file, waiting while another process holds
+// it, and returns the function releasing it. Commands that read, change and
+// write back a cases file hold it throughout, so concurrent jobs do not
+// overwrite each other's changes. The lock is a hidden file next to file.
+func Lock(file string) (func(), error) {
+	name := filepath.Join(filepath.Dir(file), "."+filepath.Base(file)+".lock")
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockFile(f); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return func() {
+		unlockFile(f)
+		f.Close()
+	}, nil
+}
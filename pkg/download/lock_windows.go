@@ -0,0 +1,12 @@
+//go:build windows
+
+package download
+
+import "os"
+
+// The syscall package has no file locking on Windows, cases files are only
+// written atomically there.
+
+func lockFile(f *os.File) error { return nil }
+
+func unlockFile(f *os.File) error { return nil }
@@ -0,0 +1,97 @@
+package download
+
+import (
+	"reflect"
+	"sort"
+	"time"
+)
+
+// Conflict is a case that merged suites have different entries for.
+// Suites holds the indexes of the suites that have the case, Entries their
+// entries in the same order.
+type Conflict struct {
+	ID      int
+	Suites  []int
+	Entries []Entry
+}
+
+// Merge combines suites into one, for example the files of several
+// components into a file for the whole product. Cases take the entry of the
+// first suite that has them, the ones other suites have different entries
+// for are returned as conflicts. The merged suite keeps the project and suite
+// only if all suites agree on them, and the oldest last_updated time so
+// download does not miss changes any of the suites missed.
+func Merge(suites []Suite) (Suite, []Conflict) {
+	merged := New(0, 0)
+	if len(suites) == 0 {
+		return merged, nil
+	}
+	merged.ProjectID, merged.SuiteID = suites[0].ProjectID, suites[0].SuiteID
+	merged.Base = map[int]string{}
+
+	var oldest time.Time
+	conflicts := map[int]*Conflict{}
+	for i, s := range suites {
+		if s.ProjectID != merged.ProjectID || s.SuiteID != merged.SuiteID {
+			merged.ProjectID, merged.SuiteID = 0, 0
+		}
+		updated, err := time.Parse(time.RFC3339Nano, s.LastUpdated)
+		if err != nil {
+			updated = time.Unix(0, 0)
+		}
+		if i == 0 || updated.Before(oldest) {
+			oldest = updated
+			merged.LastUpdated = updated.Format(time.RFC3339Nano)
+		}
+
+		for id, e := range s.Cases {
+			first, ok := merged.Cases[id]
+			if !ok {
+				merged.Cases[id] = e
+				if title, ok := s.Base[id]; ok {
+					merged.Base[id] = title
+				}
+				continue
+			}
+			if c, ok := conflicts[id]; ok {
+				c.Suites = append(c.Suites, i)
+				c.Entries = append(c.Entries, e)
+				continue
+			}
+			if !reflect.DeepEqual(first, e) {
+				conflicts[id] = &Conflict{ID: id, Suites: []int{firstSuite(suites[:i], id), i}, Entries: []Entry{first, e}}
+			}
+		}
+
+		for _, c := range s.New {
+			if !containsCase(merged.New, c) {
+				merged.New = append(merged.New, c)
+			}
+		}
+	}
+	sorted := []Conflict{}
+	for _, c := range conflicts {
+		sorted = append(sorted, *c)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ID < sorted[j].ID })
+	return merged, sorted
+}
+
+// firstSuite returns the index of the first suite that has the case.
+func firstSuite(suites []Suite, id int) int {
+	for i, s := range suites {
+		if _, ok := s.Cases[id]; ok {
+			return i
+		}
+	}
+	return -1
+}
+
+func containsCase(cases []Case, c Case) bool {
+	for _, other := range cases {
+		if other == c {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,61 @@
+package download
+
+import (
+	"fmt"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Version is the version of the cases file format written by Save. Files
+// written before the format had versions are version 0.
+const Version = 2
+
+// migrations upgrade the raw content of a cases file, migrations[i] from
+// version i to version i+1. Changes to the format add a migration here and
+// raise Version, so older files keep loading.
+var migrations = []func(raw map[interface{}]interface{}) error{
+	// Version 1 only adds the version field.
+	func(raw map[interface{}]interface{}) error { return nil },
+	// Version 2 allows entries with more than a title, the titles of
+	// version 1 are still valid entries.
+	func(raw map[interface{}]interface{}) error { return nil },
+}
+
+// VersionError is returned by Load for cases files written by a newer
+// trailer, which this one would misread.
+type VersionError struct {
+	Version int
+}
+
+func (e *VersionError) Error() string {
+	return fmt.Sprintf("cases file version %d is newer than the supported version %d, upgrade trailer", e.Version, Version)
+}
+
+// migrate upgrades the content of a cases file to Version.
+func migrate(data []byte) ([]byte, error) {
+	raw := map[interface{}]interface{}{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	version := 0
+	if v, ok := raw["version"]; ok {
+		if version, ok = v.(int); !ok {
+			return nil, fmt.Errorf("cases file version %v is not a number", v)
+		}
+	}
+	if version > Version {
+		return nil, &VersionError{Version: version}
+	}
+	if version == Version {
+		return data, nil
+	}
+
+	for ; version < Version; version++ {
+		if err := migrations[version](raw); err != nil {
+			return nil, fmt.Errorf("upgrading cases file from version %d: %s", version, err)
+		}
+	}
+	raw["version"] = Version
+	return yaml.Marshal(raw)
+}
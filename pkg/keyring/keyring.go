@@ -0,0 +1,107 @@
+// Package keyring stores secrets in the keychain of the operating system
+// through its command line tools: security on macOS and secret-tool from
+// libsecret on Linux.
+package keyring
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+var (
+	// ErrNotFound is returned by Get when no secret is stored.
+	ErrNotFound = errors.New("secret not found in keyring")
+	// ErrUnsupported is returned when the keychain of the system cannot be
+	// used.
+	ErrUnsupported = errors.New("no supported keyring on this system")
+)
+
+// goos is the system whose keychain is used, replaced in tests.
+var goos = runtime.GOOS
+
+// Get returns the secret stored for the account of the service.
+func Get(service, account string) (string, error) {
+	var out []byte
+	var err error
+	switch goos {
+	case "darwin":
+		out, err = lookup("security", "find-generic-password", "-s", service, "-a", account, "-w")
+	case "linux":
+		out, err = lookup("secret-tool", "lookup", "service", service, "account", account)
+	default:
+		return "", ErrUnsupported
+	}
+	if err != nil {
+		return "", err
+	}
+
+	secret := strings.TrimRight(string(out), "\r\n")
+	if secret == "" {
+		return "", ErrNotFound
+	}
+	return secret, nil
+}
+
+// Set stores the secret for the account of the service, replacing the
+// current one.
+func Set(service, account, secret string) error {
+	var err error
+	switch goos {
+	case "darwin":
+		// security only takes the password as an argument.
+		_, err = run("", "security", "add-generic-password", "-U", "-s", service, "-a", account, "-w", secret)
+	case "linux":
+		_, err = run(secret, "secret-tool", "store", "--label", service+" "+account, "service", service, "account", account)
+	default:
+		return ErrUnsupported
+	}
+	return err
+}
+
+// Delete removes the secret stored for the account of the service.
+func Delete(service, account string) error {
+	var err error
+	switch goos {
+	case "darwin":
+		_, err = lookup("security", "delete-generic-password", "-s", service, "-a", account)
+	case "linux":
+		_, err = run("", "secret-tool", "clear", "service", service, "account", account)
+	default:
+		return ErrUnsupported
+	}
+	return err
+}
+
+// lookup runs a keyring tool command that fails when the secret does not
+// exist. Neither tool tells that apart from other failures in a portable way,
+// so every failure is reported as ErrNotFound.
+func lookup(name string, args ...string) ([]byte, error) {
+	out, err := run("", name, args...)
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return nil, ErrNotFound
+	}
+	return out, err
+}
+
+// run runs the keyring tool with stdin as its input.
+func run(stdin, name string, args ...string) ([]byte, error) {
+	if _, err := exec.LookPath(name); err != nil {
+		return nil, ErrUnsupported
+	}
+
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = strings.NewReader(stdin)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w: %s", name, err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	return out, nil
+}
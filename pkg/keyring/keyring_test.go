@@ -0,0 +1,47 @@
+package keyring
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeSecretTool keeps a single secret in a file next to the script.
+const fakeSecretTool = `#!/bin/sh
+store="$(dirname "$0")/secret"
+case "$1" in
+store) cat > "$store" ;;
+lookup) cat "$store" 2>/dev/null || exit 1 ;;
+clear) rm -f "$store" ;;
+esac
+`
+
+func TestKeyring(t *testing.T) {
+	dir, err := ioutil.TempDir("", "keyring")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "secret-tool"), []byte(fakeSecretTool), 0755))
+	defer os.Setenv("PATH", os.Getenv("PATH"))
+	os.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	defer func(old string) { goos = old }(goos)
+	goos = "linux"
+
+	_, err = Get("trailer", "me")
+	assert.Equal(t, ErrNotFound, err)
+
+	assert.NoError(t, Set("trailer", "me", "s3cret"))
+	secret, err := Get("trailer", "me")
+	assert.NoError(t, err)
+	assert.Equal(t, "s3cret", secret)
+
+	assert.NoError(t, Delete("trailer", "me"))
+	_, err = Get("trailer", "me")
+	assert.Equal(t, ErrNotFound, err)
+
+	goos = "plan9"
+	assert.Equal(t, ErrUnsupported, Set("trailer", "me", "s3cret"))
+}
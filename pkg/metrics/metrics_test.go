@@ -0,0 +1,58 @@
+package metrics
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteTo(t *testing.T) {
+	r := NewRegistry()
+	uploaded := r.Counter("results_uploaded_total", "Results uploaded.")
+	failures := r.Counter("upload_failures_total", "Failed uploads.", "reason")
+	duration := r.Histogram("request_duration_seconds", "Request duration.", []float64{0.1, 1}, "endpoint")
+
+	uploaded.Add(3)
+	failures.Inc("api")
+	failures.Inc("api")
+	duration.Observe(0.05, "get_tests")
+	duration.Observe(0.5, "get_tests")
+	assert.Equal(t, float64(2), failures.Value("api"))
+
+	var b bytes.Buffer
+	_, err := r.WriteTo(&b)
+	assert.NoError(t, err)
+	assert.Equal(t, `# HELP results_uploaded_total Results uploaded.
+# TYPE results_uploaded_total counter
+results_uploaded_total 3
+# HELP upload_failures_total Failed uploads.
+# TYPE upload_failures_total counter
+upload_failures_total{reason="api"} 2
+# HELP request_duration_seconds Request duration.
+# TYPE request_duration_seconds histogram
+request_duration_seconds_bucket{endpoint="get_tests",le="0.1"} 1
+request_duration_seconds_bucket{endpoint="get_tests",le="1"} 2
+request_duration_seconds_bucket{endpoint="get_tests",le="+Inf"} 2
+request_duration_seconds_sum{endpoint="get_tests"} 0.55
+request_duration_seconds_count{endpoint="get_tests"} 2
+`, b.String())
+}
+
+func TestPush(t *testing.T) {
+	var path, body string
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := ioutil.ReadAll(r.Body)
+		path, body = r.Method+" "+r.URL.Path, string(data)
+	}))
+	defer gateway.Close()
+
+	r := NewRegistry()
+	r.Counter("runs_total", "Runs.").Inc()
+	assert.NoError(t, r.Push(gateway.URL+"/", "trailer"))
+	assert.Equal(t, "PUT /metrics/job/trailer", path)
+	assert.Contains(t, body, "runs_total 1\n")
+}
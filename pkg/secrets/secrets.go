@@ -0,0 +1,160 @@
+// Package secrets resolves references to secrets kept in a secrets manager,
+// so config files can name a secret instead of holding it:
+//
+//	vault://secret/testrail#token     HashiCorp Vault, at VAULT_ADDR with VAULT_TOKEN
+//	awssm://testrail#token            AWS Secrets Manager, through the aws CLI
+//	gcpsm://my-project/testrail       GCP Secret Manager, through the gcloud CLI
+//
+// The fragment selects a key of a JSON secret. Vault secrets always need one.
+package secrets
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// IsReference reports whether value refers to a secret rather than being
+// one.
+func IsReference(value string) bool {
+	for _, scheme := range []string{"vault://", "awssm://", "gcpsm://"} {
+		if strings.HasPrefix(value, scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+// Resolve returns the secret value refers to, or value itself if it is not a
+// reference.
+func Resolve(value string) (string, error) {
+	if !IsReference(value) {
+		return value, nil
+	}
+
+	ref, err := url.Parse(value)
+	if err != nil {
+		return "", err
+	}
+	name := strings.Trim(ref.Host+ref.Path, "/")
+	if name == "" {
+		return "", fmt.Errorf("%s: missing secret name", value)
+	}
+
+	var secret string
+	switch ref.Scheme {
+	case "vault":
+		if ref.Fragment == "" {
+			return "", fmt.Errorf("%s: missing #key of the secret", value)
+		}
+		return vault(name, ref.Fragment)
+	case "awssm":
+		secret, err = command("aws", "secretsmanager", "get-secret-value", "--secret-id", name, "--query", "SecretString", "--output", "text")
+	case "gcpsm":
+		parts := strings.SplitN(name, "/", 2)
+		if len(parts) != 2 {
+			return "", fmt.Errorf("%s: want gcpsm://<project>/<secret>", value)
+		}
+		secret, err = command("gcloud", "secrets", "versions", "access", "latest", "--project", parts[0], "--secret", parts[1])
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if ref.Fragment == "" {
+		return secret, nil
+	}
+	return jsonKey([]byte(secret), ref.Fragment)
+}
+
+// vault reads the key of the secret at path. Paths of version 2 KV engines
+// may leave out the data/ segment of the API path, it is added when the
+// secret is not found without it.
+func vault(path, key string) (string, error) {
+	addr := strings.TrimSuffix(os.Getenv("VAULT_ADDR"), "/")
+	if addr == "" {
+		return "", fmt.Errorf("vault: VAULT_ADDR is not set")
+	}
+
+	data, status, err := vaultGet(addr, path)
+	if err == nil && status == http.StatusNotFound {
+		if parts := strings.SplitN(path, "/", 2); len(parts) == 2 {
+			data, status, err = vaultGet(addr, parts[0]+"/data/"+parts[1])
+		}
+	}
+	if err != nil {
+		return "", fmt.Errorf("vault: %s", err)
+	}
+	if status != http.StatusOK {
+		return "", fmt.Errorf("vault: reading %s: status %d", path, status)
+	}
+
+	var resp struct {
+		Data map[string]json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return "", fmt.Errorf("vault: %s", err)
+	}
+	// Version 2 KV engines nest the secret in another data object.
+	if nested, ok := resp.Data["data"]; ok && resp.Data["metadata"] != nil {
+		return jsonKey(nested, key)
+	}
+	raw, err := json.Marshal(resp.Data)
+	if err != nil {
+		return "", err
+	}
+	return jsonKey(raw, key)
+}
+
+func vaultGet(addr, path string) ([]byte, int, error) {
+	req, err := http.NewRequest("GET", addr+"/v1/"+path, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("X-Vault-Token", os.Getenv("VAULT_TOKEN"))
+	if ns := os.Getenv("VAULT_NAMESPACE"); ns != "" {
+		req.Header.Set("X-Vault-Namespace", ns)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	return data, resp.StatusCode, err
+}
+
+// command returns the output of a secrets manager CLI.
+func command(name string, args ...string) (string, error) {
+	cmd := exec.Command(name, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("%s: %s: %s", name, err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	return strings.TrimRight(string(out), "\r\n"), nil
+}
+
+// jsonKey returns the string value of key in the JSON object data.
+func jsonKey(data []byte, key string) (string, error) {
+	var values map[string]interface{}
+	if err := json.Unmarshal(data, &values); err != nil {
+		return "", fmt.Errorf("secret is not a JSON object: %s", err)
+	}
+
+	value, ok := values[key].(string)
+	if !ok {
+		return "", fmt.Errorf("secret has no %q string", key)
+	}
+	return value, nil
+}
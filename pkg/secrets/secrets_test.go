@@ -0,0 +1,81 @@
+package secrets
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveVault(t *testing.T) {
+	vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "root" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/kv1/testrail":
+			w.Write([]byte(`{"data": {"token": "v1-token"}}`))
+		case "/v1/secret/data/testrail":
+			w.Write([]byte(`{"data": {"data": {"token": "v2-token"}, "metadata": {"version": 3}}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer vault.Close()
+
+	defer os.Unsetenv("VAULT_ADDR")
+	defer os.Unsetenv("VAULT_TOKEN")
+	os.Setenv("VAULT_ADDR", vault.URL)
+	os.Setenv("VAULT_TOKEN", "root")
+
+	for _, tc := range []struct {
+		ref, expected string
+	}{
+		{"plain-token", "plain-token"},
+		{"vault://kv1/testrail#token", "v1-token"},
+		{"vault://secret/testrail#token", "v2-token"},
+		{"vault://secret/data/testrail#token", "v2-token"},
+	} {
+		secret, err := Resolve(tc.ref)
+		assert.NoError(t, err, tc.ref)
+		assert.Equal(t, tc.expected, secret, tc.ref)
+	}
+
+	for _, ref := range []string{
+		"vault://secret/testrail",
+		"vault://secret/testrail#password",
+		"vault://secret/missing#token",
+	} {
+		_, err := Resolve(ref)
+		assert.Error(t, err, ref)
+	}
+}
+
+func TestResolveCLI(t *testing.T) {
+	dir, err := ioutil.TempDir("", "secrets")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	aws := "#!/bin/sh\necho '{\"token\": \"aws-token\"}'\n"
+	gcloud := "#!/bin/sh\necho \"gcp-token $4 $6\"\n"
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "aws"), []byte(aws), 0755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "gcloud"), []byte(gcloud), 0755))
+	defer os.Setenv("PATH", os.Getenv("PATH"))
+	os.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	secret, err := Resolve("awssm://testrail#token")
+	assert.NoError(t, err)
+	assert.Equal(t, "aws-token", secret)
+
+	secret, err = Resolve("gcpsm://ci/testrail")
+	assert.NoError(t, err)
+	assert.Equal(t, "gcp-token latest ci", secret)
+
+	_, err = Resolve("gcpsm://testrail")
+	assert.Error(t, err)
+}
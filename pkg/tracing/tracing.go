@@ -0,0 +1,273 @@
+// Package tracing records spans of the work trailer does and exports them
+// to an OpenTelemetry collector with OTLP over HTTP, encoded as JSON.
+//
+// Tracing is configured with the standard OpenTelemetry variables:
+// OTEL_EXPORTER_OTLP_TRACES_ENDPOINT or OTEL_EXPORTER_OTLP_ENDPOINT,
+// OTEL_EXPORTER_OTLP_HEADERS and OTEL_SERVICE_NAME. A W3C TRACEPARENT
+// variable, as set by CI systems that trace their pipelines, makes the spans
+// part of the trace of the pipeline.
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Kinds of spans.
+const (
+	KindInternal = 1
+	KindClient   = 3
+)
+
+// Tracer collects the spans to export. A nil Tracer records nothing.
+type Tracer struct {
+	service  string
+	endpoint string
+	headers  map[string]string
+	parent   SpanContext
+
+	mu    sync.Mutex
+	spans []*Span
+}
+
+// SpanContext identifies a span across processes.
+type SpanContext struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+}
+
+// Valid reports whether the trace ID is set.
+func (sc SpanContext) Valid() bool {
+	return sc.TraceID != [16]byte{}
+}
+
+// Traceparent formats the span context as a W3C traceparent header.
+func (sc SpanContext) Traceparent() string {
+	return fmt.Sprintf("00-%s-%s-01", hex.EncodeToString(sc.TraceID[:]), hex.EncodeToString(sc.SpanID[:]))
+}
+
+// ParseTraceparent reads a W3C traceparent header.
+func ParseTraceparent(header string) (SpanContext, bool) {
+	var sc SpanContext
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) != 4 || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return sc, false
+	}
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return sc, false
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return sc, false
+	}
+	return sc, sc.Valid()
+}
+
+// FromEnv returns a tracer exporting to the collector set in the
+// environment, or nil if none is.
+func FromEnv() *Tracer {
+	endpoint := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT")
+	if endpoint == "" {
+		base := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+		if base == "" {
+			return nil
+		}
+		endpoint = strings.TrimRight(base, "/") + "/v1/traces"
+	}
+
+	t := &Tracer{service: os.Getenv("OTEL_SERVICE_NAME"), endpoint: endpoint, headers: map[string]string{}}
+	if t.service == "" {
+		t.service = "trailer"
+	}
+	for _, pair := range strings.Split(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS"), ",") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) == 2 {
+			key, _ := url.QueryUnescape(strings.TrimSpace(kv[0]))
+			value, _ := url.QueryUnescape(strings.TrimSpace(kv[1]))
+			t.headers[key] = value
+		}
+	}
+	t.parent, _ = ParseTraceparent(os.Getenv("TRACEPARENT"))
+	return t
+}
+
+// Span is a timed operation.
+type Span struct {
+	tracer *Tracer
+	ctx    SpanContext
+	parent [8]byte
+
+	Name  string
+	Kind  int
+	Start time.Time
+	End   time.Time
+	Attrs map[string]interface{}
+	Err   string
+}
+
+type spanKey struct{}
+
+// SpanFromContext returns the span of ctx, or nil.
+func SpanFromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(spanKey{}).(*Span)
+	return s
+}
+
+// ContextWithSpan returns a copy of ctx with the span.
+func ContextWithSpan(ctx context.Context, s *Span) context.Context {
+	if s == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, spanKey{}, s)
+}
+
+// Start starts a span, a child of the span of ctx if it has one. Spans of a
+// nil tracer do nothing.
+func (t *Tracer) Start(ctx context.Context, name string, kind int) (context.Context, *Span) {
+	if t == nil {
+		return ctx, nil
+	}
+
+	s := &Span{tracer: t, Name: name, Kind: kind, Start: time.Now(), Attrs: map[string]interface{}{}}
+	if parent := SpanFromContext(ctx); parent != nil {
+		s.ctx.TraceID = parent.ctx.TraceID
+		s.parent = parent.ctx.SpanID
+	} else if t.parent.Valid() {
+		s.ctx.TraceID = t.parent.TraceID
+		s.parent = t.parent.SpanID
+	} else {
+		rand.Read(s.ctx.TraceID[:])
+	}
+	rand.Read(s.ctx.SpanID[:])
+	return ContextWithSpan(ctx, s), s
+}
+
+// Context returns the identity of the span.
+func (s *Span) Context() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.ctx
+}
+
+// SetAttr records an attribute of the span.
+func (s *Span) SetAttr(key string, value interface{}) {
+	if s != nil {
+		s.Attrs[key] = value
+	}
+}
+
+// Finish ends the span, marking it failed if err is not nil.
+func (s *Span) Finish(err error) {
+	if s == nil {
+		return
+	}
+	s.End = time.Now()
+	if err != nil {
+		s.Err = err.Error()
+	}
+	s.tracer.mu.Lock()
+	s.tracer.spans = append(s.tracer.spans, s)
+	s.tracer.mu.Unlock()
+}
+
+// Export sends the finished spans to the collector.
+func (t *Tracer) Export() error {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	spans := t.spans
+	t.spans = nil
+	t.mu.Unlock()
+	if len(spans) == 0 {
+		return nil
+	}
+
+	data, err := json.Marshal(t.payload(spans))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", t.endpoint, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range t.headers {
+		req.Header.Set(k, v)
+	}
+
+	client := http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("collector answered %s", resp.Status)
+	}
+	return nil
+}
+
+// payload encodes the spans as an OTLP ExportTraceServiceRequest.
+func (t *Tracer) payload(spans []*Span) map[string]interface{} {
+	encoded := []map[string]interface{}{}
+	for _, s := range spans {
+		span := map[string]interface{}{
+			"traceId":           hex.EncodeToString(s.ctx.TraceID[:]),
+			"spanId":            hex.EncodeToString(s.ctx.SpanID[:]),
+			"name":              s.Name,
+			"kind":              s.Kind,
+			"startTimeUnixNano": strconv.FormatInt(s.Start.UnixNano(), 10),
+			"endTimeUnixNano":   strconv.FormatInt(s.End.UnixNano(), 10),
+			"attributes":        attributes(s.Attrs),
+		}
+		if s.parent != [8]byte{} {
+			span["parentSpanId"] = hex.EncodeToString(s.parent[:])
+		}
+		if s.Err != "" {
+			span["status"] = map[string]interface{}{"code": 2, "message": s.Err}
+		}
+		encoded = append(encoded, span)
+	}
+
+	return map[string]interface{}{
+		"resourceSpans": []interface{}{map[string]interface{}{
+			"resource": map[string]interface{}{
+				"attributes": attributes(map[string]interface{}{"service.name": t.service}),
+			},
+			"scopeSpans": []interface{}{map[string]interface{}{
+				"scope": map[string]string{"name": "github.com/docker/trailer"},
+				"spans": encoded,
+			}},
+		}},
+	}
+}
+
+// attributes encodes attributes as OTLP key-values.
+func attributes(attrs map[string]interface{}) []map[string]interface{} {
+	kvs := []map[string]interface{}{}
+	for k, v := range attrs {
+		var value map[string]interface{}
+		switch v := v.(type) {
+		case int:
+			value = map[string]interface{}{"intValue": strconv.Itoa(v)}
+		case bool:
+			value = map[string]interface{}{"boolValue": v}
+		default:
+			value = map[string]interface{}{"stringValue": fmt.Sprint(v)}
+		}
+		kvs = append(kvs, map[string]interface{}{"key": k, "value": value})
+	}
+	return kvs
+}
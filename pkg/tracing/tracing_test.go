@@ -0,0 +1,50 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	sc, ok := ParseTraceparent("00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+	assert.True(t, ok)
+	assert.Equal(t, "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01", sc.Traceparent())
+
+	for _, header := range []string{"", "00-xyz-b7ad6b7169203331-01", "00-00000000000000000000000000000000-b7ad6b7169203331-01"} {
+		_, ok := ParseTraceparent(header)
+		assert.False(t, ok, header)
+	}
+}
+
+func TestSpans(t *testing.T) {
+	var nilTracer *Tracer
+	ctx, span := nilTracer.Start(context.Background(), "ignored", KindInternal)
+	span.SetAttr("key", "value")
+	span.Finish(nil)
+	assert.Nil(t, SpanFromContext(ctx))
+	assert.NoError(t, nilTracer.Export())
+
+	defer os.Unsetenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+	defer os.Unsetenv("TRACEPARENT")
+	os.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "http://collector:4318/")
+	os.Setenv("TRACEPARENT", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+	tracer := FromEnv()
+	assert.Equal(t, "http://collector:4318/v1/traces", tracer.endpoint)
+
+	ctx, root := tracer.Start(context.Background(), "root", KindInternal)
+	_, child := tracer.Start(ctx, "child", KindClient)
+	child.SetAttr("http.status_code", 500)
+	child.Finish(errors.New("500 Internal Server Error"))
+	root.Finish(nil)
+
+	spans := tracer.payload(tracer.spans)["resourceSpans"].([]interface{})[0].(map[string]interface{})["scopeSpans"].([]interface{})[0].(map[string]interface{})["spans"].([]map[string]interface{})
+	assert.Len(t, spans, 2)
+	assert.Equal(t, "0af7651916cd43dd8448eb211c80319c", spans[1]["traceId"])
+	assert.Equal(t, "b7ad6b7169203331", spans[1]["parentSpanId"])
+	assert.Equal(t, spans[1]["spanId"], spans[0]["parentSpanId"])
+	assert.Equal(t, map[string]interface{}{"code": 2, "message": "500 Internal Server Error"}, spans[0]["status"])
+}
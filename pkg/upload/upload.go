@@ -0,0 +1,307 @@
+// Package upload reads the results of JUnit XML reports and uploads them to a
+// TestRail run.
+package upload
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/educlos/testrail"
+	"github.com/onsi/ginkgo/reporters"
+
+	"github.com/docker/trailer/spec"
+)
+
+// ErrRejected is returned by Upload when TestRail still rejects the results
+// after the last attempt.
+var ErrRejected = errors.New("TestRail rejected the results")
+
+// APIError is returned when a TestRail API call fails. Err is the error
+// returned by the testrail client.
+type APIError struct {
+	Op  string
+	Err error
+}
+
+func (e *APIError) Error() string { return fmt.Sprintf("failed to %s: %s", e.Op, e.Err) }
+func (e *APIError) Unwrap() error { return e.Err }
+
+// Client is the part of the TestRail API needed to upload results.
+// *testrail.Client implements it.
+type Client interface {
+	GetTests(runID int, statusID ...[]int) ([]testrail.Test, error)
+	AddResultsForCases(runID int, newResult testrail.SendableResultsForCase) ([]testrail.Result, error)
+}
+
+// ResponseError is a TestRail API error response, which is only available
+// from the testrail client as the text of its error.
+type ResponseError struct {
+	StatusCode int
+	// Message is the error field of the response body, or the body itself
+	// when it is not a TestRail error document.
+	Message string
+}
+
+func (e *ResponseError) Error() string {
+	return fmt.Sprintf("%d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+var responseErrorRegex = regexp.MustCompile(`(?s)^response: status: "(\d{3})[^"]*", body: (.*)$`)
+
+// ParseResponseError returns the response of a TestRail API error returned
+// by the testrail client, or false if the request failed without one.
+func ParseResponseError(err error) (*ResponseError, bool) {
+	m := responseErrorRegex.FindStringSubmatch(err.Error())
+	if m == nil {
+		return nil, false
+	}
+	code, _ := strconv.Atoi(m[1])
+	resp := &ResponseError{StatusCode: code, Message: strings.TrimSpace(m[2])}
+
+	var body struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal([]byte(m[2]), &body) == nil && body.Error != "" {
+		resp.Message = body.Error
+	}
+	return resp, true
+}
+
+var unknownCaseRegex = regexp.MustCompile(`\bC(\d+) unknown`)
+
+// UnknownCases returns the cases a TestRail error message rejects as unknown,
+// such as "Field :results cannot be parsed (case C12 unknown)".
+func UnknownCases(message string) []int {
+	ids := []int{}
+	for _, m := range unknownCaseRegex.FindAllStringSubmatch(message, -1) {
+		id, err := strconv.Atoi(m[1])
+		if err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// ParseReports reads the results of the given reports, prefixing their
+// comments with comment and mapping their outcomes with statuses. The reports
+// are parsed as format, or as the format detected from their content when it
+// is empty. They are parsed in parallel but their results are combined in the
+// order of files. Tests whose names embed no case ID are looked up in
+// caseMap, which may be nil. It stops early when ctx is done.
+func ParseReports(ctx context.Context, files []string, format, comment string, statuses spec.StatusMap, caseMap spec.CaseMap) (spec.Updates, error) {
+	updates := spec.Updates{
+		ResultMap: map[int]spec.Update{},
+		Statuses:  statuses,
+		CaseMap:   caseMap,
+	}
+
+	suites, err := ParseSuites(ctx, files, format)
+	if err != nil {
+		return updates, err
+	}
+
+	if err := updates.AddSuites(comment, suites); err != nil {
+		return updates, fmt.Errorf("Failed to read results: %s", err)
+	}
+	slog.Debug("Read results", "reports", len(files), "results", len(updates.ResultMap))
+	return updates, nil
+}
+
+// ParseSuites reads the JUnit test suites of the given reports like
+// ParseReports, without turning them into results.
+func ParseSuites(ctx context.Context, files []string, format string) (spec.JUnitTestSuites, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	parsed := make([][]reporters.JUnitTestSuite, len(files))
+	errs := make([]error, len(files))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < runtime.GOMAXPROCS(0) && w < len(files); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				parsed[i], errs[i] = spec.ParseFileContext(ctx, files[i], format)
+				if errs[i] != nil {
+					cancel()
+				}
+			}
+		}()
+	}
+	for i := range files {
+		if ctx.Err() != nil {
+			break
+		}
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	suites := spec.JUnitTestSuites{}
+	for i := range files {
+		if errs[i] != nil {
+			return suites, fmt.Errorf("Failed to parse file: %s", errs[i])
+		}
+		tests := 0
+		for _, suite := range parsed[i] {
+			tests += len(suite.TestCases)
+		}
+		slog.Debug("Parsed report", "file", files[i], "suites", len(parsed[i]), "tests", tests)
+		suites.Suites = append(suites.Suites, parsed[i]...)
+	}
+	return suites, ctx.Err()
+}
+
+// BatchSize is the most results sent to TestRail in one request, which keeps
+// the requests of runs with tens of thousands of results small.
+var BatchSize = 1000
+
+type progressKey struct{}
+
+// WithProgress returns a context that makes Upload call progress after each
+// batch with the number of results sent so far and in all.
+func WithProgress(ctx context.Context, progress func(sent, total int)) context.Context {
+	return context.WithValue(ctx, progressKey{}, progress)
+}
+
+// Upload sends the results in updates to the run in batches of BatchSize,
+// dropping results for cases TestRail reports as unknown and retrying each
+// batch up to retries times. Results for cases outside the run are not sent
+// and listed in updates.Pruned. It returns the results TestRail recorded and,
+// when it fails, the cases of the batches it did not send, starting with the
+// failed one since TestRail may have recorded it anyway. It returns ctx's
+// error if ctx is done before an attempt.
+func Upload(ctx context.Context, client Client, runID, retries int, updates *spec.Updates) (recorded []testrail.Result, unsent []int, err error) {
+	if err := ctx.Err(); err != nil {
+		return nil, updates.SortedCaseIDs(), err
+	}
+
+	// The run does not change between attempts, so its cases are only
+	// fetched once.
+	runCases, err := RunCases(client, runID)
+	if err != nil {
+		return nil, updates.SortedCaseIDs(), &APIError{Op: "prune test results", Err: err}
+	}
+
+	// Results for cases outside the run are left out up front, since
+	// TestRail rejects the whole request for them.
+	caseIDs, pruned := []int{}, []int{}
+	for _, id := range updates.SortedCaseIDs() {
+		if runCases[id] {
+			caseIDs = append(caseIDs, id)
+		} else {
+			slog.Debug("Pruning the result of a case that is not part of the run", "run", runID, "case", id, "test", updates.ResultMap[id].Test)
+			pruned = append(pruned, id)
+		}
+	}
+	updates.Pruned = pruned
+	if len(pruned) > 0 {
+		slog.Info("Skipping results for cases that are not part of the run", "run", runID, "cases", pruned)
+	}
+	slog.Debug("Uploading results", "run", runID, "results", len(caseIDs))
+
+	for start := 0; start < len(caseIDs); start += BatchSize {
+		end := start + BatchSize
+		if end > len(caseIDs) {
+			end = len(caseIDs)
+		}
+		r, err := uploadBatch(ctx, client, runID, retries, updates, caseIDs[start:end])
+		recorded = append(recorded, r...)
+		if err != nil {
+			return recorded, caseIDs[start:], err
+		}
+		if progress, ok := ctx.Value(progressKey{}).(func(sent, total int)); ok {
+			progress(end, len(caseIDs))
+		}
+	}
+
+	if len(recorded) == 0 {
+		slog.Warn("No results uploaded", "run", runID)
+	}
+	return recorded, nil, nil
+}
+
+// uploadBatch sends the results for caseIDs, which must be part of the run.
+func uploadBatch(ctx context.Context, client Client, runID, retries int, updates *spec.Updates, caseIDs []int) ([]testrail.Result, error) {
+	rejected := false
+	for i := 0; i < retries; i++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		results := updates.PayloadFor(caseIDs)
+		if len(results.Results) == 0 {
+			return nil, nil
+		}
+		slog.Debug("Uploading batch", "run", runID, "attempt", i+1, "first", caseIDs[0], "results", len(results.Results))
+		start := time.Now()
+		r, err := client.AddResultsForCases(runID, results)
+		slog.Debug("Uploaded batch", "run", runID, "attempt", i+1, "results", len(results.Results), "recorded", len(r), "duration", time.Since(start), "error", err)
+		rejected = err != nil
+		if err != nil {
+			// Only a rejection naming the unknown cases can be fixed by
+			// dropping them and trying again.
+			resp, ok := ParseResponseError(err)
+			if !ok || resp.StatusCode != http.StatusBadRequest {
+				return nil, &APIError{Op: "upload test results to TestRail", Err: err}
+			}
+			unknown := UnknownCases(resp.Message)
+			if len(unknown) == 0 {
+				return nil, &APIError{Op: "upload test results to TestRail", Err: resp}
+			}
+
+			slog.Warn("Dropping results for cases TestRail rejected", "run", runID, "cases", unknown, "reason", resp.Message)
+			for _, id := range unknown {
+				updates.RemoveResult(id)
+			}
+		}
+
+		if len(r) == 0 {
+			slog.Warn("No results uploaded", "run", runID, "attempt", i+1)
+			continue
+		}
+		return r, nil
+	}
+
+	if rejected {
+		return nil, ErrRejected
+	}
+	return nil, nil
+}
+
+// RunCases returns the IDs of the cases that are part of the run.
+func RunCases(client Client, runID int) (map[int]bool, error) {
+	tests, err := client.GetTests(runID)
+	if err != nil {
+		return nil, err
+	}
+
+	cases := make(map[int]bool, len(tests))
+	for _, test := range tests {
+		cases[test.CaseID] = true
+	}
+	return cases, nil
+}
+
+// Prune drops the results for cases that are not part of the run, since
+// TestRail rejects the whole upload otherwise.
+func Prune(runCases map[int]bool, results testrail.SendableResultsForCase) testrail.SendableResultsForCase {
+	var applicableResults testrail.SendableResultsForCase
+	for _, result := range results.Results {
+		if runCases[result.CaseID] {
+			applicableResults.Results = append(applicableResults.Results, result)
+		}
+	}
+	return applicableResults
+}
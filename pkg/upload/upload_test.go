@@ -0,0 +1,283 @@
+package upload
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/educlos/testrail"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/docker/trailer/spec"
+)
+
+func TestUpload(t *testing.T) {
+	var uploaded [][]int
+	fetched := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.RawQuery {
+		case "/api/v2/get_tests/5":
+			fetched++
+			json.NewEncoder(w).Encode([]testrail.Test{{CaseID: 1}, {CaseID: 2}, {CaseID: 3}})
+		case "/api/v2/add_results_for_cases/5":
+			var payload testrail.SendableResultsForCase
+			json.NewDecoder(r.Body).Decode(&payload)
+			ids := []int{}
+			for _, result := range payload.Results {
+				if result.CaseID == 2 {
+					w.WriteHeader(http.StatusBadRequest)
+					w.Write([]byte(`{"error":"Field :results cannot be parsed (case C2 unknown)"}`))
+					return
+				}
+				if result.CaseID == 3 {
+					w.WriteHeader(http.StatusBadRequest)
+					w.Write([]byte(`{"error":"Field :run_id is not a valid test run."}`))
+					return
+				}
+				ids = append(ids, result.CaseID)
+			}
+			uploaded = append(uploaded, ids)
+			w.Write([]byte(`[{"id":1,"test_id":11,"status_id":1}]`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := testrail.NewClient(server.URL, "user", "token")
+	newUpdates := func() *spec.Updates {
+		return &spec.Updates{ResultMap: map[int]spec.Update{
+			1: {Status: spec.Passed},
+			2: {Status: spec.Failed},
+			4: {Status: spec.Passed},
+		}}
+	}
+
+	updates := newUpdates()
+	_, _, err := Upload(context.Background(), client, 5, 1, updates)
+	assert.Equal(t, ErrRejected, err)
+	assert.Empty(t, uploaded)
+
+	updates = newUpdates()
+	results, _, err := Upload(context.Background(), client, 5, 2, updates)
+	assert.NoError(t, err)
+	assert.Len(t, results, 1)
+	assert.Equal(t, [][]int{{1}}, uploaded)
+	assert.Equal(t, 2, fetched)
+	assert.NotContains(t, updates.ResultMap, 2)
+
+	_, _, err = Upload(context.Background(), testrail.NewClient("http://127.0.0.1:0", "user", "token"), 5, 1, newUpdates())
+	_, ok := err.(*APIError)
+	assert.True(t, ok)
+
+	// Rejections that do not name unknown cases are not retried.
+	updates = &spec.Updates{ResultMap: map[int]spec.Update{3: {Status: spec.Passed}}}
+	uploaded = nil
+	_, _, err = Upload(context.Background(), client, 5, 3, updates)
+	var apiErr *APIError
+	assert.True(t, errors.As(err, &apiErr))
+	assert.Contains(t, err.Error(), "not a valid test run")
+	assert.Empty(t, uploaded)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, _, err = Upload(ctx, client, 5, 1, newUpdates())
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestParseResponseError(t *testing.T) {
+	testcases := []struct {
+		err     string
+		ok      bool
+		code    int
+		message string
+		unknown []int
+	}{
+		{
+			err:     `response: status: "400 Bad Request", body: {"error":"Field :results cannot be parsed (case C2 unknown)"}`,
+			ok:      true,
+			code:    400,
+			message: "Field :results cannot be parsed (case C2 unknown)",
+			unknown: []int{2},
+		},
+		{
+			err:     `response: status: "400 Bad Request", body: {"error":"Field :results contains invalid cases (case C2 unknown, case C31 unknown)"}`,
+			ok:      true,
+			code:    400,
+			message: "Field :results contains invalid cases (case C2 unknown, case C31 unknown)",
+			unknown: []int{2, 31},
+		},
+		{
+			err:     `response: status: "400 Bad Request", body: {"error":"Field :run_id is not a valid test run."}`,
+			ok:      true,
+			code:    400,
+			message: "Field :run_id is not a valid test run.",
+			unknown: []int{},
+		},
+		{
+			err:     "response: status: \"502 Bad Gateway\", body: <html>\nC1 unknown</html>",
+			ok:      true,
+			code:    502,
+			message: "<html>\nC1 unknown</html>",
+			unknown: []int{1},
+		},
+		{err: `Post "http://127.0.0.1:0": connection refused`},
+	}
+
+	for _, testcase := range testcases {
+		resp, ok := ParseResponseError(errors.New(testcase.err))
+		assert.Equal(t, testcase.ok, ok, testcase.err)
+		if ok {
+			assert.Equal(t, testcase.code, resp.StatusCode)
+			assert.Equal(t, testcase.message, resp.Message)
+			assert.Equal(t, testcase.unknown, UnknownCases(resp.Message))
+		}
+	}
+}
+
+func TestUploadBatches(t *testing.T) {
+	var uploaded [][]int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.RawQuery {
+		case "/api/v2/get_tests/5":
+			json.NewEncoder(w).Encode([]testrail.Test{{CaseID: 1}, {CaseID: 2}, {CaseID: 3}, {CaseID: 4}, {CaseID: 5}})
+		case "/api/v2/add_results_for_cases/5":
+			var payload testrail.SendableResultsForCase
+			json.NewDecoder(r.Body).Decode(&payload)
+			ids := []int{}
+			results := []string{}
+			for _, result := range payload.Results {
+				ids = append(ids, result.CaseID)
+				results = append(results, fmt.Sprintf(`{"test_id":%d,"status_id":%d}`, 10+result.CaseID, result.StatusID))
+			}
+			uploaded = append(uploaded, ids)
+			w.Write([]byte("[" + strings.Join(results, ",") + "]"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	defer func(size int) { BatchSize = size }(BatchSize)
+	BatchSize = 2
+
+	updates := &spec.Updates{ResultMap: map[int]spec.Update{}}
+	for _, id := range []int{5, 4, 3, 2, 1, 9} {
+		updates.ResultMap[id] = spec.Update{Status: spec.Passed}
+	}
+	progress := [][2]int{}
+	ctx := WithProgress(context.Background(), func(sent, total int) { progress = append(progress, [2]int{sent, total}) })
+	results, _, err := Upload(ctx, testrail.NewClient(server.URL, "user", "token"), 5, 1, updates)
+	assert.NoError(t, err)
+	assert.Len(t, results, 5)
+	assert.Equal(t, [][]int{{1, 2}, {3, 4}, {5}}, uploaded)
+	assert.Equal(t, [][2]int{{2, 5}, {4, 5}, {5, 5}}, progress)
+}
+
+func TestUploadFailedBatch(t *testing.T) {
+	var uploaded [][]int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.RawQuery {
+		case "/api/v2/get_tests/5":
+			json.NewEncoder(w).Encode([]testrail.Test{{CaseID: 1}, {CaseID: 2}, {CaseID: 3}, {CaseID: 4}, {CaseID: 5}})
+		case "/api/v2/add_results_for_cases/5":
+			// The second batch fails.
+			if len(uploaded) == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			var payload testrail.SendableResultsForCase
+			json.NewDecoder(r.Body).Decode(&payload)
+			ids := []int{}
+			for _, result := range payload.Results {
+				ids = append(ids, result.CaseID)
+			}
+			uploaded = append(uploaded, ids)
+			w.Write([]byte(`[{"test_id":11,"status_id":1},{"test_id":12,"status_id":1}]`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	defer func(size int) { BatchSize = size }(BatchSize)
+	BatchSize = 2
+
+	updates := &spec.Updates{ResultMap: map[int]spec.Update{}}
+	for _, id := range []int{1, 2, 3, 4, 5} {
+		updates.ResultMap[id] = spec.Update{Status: spec.Passed}
+	}
+	results, unsent, err := Upload(context.Background(), testrail.NewClient(server.URL, "user", "token"), 5, 1, updates)
+	var apiErr *APIError
+	assert.True(t, errors.As(err, &apiErr))
+	assert.Len(t, results, 2)
+	assert.Equal(t, [][]int{{1, 2}}, uploaded)
+	assert.Equal(t, []int{3, 4, 5}, unsent)
+}
+
+func TestParseReports(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reports")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// Later reports win for the same case, whatever order they are parsed in.
+	files := []string{}
+	for i := 0; i < 50; i++ {
+		file := filepath.Join(dir, fmt.Sprintf("report-%d.xml", i))
+		report := fmt.Sprintf(`<testsuite name="s%d" tests="1"><testcase name="TestRailC1 run" time="%d"></testcase></testsuite>`, i, i)
+		assert.NoError(t, ioutil.WriteFile(file, []byte(report), 0644))
+		files = append(files, file)
+	}
+
+	updates, err := ParseReports(context.Background(), files, "", "", spec.StatusMap{}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 49*time.Second, updates.ResultMap[1].Elapsed)
+
+	bad := filepath.Join(dir, "bad.xml")
+	assert.NoError(t, ioutil.WriteFile(bad, []byte("<html>"), 0644))
+	_, err = ParseReports(context.Background(), append(files, bad), "", "", spec.StatusMap{}, nil)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "bad.xml")
+}
+
+func TestParseReportsDebugLogs(t *testing.T) {
+	defer slog.SetDefault(slog.Default())
+	out := &bytes.Buffer{}
+	slog.SetDefault(slog.New(slog.NewTextHandler(out, &slog.HandlerOptions{Level: slog.LevelDebug})))
+
+	dir, err := ioutil.TempDir("", "reports")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "report.xml")
+	assert.NoError(t, ioutil.WriteFile(file, []byte(`<testsuite name="s" tests="4">
+  <testcase name="TestRailC1 fails" time="1"><failure message="boom"></failure></testcase>
+  <testcase name="TestRailC1 passes" time="1"></testcase>
+  <testcase name="TestMapped" time="1"></testcase>
+  <testcase name="TestOther" time="1"></testcase>
+</testsuite>`), 0644))
+
+	_, err = ParseReports(context.Background(), []string{file}, "", "", spec.DefaultStatusMap, spec.CaseMap{"TestMapped": {2}})
+	assert.NoError(t, err)
+	for _, msg := range []string{
+		`msg="Parsed report" file=` + file + ` suites=1 tests=4`,
+		`msg="Found case IDs in the test name" suite=s test="TestRailC1 fails" cases=[1]`,
+		`msg="Keeping the earlier failure of the case" case=1 failed="TestRailC1 fails" test="TestRailC1 passes"`,
+		`msg="Found case IDs in the case map" suite=s test=TestMapped cases=[2]`,
+		`msg="Skipping test without a case ID" suite=s test=TestOther`,
+		`msg="Read results" reports=1 results=2`,
+	} {
+		assert.Contains(t, out.String(), msg)
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/urfave/cli"
+)
+
+var noProgressFlag = cli.BoolFlag{
+	Name:  "no-progress",
+	Usage: "do not draw progress bars, which are only drawn when stderr is a terminal",
+}
+
+// progressOutput is where progress bars are drawn, nil when they are turned
+// off by --no-progress or stderr is not a terminal.
+var progressOutput io.Writer
+
+// setupProgress sets where progress bars are drawn.
+func setupProgress(disabled bool) {
+	progressOutput = nil
+	if !disabled && isTerminal(os.Stderr) {
+		progressOutput = os.Stderr
+	}
+}
+
+// progressWidth is the number of characters of the bar itself.
+const progressWidth = 30
+
+// progress draws a progress bar on a line of its own, redrawn as work is done
+// and every second in between, so slow operations can be told from hung
+// ones. Without a total, only the count and the time taken are drawn. The
+// methods of a nil *progress do nothing.
+type progress struct {
+	mu    sync.Mutex
+	w     io.Writer
+	label string
+	done  int
+	total int
+	start time.Time
+	stop  chan struct{}
+}
+
+// newProgress starts a progress bar on progressOutput, returning nil when
+// progress bars are turned off.
+func newProgress(label string, total int) *progress {
+	if progressOutput == nil {
+		return nil
+	}
+	return startProgress(progressOutput, label, total)
+}
+
+func startProgress(w io.Writer, label string, total int) *progress {
+	p := &progress{w: w, label: label, total: total, start: time.Now(), stop: make(chan struct{})}
+	p.draw()
+	go func() {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				p.mu.Lock()
+				p.draw()
+				p.mu.Unlock()
+			case <-p.stop:
+				return
+			}
+		}
+	}()
+	return p
+}
+
+// Set records that done of total units of work are done.
+func (p *progress) Set(done, total int) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done, p.total = done, total
+	p.draw()
+}
+
+// Add records that n more units of work are done.
+func (p *progress) Add(n int) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done += n
+	p.draw()
+}
+
+// Finish draws the bar a last time and ends its line.
+func (p *progress) Finish() {
+	if p == nil {
+		return
+	}
+	close(p.stop)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.draw()
+	fmt.Fprintln(p.w)
+}
+
+// draw redraws the line of the bar. The caller must hold p.mu.
+func (p *progress) draw() {
+	elapsed := time.Since(p.start).Truncate(time.Second)
+	if p.total <= 0 {
+		fmt.Fprintf(p.w, "\r%s %d %s\x1b[K", p.label, p.done, elapsed)
+		return
+	}
+	done := p.done
+	if done > p.total {
+		done = p.total
+	}
+	filled := progressWidth * done / p.total
+	bar := strings.Repeat("=", filled) + strings.Repeat(" ", progressWidth-filled)
+	fmt.Fprintf(p.w, "\r%s [%s] %d/%d %d%% %s\x1b[K", p.label, bar, p.done, p.total, 100*done/p.total, elapsed)
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProgress(t *testing.T) {
+	var buf bytes.Buffer
+	p := startProgress(&buf, "Uploading", 0)
+	p.Set(3, 0)
+	p.Set(10, 40)
+	p.Add(30)
+	p.Finish()
+
+	lines := strings.Split(strings.TrimPrefix(buf.String(), "\r"), "\r")
+	if assert.Len(t, lines, 5) {
+		assert.Equal(t, "Uploading 0 0s\x1b[K", lines[0])
+		assert.Equal(t, "Uploading 3 0s\x1b[K", lines[1])
+		assert.Equal(t, "Uploading [=======                       ] 10/40 25% 0s\x1b[K", lines[2])
+		assert.Equal(t, "Uploading [==============================] 40/40 100% 0s\x1b[K", lines[3])
+		assert.Equal(t, "Uploading [==============================] 40/40 100% 0s\x1b[K\n", lines[4])
+	}
+
+	// Progress bars are off when they have nowhere to go.
+	var off *progress
+	off.Set(1, 2)
+	off.Add(1)
+	off.Finish()
+}
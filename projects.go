@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/educlos/testrail"
+	"github.com/urfave/cli"
+)
+
+func projectsCommand() cli.Command {
+	return cli.Command{
+		Name:  "projects",
+		Usage: "Discover TestRail projects",
+		Subcommands: []cli.Command{
+			{
+				Name:  "list",
+				Usage: "List the projects visible to the current user",
+				Flags: []cli.Flag{
+					cli.BoolFlag{
+						Name:  "all, a",
+						Usage: "include completed projects",
+					},
+					outputFlag,
+				},
+				Action: func(c *cli.Context) error {
+					client, err := newClient()
+					if err != nil {
+						return err
+					}
+
+					var filter []bool
+					if !c.Bool("all") {
+						filter = append(filter, false)
+					}
+
+					projects, err := client.GetProjects(filter...)
+					if err != nil {
+						return apiErrorf("Error getting projects: %s", err)
+					}
+
+					rows := [][]string{}
+					for _, p := range projects {
+						rows = append(rows, []string{strconv.Itoa(p.ID), p.Name, oneLine(p.Announcement)})
+					}
+
+					err = render(c.String("output"), projects, []string{"ID", "NAME", "DESCRIPTION"}, rows)
+					if err != nil {
+						return fmt.Errorf("Error printing projects: %s", err)
+					}
+
+					return nil
+				},
+			},
+		},
+	}
+}
+
+func suitesCommand() cli.Command {
+	return cli.Command{
+		Name:  "suites",
+		Usage: "Discover and copy the suites of a TestRail project",
+		Subcommands: []cli.Command{
+			{
+				Name:  "list",
+				Usage: "List the suites of a project",
+				Flags: []cli.Flag{
+					cli.IntFlag{
+						Name:  "project-id, p",
+						Usage: "TestRail project ID to list suites from",
+					},
+					outputFlag,
+				},
+				Action: func(c *cli.Context) error {
+					projectID := c.Int("project-id")
+					if projectID == 0 {
+						return configErrorf("Must set --project-id to a non-zero integer")
+					}
+
+					client, err := newClient()
+					if err != nil {
+						return err
+					}
+					suites, err := client.GetSuites(projectID)
+					if err != nil {
+						return apiErrorf("Error getting suites: %s", err)
+					}
+
+					rows := [][]string{}
+					for _, s := range suites {
+						rows = append(rows, []string{strconv.Itoa(s.ID), s.Name, oneLine(s.Description)})
+					}
+
+					err = render(c.String("output"), suites, []string{"ID", "NAME", "DESCRIPTION"}, rows)
+					if err != nil {
+						return fmt.Errorf("Error printing suites: %s", err)
+					}
+
+					return nil
+				},
+			},
+			{
+				Name:  "clone",
+				Usage: "Copy a suite with its sections and cases into a new suite",
+				Flags: []cli.Flag{
+					cli.IntFlag{
+						Name:  "suite-id, s",
+						Usage: "TestRail suite ID to copy",
+					},
+					cli.StringFlag{
+						Name:  "name, n",
+						Usage: "name of the new suite, defaults to the name of the copied suite with \" (copy)\"",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					suiteID := c.Int("suite-id")
+					if suiteID == 0 {
+						return configErrorf("Must set --suite-id to a non-zero integer")
+					}
+
+					client, err := newClient()
+					if err != nil {
+						return err
+					}
+					var suite testrail.Suite
+					if err := client.send("GET", fmt.Sprintf("get_suite/%d", suiteID), nil, &suite); err != nil {
+						return apiErrorf("Error getting suite %d: %s", suiteID, err)
+					}
+					s, err := backupSuite(client, suite.ProjectID, suite)
+					if err != nil {
+						return apiErrorf("Error copying suite %d: %s", suiteID, err)
+					}
+					s.Suite.Name = c.String("name")
+					if s.Suite.Name == "" {
+						s.Suite.Name = suite.Name + " (copy)"
+					}
+
+					// Restoring a backup of just the suite adds it again with new IDs.
+					bar := newProgress("Copying cases", 0)
+					n, err := restoreBackup(client, suite.ProjectID, projectBackup{Suites: []suiteBackup{s}}, bar)
+					bar.Finish()
+					if err != nil {
+						return apiErrorf("Error copying suite %d: %s", suiteID, err)
+					}
+					statusf("Copied suite %d to %q with %d sections and %d cases\n", suiteID, s.Suite.Name, n.Sections, n.Cases)
+					return nil
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log/slog"
+	"os"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/urfave/cli"
+
+	"github.com/docker/trailer/pkg/download"
+)
+
+var matchFlag = cli.StringFlag{
+	Name:  "match",
+	Usage: "also prune the cases whose title matches this regular expression",
+}
+
+var notUpdatedSinceFlag = cli.DurationFlag{
+	Name:  "not-updated-since",
+	Usage: "also prune the cases not updated in TestRail for this long, such as 2160h for 90 days",
+}
+
+var orphansFlag = cli.BoolFlag{
+	Name:  "orphans",
+	Usage: "also prune the cases that no longer exist in TestRail, which download never removes",
+}
+
+// selectCases returns the IDs of the cases of the suite the prune filters
+// select, the cases that pass all of the filters set. It returns nil if none
+// are set.
+func selectCases(c *cli.Context, s download.Suite) ([]int, error) {
+	age, orphans := c.Duration("not-updated-since"), c.Bool("orphans")
+	if c.String("match") == "" && age == 0 && !orphans {
+		return nil, nil
+	}
+
+	var pattern *regexp.Regexp
+	if c.String("match") != "" {
+		var err error
+		if pattern, err = regexp.Compile(c.String("match")); err != nil {
+			return nil, configErrorf("Invalid --match pattern: %s", err)
+		}
+	}
+
+	// updated holds when each case TestRail has was last updated.
+	var updated map[int]time.Time
+	if age > 0 || orphans {
+		if s.ProjectID == 0 || s.SuiteID == 0 {
+			return nil, configErrorf("Cases file has no project_id and suite_id to look up its cases in TestRail")
+		}
+		client, err := newClient()
+		if err != nil {
+			return nil, err
+		}
+		remote, err := client.GetCases(s.ProjectID, s.SuiteID)
+		if err != nil {
+			return nil, apiErrorf("Error getting cases: %s", err)
+		}
+		updated = map[int]time.Time{}
+		for _, r := range remote {
+			updated[r.ID] = time.Unix(int64(r.UdpatedOn), 0)
+		}
+	}
+	cutoff := time.Now().Add(-age)
+
+	ids := []int{}
+	for id, e := range s.Cases {
+		if pattern != nil && !pattern.MatchString(e.Title) {
+			continue
+		}
+		t, exists := updated[id]
+		if orphans && exists {
+			continue
+		}
+		// Cases TestRail no longer has are left to --orphans.
+		if age > 0 && (!exists || !t.Before(cutoff)) {
+			continue
+		}
+		slog.Debug("Selected case for pruning", "case", id, "title", e.Title, "in_testrail", exists, "updated", t)
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids, nil
+}
+
+// previewPrune prints the cases of the suite that pruning ids removes and
+// returns whether to go ahead, which is not the case for --dry-run or when
+// --interactive is not confirmed.
+func previewPrune(c *cli.Context, s download.Suite, ids []int) (bool, error) {
+	seen := map[int]bool{}
+	present := []int{}
+	for _, id := range ids {
+		if _, ok := s.Cases[id]; !ok {
+			slog.Debug("Not pruning a case that is not in the cases file", "case", id)
+		} else if !seen[id] {
+			seen[id] = true
+			present = append(present, id)
+		}
+	}
+	sort.Ints(present)
+
+	action := "pruning"
+	if c.Bool("dry-run") || c.Bool("interactive") {
+		action = "would prune"
+	}
+	for _, id := range present {
+		statusf("%s C%d: %s\n", action, id, s.Cases[id].Title)
+	}
+
+	switch {
+	case len(present) == 0 || c.Bool("dry-run"):
+		return false, nil
+	case c.Bool("interactive"):
+		p := prompter{r: bufio.NewReader(os.Stdin), w: os.Stdout}
+		ok, err := p.confirm(fmt.Sprintf("Prune %d cases from %s?", len(present), c.String("file")))
+		if err != nil {
+			return false, fmt.Errorf("Error reading the confirmation: %s", err)
+		}
+		if !ok {
+			statusf("Nothing pruned\n")
+		}
+		return ok, nil
+	}
+	return true, nil
+}
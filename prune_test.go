@@ -0,0 +1,102 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/educlos/testrail"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/docker/trailer/pkg/download"
+)
+
+func TestPruneFilters(t *testing.T) {
+	s, stop := startFake(t)
+	defer stop()
+	s.Lock()
+	s.Cases = append(s.Cases,
+		testrail.Case{ID: 13, SuiteID: 2, SectionID: 3, Title: "Legacy checkout", UdpatedOn: int(time.Now().Unix())},
+		testrail.Case{ID: 14, SuiteID: 2, SectionID: 3, Title: "Legacy refund", UdpatedOn: 100},
+	)
+	s.Unlock()
+
+	dir, err := ioutil.TempDir("", "prune")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "cases.yml")
+	titles := func() map[int]string {
+		s, err := download.Load(file)
+		assert.NoError(t, err)
+		return s.Titles()
+	}
+
+	assert.NoError(t, run("download", "--project-id", "1", "--suite-id", "2", "--file", file))
+	assert.Len(t, titles(), 4)
+
+	assert.NoError(t, run("prune", "--file", file, "--match", "^Legacy", "--not-updated-since", "24h"))
+	assert.Equal(t, map[int]string{11: "Login", 12: "Logout", 13: "Legacy checkout"}, titles())
+
+	assert.NoError(t, run("prune", "--file", file, "--match", "^Log(in|out)$", "13"))
+	assert.Empty(t, titles())
+
+	assert.Equal(t, exitConfig, exitCode(run("prune", "--file", file, "--match", "(")))
+}
+
+func TestPrunePreview(t *testing.T) {
+	dir, err := ioutil.TempDir("", "prune")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "cases.yml")
+	assert.NoError(t, ioutil.WriteFile(file, []byte("cases:\n  11: Login\n  12: Logout\n"), 0644))
+	count := func() int {
+		s, err := download.Load(file)
+		assert.NoError(t, err)
+		return len(s.Cases)
+	}
+	answer := func(text string) {
+		f, err := ioutil.TempFile(dir, "stdin")
+		assert.NoError(t, err)
+		f.WriteString(text)
+		f.Seek(0, 0)
+		os.Stdin = f
+	}
+	defer func(stdin *os.File) { os.Stdin = stdin }(os.Stdin)
+
+	assert.NoError(t, run("prune", "--file", file, "--dry-run", "11"))
+	assert.Equal(t, 2, count())
+
+	answer("n\n")
+	assert.NoError(t, run("prune", "--file", file, "--interactive", "11"))
+	assert.Equal(t, 2, count())
+
+	answer("yes\n")
+	assert.NoError(t, run("prune", "--file", file, "--interactive", "11"))
+	assert.Equal(t, 1, count())
+}
+
+func TestPruneOrphans(t *testing.T) {
+	_, stop := startFake(t)
+	defer stop()
+
+	dir, err := ioutil.TempDir("", "prune")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "cases.yml")
+	assert.NoError(t, ioutil.WriteFile(file, []byte("project_id: 1\nsuite_id: 2\ncases:\n  11: Login\n  12: Logout\n  98: Deleted\n  99: Moved\n"), 0644))
+
+	assert.NoError(t, run("prune", "--file", file, "--orphans", "--match", "Deleted"))
+	s, err := download.Load(file)
+	assert.NoError(t, err)
+	assert.Equal(t, map[int]string{11: "Login", 12: "Logout", 99: "Moved"}, s.Titles())
+
+	assert.NoError(t, run("prune", "--file", file, "--orphans"))
+	s, err = download.Load(file)
+	assert.NoError(t, err)
+	assert.Equal(t, map[int]string{11: "Login", 12: "Logout"}, s.Titles())
+
+	assert.NoError(t, ioutil.WriteFile(file, []byte("cases:\n  11: Login\n"), 0644))
+	assert.Equal(t, exitConfig, exitCode(run("prune", "--file", file, "--orphans")))
+}
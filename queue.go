@@ -0,0 +1,220 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/docker/trailer/spec"
+)
+
+// queuedUpload is a set of parsed results waiting to be uploaded to a run.
+type queuedUpload struct {
+	RunID    int          `json:"run_id"`
+	Enqueued time.Time    `json:"enqueued"`
+	Attempts int          `json:"attempts"`
+	LastErr  string       `json:"last_error,omitempty"`
+	Updates  spec.Updates `json:"updates"`
+}
+
+// uploadQueue is a durable queue of uploads kept as one JSON file per entry
+// in a directory. Entries move between the pending, processing and failed
+// subdirectories with renames, so several workers can share a queue and an
+// interrupted worker never loses an entry. A claimed entry is leased to its
+// worker, which renews the lease by touching the file while it uploads; an
+// entry whose lease expired belongs to a worker that stopped.
+type uploadQueue struct {
+	dir string
+}
+
+const (
+	queuePending    = "pending"
+	queueProcessing = "processing"
+	queueFailed     = "failed"
+)
+
+// defaultLease is how long a claimed entry stays with a worker that stopped
+// renewing it.
+const defaultLease = time.Minute
+
+func openQueue(dir string) (*uploadQueue, error) {
+	for _, sub := range []string{queuePending, queueProcessing, queueFailed} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0755); err != nil {
+			return nil, err
+		}
+	}
+	return &uploadQueue{dir: dir}, nil
+}
+
+// push writes an entry to the pending directory and returns its name.
+func (q *uploadQueue) push(u queuedUpload) (string, error) {
+	if u.Enqueued.IsZero() {
+		u.Enqueued = time.Now()
+	}
+	name := fmt.Sprintf("%020d-%d.json", u.Enqueued.UnixNano(), os.Getpid())
+	return name, q.write(queuePending, name, u)
+}
+
+// write stores an entry through a temporary file so readers never see a
+// partially written entry.
+func (q *uploadQueue) write(sub, name string, u queuedUpload) error {
+	data, err := json.Marshal(u)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(q.dir, ".tmp-")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), filepath.Join(q.dir, sub, name))
+}
+
+// list returns the entry names in a subdirectory, oldest first.
+func (q *uploadQueue) list(sub string) ([]string, error) {
+	infos, err := ioutil.ReadDir(filepath.Join(q.dir, sub))
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for _, info := range infos {
+		if strings.HasSuffix(info.Name(), ".json") {
+			names = append(names, info.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// claim moves the oldest pending entry to processing and returns it. ok is
+// false when the queue is empty. Entries that cannot be read are moved to
+// failed, since no worker could ever upload them.
+func (q *uploadQueue) claim() (name string, u queuedUpload, ok bool, err error) {
+	names, err := q.list(queuePending)
+	if err != nil {
+		return "", u, false, err
+	}
+
+	for _, name := range names {
+		processing := filepath.Join(q.dir, queueProcessing, name)
+		// Another worker may have claimed the entry first.
+		if err := os.Rename(filepath.Join(q.dir, queuePending, name), processing); err != nil {
+			continue
+		}
+		// The rename keeps the time the entry was written, which may be
+		// older than a lease.
+		if err := q.renew(name); err != nil {
+			continue
+		}
+
+		data, err := ioutil.ReadFile(processing)
+		if err == nil {
+			if err = json.Unmarshal(data, &u); err == nil {
+				return name, u, true, nil
+			}
+		}
+		slog.Error("Moving unreadable queue entry to failed", "entry", name, "error", err)
+		if err := os.Rename(processing, filepath.Join(q.dir, queueFailed, name)); err != nil {
+			return name, u, false, err
+		}
+	}
+
+	return "", u, false, nil
+}
+
+// renew extends the lease of a claimed entry.
+func (q *uploadQueue) renew(name string) error {
+	now := time.Now()
+	return os.Chtimes(filepath.Join(q.dir, queueProcessing, name), now, now)
+}
+
+// keepClaimed renews the lease of a claimed entry until the returned function
+// is called.
+func (q *uploadQueue) keepClaimed(name string, lease time.Duration) func() {
+	ticker := time.NewTicker(lease / 3)
+	stop := make(chan struct{})
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := q.renew(name); err != nil {
+					slog.Warn("Error renewing the lease of a queue entry", "entry", name, "error", err)
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+	return func() { close(stop) }
+}
+
+// done removes a processed entry.
+func (q *uploadQueue) done(name string) error {
+	return os.Remove(filepath.Join(q.dir, queueProcessing, name))
+}
+
+// release records a failed attempt and returns the entry to pending, or to
+// failed once it has been attempted maxAttempts times.
+func (q *uploadQueue) release(name string, u queuedUpload, cause error, maxAttempts int) error {
+	u.Attempts++
+	u.LastErr = cause.Error()
+
+	sub := queuePending
+	if maxAttempts > 0 && u.Attempts >= maxAttempts {
+		sub = queueFailed
+	}
+
+	if err := q.write(sub, name, u); err != nil {
+		return err
+	}
+	return os.Remove(filepath.Join(q.dir, queueProcessing, name))
+}
+
+// recover returns entries whose lease expired, left in processing by an
+// interrupted worker, to pending. Entries other workers are still uploading
+// keep being renewed and are left alone.
+func (q *uploadQueue) recover(lease time.Duration) error {
+	names, err := q.list(queueProcessing)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		processing := filepath.Join(q.dir, queueProcessing, name)
+		info, err := os.Stat(processing)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if time.Since(info.ModTime()) < lease {
+			continue
+		}
+
+		// Another worker may have recovered or finished the entry first.
+		err = os.Rename(processing, filepath.Join(q.dir, queuePending, name))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/docker/trailer/spec"
+)
+
+func TestUploadQueue(t *testing.T) {
+	dir, err := ioutil.TempDir("", "trailer-queue")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	q, err := openQueue(dir)
+	assert.NoError(t, err)
+
+	first := queuedUpload{
+		RunID:    1,
+		Enqueued: time.Unix(100, 0),
+		Updates:  spec.Updates{ResultMap: map[int]spec.Update{12: {Status: spec.Failed, Message: "boom"}}},
+	}
+	second := queuedUpload{RunID: 2, Enqueued: time.Unix(200, 0)}
+
+	_, err = q.push(second)
+	assert.NoError(t, err)
+	_, err = q.push(first)
+	assert.NoError(t, err)
+
+	name, u, ok, err := q.claim()
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, 1, u.RunID)
+	assert.Equal(t, spec.Failed, u.Updates.ResultMap[12].Status)
+
+	assert.NoError(t, q.release(name, u, errors.New("unreachable"), 2))
+	name, u, ok, _ = q.claim()
+	assert.True(t, ok)
+	assert.Equal(t, 1, u.RunID)
+	assert.Equal(t, 1, u.Attempts)
+	assert.Equal(t, "unreachable", u.LastErr)
+
+	assert.NoError(t, q.release(name, u, errors.New("unreachable"), 2))
+	failed, _ := q.list(queueFailed)
+	assert.Len(t, failed, 1)
+
+	name, u, ok, _ = q.claim()
+	assert.True(t, ok)
+	assert.Equal(t, 2, u.RunID)
+
+	// Entries other workers are uploading are left alone.
+	assert.NoError(t, q.recover(time.Minute))
+	_, _, ok, _ = q.claim()
+	assert.False(t, ok)
+
+	// An interrupted worker leaves the entry in processing until its lease
+	// expires.
+	old := time.Now().Add(-2 * time.Minute)
+	assert.NoError(t, os.Chtimes(filepath.Join(dir, queueProcessing, name), old, old))
+	assert.NoError(t, q.recover(time.Minute))
+	name, _, ok, _ = q.claim()
+	assert.True(t, ok)
+	assert.NoError(t, q.done(name))
+
+	_, _, ok, err = q.claim()
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestClaimCorruptEntry(t *testing.T) {
+	dir, err := ioutil.TempDir("", "trailer-queue")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	q, err := openQueue(dir)
+	assert.NoError(t, err)
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, queuePending, "00000000000000000001-1.json"), []byte("{truncated"), 0644))
+	_, err = q.push(queuedUpload{RunID: 2, Enqueued: time.Unix(200, 0)})
+	assert.NoError(t, err)
+
+	// The corrupt entry is skipped for the next one and never retried.
+	_, u, ok, err := q.claim()
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, 2, u.RunID)
+	failed, _ := q.list(queueFailed)
+	assert.Equal(t, []string{"00000000000000000001-1.json"}, failed)
+
+	// Expired leases only return the readable entry.
+	assert.NoError(t, q.recover(0))
+	pending, _ := q.list(queuePending)
+	assert.Len(t, pending, 1)
+	failed, _ = q.list(queueFailed)
+	assert.Len(t, failed, 1)
+}
+
+func TestWorkerRate(t *testing.T) {
+	assert.Equal(t, exitConfig, exitCode(run("worker", "--rate", "0", "--once")))
+}
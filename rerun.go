@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/urfave/cli"
+
+	"github.com/docker/trailer/spec"
+)
+
+// testFilters build the arguments that select tests by name for each test
+// runner.
+var testFilters = map[string]func(tests []string) string{
+	"gotest": goTestFilter,
+	"pytest": pytestFilter,
+	"maven":  mavenFilter,
+}
+
+// goTestFilter runs the top-level tests, as -run matches each level of
+// subtest names separately.
+func goTestFilter(tests []string) string {
+	names := []string{}
+	for _, t := range uniqueNames(tests, func(t string) string { return strings.SplitN(t, "/", 2)[0] }) {
+		names = append(names, regexp.QuoteMeta(t))
+	}
+	return fmt.Sprintf("-run '^(%s)$'", strings.Join(names, "|"))
+}
+
+// pytestFilter selects tests by function name, dropping the module and
+// class path and the parameters, which -k does not accept.
+func pytestFilter(tests []string) string {
+	names := uniqueNames(tests, func(t string) string {
+		if i := strings.LastIndex(t, "::"); i >= 0 {
+			t = t[i+2:]
+		}
+		return strings.SplitN(t, "[", 2)[0]
+	})
+	return fmt.Sprintf("-k '%s'", strings.Join(names, " or "))
+}
+
+// mavenFilter selects test methods, named Class#method or Class.method, and
+// methods of any class when there is no class in the name.
+func mavenFilter(tests []string) string {
+	classes := []string{}
+	methods := map[string][]string{}
+	for _, t := range uniqueNames(tests, func(t string) string { return t }) {
+		class, method := "*", t
+		if i := strings.LastIndexAny(t, "#."); i >= 0 {
+			class, method = t[:i], t[i+1:]
+		}
+		if _, ok := methods[class]; !ok {
+			classes = append(classes, class)
+		}
+		methods[class] = append(methods[class], method)
+	}
+	selections := []string{}
+	for _, class := range classes {
+		selections = append(selections, class+"#"+strings.Join(methods[class], "+"))
+	}
+	return fmt.Sprintf("-Dtest='%s'", strings.Join(selections, ","))
+}
+
+// uniqueNames returns the names of the tests in order, without repeats.
+func uniqueNames(tests []string, name func(string) string) []string {
+	seen := map[string]bool{}
+	names := []string{}
+	for _, t := range tests {
+		n := name(t)
+		if !seen[n] {
+			seen[n] = true
+			names = append(names, n)
+		}
+	}
+	return names
+}
+
+// testsForCases returns the tests of the case map that cover the cases, and
+// the cases no test covers.
+func testsForCases(caseIDs []int, caseMap spec.CaseMap) ([]string, []int) {
+	names := make([]string, 0, len(caseMap))
+	for name := range caseMap {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	byCase := map[int][]string{}
+	for _, name := range names {
+		for _, id := range caseMap[name] {
+			byCase[id] = append(byCase[id], name)
+		}
+	}
+
+	tests, unmapped := []string{}, []int{}
+	for _, id := range caseIDs {
+		if len(byCase[id]) == 0 {
+			unmapped = append(unmapped, id)
+		}
+		tests = append(tests, byCase[id]...)
+	}
+	return tests, unmapped
+}
+
+func rerunFilterCommand() cli.Command {
+	return cli.Command{
+		Name:  "rerun-filter",
+		Usage: "Print the arguments that make a test runner rerun the failed tests of a run, using the case map",
+		Flags: []cli.Flag{
+			cli.IntFlag{
+				Name:  "run-id, r",
+				Usage: "TestRail run ID of the failed tests",
+			},
+			cli.StringFlag{
+				Name:  "case-map",
+				Usage: "YAML file mapping the names of tests to their case IDs, see scan and suggest",
+			},
+			cli.StringFlag{
+				Name:  "format, f",
+				Value: "gotest",
+				Usage: "test runner to print the selection for, one of gotest, pytest or maven",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			runID := c.Int("run-id")
+			if runID == 0 {
+				return configErrorf("Must set --run-id to a non-zero integer")
+			}
+			if c.String("case-map") == "" {
+				return configErrorf("Must set --case-map")
+			}
+			filter, ok := testFilters[c.String("format")]
+			if !ok {
+				return configErrorf("Unknown format %q, must be one of gotest, pytest or maven", c.String("format"))
+			}
+			caseMap, err := spec.LoadCaseMap(c.String("case-map"))
+			if err != nil {
+				return configErrorf("Failed to load case map: %s", err)
+			}
+
+			client, err := newClient()
+			if err != nil {
+				return err
+			}
+			tests, err := freshTests(client, runID)
+			if err != nil {
+				return apiErrorf("Error getting tests: %s", err)
+			}
+
+			names, unmapped := testsForCases(failedCases(tests), caseMap)
+			for _, id := range unmapped {
+				fmt.Fprintf(os.Stderr, "No test in the case map covers failed case C%d\n", id)
+			}
+			if len(names) > 0 {
+				fmt.Println(filter(names))
+			}
+			return nil
+		},
+	}
+}
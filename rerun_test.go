@@ -0,0 +1,57 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/docker/trailer/spec"
+)
+
+func TestTestFilters(t *testing.T) {
+	for _, tc := range []struct {
+		format string
+		tests  []string
+		want   string
+	}{
+		{format: "gotest", tests: []string{"TestLogin", "TestLogout/expired", "TestLogout/valid"}, want: "-run '^(TestLogin|TestLogout)$'"},
+		{format: "gotest", tests: []string{"TestA.B"}, want: `-run '^(TestA\.B)$'`},
+		{format: "pytest", tests: []string{"tests/test_login.py::TestLogin::test_ok", "test_logout[admin]", "test_logout[guest]"}, want: "-k 'test_ok or test_logout'"},
+		{format: "maven", tests: []string{"LoginTest#ok", "com.acme.LoginTest.fails", "LoginTest#slow", "logout"}, want: "-Dtest='LoginTest#ok+slow,com.acme.LoginTest#fails,*#logout'"},
+	} {
+		assert.Equal(t, tc.want, testFilters[tc.format](tc.tests), tc.format)
+	}
+}
+
+func TestTestsForCases(t *testing.T) {
+	caseMap := spec.CaseMap{"TestLogin": {11}, "TestSession": {11, 12}, "TestOther": {14}}
+	tests, unmapped := testsForCases([]int{12, 11, 13}, caseMap)
+	assert.Equal(t, []string{"TestSession", "TestLogin", "TestSession"}, tests)
+	assert.Equal(t, []int{13}, unmapped)
+}
+
+func TestRerunFilter(t *testing.T) {
+	s, stop := startFake(t)
+	defer stop()
+
+	dir, err := ioutil.TempDir("", "rerun")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	runID := strconv.Itoa(s.AddRun(1, 2, 11, 12).ID)
+	report := writeReport(t, dir, `<testsuite name="accounts" tests="2">
+  <testcase name="TestLogin" time="1"><failure message="boom">denied</failure></testcase>
+  <testcase name="TestLogout" time="1"></testcase>
+</testsuite>`)
+	caseMap := filepath.Join(dir, "cases.yaml")
+	assert.NoError(t, ioutil.WriteFile(caseMap, []byte("tests:\n  TestLogin: 11\n  TestLogout: 12\n"), 0644))
+	assert.NoError(t, run("upload", "--run-id", runID, "--case-map", caseMap, report))
+
+	assert.NoError(t, run("rerun-filter", "--run-id", runID, "--case-map", caseMap))
+	assert.Equal(t, exitConfig, exitCode(run("rerun-filter", "--run-id", runID, "--case-map", caseMap, "--format", "junit")))
+	assert.Equal(t, exitConfig, exitCode(run("rerun-filter", "--run-id", runID)))
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/educlos/testrail"
+	"github.com/urfave/cli"
+)
+
+// sheetColumns are the header names each field of a results spreadsheet is
+// read from, compared ignoring case.
+var sheetColumns = map[string][]string{
+	"case":    {"case id", "case"},
+	"title":   {"title"},
+	"status":  {"status"},
+	"comment": {"comment"},
+	"elapsed": {"elapsed", "time"},
+}
+
+// caseRef matches a case ID as TestRail shows it, such as C11.
+var caseRef = regexp.MustCompile(`^[Cc][0-9]+$`)
+
+// idColumn returns the column of a bare id header when every value in it is
+// a case ID such as C11. Sheets often number their rows or tests in an id
+// column, which must not be read as case IDs.
+func idColumn(rows [][]string) (int, bool) {
+	for i, name := range rows[0] {
+		if strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))) != "id" {
+			continue
+		}
+		found := false
+		for _, row := range rows[1:] {
+			if i >= len(row) || strings.TrimSpace(row[i]) == "" {
+				continue
+			}
+			if !caseRef.MatchString(strings.TrimSpace(row[i])) {
+				return 0, false
+			}
+			found = true
+		}
+		return i, found
+	}
+	return 0, false
+}
+
+// parseElapsed reads an elapsed time in seconds, such as 90, in TestRail's
+// format, such as 1m 30s or 1w 2d, or as a Go duration, such as 1.5s.
+func parseElapsed(elapsed string) (time.Duration, error) {
+	if seconds, err := strconv.ParseFloat(elapsed, 64); err == nil && seconds >= 0 {
+		return time.Duration(seconds * float64(time.Second)), nil
+	}
+	var r testrail.SendableResult
+	if err := json.Unmarshal([]byte(strconv.Quote(elapsed)), &r.Elapsed); err == nil {
+		return r.Elapsed.Duration, nil
+	}
+	return time.ParseDuration(strings.Replace(elapsed, " ", "", -1))
+}
+
+// sheetResults reads results from a CSV file with a header row. Each row
+// names its case by ID, such as C11, or by the title of its test in the run,
+// and its status by name, label or ID. Case IDs are read from a case ID or
+// case column, or from an id column only when all its values look like C11.
+// Rows without a status are left out, as are manual tests that were not run
+// yet. All the rows are checked before any result is returned, so a bad sheet
+// records nothing.
+func sheetResults(r io.Reader, tests []testrail.Test, statuses []testrail.Status, version string) (testrail.SendableResultsForCase, error) {
+	results := testrail.SendableResultsForCase{Results: []testrail.ResultsForCase{}}
+
+	rows, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return results, err
+	}
+	if len(rows) == 0 {
+		return results, fmt.Errorf("no header row")
+	}
+
+	columns := map[string]int{}
+	for i, name := range rows[0] {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		for field, names := range sheetColumns {
+			for _, n := range names {
+				if _, ok := columns[field]; !ok && name == n {
+					columns[field] = i
+				}
+			}
+		}
+	}
+	if _, ok := columns["case"]; !ok {
+		if i, ok := idColumn(rows); ok {
+			columns["case"] = i
+		}
+	}
+	_, hasCase := columns["case"]
+	_, hasTitle := columns["title"]
+	if !hasCase && !hasTitle {
+		return results, fmt.Errorf("no case ID or title column")
+	}
+	if _, ok := columns["status"]; !ok {
+		return results, fmt.Errorf("no status column")
+	}
+
+	inRun := map[int]bool{}
+	byTitle := map[string][]int{}
+	for _, t := range tests {
+		inRun[t.CaseID] = true
+		title := strings.ToLower(t.Title)
+		byTitle[title] = append(byTitle[title], t.CaseID)
+	}
+	statusIDs := map[string]int{}
+	for _, s := range statuses {
+		statusIDs[strings.ToLower(s.Name)] = s.ID
+		statusIDs[strings.ToLower(s.Label)] = s.ID
+		statusIDs[strconv.Itoa(s.ID)] = s.ID
+	}
+
+	cell := func(row []string, field string) string {
+		i, ok := columns[field]
+		if !ok || i >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[i])
+	}
+
+	problems := []string{}
+	for n, row := range rows[1:] {
+		line := n + 2
+		status := cell(row, "status")
+		if status == "" {
+			continue
+		}
+
+		var caseID int
+		if id := cell(row, "case"); id != "" {
+			caseID, err = strconv.Atoi(strings.TrimPrefix(strings.ToUpper(id), "C"))
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("line %d: bad case ID %q", line, id))
+				continue
+			}
+			if !inRun[caseID] {
+				problems = append(problems, fmt.Sprintf("line %d: C%d is not in the run", line, caseID))
+				continue
+			}
+		} else {
+			title := cell(row, "title")
+			switch ids := byTitle[strings.ToLower(title)]; len(ids) {
+			case 0:
+				problems = append(problems, fmt.Sprintf("line %d: no test of the run is titled %q", line, title))
+				continue
+			case 1:
+				caseID = ids[0]
+			default:
+				problems = append(problems, fmt.Sprintf("line %d: %d tests of the run are titled %q, set the case ID", line, len(ids), title))
+				continue
+			}
+		}
+
+		statusID, ok := statusIDs[strings.ToLower(status)]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("line %d: unknown status %q", line, status))
+			continue
+		}
+
+		result := testrail.SendableResult{StatusID: statusID, Comment: cell(row, "comment"), Version: version}
+		if elapsed := cell(row, "elapsed"); elapsed != "" {
+			d, err := parseElapsed(elapsed)
+			if err != nil || d < 0 {
+				problems = append(problems, fmt.Sprintf("line %d: bad elapsed time %q", line, elapsed))
+				continue
+			}
+			if timespan := testrail.TimespanFromDuration(d); timespan != nil {
+				result.Elapsed = *timespan
+			}
+		}
+		results.Results = append(results.Results, testrail.ResultsForCase{CaseID: caseID, SendableResult: result})
+	}
+	if len(problems) > 0 {
+		return results, fmt.Errorf("%s", strings.Join(problems, "\n"))
+	}
+	return results, nil
+}
+
+func importResultsCommand() cli.Command {
+	return cli.Command{
+		Name:      "import-results",
+		Usage:     "Record the results of a spreadsheet, such as a manual test cycle, in a run",
+		ArgsUsage: "--file results.csv --run-id N",
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "file, f",
+				Usage: "CSV file with a header row and case ID or title, status, comment and elapsed columns",
+			},
+			cli.IntFlag{
+				Name:  "run-id, r",
+				Usage: "TestRail run to record the results in",
+			},
+			cli.BoolFlag{
+				Name:  "dry, d",
+				Usage: "check the file and print the results without recording them",
+			},
+			resultVersionFlag,
+		},
+		Action: func(c *cli.Context) error {
+			runID := c.Int("run-id")
+			if c.String("file") == "" || runID == 0 {
+				return configErrorf("Must set --file and --run-id")
+			}
+			f, err := os.Open(c.String("file"))
+			if err != nil {
+				return configErrorf("Error opening results file: %s", err)
+			}
+			defer f.Close()
+
+			client, err := newClient()
+			if err != nil {
+				return err
+			}
+			tests, err := freshTests(client, runID)
+			if err != nil {
+				return apiErrorf("Error getting tests of run %d: %s", runID, err)
+			}
+			statuses, err := client.GetStatuses()
+			if err != nil {
+				return apiErrorf("Error getting statuses: %s", err)
+			}
+
+			results, err := sheetResults(f, tests, statuses, c.String("result-version"))
+			if err != nil {
+				return parseErrorf("Error reading results file %s:\n%s", c.String("file"), err)
+			}
+			if c.Bool("dry") {
+				for _, r := range results.Results {
+					statusf("C%d: status %d %s\n", r.CaseID, r.StatusID, r.Comment)
+				}
+				return nil
+			}
+			if len(results.Results) == 0 {
+				statusf("No results to record\n")
+				return nil
+			}
+
+			recorded, err := client.AddResultsForCases(runID, results)
+			if err != nil {
+				return apiErrorf("Error recording results: %s", err)
+			}
+			resultsUploaded.Add(float64(len(recorded)))
+			statusf("Recorded %d results in run %d\n", len(recorded), runID)
+			return nil
+		},
+	}
+}
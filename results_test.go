@@ -0,0 +1,109 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/educlos/testrail"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSheetResults(t *testing.T) {
+	tests := []testrail.Test{{ID: 101, CaseID: 11, Title: "Login"}, {ID: 102, CaseID: 12, Title: "Logout"}}
+	statuses := []testrail.Status{{ID: 1, Name: "passed", Label: "Passed"}, {ID: 2, Name: "blocked", Label: "Blocked"}, {ID: 5, Name: "failed", Label: "Failed"}}
+
+	for _, tc := range []struct {
+		name  string
+		sheet string
+		want  []testrail.ResultsForCase
+		err   string
+	}{
+		{
+			name:  "by ID",
+			sheet: "\ufeffCase ID,Status,Comment,Elapsed\nC11,Passed,,1m 30s\n12,blocked,no VPN,\n",
+			want: []testrail.ResultsForCase{
+				{CaseID: 11, SendableResult: testrail.SendableResult{StatusID: 1, Version: "1.0", Elapsed: *testrail.TimespanFromDuration(90 * time.Second)}},
+				{CaseID: 12, SendableResult: testrail.SendableResult{StatusID: 2, Comment: "no VPN", Version: "1.0"}},
+			},
+		},
+		{
+			name:  "by title",
+			sheet: "title,STATUS\nlogout,5\nlogin,\n",
+			want:  []testrail.ResultsForCase{{CaseID: 12, SendableResult: testrail.SendableResult{StatusID: 5, Version: "1.0"}}},
+		},
+		{
+			name:  "bad rows",
+			sheet: "Case,Title,Status,Elapsed\nC13,,Passed,\n,Signup,Passed,\nC11,,Flaky,\nC12,,Passed,ages\n",
+			err:   "line 2: C13 is not in the run\nline 3: no test of the run is titled \"Signup\"\nline 4: unknown status \"Flaky\"\nline 5: bad elapsed time \"ages\"",
+		},
+		{
+			name:  "id column of case IDs",
+			sheet: "ID,Status,Elapsed\nC11,Passed,90\nc12,Failed,1h 2m\n",
+			want: []testrail.ResultsForCase{
+				{CaseID: 11, SendableResult: testrail.SendableResult{StatusID: 1, Version: "1.0", Elapsed: *testrail.TimespanFromDuration(90 * time.Second)}},
+				{CaseID: 12, SendableResult: testrail.SendableResult{StatusID: 5, Version: "1.0", Elapsed: *testrail.TimespanFromDuration(62 * time.Minute)}},
+			},
+		},
+		{
+			name:  "id column of row numbers",
+			sheet: "ID,Title,Status,Elapsed\n1,Logout,Passed,1.5s\n2,Login,Failed,1d\n",
+			want: []testrail.ResultsForCase{
+				{CaseID: 12, SendableResult: testrail.SendableResult{StatusID: 1, Version: "1.0", Elapsed: *testrail.TimespanFromDuration(1500 * time.Millisecond)}},
+				{CaseID: 11, SendableResult: testrail.SendableResult{StatusID: 5, Version: "1.0", Elapsed: *testrail.TimespanFromDuration(8 * time.Hour)}},
+			},
+		},
+		{
+			name:  "id column only",
+			sheet: "ID,Status\n11,Passed\n",
+			err:   "no case ID or title column",
+		},
+		{
+			name:  "no status",
+			sheet: "Case ID,Comment\nC11,ok\n",
+			err:   "no status column",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			results, err := sheetResults(strings.NewReader(tc.sheet), tests, statuses, "1.0")
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, results.Results)
+		})
+	}
+}
+
+func TestImportResults(t *testing.T) {
+	s, stop := startFake(t)
+	defer stop()
+
+	dir, err := ioutil.TempDir("", "results")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	runID := s.AddRun(1, 2, 11, 12).ID
+	file := filepath.Join(dir, "results.csv")
+	assert.NoError(t, ioutil.WriteFile(file, []byte("Case ID,Status,Comment\nC11,Passed,\nC12,Failed,still logged in\n"), 0644))
+
+	assert.NoError(t, run("import-results", "--file", file, "--run-id", strconv.Itoa(runID), "--dry"))
+	assert.Empty(t, s.Results)
+
+	assert.NoError(t, run("import-results", "--file", file, "--run-id", strconv.Itoa(runID)))
+	if assert.Len(t, s.Results, 2) {
+		assert.Equal(t, 1, s.Results[0].StatusID)
+		assert.Equal(t, 5, s.Results[1].StatusID)
+		assert.Equal(t, "still logged in", s.Results[1].Comment)
+	}
+
+	assert.NoError(t, ioutil.WriteFile(file, []byte("Case ID,Status\nC99,Passed\n"), 0644))
+	assert.Equal(t, exitParse, exitCode(run("import-results", "--file", file, "--run-id", strconv.Itoa(runID))))
+	assert.Len(t, s.Results, 2)
+	assert.Equal(t, exitConfig, exitCode(run("import-results", "--file", file)))
+}
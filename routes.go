@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"path"
+
+	"github.com/urfave/cli"
+	yaml "gopkg.in/yaml.v2"
+
+	"github.com/docker/trailer/pkg/config"
+	"github.com/docker/trailer/pkg/keyring"
+	"github.com/docker/trailer/pkg/secrets"
+	"github.com/docker/trailer/pkg/upload"
+	"github.com/docker/trailer/spec"
+)
+
+var routesFlag = cli.StringFlag{
+	Name:  "routes",
+	Usage: "YAML file sending the results of each JUnit test suite to the run of the first route whose suite patterns match its name, instead of --run-id",
+}
+
+// routeFile is the layout of a routes file:
+//
+//	routes:
+//	  - suites: [platform-*, api]
+//	    run_id: 12
+//	  - suites: [ui-*]
+//	    run_id: 34
+//	    url: https://ui.testrail.io
+//	    username: ci@example.com
+//	    token: vault://secret/testrail#token
+//
+// Routes without a url upload to the configured instance. The token of the
+// other instances is read from the routes file, which may reference a
+// secrets manager, or the keyring.
+type routeFile struct {
+	Routes []route `yaml:"routes"`
+}
+
+type route struct {
+	Suites   []string `yaml:"suites"`
+	RunID    int      `yaml:"run_id"`
+	URL      string   `yaml:"url"`
+	Username string   `yaml:"username"`
+	Token    string   `yaml:"token"`
+}
+
+// loadRoutes reads and checks a routes file.
+func loadRoutes(file string) ([]route, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var f routeFile
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return nil, err
+	}
+	if len(f.Routes) == 0 {
+		return nil, fmt.Errorf("no routes")
+	}
+	for i, r := range f.Routes {
+		if r.RunID == 0 {
+			return nil, fmt.Errorf("route %d has no run_id", i+1)
+		}
+		if len(r.Suites) == 0 {
+			return nil, fmt.Errorf("route %d has no suites", i+1)
+		}
+		for _, p := range r.Suites {
+			if _, err := path.Match(p, ""); err != nil {
+				return nil, fmt.Errorf("route %d: suite pattern %q: %s", i+1, p, err)
+			}
+		}
+	}
+	return f.Routes, nil
+}
+
+// routeSuites splits the suites by the first route matching their names. The
+// suites no route matches are returned last.
+func routeSuites(routes []route, suites spec.JUnitTestSuites) ([]spec.JUnitTestSuites, spec.JUnitTestSuites) {
+	routed := make([]spec.JUnitTestSuites, len(routes))
+	unrouted := spec.JUnitTestSuites{}
+	for _, suite := range suites.Suites {
+		i := matchRoute(routes, suite.Name)
+		if i < 0 {
+			unrouted.Suites = append(unrouted.Suites, suite)
+			continue
+		}
+		routed[i].Suites = append(routed[i].Suites, suite)
+	}
+	return routed, unrouted
+}
+
+func matchRoute(routes []route, name string) int {
+	for i, r := range routes {
+		for _, p := range r.Suites {
+			if ok, _ := path.Match(p, name); ok {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// routeClient builds a client for the instance of a route.
+func routeClient(r route) (testrailAPI, error) {
+	if r.URL == "" {
+		return newClient()
+	}
+	cfg, err := config.Load(config.File())
+	if err != nil {
+		return nil, configErrorf("Error reading config file: %s", err)
+	}
+	username := r.Username
+	if username == "" {
+		username = envOr("TESTRAIL_USERNAME", cfg.Username)
+	}
+	token := ""
+	if r.Token != "" {
+		if token, err = secrets.Resolve(r.Token); err != nil {
+			return nil, configErrorf("Error reading the API token of %s: %s", r.URL, err)
+		}
+	} else if token, err = keyring.Get(keyringService(r.URL), username); err != nil && err != keyring.ErrNotFound && err != keyring.ErrUnsupported {
+		slog.Warn("Error reading the API token from the keyring", "url", r.URL, "error", err)
+	}
+	c, err := newClientFor(r.URL, username, token)
+	if err != nil {
+		return nil, configErrorf("Error connecting to %s: %s", r.URL, err)
+	}
+	return withCache(c, apiCache), nil
+}
+
+// uploadRoutes sends the results of the suites of each route to its run,
+// read with the statuses, case map, version and step results of base. The
+// results of the suites no route matches are left out.
+func uploadRoutes(ctx context.Context, routes []route, suites spec.JUnitTestSuites, comment string, base spec.Updates, retries int, dry, strict bool) error {
+	routed, unrouted := routeSuites(routes, suites)
+	for _, suite := range unrouted.Suites {
+		slog.Warn("No route for test suite, leaving out its results", "suite", suite.Name, "tests", len(suite.TestCases))
+	}
+
+	dropped, parsed := 0, 0
+	var strictErr error
+	for i, r := range routes {
+		updates := spec.Updates{ResultMap: map[int]spec.Update{}, Statuses: base.Statuses, CaseMap: base.CaseMap, Version: base.Version, StepResults: base.StepResults}
+		if err := updates.AddSuites(comment, routed[i]); err != nil {
+			return parseErrorf("Failed to read results: %s", err)
+		}
+		if len(updates.ResultMap) == 0 && len(updates.Unmatched) == 0 {
+			continue
+		}
+		parsedResults := map[int]spec.Update{}
+		for id, u := range updates.ResultMap {
+			parsedResults[id] = u
+		}
+
+		instance := instanceFromEnv()
+		if r.URL != "" {
+			instance = instanceURL(r.URL)
+		}
+		rejected := map[int]bool{}
+		if !dry {
+			client, err := routeClient(r)
+			if err != nil {
+				return err
+			}
+			sent := updates.SortedCaseIDs()
+			bar := newProgress(fmt.Sprintf("Uploading results to run %d", r.RunID), len(sent))
+			err = uploadResults(upload.WithProgress(ctx, bar.Set), client, r.RunID, retries, &updates)
+			bar.Finish()
+			if err != nil {
+				return err
+			}
+			for _, id := range sent {
+				if _, ok := updates.ResultMap[id]; !ok {
+					rejected[id] = true
+				}
+			}
+		}
+
+		summary := newUploadSummary(newUploadReport(instance, r.RunID, parsedResults, rejected, dry))
+		if dry {
+			statusf("Parsed %s for run %d\n", countsLine(summary), r.RunID)
+		} else {
+			statusf("Uploaded %s to run %d\n", countsLine(summary), r.RunID)
+		}
+		parsed += len(parsedResults)
+		dropped += len(rejected)
+		if strict && strictErr == nil {
+			strictErr = strictCheck(r.RunID, &updates, len(rejected))
+		}
+	}
+
+	if strict && len(unrouted.Suites) > 0 {
+		return exitError{code: exitPartial, err: fmt.Errorf("Left out results in strict mode: %d test suites match no route", len(unrouted.Suites))}
+	}
+	if strictErr != nil {
+		return strictErr
+	}
+	if dropped > 0 {
+		return exitError{code: exitPartial, err: fmt.Errorf("Dropped %d of %d results for cases unknown to TestRail", dropped, parsed)}
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/educlos/testrail"
+	"github.com/onsi/ginkgo/reporters"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/docker/trailer/internal/faketestrail"
+	"github.com/docker/trailer/spec"
+)
+
+func TestLoadRoutes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "routes")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "routes.yaml")
+	assert.NoError(t, ioutil.WriteFile(file, []byte(`routes:
+  - suites: [platform-*, api]
+    run_id: 12
+  - suites: ["*"]
+    run_id: 34
+    url: https://ui.testrail.io
+`), 0644))
+	routes, err := loadRoutes(file)
+	assert.NoError(t, err)
+	assert.Equal(t, []route{
+		{Suites: []string{"platform-*", "api"}, RunID: 12},
+		{Suites: []string{"*"}, RunID: 34, URL: "https://ui.testrail.io"},
+	}, routes)
+
+	suites := spec.JUnitTestSuites{}
+	for _, name := range []string{"api", "ui-login", "platform-net"} {
+		suites.Suites = append(suites.Suites, reporters.JUnitTestSuite{Name: name})
+	}
+	routed, unrouted := routeSuites(routes[:1], suites)
+	if assert.Len(t, routed, 1) && assert.Len(t, routed[0].Suites, 2) {
+		assert.Equal(t, "api", routed[0].Suites[0].Name)
+		assert.Equal(t, "platform-net", routed[0].Suites[1].Name)
+	}
+	if assert.Len(t, unrouted.Suites, 1) {
+		assert.Equal(t, "ui-login", unrouted.Suites[0].Name)
+	}
+
+	for _, bad := range []string{
+		"routes: []\n",
+		"routes:\n  - suites: [api]\n",
+		"routes:\n  - run_id: 1\n",
+		"routes:\n  - suites: [\"[\"]\n    run_id: 1\n",
+	} {
+		assert.NoError(t, ioutil.WriteFile(file, []byte(bad), 0644))
+		_, err := loadRoutes(file)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestUploadRoutes(t *testing.T) {
+	s, stop := startFake(t)
+	defer stop()
+
+	// The UI tests go to another instance.
+	ui := faketestrail.New()
+	defer ui.Close()
+	ui.Projects = []testrail.Project{{ID: 1, Name: "UI"}}
+	ui.Suites = []testrail.Suite{{ID: 2, ProjectID: 1, Name: "Master"}}
+	ui.Cases = []testrail.Case{{ID: 11, SuiteID: 2, Title: "Button"}}
+
+	dir, err := ioutil.TempDir("", "routes")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	platformRun := s.AddRun(1, 2, 11, 12).ID
+	uiRun := ui.AddRun(1, 2, 11).ID
+	routes := filepath.Join(dir, "routes.yaml")
+	assert.NoError(t, ioutil.WriteFile(routes, []byte(fmt.Sprintf(`routes:
+  - suites: [platform-*]
+    run_id: %d
+  - suites: [ui]
+    run_id: %d
+    url: %s
+    username: ui@example.com
+    token: secret
+`, platformRun, uiRun, ui.URL)), 0644))
+
+	report := writeReport(t, dir, `<testsuites>
+  <testsuite name="platform-accounts" tests="2">
+    <testcase name="TestRailC11 login" time="1"></testcase>
+    <testcase name="TestRailC12 logout" time="1"><failure message="boom">stuck</failure></testcase>
+  </testsuite>
+  <testsuite name="ui" tests="1">
+    <testcase name="TestRailC11 button" time="1"></testcase>
+  </testsuite>
+  <testsuite name="docs" tests="1">
+    <testcase name="TestRailC12 links" time="1"></testcase>
+  </testsuite>
+</testsuites>`)
+
+	assert.NoError(t, run("upload", "--routes", routes, "--dry", report))
+	assert.Empty(t, s.Results)
+	assert.Empty(t, ui.Results)
+
+	assert.NoError(t, run("upload", "--routes", routes, report))
+	s.Lock()
+	if assert.Len(t, s.Results, 2) {
+		assert.Equal(t, 11, s.Results[0].CaseID)
+		assert.Equal(t, 1, s.Results[0].StatusID)
+		assert.Equal(t, 12, s.Results[1].CaseID)
+		assert.Equal(t, 5, s.Results[1].StatusID)
+	}
+	s.Unlock()
+	ui.Lock()
+	if assert.Len(t, ui.Results, 1) {
+		assert.Equal(t, 11, ui.Results[0].CaseID)
+	}
+	ui.Unlock()
+
+	// The docs suite matches no route.
+	assert.Equal(t, exitPartial, exitCode(run("upload", "--routes", routes, "--strict", report)))
+
+	assert.Equal(t, exitConfig, exitCode(run("upload", "--routes", routes, "--run-id", "1", report)))
+	assert.Equal(t, exitConfig, exitCode(run("upload", "--routes", routes, "--verify", report)))
+	assert.Equal(t, exitConfig, exitCode(run("upload", "--routes", filepath.Join(dir, "missing.yaml"), report)))
+}
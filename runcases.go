@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/educlos/testrail"
+	"github.com/urfave/cli"
+
+	"github.com/docker/trailer/spec"
+)
+
+var addMissingFlag = cli.BoolFlag{
+	Name:  "add-missing-to-run",
+	Usage: "add the cases of the run's suite with results that are missing from the run to it instead of skipping their results",
+}
+
+// runCaseAttempts is how many times the cases of a run are merged before
+// giving up on shards that keep overwriting each other's selections.
+const runCaseAttempts = 5
+
+// runCaseBackoff is the base of the random wait between merges, which
+// spreads out shards that raced each other.
+var runCaseBackoff = 500 * time.Millisecond
+
+// missingRunCases returns the cases that are not part of the run and every
+// case the run has, or nil for both when the run includes all the cases of
+// its suite and so cannot be given more.
+func missingRunCases(client testrailAPI, runID int, caseIDs []int) (missing, have []int, err error) {
+	var run testrail.Run
+	if err := client.send("GET", fmt.Sprintf("get_run/%d", runID), nil, &run); err != nil {
+		return nil, nil, err
+	}
+	if run.IncludeAll {
+		return nil, nil, nil
+	}
+
+	// Other shards change the cases of the run, so they are never cached.
+	tests, err := freshTests(client, runID)
+	if err != nil {
+		return nil, nil, err
+	}
+	inRun := map[int]bool{}
+	for _, test := range tests {
+		inRun[test.CaseID] = true
+		have = append(have, test.CaseID)
+	}
+	for _, id := range caseIDs {
+		if !inRun[id] {
+			missing = append(missing, id)
+		}
+	}
+	return missing, have, nil
+}
+
+// suiteCases returns the cases that belong to the suite, which are the only
+// ones its runs can be given.
+func suiteCases(client testrailAPI, projectID, suiteID int, caseIDs []int) ([]int, error) {
+	cases, err := client.GetCases(projectID, suiteID)
+	if err != nil {
+		return nil, err
+	}
+	inSuite := map[int]bool{}
+	for _, c := range cases {
+		inSuite[c.ID] = true
+	}
+
+	ids := []int{}
+	for _, id := range caseIDs {
+		if inSuite[id] {
+			ids = append(ids, id)
+		} else {
+			slog.Debug("Not adding a case from another suite to the run", "suite", suiteID, "case", id)
+		}
+	}
+	return ids, nil
+}
+
+// ensureRunCases adds the cases of the run's suite to the run, the others
+// are left for Upload to prune. TestRail replaces the case list of a run as a
+// whole, so the current list is fetched and merged right before each update,
+// and read back afterwards: another shard updating the run at the same time
+// may have written a list without these cases, in which case the merge is
+// retried after a random wait. It returns the cases it added.
+func ensureRunCases(client testrailAPI, runID int, caseIDs []int) ([]int, error) {
+	var run testrail.Run
+	if err := client.send("GET", fmt.Sprintf("get_run/%d", runID), nil, &run); err != nil {
+		return nil, err
+	}
+	if run.IncludeAll {
+		return nil, nil
+	}
+	caseIDs, err := suiteCases(client, run.ProjectID, run.SuiteID, caseIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	added := map[int]bool{}
+	// The last pass only reads the run back to check the last update.
+	for attempt := 0; attempt <= runCaseAttempts; attempt++ {
+		if attempt > 1 {
+			wait := time.Duration(rand.Int63n(int64(runCaseBackoff) << uint(attempt-1)))
+			slog.Debug("Cases of the run were overwritten, merging again", "run", runID, "attempt", attempt, "wait", wait)
+			time.Sleep(wait)
+		}
+
+		missing, have, err := missingRunCases(client, runID, caseIDs)
+		if err != nil {
+			return nil, err
+		}
+		if len(missing) == 0 {
+			return sortedKeys(added), nil
+		}
+		if attempt == runCaseAttempts {
+			break
+		}
+
+		merged := append(have, missing...)
+		sort.Ints(merged)
+		includeAll := false
+		update := testrail.UpdatableRun{IncludeAll: &includeAll, CaseIDs: merged}
+		slog.Debug("Adding cases to the run", "run", runID, "cases", missing)
+		if err := client.send("POST", fmt.Sprintf("update_run/%d", runID), update, nil); err != nil {
+			return nil, err
+		}
+		for _, id := range missing {
+			added[id] = true
+		}
+	}
+	return nil, fmt.Errorf("cases of run %d were still overwritten after %d attempts", runID, runCaseAttempts)
+}
+
+func sortedKeys(m map[int]bool) []int {
+	keys := make([]int, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	return keys
+}
+
+// reuploadLost uploads the results of the cases in sent again if another
+// shard removed them from the run after they were uploaded, which deletes
+// their results in TestRail.
+func reuploadLost(ctx context.Context, client testrailAPI, runID, retries int, updates *spec.Updates, sent []int) error {
+	for attempt := 0; attempt < runCaseAttempts; attempt++ {
+		lost, _, err := missingRunCases(client, runID, sent)
+		if err != nil {
+			return apiErrorf("Failed to check the cases of run %d: %s", runID, err)
+		}
+		if len(lost) == 0 {
+			return nil
+		}
+		slog.Warn("Cases were removed from the run by another upload, uploading their results again", "run", runID, "cases", lost)
+		if _, err := ensureRunCases(client, runID, lost); err != nil {
+			return apiErrorf("Failed to add cases to run %d: %s", runID, err)
+		}
+
+		again := *updates
+		again.ResultMap = map[int]spec.Update{}
+		for _, id := range lost {
+			if u, ok := updates.ResultMap[id]; ok {
+				again.ResultMap[id] = u
+			}
+		}
+		if err := uploadResults(ctx, client, runID, retries, &again); err != nil {
+			return err
+		}
+		sent = lost
+	}
+	return apiErrorf("Cases of run %d were still removed by other uploads after %d attempts", runID, runCaseAttempts)
+}
+
+// uploadedCases returns the cases of sent whose results are in the run: the
+// ones neither dropped for being unknown nor pruned for being outside it.
+func uploadedCases(sent []int, dropped map[int]bool, pruned []int) []int {
+	outside := map[int]bool{}
+	for _, id := range pruned {
+		outside[id] = true
+	}
+	ids := []int{}
+	for _, id := range sent {
+		if !dropped[id] && !outside[id] {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
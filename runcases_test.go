@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/educlos/testrail"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/docker/trailer/internal/faketestrail"
+	"github.com/docker/trailer/spec"
+)
+
+func runCaseIDs(s *faketestrail.Server, runID int) []int {
+	s.Lock()
+	defer s.Unlock()
+	ids := []int{}
+	for _, test := range s.Tests {
+		if test.RunID == runID {
+			ids = append(ids, test.CaseID)
+		}
+	}
+	return ids
+}
+
+// racingClient overwrites the cases of the run with a stale selection right
+// after the first races updates, like other shards that read the run before
+// it.
+type racingClient struct {
+	testrailAPI
+	stale []int
+	races int
+}
+
+func (c *racingClient) send(method, uri string, data, v interface{}) error {
+	if err := c.testrailAPI.send(method, uri, data, v); err != nil {
+		return err
+	}
+	if strings.HasPrefix(uri, "update_run/") && c.races > 0 {
+		c.races--
+		includeAll := false
+		return c.testrailAPI.send(method, uri, testrail.UpdatableRun{IncludeAll: &includeAll, CaseIDs: c.stale}, nil)
+	}
+	return nil
+}
+
+func TestEnsureRunCases(t *testing.T) {
+	s, stop := startFake(t)
+	defer stop()
+
+	backoff := runCaseBackoff
+	runCaseBackoff = time.Millisecond
+	defer func() { runCaseBackoff = backoff }()
+
+	base, err := newClient()
+	assert.NoError(t, err)
+
+	testcases := []struct {
+		races int
+		err   bool
+	}{
+		{races: 0},
+		{races: 1},
+		// The last update is read back before giving up.
+		{races: runCaseAttempts - 1},
+		{races: runCaseAttempts, err: true},
+	}
+	for _, testcase := range testcases {
+		r := s.AddRun(1, 2, 11)
+		client := &racingClient{testrailAPI: base, stale: []int{11}, races: testcase.races}
+		added, err := ensureRunCases(client, r.ID, []int{11, 12})
+		if testcase.err {
+			assert.Error(t, err)
+			continue
+		}
+		assert.NoError(t, err)
+		assert.Equal(t, []int{12}, added)
+		assert.ElementsMatch(t, []int{11, 12}, runCaseIDs(s, r.ID))
+	}
+
+	// Runs including every case of their suite are left alone.
+	all := s.AddRun(1, 2)
+	added, err := ensureRunCases(base, all.ID, []int{11, 12})
+	assert.NoError(t, err)
+	assert.Empty(t, added)
+}
+
+func TestReuploadLost(t *testing.T) {
+	s, stop := startFake(t)
+	defer stop()
+
+	dir, err := ioutil.TempDir("", "runcases")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	r := s.AddRun(1, 2, 11, 12)
+	report := writeReport(t, dir, `<testsuite name="accounts" tests="2">
+  <testcase name="TestRailC11 login" time="1"></testcase>
+  <testcase name="TestRailC12 logout" time="1"></testcase>
+</testsuite>`)
+	updates, err := parseReports(context.Background(), []string{report}, "", "", spec.StatusMap{}, nil)
+	assert.NoError(t, err)
+	client, err := newClient()
+	assert.NoError(t, err)
+	assert.NoError(t, uploadResults(context.Background(), client, r.ID, 3, &updates))
+
+	// Another shard drops case 12 and its result from the run.
+	includeAll := false
+	assert.NoError(t, client.send("POST", "update_run/"+strconv.Itoa(r.ID), testrail.UpdatableRun{IncludeAll: &includeAll, CaseIDs: []int{11}}, nil))
+
+	assert.NoError(t, reuploadLost(context.Background(), client, r.ID, 3, &updates, []int{11, 12}))
+	assert.ElementsMatch(t, []int{11, 12}, runCaseIDs(s, r.ID))
+	s.Lock()
+	defer s.Unlock()
+	cases := []int{}
+	for _, result := range s.Results {
+		cases = append(cases, result.CaseID)
+	}
+	assert.ElementsMatch(t, []int{11, 12}, cases)
+}
+
+func TestUploadAddMissingToRun(t *testing.T) {
+	s, stop := startFake(t)
+	defer stop()
+
+	dir, err := ioutil.TempDir("", "runcases")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	s.Lock()
+	s.Suites = append(s.Suites, testrail.Suite{ID: 4, ProjectID: 1, Name: "Other"})
+	s.Cases = append(s.Cases, testrail.Case{ID: 41, SuiteID: 4, Title: "Elsewhere"})
+	s.Unlock()
+
+	r := s.AddRun(1, 2, 11)
+	report := writeReport(t, dir, `<testsuite name="accounts" tests="2">
+  <testcase name="TestRailC11 login" time="1"></testcase>
+  <testcase name="TestRailC12 logout" time="1"><failure message="boom"></failure></testcase>
+</testsuite>`)
+	assert.NoError(t, run("upload", "--strict", "--add-missing-to-run", "--run-id", strconv.Itoa(r.ID), report))
+	assert.ElementsMatch(t, []int{11, 12}, runCaseIDs(s, r.ID))
+
+	// Cases of other suites cannot be part of the run and are still pruned.
+	other := writeReport(t, dir, `<testsuite name="accounts" tests="2">
+  <testcase name="TestRailC11 login" time="1"></testcase>
+  <testcase name="TestRailC41 elsewhere" time="1"></testcase>
+</testsuite>`)
+	assert.Equal(t, exitPartial, exitCode(run("upload", "--strict", "--add-missing-to-run", "--run-id", strconv.Itoa(r.ID), other)))
+	assert.ElementsMatch(t, []int{11, 12}, runCaseIDs(s, r.ID))
+
+	s.Lock()
+	statuses := map[int]int{}
+	for _, result := range s.Results {
+		statuses[result.CaseID] = result.StatusID
+	}
+	s.Unlock()
+	assert.Equal(t, map[int]int{11: 1, 12: 5}, statuses)
+
+	assert.Equal(t, exitConfig, exitCode(run("upload", "--add-missing-to-run", "--routes", "routes.yml", report)))
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"text/template"
+	"time"
+
+	"github.com/educlos/testrail"
+	"github.com/urfave/cli"
+
+	"github.com/docker/trailer/pkg/config"
+)
+
+// defaultRunNameTemplate names the runs upload creates unless
+// --run-name-template is set.
+const defaultRunNameTemplate = "Automated run {{.Date}}{{if .Branch}} {{.Branch}}{{end}}{{if .ShortSHA}} ({{.ShortSHA}}){{end}}"
+
+var createRunFlags = []cli.Flag{
+	cli.BoolFlag{
+		Name:  "create-run",
+		Usage: "create a run of the cases in the reports and upload to it instead of --run-id",
+	},
+	cli.StringFlag{
+		Name:  "run-name",
+		Usage: "upload to the open run of the suite with this name, so parallel jobs share a run: it is created with the cases of the reports if there is none, and given the ones it lacks otherwise; a template like --run-name-template",
+	},
+	cli.IntFlag{
+		Name:  "project-id, p",
+		Usage: "TestRail project ID to create the run in, defaults to the project_id of the config file",
+	},
+	cli.IntFlag{
+		Name:  "suite-id, s",
+		Usage: "TestRail suite ID of the cases of the created run, defaults to the suite_id of the config file",
+	},
+	cli.StringFlag{
+		Name:  "run-name-template",
+		Usage: "Go template of the name of the created run, with .Date, .Time, .Branch, .SHA, .ShortSHA, .BuildNumber and .Provider",
+		Value: defaultRunNameTemplate,
+	},
+}
+
+// runNameData are the variables of run name templates. The CI variables are
+// empty outside of a known CI.
+type runNameData struct {
+	Date        string
+	Time        string
+	Branch      string
+	SHA         string
+	ShortSHA    string
+	BuildNumber string
+	Provider    string
+}
+
+func newRunNameData(now time.Time) runNameData {
+	d := runNameData{Date: now.Format("2006-01-02"), Time: now.Format("15:04")}
+	if b, ok := detectCI(); ok {
+		d.Branch, d.SHA, d.BuildNumber, d.Provider = b.Branch, b.Commit, b.Number, b.Provider
+		d.ShortSHA = d.SHA
+		if len(d.ShortSHA) > 7 {
+			d.ShortSHA = d.ShortSHA[:7]
+		}
+	}
+	return d
+}
+
+// runName renders a run name template.
+func runName(text string, d runNameData) (string, error) {
+	tmpl, err := template.New("run name").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, d); err != nil {
+		return "", err
+	}
+	name := buf.String()
+	if name == "" {
+		return "", fmt.Errorf("template gives an empty name")
+	}
+	return name, nil
+}
+
+// runSuite returns the project and suite of the flags or the config file
+// to create runs in.
+func runSuite(c *cli.Context) (int, int, error) {
+	projectID, suiteID := c.Int("project-id"), c.Int("suite-id")
+	if projectID == 0 || suiteID == 0 {
+		cfg, err := config.Load(config.File())
+		if err != nil {
+			return 0, 0, configErrorf("Error reading config file: %s", err)
+		}
+		if projectID == 0 {
+			projectID = cfg.ProjectID
+		}
+		if suiteID == 0 {
+			suiteID = cfg.SuiteID
+		}
+	}
+	if projectID == 0 || suiteID == 0 {
+		return 0, 0, configErrorf("Must set --project-id and --suite-id, or run trailer init, to create a run")
+	}
+	return projectID, suiteID, nil
+}
+
+// addRun adds a run of the cases to the suite, or of every case of the suite
+// when caseIDs is nil. Named runs are created with the cases of the first
+// job's reports that belong to the suite, namedRun adds those of the other
+// jobs to them as they upload.
+func addRun(client testrailAPI, projectID, suiteID int, name string, caseIDs []int) (testrail.Run, error) {
+	includeAll := caseIDs == nil
+	var run testrail.Run
+	err := client.send("POST", fmt.Sprintf("add_run/%d", projectID), testrail.SendableRun{
+		SuiteID:    suiteID,
+		Name:       name,
+		IncludeAll: &includeAll,
+		CaseIDs:    caseIDs,
+	}, &run)
+	if err != nil {
+		return run, apiErrorf("Error creating run %q: %s", name, err)
+	}
+	return run, nil
+}
+
+// createRun adds a run of the cases, named by --run-name-template, to the
+// project and suite of the flags or the config file.
+func createRun(c *cli.Context, client testrailAPI, caseIDs []int) (testrail.Run, error) {
+	projectID, suiteID, err := runSuite(c)
+	if err != nil {
+		return testrail.Run{}, err
+	}
+	name, err := runName(c.String("run-name-template"), newRunNameData(time.Now()))
+	if err != nil {
+		return testrail.Run{}, configErrorf("Error in --run-name-template: %s", err)
+	}
+	return addRun(client, projectID, suiteID, name, caseIDs)
+}
+
+// openRunNamed returns the oldest open run of the suite with the name.
+func openRunNamed(client testrailAPI, projectID, suiteID int, name string) (testrail.Run, bool, error) {
+	open := false
+	runs, err := client.GetRuns(projectID, testrail.RequestFilterForRun{IsCompleted: &open, SuiteID: []int{suiteID}})
+	if err != nil {
+		return testrail.Run{}, false, apiErrorf("Error getting runs: %s", err)
+	}
+	var oldest testrail.Run
+	found := false
+	for _, r := range runs {
+		if r.IsCompleted || r.SuiteID != suiteID || r.Name != name {
+			continue
+		}
+		if !found || r.ID < oldest.ID {
+			oldest, found = r, true
+		}
+	}
+	return oldest, found, nil
+}
+
+// namedRun returns the open run named by --run-name with the cases added to
+// it, creating it with them if there is none, so the jobs of a build that
+// upload to the same name share a run of the cases they tested. When jobs
+// create the run at the same time, all of them use the oldest and the others
+// delete theirs.
+func namedRun(c *cli.Context, client testrailAPI, caseIDs []int) (testrail.Run, error) {
+	projectID, suiteID, err := runSuite(c)
+	if err != nil {
+		return testrail.Run{}, err
+	}
+	name, err := runName(c.String("run-name"), newRunNameData(time.Now()))
+	if err != nil {
+		return testrail.Run{}, configErrorf("Error in --run-name: %s", err)
+	}
+
+	run, found, err := openRunNamed(client, projectID, suiteID, name)
+	if err != nil {
+		return run, err
+	}
+	if !found {
+		cases, err := suiteCases(client, projectID, suiteID, caseIDs)
+		if err != nil {
+			return run, apiErrorf("Error getting cases: %s", err)
+		}
+		created, err := addRun(client, projectID, suiteID, name, cases)
+		if err != nil {
+			return created, err
+		}
+		run, found, err = openRunNamed(client, projectID, suiteID, name)
+		if err != nil || !found || run.ID == created.ID {
+			return created, err
+		}
+		slog.Info("Another job created the run at the same time, deleting the duplicate", "run", run.ID, "duplicate", created.ID)
+		if err := client.send("POST", fmt.Sprintf("delete_run/%d", created.ID), nil, nil); err != nil {
+			slog.Warn("Failed to delete the duplicate run", "run", created.ID, "error", err)
+		}
+	}
+
+	added, err := ensureRunCases(client, run.ID, caseIDs)
+	if err != nil {
+		return run, apiErrorf("Failed to add cases to run %d: %s", run.ID, err)
+	}
+	if len(added) > 0 {
+		slog.Info("Added cases to the run", "run", run.ID, "cases", added)
+	}
+	return run, nil
+}
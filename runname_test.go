@@ -0,0 +1,152 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/educlos/testrail"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunName(t *testing.T) {
+	d := runNameData{Date: "2024-05-01", Time: "13:45", Branch: "main", SHA: "0123456789abcdef", ShortSHA: "0123456", BuildNumber: "42", Provider: "GitHub Actions"}
+	for _, tc := range []struct {
+		template string
+		want     string
+	}{
+		{template: "Nightly {{.Date}} {{.Branch}} ({{.ShortSHA}})", want: "Nightly 2024-05-01 main (0123456)"},
+		{template: "#{{.BuildNumber}} on {{.Provider}} at {{.Time}}", want: "#42 on GitHub Actions at 13:45"},
+		{template: defaultRunNameTemplate, want: "Automated run 2024-05-01 main (0123456)"},
+	} {
+		name, err := runName(tc.template, d)
+		assert.NoError(t, err)
+		assert.Equal(t, tc.want, name)
+	}
+
+	name, err := runName(defaultRunNameTemplate, runNameData{Date: "2024-05-01"})
+	assert.NoError(t, err)
+	assert.Equal(t, "Automated run 2024-05-01", name)
+
+	for _, bad := range []string{"{{.Date", "{{.Nope}}", ""} {
+		_, err := runName(bad, d)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestNewRunNameData(t *testing.T) {
+	vars := map[string]string{"GITHUB_ACTIONS": "true", "GITHUB_REF_NAME": "main", "GITHUB_SHA": "0123456789abcdef", "GITHUB_RUN_NUMBER": "42", "GITHUB_HEAD_REF": ""}
+	for k, v := range vars {
+		if old, ok := os.LookupEnv(k); ok {
+			defer os.Setenv(k, old)
+		} else {
+			defer os.Unsetenv(k)
+		}
+		os.Setenv(k, v)
+	}
+
+	d := newRunNameData(time.Date(2024, 5, 1, 13, 45, 0, 0, time.UTC))
+	assert.Equal(t, runNameData{Date: "2024-05-01", Time: "13:45", Branch: "main", SHA: "0123456789abcdef", ShortSHA: "0123456", BuildNumber: "42", Provider: "GitHub Actions"}, d)
+}
+
+func TestUploadCreateRun(t *testing.T) {
+	s, stop := startFake(t)
+	defer stop()
+
+	dir, err := ioutil.TempDir("", "create")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	report := writeReport(t, dir, `<testsuite name="accounts" tests="1">
+  <testcase name="TestRailC11 login" time="1"></testcase>
+</testsuite>`)
+	flags := []string{"--create-run", "--project-id", "1", "--suite-id", "2", "--run-name-template", "Nightly {{.Date}}"}
+
+	assert.NoError(t, run(append(append([]string{"upload", "--dry"}, flags...), report)...))
+	assert.Empty(t, s.Runs)
+
+	assert.NoError(t, run(append(append([]string{"upload"}, flags...), report)...))
+	s.Lock()
+	if assert.Len(t, s.Runs, 1) && assert.Len(t, s.Results, 1) {
+		assert.Equal(t, "Nightly "+time.Now().Format("2006-01-02"), s.Runs[0].Name)
+		assert.Equal(t, 11, s.Results[0].CaseID)
+		assert.Equal(t, s.Runs[0].ID, s.Tests[0].RunID)
+	}
+	s.Unlock()
+
+	assert.Equal(t, exitConfig, exitCode(run("upload", "--create-run", "--run-id", "1", report)))
+	assert.Equal(t, exitConfig, exitCode(run("upload", "--create-run", report)))
+	assert.Equal(t, exitConfig, exitCode(run("upload", "--create-run", "--project-id", "1", "--suite-id", "2", "--run-name-template", "{{.Nope}}", report)))
+}
+
+func TestUploadRunName(t *testing.T) {
+	s, stop := startFake(t)
+	defer stop()
+
+	dir, err := ioutil.TempDir("", "runname")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	flags := []string{"--project-id", "1", "--suite-id", "2", "--run-name", "Build 7"}
+
+	// A closed run of the same name is not reused.
+	closed := s.AddRun(1, 2, 11)
+	s.Lock()
+	s.Runs[0].Name, s.Runs[0].IsCompleted = "Build 7", true
+	s.Unlock()
+
+	// The shards of the build share the run the first one creates, which
+	// gets the cases each of them tested.
+	s.Lock()
+	s.Cases = append(s.Cases, testrail.Case{ID: 13, SuiteID: 2, SectionID: 3, Title: "Signup"})
+	s.Unlock()
+	login := writeReport(t, dir, `<testsuite name="accounts" tests="1">
+  <testcase name="TestRailC11 login" time="1"></testcase>
+</testsuite>`)
+	assert.NoError(t, run(append(append([]string{"upload"}, flags...), login)...))
+	logout := writeReport(t, dir, `<testsuite name="sessions" tests="1">
+  <testcase name="TestRailC12 logout" time="1"></testcase>
+</testsuite>`)
+	assert.NoError(t, run(append(append([]string{"upload"}, flags...), logout)...))
+	s.Lock()
+	if assert.Len(t, s.Runs, 2) && assert.Len(t, s.Results, 2) {
+		shared := s.Runs[1]
+		assert.NotEqual(t, closed.ID, shared.ID)
+		assert.Equal(t, "Build 7", shared.Name)
+		cases := []int{}
+		for _, test := range s.Tests {
+			if test.RunID == shared.ID {
+				cases = append(cases, test.CaseID)
+			}
+		}
+		assert.ElementsMatch(t, []int{11, 12}, cases)
+		assert.Equal(t, []int{11, 12}, []int{s.Results[0].CaseID, s.Results[1].CaseID})
+	}
+	s.Unlock()
+
+	assert.Equal(t, exitConfig, exitCode(run("upload", "--run-name", "Build 7", "--run-id", "1", logout)))
+}
+
+func TestOpenRunNamed(t *testing.T) {
+	s, stop := startFake(t)
+	defer stop()
+
+	// Jobs that created the run at the same time all pick the oldest.
+	first, second := s.AddRun(1, 2, 11), s.AddRun(1, 2, 11)
+	s.Lock()
+	s.Runs[0].Name, s.Runs[1].Name = "Build 7", "Build 7"
+	s.Unlock()
+
+	client, err := newClient()
+	assert.NoError(t, err)
+	run, found, err := openRunNamed(client, 1, 2, "Build 7")
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, first.ID, run.ID)
+	assert.NotEqual(t, second.ID, run.ID)
+
+	_, found, err = openRunNamed(client, 1, 2, "Build 8")
+	assert.NoError(t, err)
+	assert.False(t, found)
+}
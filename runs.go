@@ -0,0 +1,209 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/educlos/testrail"
+	"github.com/urfave/cli"
+
+	"github.com/docker/trailer/spec"
+)
+
+// parseAge reads an age such as 30d or 12h.
+func parseAge(age string) (time.Duration, error) {
+	if strings.HasSuffix(age, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(age, "d"))
+		if err != nil {
+			return 0, fmt.Errorf("invalid age %q", age)
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(age)
+}
+
+// staleRuns returns the open runs created before cutoff whose names match
+// one of the patterns, or any name if there are none.
+func staleRuns(runs []testrail.Run, cutoff time.Time, patterns []string) []testrail.Run {
+	stale := []testrail.Run{}
+	for _, r := range runs {
+		if r.IsCompleted || time.Unix(int64(r.CreatedOn), 0).After(cutoff) {
+			continue
+		}
+		matched := len(patterns) == 0
+		for _, p := range patterns {
+			if ok, _ := path.Match(p, r.Name); ok {
+				matched = true
+			}
+		}
+		if matched {
+			stale = append(stale, r)
+		}
+	}
+	return stale
+}
+
+// failedCases returns the cases of the tests whose latest status is neither
+// passed nor untested.
+func failedCases(tests []testrail.Test) []int {
+	passed := spec.DefaultStatusMap.ID(spec.Passed)
+	cases := []int{}
+	for _, t := range tests {
+		if t.StatusID != passed && t.StatusID != untestedStatus {
+			cases = append(cases, t.CaseID)
+		}
+	}
+	return cases
+}
+
+func runsCommand() cli.Command {
+	return cli.Command{
+		Name:  "runs",
+		Usage: "Manage the test runs of a TestRail project",
+		Subcommands: []cli.Command{
+			{
+				Name:  "close",
+				Usage: "Close the open runs older than an age, which archives them",
+				Flags: []cli.Flag{
+					cli.IntFlag{
+						Name:  "project-id, p",
+						Usage: "TestRail project ID of the runs",
+					},
+					cli.StringFlag{
+						Name:  "older-than",
+						Usage: "close runs created longer ago than this, such as 30d or 12h",
+					},
+					cli.StringSliceFlag{
+						Name:  "name, n",
+						Usage: "only close runs whose names match this pattern, such as \"CI build *\", may be repeated",
+					},
+					cli.BoolFlag{
+						Name:  "dry, d",
+						Usage: "list the runs without closing them",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					projectID := c.Int("project-id")
+					if projectID == 0 {
+						return configErrorf("Must set --project-id to a non-zero integer")
+					}
+					if c.String("older-than") == "" {
+						return configErrorf("Must set --older-than")
+					}
+					age, err := parseAge(c.String("older-than"))
+					if err != nil {
+						return configErrorf("Error parsing --older-than: %s", err)
+					}
+					for _, p := range c.StringSlice("name") {
+						if _, err := path.Match(p, ""); err != nil {
+							return configErrorf("Error in --name %q: %s", p, err)
+						}
+					}
+
+					client, err := newClient()
+					if err != nil {
+						return err
+					}
+					cutoff := time.Now().Add(-age)
+					open := false
+					runs, err := client.GetRuns(projectID, testrail.RequestFilterForRun{
+						IsCompleted:   &open,
+						CreatedBefore: strconv.FormatInt(cutoff.Unix(), 10),
+					})
+					if err != nil {
+						return apiErrorf("Error getting runs: %s", err)
+					}
+
+					stale := staleRuns(runs, cutoff, c.StringSlice("name"))
+					for _, r := range stale {
+						created := time.Unix(int64(r.CreatedOn), 0).Format("2006-01-02")
+						if c.Bool("dry") {
+							statusf("Would close run %d: %s (created %s)\n", r.ID, r.Name, created)
+							continue
+						}
+						if err := client.send("POST", fmt.Sprintf("close_run/%d", r.ID), nil, nil); err != nil {
+							return apiErrorf("Error closing run %d: %s", r.ID, err)
+						}
+						statusf("Closed run %d: %s (created %s)\n", r.ID, r.Name, created)
+					}
+					if len(stale) == 0 {
+						statusf("No open runs to close\n")
+					}
+					return nil
+				},
+			},
+			{
+				Name:  "clone",
+				Usage: "Add a run with the same cases as another run",
+				Flags: []cli.Flag{
+					cli.IntFlag{
+						Name:  "run-id, r",
+						Usage: "TestRail run ID to copy",
+					},
+					cli.StringFlag{
+						Name:  "name, n",
+						Usage: "name of the new run, defaults to the name of the copied run",
+					},
+					cli.BoolFlag{
+						Name:  "failed",
+						Usage: "only include the cases that were tested and did not pass",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					runID := c.Int("run-id")
+					if runID == 0 {
+						return configErrorf("Must set --run-id to a non-zero integer")
+					}
+
+					client, err := newClient()
+					if err != nil {
+						return err
+					}
+					var run testrail.Run
+					if err := client.send("GET", fmt.Sprintf("get_run/%d", runID), nil, &run); err != nil {
+						return apiErrorf("Error getting run %d: %s", runID, err)
+					}
+					tests, err := freshTests(client, runID)
+					if err != nil {
+						return apiErrorf("Error getting tests: %s", err)
+					}
+
+					cases := []int{}
+					if c.Bool("failed") {
+						cases = failedCases(tests)
+					} else {
+						for _, t := range tests {
+							cases = append(cases, t.CaseID)
+						}
+					}
+					// TestRail adds every case of the suite when there are none.
+					if len(cases) == 0 {
+						return configErrorf("Run %d has no cases to copy", runID)
+					}
+
+					name := c.String("name")
+					if name == "" {
+						name = run.Name
+					}
+					includeAll := false
+					var created testrail.Run
+					if err := client.send("POST", fmt.Sprintf("add_run/%d", run.ProjectID), testrail.SendableRun{
+						SuiteID:     run.SuiteID,
+						Name:        name,
+						Description: run.Description,
+						MilestoneID: run.MilestoneID,
+						IncludeAll:  &includeAll,
+						CaseIDs:     cases,
+					}, &created); err != nil {
+						return apiErrorf("Error adding run: %s", err)
+					}
+					statusf("Created run %d: %s with %d cases\n", created.ID, created.Name, len(cases))
+					return nil
+				},
+			},
+		},
+	}
+}
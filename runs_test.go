@@ -0,0 +1,135 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/educlos/testrail"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseAge(t *testing.T) {
+	for age, want := range map[string]time.Duration{
+		"30d": 30 * 24 * time.Hour,
+		"12h": 12 * time.Hour,
+		"90m": 90 * time.Minute,
+	} {
+		got, err := parseAge(age)
+		assert.NoError(t, err, age)
+		assert.Equal(t, want, got, age)
+	}
+	for _, bad := range []string{"", "d", "thirtyd", "30"} {
+		_, err := parseAge(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestRunsClose(t *testing.T) {
+	s, stop := startFake(t)
+	defer stop()
+
+	old := int(time.Now().Add(-60 * 24 * time.Hour).Unix())
+	s.AddRun(1, 2, 11)
+	s.AddRun(1, 2, 11)
+	s.AddRun(1, 2, 11)
+	s.AddRun(1, 2, 11)
+	s.Lock()
+	s.Runs[0].Name, s.Runs[0].CreatedOn = "CI build 1", old
+	s.Runs[1].Name, s.Runs[1].CreatedOn = "Release 1.0", old
+	s.Runs[2].Name = "CI build 2"
+	s.Runs[3].Name, s.Runs[3].CreatedOn, s.Runs[3].IsCompleted = "CI build 0", old, true
+	s.Unlock()
+
+	closed := func() []bool {
+		s.Lock()
+		defer s.Unlock()
+		var c []bool
+		for _, r := range s.Runs {
+			c = append(c, r.IsCompleted)
+		}
+		return c
+	}
+
+	assert.NoError(t, run("runs", "close", "--project-id", "1", "--older-than", "30d", "--name", "CI build *", "--dry"))
+	assert.Equal(t, []bool{false, false, false, true}, closed())
+
+	assert.NoError(t, run("runs", "close", "--project-id", "1", "--older-than", "30d", "--name", "CI build *"))
+	assert.Equal(t, []bool{true, false, false, true}, closed())
+
+	assert.NoError(t, run("runs", "close", "--project-id", "1", "--older-than", "30d"))
+	assert.Equal(t, []bool{true, true, false, true}, closed())
+
+	assert.Equal(t, exitConfig, exitCode(run("runs", "close", "--project-id", "1")))
+	assert.Equal(t, exitConfig, exitCode(run("runs", "close", "--project-id", "1", "--older-than", "soon")))
+	assert.Equal(t, exitConfig, exitCode(run("runs", "close", "--older-than", "30d")))
+}
+
+func TestStaleRuns(t *testing.T) {
+	cutoff := time.Unix(1000, 0)
+	runs := []testrail.Run{
+		{ID: 1, Name: "nightly", CreatedOn: 500},
+		{ID: 2, Name: "nightly", CreatedOn: 1500},
+		{ID: 3, Name: "CI 7", CreatedOn: 500},
+		{ID: 4, Name: "CI 8", CreatedOn: 500, IsCompleted: true},
+	}
+	ids := func(rs []testrail.Run) []int {
+		got := []int{}
+		for _, r := range rs {
+			got = append(got, r.ID)
+		}
+		return got
+	}
+	assert.Equal(t, []int{1, 3}, ids(staleRuns(runs, cutoff, nil)))
+	assert.Equal(t, []int{3}, ids(staleRuns(runs, cutoff, []string{"CI *"})))
+	assert.Equal(t, []int{1, 3}, ids(staleRuns(runs, cutoff, []string{"CI *", "night*"})))
+}
+
+func TestRunsClone(t *testing.T) {
+	s, stop := startFake(t)
+	defer stop()
+
+	dir, err := ioutil.TempDir("", "runs")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	s.Cases = append(s.Cases, testrail.Case{ID: 13, SuiteID: 2, SectionID: 3, Title: "Expiry"})
+	runID := strconv.Itoa(s.AddRun(1, 2, 11, 12, 13).ID)
+	report := writeReport(t, dir, `<testsuite name="accounts" tests="2">
+  <testcase name="TestRailC11 login" time="1"></testcase>
+  <testcase name="TestRailC13 expiry" time="1"><failure message="boom">never expires</failure></testcase>
+</testsuite>`)
+	assert.NoError(t, run("upload", "--run-id", runID, report))
+
+	cases := func(runID int) []int {
+		s.Lock()
+		defer s.Unlock()
+		ids := []int{}
+		for _, test := range s.Tests {
+			if test.RunID == runID {
+				ids = append(ids, test.CaseID)
+			}
+		}
+		return ids
+	}
+	last := func() testrail.Run {
+		s.Lock()
+		defer s.Unlock()
+		return s.Runs[len(s.Runs)-1]
+	}
+
+	assert.NoError(t, run("runs", "clone", "--run-id", runID))
+	copied := last()
+	assert.Equal(t, []int{11, 12, 13}, cases(copied.ID))
+
+	assert.NoError(t, run("runs", "clone", "--run-id", runID, "--failed", "--name", "Regression rerun"))
+	rerun := last()
+	assert.Equal(t, "Regression rerun", rerun.Name)
+	assert.Equal(t, []int{13}, cases(rerun.ID))
+
+	// Nothing has been tested in the copy yet.
+	assert.Equal(t, exitConfig, exitCode(run("runs", "clone", "--run-id", strconv.Itoa(copied.ID), "--failed")))
+	assert.Equal(t, exitConfig, exitCode(run("runs", "clone")))
+}
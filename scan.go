@@ -0,0 +1,287 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/urfave/cli"
+
+	"github.com/docker/trailer/pkg/download"
+	"github.com/docker/trailer/spec"
+)
+
+// annotationRegex matches the case IDs of an annotation such as
+// "testrail: C11, C12".
+var annotationRegex = regexp.MustCompile(`testrail:\s*(C?\d+(?:\s*,\s*C?\d+)*)`)
+
+// defaultScanPattern finds annotations in files of other languages than Go,
+// in a comment above a function whose name ends up in the report, as in
+// Python, Java or JavaScript. Annotations and decorators may come between.
+const defaultScanPattern = `testrail:\s*(?P<ids>C?\d+(?:\s*,\s*C?\d+)*)[^\n]*\n(?:[ \t]*(?:@|#|//)[^\n]*\n)*[ \t]*(?:(?:public|private|protected|static|async|void|def|function|func|fun)\s+)*(?P<test>\w+)\s*\(`
+
+// annotation is a test annotated with the cases it covers.
+type annotation struct {
+	Test string
+	IDs  []int
+	// Pos is the file and line of the annotation.
+	Pos string
+}
+
+// parseAnnotationIDs reads the case IDs of an annotation.
+func parseAnnotationIDs(list string) []int {
+	ids := []int{}
+	for _, id := range strings.Split(list, ",") {
+		n, err := strconv.Atoi(strings.TrimPrefix(strings.TrimSpace(id), "C"))
+		if err == nil {
+			ids = append(ids, n)
+		}
+	}
+	return ids
+}
+
+// scanGoFile returns the annotations in the doc comments of the test
+// functions of a Go test file.
+func scanGoFile(file string) ([]annotation, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, file, nil, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	annotations := []annotation{}
+	for _, decl := range f.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Recv != nil || fn.Doc == nil || !strings.HasPrefix(fn.Name.Name, "Test") {
+			continue
+		}
+		for _, c := range fn.Doc.List {
+			if m := annotationRegex.FindStringSubmatch(c.Text); m != nil {
+				annotations = append(annotations, annotation{
+					Test: fn.Name.Name,
+					IDs:  parseAnnotationIDs(m[1]),
+					Pos:  fset.Position(c.Pos()).String(),
+				})
+			}
+		}
+	}
+	return annotations, nil
+}
+
+// scanFile returns the annotations pattern finds in a file of another
+// language. The pattern has a test group with the name of the test and an
+// ids group with the case IDs.
+func scanFile(file string, pattern *regexp.Regexp) ([]annotation, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	annotations := []annotation{}
+	for _, m := range pattern.FindAllSubmatchIndex(data, -1) {
+		a := annotation{Pos: fmt.Sprintf("%s:%d", file, 1+strings.Count(string(data[:m[0]]), "\n"))}
+		for i, name := range pattern.SubexpNames() {
+			if m[2*i] < 0 {
+				continue
+			}
+			switch value := string(data[m[2*i]:m[2*i+1]]); name {
+			case "test":
+				a.Test = value
+			case "ids":
+				a.IDs = parseAnnotationIDs(value)
+			}
+		}
+		if a.Test != "" && len(a.IDs) > 0 {
+			annotations = append(annotations, a)
+		}
+	}
+	return annotations, nil
+}
+
+// scanFiles returns the files to scan for the paths, which like with go test
+// may end in /... to include the files of every directory below. Go test
+// files are always scanned, other files when their names match an include
+// pattern.
+func scanFiles(paths, include []string) ([]string, error) {
+	wanted := func(name string) bool {
+		if strings.HasSuffix(name, "_test.go") {
+			return true
+		}
+		for _, pattern := range include {
+			if ok, _ := filepath.Match(pattern, name); ok {
+				return true
+			}
+		}
+		return false
+	}
+
+	files := []string{}
+	for _, path := range paths {
+		recursive := path == "..." || strings.HasSuffix(path, "/...")
+		if recursive {
+			path = strings.TrimSuffix(strings.TrimSuffix(path, "..."), "/")
+			if path == "" {
+				path = "."
+			}
+		}
+
+		err := filepath.Walk(path, func(file string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() {
+				if file != path && (!recursive || info.Name() == "vendor" || info.Name() == "node_modules" || strings.HasPrefix(info.Name(), ".")) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if file == path || wanted(info.Name()) {
+				files = append(files, file)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+func scanCommand() cli.Command {
+	return cli.Command{
+		Name:      "scan",
+		Usage:     "Find testrail: C<ID> annotations of tests in source code and write them to a case mapping",
+		ArgsUsage: "[paths, such as ./...]",
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "case-map",
+				Usage: "case mapping file to update with the annotations, printed by default",
+			},
+			cli.StringSliceFlag{
+				Name:  "include",
+				Usage: "also scan files of other languages matching this pattern, such as *.py, may be repeated",
+			},
+			cli.StringFlag{
+				Name:  "pattern",
+				Usage: "regular expression finding annotations in the included files, with test and ids groups",
+				Value: defaultScanPattern,
+			},
+			cli.StringFlag{
+				Name:  "file, f",
+				Usage: "cases file to check the annotated cases exist in",
+			},
+			cli.IntFlag{
+				Name:  "project-id, p",
+				Usage: "TestRail project to check the annotated cases exist in, instead of --file",
+			},
+			cli.IntFlag{
+				Name:  "suite-id, s",
+				Usage: "TestRail suite to check the annotated cases exist in, every suite of the project by default",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			pattern, err := regexp.Compile(c.String("pattern"))
+			if err != nil {
+				return configErrorf("Error in --pattern: %s", err)
+			}
+			paths := c.Args()
+			if len(paths) == 0 {
+				paths = []string{"./..."}
+			}
+			files, err := scanFiles(paths, c.StringSlice("include"))
+			if err != nil {
+				return configErrorf("Error listing files: %s", err)
+			}
+
+			annotations := []annotation{}
+			for _, file := range files {
+				var found []annotation
+				if strings.HasSuffix(file, ".go") {
+					found, err = scanGoFile(file)
+				} else {
+					found, err = scanFile(file, pattern)
+				}
+				if err != nil {
+					return parseErrorf("Error scanning %s: %s", file, err)
+				}
+				annotations = append(annotations, found...)
+			}
+
+			// Check the cases exist before anything is written.
+			var known map[int]bool
+			if file := c.String("file"); file != "" {
+				s, err := download.Load(file)
+				if err != nil {
+					return parseErrorf("Error reading cases file: %s", err)
+				}
+				known = map[int]bool{}
+				for id := range s.Cases {
+					known[id] = true
+				}
+			} else if c.Int("project-id") != 0 {
+				client, err := newClient()
+				if err != nil {
+					return err
+				}
+				cases, err := searchableCases(client, c.Int("project-id"), c.Int("suite-id"))
+				if err != nil {
+					return apiErrorf("Error getting cases: %s", err)
+				}
+				known = map[int]bool{}
+				for _, cs := range cases {
+					known[cs.ID] = true
+				}
+			}
+			unknown := []string{}
+			for _, a := range annotations {
+				for _, id := range a.IDs {
+					if known != nil && !known[id] {
+						unknown = append(unknown, fmt.Sprintf("%s: %s is annotated with C%d, which is not a case", a.Pos, a.Test, id))
+					}
+				}
+			}
+			if len(unknown) > 0 {
+				return exitError{code: exitMismatch, err: fmt.Errorf("%d annotations name unknown cases:\n%s", len(unknown), strings.Join(unknown, "\n"))}
+			}
+
+			caseMap := spec.CaseMap{}
+			if file := c.String("case-map"); file != "" {
+				if _, err := os.Stat(file); err == nil {
+					if caseMap, err = spec.LoadCaseMap(file); err != nil {
+						return parseErrorf("Error reading case map: %s", err)
+					}
+				}
+			}
+			scanned := spec.CaseMap{}
+			for _, a := range annotations {
+				scanned[a.Test] = append(scanned[a.Test], a.IDs...)
+			}
+			for test, ids := range scanned {
+				caseMap[test] = ids
+			}
+
+			data, err := spec.EncodeCaseMap(caseMap)
+			if err != nil {
+				return fmt.Errorf("Error encoding case map: %s", err)
+			}
+			if c.String("case-map") == "" {
+				os.Stdout.Write(data)
+				return nil
+			}
+			if err := download.WriteFile(c.String("case-map"), data); err != nil {
+				return fmt.Errorf("Error writing case map: %s", err)
+			}
+			statusf("Mapped %d annotated tests in %d files\n", len(scanned), len(files))
+			return nil
+		},
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/docker/trailer/spec"
+)
+
+func TestScanFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "scan")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	for _, tc := range []struct {
+		name, source string
+		want         []annotation
+	}{
+		{
+			name: "test_accounts.py",
+			source: `# testrail: C11
+def test_login():
+    pass
+
+# testrail: C12, C13
+@pytest.mark.slow
+def test_logout():
+    pass
+`,
+			want: []annotation{{Test: "test_login", IDs: []int{11}, Pos: "test_accounts.py:1"}, {Test: "test_logout", IDs: []int{12, 13}, Pos: "test_accounts.py:5"}},
+		},
+		{
+			name: "AccountsTest.java",
+			source: `class AccountsTest {
+    // testrail: C11
+    @Test
+    public void testLogin() {}
+}
+`,
+			want: []annotation{{Test: "testLogin", IDs: []int{11}, Pos: "AccountsTest.java:2"}},
+		},
+	} {
+		file := filepath.Join(dir, tc.name)
+		assert.NoError(t, ioutil.WriteFile(file, []byte(tc.source), 0644))
+		found, err := scanFile(file, regexp.MustCompile(defaultScanPattern))
+		assert.NoError(t, err)
+		for i := range tc.want {
+			tc.want[i].Pos = filepath.Join(dir, tc.want[i].Pos)
+		}
+		assert.Equal(t, tc.want, found, tc.name)
+	}
+}
+
+func TestScan(t *testing.T) {
+	_, stop := startFake(t)
+	defer stop()
+
+	dir, err := ioutil.TempDir("", "scan")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	write := func(name, body string) {
+		file := filepath.Join(dir, name)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(file), 0755))
+		assert.NoError(t, ioutil.WriteFile(file, []byte(body), 0644))
+	}
+	write("e2e/accounts_test.go", `package e2e
+
+import "testing"
+
+// TestLogin signs in.
+// testrail: C11
+func TestLogin(t *testing.T) {}
+
+// testrail: C12
+func TestLogout(t *testing.T) {}
+
+// testrail: C99
+func helper() {}
+`)
+	write("e2e/vendor/lib/lib_test.go", "package lib\n\n// testrail: C98\nfunc TestLib(t *testing.T) {}\n")
+	write("e2e/signup.py", "# testrail: C13\ndef test_signup():\n    pass\n")
+
+	caseMap := filepath.Join(dir, "cases.yaml")
+	write("cases.yaml", "tests:\n  TestRefund: 14\n  TestLogin: 10\n")
+	assert.NoError(t, run("scan", "--case-map", caseMap, filepath.Join(dir, "e2e")+"/..."))
+	m, err := spec.LoadCaseMap(caseMap)
+	assert.NoError(t, err)
+	assert.Equal(t, spec.CaseMap{"TestLogin": {11}, "TestLogout": {12}, "TestRefund": {14}}, m)
+
+	assert.NoError(t, run("scan", "--case-map", caseMap, "--include", "*.py", filepath.Join(dir, "e2e")))
+	m, err = spec.LoadCaseMap(caseMap)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{13}, m["test_signup"])
+
+	// C13 is not a case of the project.
+	assert.Equal(t, exitMismatch, exitCode(run("scan", "--case-map", caseMap, "--include", "*.py", "--project-id", "1", filepath.Join(dir, "e2e"))))
+	assert.NoError(t, run("scan", "--case-map", caseMap, "--project-id", "1", filepath.Join(dir, "e2e")))
+	assert.Equal(t, exitConfig, exitCode(run("scan", "--pattern", "(", dir)))
+}
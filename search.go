@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/urfave/cli"
+
+	"github.com/docker/trailer/pkg/download"
+)
+
+// minSearchScore is the similarity below which cases are not listed.
+const minSearchScore = 0.5
+
+// caseMatch is a case whose title matches a search.
+type caseMatch struct {
+	ID      int     `json:"id"`
+	Section string  `json:"section,omitempty"`
+	Title   string  `json:"title"`
+	Score   float64 `json:"score"`
+}
+
+// rankCases returns the cases whose titles match query best, at most limit
+// of them if limit is positive.
+func rankCases(query string, cases []caseMatch, limit int) []caseMatch {
+	matches := []caseMatch{}
+	for _, c := range cases {
+		c.Score = similarity(query, c.Title)
+		if c.Score >= minSearchScore {
+			matches = append(matches, c)
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return matches[i].ID < matches[j].ID
+	})
+	if limit > 0 && len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches
+}
+
+// searchableCases returns the cases of the suite, or of every suite of the
+// project if suiteID is 0, with their section paths.
+func searchableCases(client testrailAPI, projectID, suiteID int) ([]caseMatch, error) {
+	suiteIDs := []int{suiteID}
+	if suiteID == 0 {
+		suites, err := client.GetSuites(projectID)
+		if err != nil {
+			return nil, fmt.Errorf("getting suites: %s", err)
+		}
+		suiteIDs = nil
+		for _, s := range suites {
+			suiteIDs = append(suiteIDs, s.ID)
+		}
+	}
+
+	all := []caseMatch{}
+	for _, id := range suiteIDs {
+		sections, err := client.GetSections(projectID, id)
+		if err != nil {
+			return nil, fmt.Errorf("getting sections of suite %d: %s", id, err)
+		}
+		cases, err := client.GetCases(projectID, id)
+		if err != nil {
+			return nil, fmt.Errorf("getting cases of suite %d: %s", id, err)
+		}
+		paths := sectionPaths(sections)
+		for _, c := range cases {
+			all = append(all, caseMatch{ID: c.ID, Section: sectionKey(paths[c.SectionID]), Title: c.Title})
+		}
+	}
+	return all, nil
+}
+
+func searchCommand() cli.Command {
+	return cli.Command{
+		Name:      "search",
+		Usage:     "Find cases by title, allowing for typos and other word orders",
+		ArgsUsage: "QUERY",
+		Flags: []cli.Flag{
+			cli.IntFlag{
+				Name:  "project-id, p",
+				Usage: "TestRail project to search",
+			},
+			cli.IntFlag{
+				Name:  "suite-id, s",
+				Usage: "TestRail suite to search, every suite of the project by default",
+			},
+			cli.StringFlag{
+				Name:  "file, f",
+				Usage: "search this cases file instead of TestRail",
+			},
+			cli.IntFlag{
+				Name:  "limit, n",
+				Usage: "list at most this many cases, 0 lists every match",
+				Value: 10,
+			},
+			outputFlag,
+		},
+		Action: func(c *cli.Context) error {
+			if c.NArg() == 0 {
+				return configErrorf("Must specify what to search for")
+			}
+			query := c.Args().First()
+			for _, arg := range c.Args().Tail() {
+				query += " " + arg
+			}
+
+			var cases []caseMatch
+			if file := c.String("file"); file != "" {
+				s, err := download.Load(file)
+				if err != nil {
+					return parseErrorf("Error reading cases file: %s", err)
+				}
+				for id, e := range s.Cases {
+					cases = append(cases, caseMatch{ID: id, Title: e.Title})
+				}
+			} else {
+				if c.Int("project-id") == 0 {
+					return configErrorf("Must set --project-id to a non-zero integer, or --file")
+				}
+				client, err := newClient()
+				if err != nil {
+					return err
+				}
+				if cases, err = searchableCases(client, c.Int("project-id"), c.Int("suite-id")); err != nil {
+					return apiErrorf("Error getting cases: %s", err)
+				}
+			}
+
+			matches := rankCases(query, cases, c.Int("limit"))
+			rows := [][]string{}
+			for _, m := range matches {
+				rows = append(rows, []string{fmt.Sprintf("C%d", m.ID), m.Section, m.Title, strconv.FormatFloat(m.Score, 'f', 2, 64)})
+			}
+			if err := render(c.String("output"), matches, []string{"CASE", "SECTION", "TITLE", "SCORE"}, rows); err != nil {
+				return fmt.Errorf("Error printing cases: %s", err)
+			}
+			return nil
+		},
+	}
+}
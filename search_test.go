@@ -0,0 +1,54 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSimilarity(t *testing.T) {
+	for _, tc := range []struct {
+		query, text string
+		min, max    float64
+	}{
+		{query: "login timeout", text: "Login times out after 5 minutes", min: 0.75, max: 0.9},
+		{query: "timeout login", text: "Login timeout", min: 1, max: 1},
+		{query: "logn", text: "Login", min: 0.7, max: 0.9},
+		{query: "TestLoginTimeout", text: "Login timeout", min: 0.6, max: 0.7},
+		{query: "refund", text: "Login", min: 0, max: 0},
+		{query: "", text: "Login", min: 0, max: 0},
+	} {
+		s := similarity(tc.query, tc.text)
+		assert.True(t, s >= tc.min && s <= tc.max, "%q in %q scored %v", tc.query, tc.text, s)
+	}
+	assert.Equal(t, []string{"test", "login", "timeout", "c11"}, words("TestLoginTimeout (C11)"))
+}
+
+func TestRankCases(t *testing.T) {
+	cases := []caseMatch{{ID: 11, Title: "Login"}, {ID: 12, Title: "Logout"}, {ID: 13, Title: "Login with SSO"}, {ID: 14, Title: "Refund"}}
+	matches := rankCases("login", cases, 2)
+	assert.Equal(t, []int{11, 13}, []int{matches[0].ID, matches[1].ID})
+	assert.Len(t, rankCases("login", cases, 0), 3)
+}
+
+func TestSearch(t *testing.T) {
+	_, stop := startFake(t)
+	defer stop()
+
+	dir, err := ioutil.TempDir("", "search")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	assert.NoError(t, run("search", "--project-id", "1", "logn"))
+	assert.NoError(t, run("search", "--project-id", "1", "--suite-id", "2", "--output", "json", "log", "out"))
+
+	file := filepath.Join(dir, "cases.yaml")
+	assert.NoError(t, ioutil.WriteFile(file, []byte("project_id: 1\nsuite_id: 2\ncases:\n  11: Login\n"), 0644))
+	assert.NoError(t, run("search", "--file", file, "login"))
+
+	assert.Equal(t, exitConfig, exitCode(run("search", "login")))
+	assert.Equal(t, exitConfig, exitCode(run("search", "--project-id", "1")))
+}
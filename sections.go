@@ -0,0 +1,208 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/educlos/testrail"
+	"github.com/urfave/cli"
+)
+
+// moveSection holds the parameters of the move_section endpoint, which the
+// testrail package does not wrap.
+type moveSection struct {
+	ParentID *int `json:"parent_id"`
+	AfterID  *int `json:"after_id"`
+}
+
+func sectionsCommand() cli.Command {
+	suiteFlags := []cli.Flag{
+		cli.IntFlag{
+			Name:  "project-id, p",
+			Usage: "TestRail project ID the sections belong to",
+		},
+		cli.IntFlag{
+			Name:  "suite-id, s",
+			Usage: "TestRail suite ID the sections belong to",
+		},
+	}
+
+	return cli.Command{
+		Name:  "sections",
+		Usage: "Manage the section tree of a TestRail suite",
+		Subcommands: []cli.Command{
+			{
+				Name:  "list",
+				Usage: "List the sections of a suite",
+				Flags: append(suiteFlags, outputFlag),
+				Action: func(c *cli.Context) error {
+					projectID, suiteID, err := requireSuite(c)
+					if err != nil {
+						return err
+					}
+
+					client, err := newClient()
+					if err != nil {
+						return err
+					}
+					sections, err := client.GetSections(projectID, suiteID)
+					if err != nil {
+						return apiErrorf("Error getting sections: %s", err)
+					}
+
+					rows := [][]string{}
+					for _, s := range sections {
+						rows = append(rows, []string{
+							strconv.Itoa(s.ID),
+							strings.Repeat("  ", s.Depth) + s.Name,
+							strconv.Itoa(s.ParentID),
+						})
+					}
+
+					err = render(c.String("output"), sections, []string{"ID", "NAME", "PARENT"}, rows)
+					if err != nil {
+						return fmt.Errorf("Error printing sections: %s", err)
+					}
+
+					return nil
+				},
+			},
+			{
+				Name:  "create",
+				Usage: "Create a section",
+				Flags: append(suiteFlags,
+					cli.StringFlag{
+						Name:  "name, n",
+						Usage: "name of the section",
+					},
+					cli.StringFlag{
+						Name:  "description",
+						Usage: "description of the section",
+					},
+					cli.IntFlag{
+						Name:  "parent-id",
+						Usage: "ID of the section to create this one under",
+					},
+				),
+				Action: func(c *cli.Context) error {
+					projectID, suiteID, err := requireSuite(c)
+					if err != nil {
+						return err
+					}
+
+					if c.String("name") == "" {
+						return configErrorf("Must set --name")
+					}
+
+					client, err := newClient()
+					if err != nil {
+						return err
+					}
+					section, err := client.AddSection(projectID, testrail.SendableSection{
+						Name:        c.String("name"),
+						Description: c.String("description"),
+						SuiteID:     suiteID,
+						ParentID:    c.Int("parent-id"),
+					})
+					if err != nil {
+						return apiErrorf("Error creating section: %s", err)
+					}
+
+					statusf("Created section %d: %s\n", section.ID, section.Name)
+					return nil
+				},
+			},
+			{
+				Name:      "move",
+				Usage:     "Move a section under a new parent or after a sibling",
+				ArgsUsage: "[section ID]",
+				Flags: []cli.Flag{
+					cli.IntFlag{
+						Name:  "parent-id",
+						Usage: "ID of the new parent section, 0 moves it to the root",
+					},
+					cli.IntFlag{
+						Name:  "after-id",
+						Usage: "ID of the sibling to place the section after, 0 moves it first",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					if c.NArg() != 1 {
+						return configErrorf("Must specify exactly one section ID")
+					}
+					sectionID, err := strconv.Atoi(c.Args().First())
+					if err != nil {
+						return configErrorf("Cannot convert string to int: %s", err)
+					}
+
+					move := moveSection{}
+					if parentID := c.Int("parent-id"); parentID != 0 {
+						move.ParentID = &parentID
+					}
+					if afterID := c.Int("after-id"); afterID != 0 {
+						move.AfterID = &afterID
+					}
+
+					var section testrail.Section
+					client, err := newClient()
+					if err != nil {
+						return err
+					}
+					err = client.send("POST", fmt.Sprintf("move_section/%d", sectionID), move, &section)
+					if err != nil {
+						return apiErrorf("Error moving section: %s", err)
+					}
+
+					statusf("Moved section %d: %s\n", section.ID, section.Name)
+					return nil
+				},
+			},
+			{
+				Name:      "delete",
+				Usage:     "Delete sections along with their cases",
+				ArgsUsage: "[section IDs...]",
+				Action: func(c *cli.Context) error {
+					if c.NArg() == 0 {
+						return configErrorf("Must specify at least one section ID")
+					}
+
+					client, err := newClient()
+					if err != nil {
+						return err
+					}
+					for _, arg := range c.Args() {
+						id, err := strconv.Atoi(arg)
+						if err != nil {
+							return configErrorf("Cannot convert string to int: %s", err)
+						}
+
+						if err := client.DeleteSection(id); err != nil {
+							return apiErrorf("Error deleting section %d: %s", id, err)
+						}
+
+						statusf("Deleted section %d\n", id)
+					}
+
+					return nil
+				},
+			},
+		},
+	}
+}
+
+// requireSuite returns the --project-id and --suite-id flags, failing if
+// either is unset.
+func requireSuite(c *cli.Context) (int, int, error) {
+	projectID := c.Int("project-id")
+	if projectID == 0 {
+		return 0, 0, configErrorf("Must set --project-id to a non-zero integer")
+	}
+
+	suiteID := c.Int("suite-id")
+	if suiteID == 0 {
+		return 0, 0, configErrorf("Must set --suite-id to a non-zero integer")
+	}
+
+	return projectID, suiteID, nil
+}
@@ -0,0 +1,501 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/urfave/cli"
+
+	"github.com/docker/trailer/spec"
+)
+
+// webhookServer receives CI webhooks about finished pipelines, fetches the
+// reports they produced and uploads them to TestRail.
+type webhookServer struct {
+	client     testrailAPI
+	httpClient *http.Client
+
+	// ctx cancels the uploads that run after the webhook was answered.
+	ctx context.Context
+
+	runID    int
+	retries  int
+	comment  string
+	statuses spec.StatusMap
+	pattern  string
+	format   string
+
+	githubSecret string
+	githubToken  string
+	githubAPI    *url.URL
+	gitlabSecret string
+	gitlabToken  string
+	gitlabURL    string
+}
+
+// githubWorkflowRun is the part of a GitHub workflow_run event trailer uses.
+type githubWorkflowRun struct {
+	Action      string `json:"action"`
+	WorkflowRun struct {
+		ID           int    `json:"id"`
+		Name         string `json:"name"`
+		Conclusion   string `json:"conclusion"`
+		HTMLURL      string `json:"html_url"`
+		ArtifactsURL string `json:"artifacts_url"`
+	} `json:"workflow_run"`
+}
+
+// gitlabPipeline is the part of a GitLab pipeline event trailer uses.
+type gitlabPipeline struct {
+	ObjectKind       string `json:"object_kind"`
+	ObjectAttributes struct {
+		ID     int    `json:"id"`
+		Status string `json:"status"`
+		URL    string `json:"url"`
+	} `json:"object_attributes"`
+	Project struct {
+		ID     int    `json:"id"`
+		WebURL string `json:"web_url"`
+	} `json:"project"`
+	Builds []struct {
+		ID            int    `json:"id"`
+		Name          string `json:"name"`
+		ArtifactsFile struct {
+			Filename string `json:"filename"`
+		} `json:"artifacts_file"`
+	} `json:"builds"`
+}
+
+func serveCommand() cli.Command {
+	return cli.Command{
+		Name:  "serve",
+		Usage: "Listen for GitHub and GitLab webhooks and upload the reports of finished pipelines",
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "listen, l",
+				Usage: "address to listen on",
+				Value: ":8080",
+			},
+			cli.IntFlag{
+				Name:  "run-id, r",
+				Usage: "TestRail run ID to upload to, a run_id query parameter on the webhook URL overrides it",
+			},
+			cli.StringFlag{
+				Name:  "comment, c",
+				Usage: "prefix to use when commenting on TestRail updates",
+			},
+			cli.StringFlag{
+				Name:  "status-map",
+				Usage: "YAML file mapping test outcomes to TestRail status IDs",
+			},
+			cli.IntFlag{
+				Name:  "ignore-failures, i",
+				Usage: "ignore failures and retry this number of times",
+				Value: 1,
+			},
+			cli.StringFlag{
+				Name:  "artifact-pattern",
+				Usage: "only fetch reports from artifacts whose name matches this glob",
+				Value: "*",
+			},
+			cli.StringFlag{
+				Name:   "github-secret",
+				Usage:  "secret used to sign GitHub webhooks",
+				EnvVar: "GITHUB_WEBHOOK_SECRET",
+			},
+			cli.StringFlag{
+				Name:   "github-token",
+				Usage:  "token used to download GitHub Actions artifacts",
+				EnvVar: "GITHUB_TOKEN",
+			},
+			cli.StringFlag{
+				Name:  "github-api-url",
+				Usage: "base URL of the GitHub API, the only host artifacts are downloaded from",
+				Value: "https://api.github.com",
+			},
+			cli.StringFlag{
+				Name:   "gitlab-secret",
+				Usage:  "secret token configured on GitLab webhooks",
+				EnvVar: "GITLAB_WEBHOOK_SECRET",
+			},
+			cli.StringFlag{
+				Name:   "gitlab-token",
+				Usage:  "token used to download GitLab job artifacts",
+				EnvVar: "GITLAB_TOKEN",
+			},
+			cli.StringFlag{
+				Name:  "gitlab-url",
+				Usage: "base URL of the GitLab instance",
+				Value: "https://gitlab.com",
+			},
+			formatFlag,
+		},
+		Action: func(c *cli.Context) error {
+			// Unsigned webhooks would let anyone upload results, and make
+			// trailer download artifacts with its tokens, so only the
+			// providers with a secret are served.
+			if c.String("github-secret") == "" && c.String("gitlab-secret") == "" {
+				return configErrorf("Must set --github-secret, --gitlab-secret or both")
+			}
+			githubAPI, err := url.Parse(c.String("github-api-url"))
+			if err != nil || githubAPI.Host == "" {
+				return configErrorf("Invalid --github-api-url %q", c.String("github-api-url"))
+			}
+
+			client, err := newClient()
+			if err != nil {
+				return err
+			}
+
+			ctx := commandContext(c)
+			s := &webhookServer{
+				client:       client,
+				httpClient:   &http.Client{},
+				ctx:          ctx,
+				runID:        c.Int("run-id"),
+				retries:      c.Int("ignore-failures"),
+				comment:      c.String("comment"),
+				pattern:      c.String("artifact-pattern"),
+				format:       c.String("format"),
+				githubSecret: c.String("github-secret"),
+				githubToken:  c.String("github-token"),
+				githubAPI:    githubAPI,
+				gitlabSecret: c.String("gitlab-secret"),
+				gitlabToken:  c.String("gitlab-token"),
+				gitlabURL:    strings.TrimSuffix(c.String("gitlab-url"), "/"),
+			}
+
+			if file := c.String("status-map"); file != "" {
+				if s.statuses, err = spec.LoadStatusMap(file); err != nil {
+					return configErrorf("Failed to load status map: %s", err)
+				}
+			}
+
+			srv := &http.Server{Addr: c.String("listen"), Handler: s.handler()}
+			go func() {
+				<-ctx.Done()
+				slog.Info("Shutting down")
+				srv.Shutdown(context.Background())
+			}()
+
+			slog.Info("Listening for webhooks", "addr", c.String("listen"))
+			if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+				return err
+			}
+			return nil
+		},
+	}
+}
+
+// handler serves the webhooks of the providers with a secret, the others are
+// not found.
+func (s *webhookServer) handler() http.Handler {
+	mux := http.NewServeMux()
+	if s.githubSecret != "" {
+		mux.HandleFunc("/github", s.handleGitHub)
+	}
+	if s.gitlabSecret != "" {
+		mux.HandleFunc("/gitlab", s.handleGitLab)
+	}
+	mux.Handle("/metrics", metricsRegistry)
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "ok")
+	})
+	return mux
+}
+
+// targetRun returns the run ID from the run_id query parameter, falling back
+// to the --run-id flag.
+func (s *webhookServer) targetRun(r *http.Request) (int, error) {
+	if v := r.URL.Query().Get("run_id"); v != "" {
+		return strconv.Atoi(v)
+	}
+	if s.runID == 0 {
+		return 0, fmt.Errorf("no run_id query parameter and no --run-id set")
+	}
+	return s.runID, nil
+}
+
+// maxWebhookBody is the largest webhook body read, the payload limit of
+// GitHub. Bodies are read before they are authenticated.
+const maxWebhookBody = 25 << 20
+
+func (s *webhookServer) handleGitHub(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBody))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if !validGitHubSignature(s.githubSecret, body, r.Header.Get("X-Hub-Signature-256")) {
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	if r.Header.Get("X-GitHub-Event") != "workflow_run" {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	var event githubWorkflowRun
+	if err := json.Unmarshal(body, &event); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if event.Action != "completed" {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	runID, err := s.targetRun(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// CI providers time out webhook deliveries quickly, so the artifacts
+	// are fetched and uploaded after responding.
+	w.WriteHeader(http.StatusAccepted)
+	go func() {
+		run := event.WorkflowRun
+		if err := s.processGitHub(s.ctx, runID, event); err != nil {
+			slog.Error("Error processing GitHub workflow run", "workflow_run", run.ID, "error", err)
+		}
+	}()
+}
+
+func (s *webhookServer) processGitHub(ctx context.Context, runID int, event githubWorkflowRun) error {
+	var list struct {
+		Artifacts []struct {
+			Name               string `json:"name"`
+			Expired            bool   `json:"expired"`
+			ArchiveDownloadURL string `json:"archive_download_url"`
+		} `json:"artifacts"`
+	}
+
+	auth := map[string]string{"Authorization": "Bearer " + s.githubToken}
+	if err := s.checkGitHubURL(event.WorkflowRun.ArtifactsURL); err != nil {
+		return err
+	}
+	data, err := s.fetch(ctx, event.WorkflowRun.ArtifactsURL, auth)
+	if err != nil {
+		return fmt.Errorf("listing artifacts: %s", err)
+	}
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("decoding artifacts: %s", err)
+	}
+
+	suites := []spec.JUnitTestSuites{}
+	for _, artifact := range list.Artifacts {
+		if artifact.Expired || !s.matches(artifact.Name) {
+			continue
+		}
+		if err := s.checkGitHubURL(artifact.ArchiveDownloadURL); err != nil {
+			return err
+		}
+		archive, err := s.fetch(ctx, artifact.ArchiveDownloadURL, auth)
+		if err != nil {
+			return fmt.Errorf("downloading artifact %s: %s", artifact.Name, err)
+		}
+		found, err := reportsFromZip(ctx, archive, s.format)
+		if err != nil {
+			return fmt.Errorf("reading artifact %s: %s", artifact.Name, err)
+		}
+		suites = append(suites, found)
+	}
+
+	comment := strings.TrimSpace(fmt.Sprintf("%s %s", s.comment, event.WorkflowRun.HTMLURL))
+	return s.upload(ctx, runID, comment, suites)
+}
+
+func (s *webhookServer) handleGitLab(w http.ResponseWriter, r *http.Request) {
+	if !hmac.Equal([]byte(r.Header.Get("X-Gitlab-Token")), []byte(s.gitlabSecret)) {
+		http.Error(w, "invalid token", http.StatusUnauthorized)
+		return
+	}
+
+	var event gitlabPipeline
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxWebhookBody)).Decode(&event); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	status := event.ObjectAttributes.Status
+	if event.ObjectKind != "pipeline" || (status != "success" && status != "failed") {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	runID, err := s.targetRun(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	go func() {
+		if err := s.processGitLab(s.ctx, runID, event); err != nil {
+			slog.Error("Error processing GitLab pipeline", "pipeline", event.ObjectAttributes.ID, "error", err)
+		}
+	}()
+}
+
+func (s *webhookServer) processGitLab(ctx context.Context, runID int, event gitlabPipeline) error {
+	auth := map[string]string{"PRIVATE-TOKEN": s.gitlabToken}
+
+	suites := []spec.JUnitTestSuites{}
+	for _, build := range event.Builds {
+		if build.ArtifactsFile.Filename == "" || !s.matches(build.Name) {
+			continue
+		}
+		url := fmt.Sprintf("%s/api/v4/projects/%d/jobs/%d/artifacts", s.gitlabURL, event.Project.ID, build.ID)
+		archive, err := s.fetch(ctx, url, auth)
+		if err != nil {
+			return fmt.Errorf("downloading artifacts of job %s: %s", build.Name, err)
+		}
+		found, err := reportsFromZip(ctx, archive, s.format)
+		if err != nil {
+			return fmt.Errorf("reading artifacts of job %s: %s", build.Name, err)
+		}
+		suites = append(suites, found)
+	}
+
+	comment := strings.TrimSpace(fmt.Sprintf("%s %s", s.comment, event.ObjectAttributes.URL))
+	return s.upload(ctx, runID, comment, suites)
+}
+
+// checkGitHubURL returns an error unless raw points to the GitHub API, so the
+// token is never sent to a host named by a webhook payload.
+func (s *webhookServer) checkGitHubURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid artifact URL %q: %s", raw, err)
+	}
+	if u.Scheme != s.githubAPI.Scheme || u.Host != s.githubAPI.Host {
+		return fmt.Errorf("refusing to download artifacts from %s, which is not %s", u.Host, s.githubAPI.Host)
+	}
+	return nil
+}
+
+func (s *webhookServer) matches(name string) bool {
+	ok, err := path.Match(s.pattern, name)
+	return err == nil && ok
+}
+
+func (s *webhookServer) fetch(ctx context.Context, url string, headers map[string]string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return nil, fmt.Errorf("status %q", resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+func (s *webhookServer) upload(ctx context.Context, runID int, comment string, found []spec.JUnitTestSuites) error {
+	updates := spec.Updates{
+		ResultMap: map[int]spec.Update{},
+		Statuses:  s.statuses,
+	}
+	for _, suites := range found {
+		if err := updates.AddSuites(comment, suites); err != nil {
+			return err
+		}
+	}
+
+	if len(updates.ResultMap) == 0 {
+		slog.Warn("No TestRail results found", "run", runID)
+		return nil
+	}
+
+	slog.Info("Uploading results", "results", len(updates.ResultMap), "run", runID)
+	return uploadResults(ctx, s.client, runID, s.retries, &updates)
+}
+
+// validGitHubSignature checks the X-Hub-Signature-256 header of a GitHub
+// webhook delivery against the shared secret.
+func validGitHubSignature(secret string, body []byte, signature string) bool {
+	if !strings.HasPrefix(signature, "sha256=") {
+		return false
+	}
+	expected, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
+// reportsFromZip parses the reports in a zip archive. Without a format, the
+// files are the ones a parser detects, like upload does for its reports;
+// with one, every file is parsed as that format and those that fail are
+// skipped.
+func reportsFromZip(ctx context.Context, archive []byte, format string) (spec.JUnitTestSuites, error) {
+	suites := spec.JUnitTestSuites{}
+
+	r, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		return suites, err
+	}
+
+	for _, f := range r.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			return suites, err
+		}
+		data, err := ioutil.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return suites, err
+		}
+		if format == "" && spec.Detect(data) == "" {
+			slog.Debug("Skipping file that is not a report", "file", f.Name)
+			continue
+		}
+
+		parsed, err := spec.ParseBytesContext(ctx, f.Name, format, data)
+		if err != nil {
+			slog.Warn("Skipping report", "file", f.Name, "error", err)
+			continue
+		}
+		suites.Suites = append(suites.Suites, parsed...)
+	}
+
+	return suites, nil
+}
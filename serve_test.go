@@ -0,0 +1,135 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidGitHubSignature(t *testing.T) {
+	body := []byte(`{"action":"completed"}`)
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write(body)
+	signature := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	assert.True(t, validGitHubSignature("secret", body, signature))
+	assert.False(t, validGitHubSignature("other", body, signature))
+	assert.False(t, validGitHubSignature("secret", []byte(`{}`), signature))
+	assert.False(t, validGitHubSignature("secret", body, ""))
+}
+
+func TestReportsFromZip(t *testing.T) {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	files := map[string]string{
+		"reports/unit.xml": `<testsuite name="unit"><testcase name="TestRailC1 a"></testcase></testsuite>`,
+		"reports/e2e.xml":  `<testsuites><testsuite name="e2e"><testcase name="TestRailC2 b"></testcase></testsuite></testsuites>`,
+		"reports/bad.xml":  `<html></html>`,
+		"reports/checkout.json": `[{"name": "checkout", "elements": [
+  {"name": "pay", "type": "scenario", "tags": [{"name": "@TestRailC3"}], "steps": [{"keyword": "When ", "name": "I pay", "result": {"status": "passed"}}]}
+]}]`,
+		"coverage.txt": `mode: set`,
+	}
+	for name, content := range files {
+		f, err := w.Create(name)
+		assert.NoError(t, err)
+		f.Write([]byte(content))
+	}
+	assert.NoError(t, w.Close())
+
+	suites, err := reportsFromZip(context.Background(), buf.Bytes(), "")
+	assert.NoError(t, err)
+	names := []string{}
+	for _, suite := range suites.Suites {
+		names = append(names, suite.Name)
+	}
+	assert.ElementsMatch(t, []string{"unit", "e2e", "checkout"}, names)
+
+	// With a format, the files that are not reports of it are skipped.
+	suites, err = reportsFromZip(context.Background(), buf.Bytes(), "cucumber")
+	assert.NoError(t, err)
+	if assert.Len(t, suites.Suites, 1) {
+		assert.Equal(t, "checkout", suites.Suites[0].Name)
+	}
+}
+
+func TestServeRequiresSecrets(t *testing.T) {
+	_, stop := startFake(t)
+	defer stop()
+
+	assert.Equal(t, exitConfig, exitCode(run("serve", "--run-id", "1")))
+}
+
+func TestServeOnlyProvidersWithSecrets(t *testing.T) {
+	testcases := []struct {
+		githubSecret string
+		gitlabSecret string
+		github       int
+		gitlab       int
+	}{
+		{githubSecret: "s", github: http.StatusUnauthorized, gitlab: http.StatusNotFound},
+		{gitlabSecret: "s", github: http.StatusNotFound, gitlab: http.StatusUnauthorized},
+		{githubSecret: "s", gitlabSecret: "s", github: http.StatusUnauthorized, gitlab: http.StatusUnauthorized},
+	}
+	for _, testcase := range testcases {
+		s := &webhookServer{githubSecret: testcase.githubSecret, gitlabSecret: testcase.gitlabSecret}
+		srv := httptest.NewServer(s.handler())
+		for provider, status := range map[string]int{"github": testcase.github, "gitlab": testcase.gitlab} {
+			resp, err := http.Post(srv.URL+"/"+provider, "application/json", strings.NewReader("{}"))
+			if assert.NoError(t, err) {
+				resp.Body.Close()
+				assert.Equal(t, status, resp.StatusCode, provider)
+			}
+		}
+		srv.Close()
+	}
+}
+
+func TestProcessGitHubForeignHost(t *testing.T) {
+	requests := 0
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		fmt.Fprintf(w, `{"artifacts": [{"name": "reports", "archive_download_url": "http://169.254.169.254/zip"}]}`)
+	}))
+	defer api.Close()
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	defer other.Close()
+
+	githubAPI, err := url.Parse(api.URL)
+	assert.NoError(t, err)
+	s := &webhookServer{httpClient: api.Client(), githubAPI: githubAPI, githubToken: "token", pattern: "*"}
+
+	var event githubWorkflowRun
+	event.WorkflowRun.ArtifactsURL = other.URL + "/artifacts"
+	assert.Error(t, s.processGitHub(context.Background(), 1, event))
+	assert.Equal(t, 0, requests)
+
+	// Archives are checked too, not only the artifact list.
+	event.WorkflowRun.ArtifactsURL = api.URL + "/artifacts"
+	assert.Error(t, s.processGitHub(context.Background(), 1, event))
+	assert.Equal(t, 1, requests)
+}
+
+func TestGitHubBodyLimit(t *testing.T) {
+	s := &webhookServer{githubSecret: "s"}
+	srv := httptest.NewServer(s.handler())
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+"/github", "application/json", bytes.NewReader(make([]byte, maxWebhookBody+1)))
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+}
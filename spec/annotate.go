@@ -0,0 +1,115 @@
+package spec
+
+import (
+	"encoding/xml"
+	"io"
+)
+
+// Property is a name and value added to a testcase of a JUnit XML report.
+type Property struct {
+	Name, Value string
+}
+
+// Annotate copies the JUnit XML report in r to w, adding the properties
+// returned for the name of every testcase to its properties element, which
+// is created when the testcase has none. The rest of the report is copied
+// as it is.
+func Annotate(r io.Reader, w io.Writer, properties func(name string) []Property) error {
+	d := xml.NewDecoder(r)
+	e := xml.NewEncoder(w)
+
+	// pending holds the properties of the testcase just opened, until its
+	// first child shows whether it has a properties element.
+	var pending []Property
+	depth, caseDepth := 0, -1
+	for {
+		token, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			depth++
+			if pending != nil && depth == caseDepth+1 && t.Name.Local == "properties" {
+				if err := e.EncodeToken(t); err != nil {
+					return err
+				}
+				if err := encodeProperties(e, pending); err != nil {
+					return err
+				}
+				pending = nil
+				continue
+			}
+			if err := flushProperties(e, &pending); err != nil {
+				return err
+			}
+			if t.Name.Local == "testcase" {
+				for _, attr := range t.Attr {
+					if attr.Name.Local == "name" {
+						pending = properties(attr.Value)
+						caseDepth = depth
+					}
+				}
+				if len(pending) == 0 {
+					pending = nil
+				}
+			}
+		case xml.EndElement:
+			if depth == caseDepth {
+				if err := flushProperties(e, &pending); err != nil {
+					return err
+				}
+				caseDepth = -1
+			}
+			depth--
+		case xml.CharData:
+			// Whitespace before the first child stays where it is.
+		default:
+			if err := flushProperties(e, &pending); err != nil {
+				return err
+			}
+		}
+
+		if err := e.EncodeToken(xml.CopyToken(token)); err != nil {
+			return err
+		}
+	}
+	return e.Flush()
+}
+
+// flushProperties writes the pending properties in a new properties
+// element.
+func flushProperties(e *xml.Encoder, pending *[]Property) error {
+	if *pending == nil {
+		return nil
+	}
+	properties := xml.StartElement{Name: xml.Name{Local: "properties"}}
+	if err := e.EncodeToken(properties); err != nil {
+		return err
+	}
+	if err := encodeProperties(e, *pending); err != nil {
+		return err
+	}
+	*pending = nil
+	return e.EncodeToken(properties.End())
+}
+
+func encodeProperties(e *xml.Encoder, properties []Property) error {
+	for _, p := range properties {
+		start := xml.StartElement{
+			Name: xml.Name{Local: "property"},
+			Attr: []xml.Attr{{Name: xml.Name{Local: "name"}, Value: p.Name}, {Name: xml.Name{Local: "value"}, Value: p.Value}},
+		}
+		if err := e.EncodeToken(start); err != nil {
+			return err
+		}
+		if err := e.EncodeToken(start.End()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
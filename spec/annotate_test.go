@@ -0,0 +1,51 @@
+package spec
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnnotate(t *testing.T) {
+	report := `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites>
+  <testsuite name="accounts" tests="3">
+    <testcase name="TestRailC11 login" time="1"/>
+    <testcase name="TestRailC12 logout" time="2">
+      <properties>
+        <property name="owner" value="accounts"></property>
+      </properties>
+      <failure message="boom">still logged in</failure>
+    </testcase>
+    <testcase name="signup" time="1"><skipped/></testcase>
+  </testsuite>
+</testsuites>`
+
+	var b bytes.Buffer
+	err := Annotate(strings.NewReader(report), &b, func(name string) []Property {
+		if !strings.HasPrefix(name, "TestRailC") {
+			return nil
+		}
+		return []Property{{Name: "testrail_case", Value: strings.Fields(name)[0]}}
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites>
+  <testsuite name="accounts" tests="3">
+    <testcase name="TestRailC11 login" time="1"><properties><property name="testrail_case" value="TestRailC11"></property></properties></testcase>
+    <testcase name="TestRailC12 logout" time="2">
+      <properties><property name="testrail_case" value="TestRailC12"></property>
+        <property name="owner" value="accounts"></property>
+      </properties>
+      <failure message="boom">still logged in</failure>
+    </testcase>
+    <testcase name="signup" time="1"><skipped></skipped></testcase>
+  </testsuite>
+</testsuites>`, b.String())
+
+	suites, err := ParseBytes("annotated", b.Bytes())
+	assert.NoError(t, err)
+	assert.Len(t, suites[0].TestCases, 3)
+}
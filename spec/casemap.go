@@ -0,0 +1,59 @@
+package spec
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// CaseMap maps the names of tests that do not embed their case IDs to the
+// TestRail cases they cover.
+type CaseMap map[string][]int
+
+// caseMapFile is the YAML layout of a case mapping file. Each test maps to
+// one case ID or to a list of them.
+type caseMapFile struct {
+	Tests map[string]caseIDList `yaml:"tests"`
+}
+
+type caseIDList []int
+
+func (l *caseIDList) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var id int
+	if err := unmarshal(&id); err == nil {
+		*l = caseIDList{id}
+		return nil
+	}
+	var ids []int
+	if err := unmarshal(&ids); err != nil {
+		return fmt.Errorf("case IDs must be a number or a list of numbers")
+	}
+	*l = ids
+	return nil
+}
+
+// LoadCaseMap reads a case mapping YAML file.
+func LoadCaseMap(file string) (CaseMap, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var f caseMapFile
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return nil, err
+	}
+	m := CaseMap{}
+	for name, ids := range f.Tests {
+		if len(ids) > 0 {
+			m[name] = ids
+		}
+	}
+	return m, nil
+}
+
+// EncodeCaseMap returns the YAML of a case mapping file, with the tests in
+// order.
+func EncodeCaseMap(m CaseMap) ([]byte, error) {
+	return yaml.Marshal(map[string]CaseMap{"tests": m})
+}
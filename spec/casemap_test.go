@@ -0,0 +1,43 @@
+package spec
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/onsi/ginkgo/reporters"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCaseMap(t *testing.T) {
+	dir, err := ioutil.TempDir("", "casemap")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "cases.yaml")
+	assert.NoError(t, ioutil.WriteFile(file, []byte(`tests:
+  TestLoginTimeout: 11
+  "accounts logout": [12, 13]
+  TestSignup: []
+`), 0644))
+	m, err := LoadCaseMap(file)
+	assert.NoError(t, err)
+	assert.Equal(t, CaseMap{"TestLoginTimeout": {11}, "accounts logout": {12, 13}}, m)
+
+	u := Updates{ResultMap: map[int]Update{}, CaseMap: m}
+	assert.NoError(t, u.AddSuites("", JUnitTestSuites{Suites: []reporters.JUnitTestSuite{{
+		Name: "accounts",
+		TestCases: []reporters.JUnitTestCase{
+			{Name: "TestLoginTimeout"},
+			{Name: "accounts logout"},
+			{Name: "TestSignup"},
+			{Name: "TestRailC14 refund"},
+		},
+	}}}))
+	assert.Equal(t, []int{11, 12, 13, 14}, u.SortedCaseIDs())
+
+	assert.NoError(t, ioutil.WriteFile(file, []byte("tests:\n  TestLogin: C11\n"), 0644))
+	_, err = LoadCaseMap(file)
+	assert.Error(t, err)
+}
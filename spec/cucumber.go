@@ -0,0 +1,129 @@
+package spec
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/onsi/ginkgo/reporters"
+)
+
+func init() {
+	Register(cucumberParser{})
+}
+
+// cucumberFeature is a feature of a Cucumber JSON report, as written by the
+// json formatter of Cucumber for Ruby, Java and JavaScript.
+type cucumberFeature struct {
+	Name     string            `json:"name"`
+	Elements []cucumberElement `json:"elements"`
+}
+
+// cucumberElement is a scenario or the background run before the next one.
+type cucumberElement struct {
+	Name  string         `json:"name"`
+	Type  string         `json:"type"`
+	Tags  []cucumberTag  `json:"tags"`
+	Steps []cucumberStep `json:"steps"`
+}
+
+type cucumberTag struct {
+	Name string `json:"name"`
+}
+
+type cucumberStep struct {
+	Keyword string `json:"keyword"`
+	Name    string `json:"name"`
+	Result  struct {
+		Status string `json:"status"`
+		// Duration is in nanoseconds.
+		Duration     float64 `json:"duration"`
+		ErrorMessage string  `json:"error_message"`
+	} `json:"result"`
+}
+
+// cucumberParser reads Cucumber JSON reports. Each feature becomes a suite
+// and each scenario a test, with the outcome of every step kept as its steps.
+// Tags naming a case, such as @TestRailC12, are added to the test name.
+type cucumberParser struct{}
+
+func (cucumberParser) Name() string { return "cucumber" }
+
+// Detect looks for a JSON array of features with elements.
+func (cucumberParser) Detect(data []byte) bool {
+	data = bytes.TrimSpace(data)
+	return bytes.HasPrefix(data, []byte("[")) && bytes.Contains(data, []byte(`"elements"`))
+}
+
+func (cucumberParser) Parse(name string, data []byte) ([]reporters.JUnitTestSuite, error) {
+	var features []cucumberFeature
+	if err := json.Unmarshal(data, &features); err != nil {
+		return nil, fmt.Errorf("%s: invalid Cucumber JSON: %s", name, err)
+	}
+
+	suites := []reporters.JUnitTestSuite{}
+	for _, feature := range features {
+		suite := reporters.JUnitTestSuite{Name: feature.Name}
+		var background []cucumberStep
+		for _, element := range feature.Elements {
+			if element.Type == "background" {
+				background = element.Steps
+				continue
+			}
+			test := cucumberTest(element, append(background, element.Steps...))
+			background = nil
+
+			suite.Tests++
+			suite.Time += test.Time
+			if test.FailureMessage != nil {
+				suite.Failures++
+			}
+			suite.TestCases = append(suite.TestCases, test)
+		}
+		suites = append(suites, suite)
+	}
+	return suites, nil
+}
+
+// cucumberTest returns the test of a scenario, which fails if any of its
+// steps failed and is skipped if any other did not pass.
+func cucumberTest(scenario cucumberElement, steps []cucumberStep) reporters.JUnitTestCase {
+	name := scenario.Name
+	for _, tag := range scenario.Tags {
+		if caseIDRegex.MatchString(tag.Name) {
+			name += " " + strings.TrimPrefix(tag.Name, "@")
+		}
+	}
+	test := reporters.JUnitTestCase{Name: name}
+
+	status := Passed
+	results := make([]Step, 0, len(steps))
+	for _, step := range steps {
+		result := Step{Content: strings.TrimSpace(step.Keyword + step.Name)}
+		switch step.Result.Status {
+		case "passed":
+			result.Status = Passed
+		case "failed":
+			result.Status = Failed
+			result.Actual = step.Result.ErrorMessage
+			if status != Failed {
+				test.FailureMessage = &reporters.JUnitFailureMessage{Message: fmt.Sprintf("%s\n%s", result.Content, step.Result.ErrorMessage)}
+			}
+			status = Failed
+		default:
+			// Skipped, pending and undefined steps.
+			result.Status = Skipped
+			if status == Passed {
+				status = Skipped
+			}
+		}
+		test.Time += step.Result.Duration / 1e9
+		results = append(results, result)
+	}
+	if status == Skipped {
+		test.Skipped = &reporters.JUnitSkipped{}
+	}
+	SetSteps(&test, results)
+	return test
+}
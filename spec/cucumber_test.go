@@ -0,0 +1,76 @@
+package spec
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const cucumberReport = `[
+  {
+    "name": "Accounts",
+    "elements": [
+      {
+        "name": "Background",
+        "type": "background",
+        "steps": [
+          {"keyword": "Given ", "name": "a user", "result": {"status": "passed", "duration": 500000000}}
+        ]
+      },
+      {
+        "name": "Login",
+        "type": "scenario",
+        "tags": [{"name": "@smoke"}, {"name": "@TestRailC11"}],
+        "steps": [
+          {"keyword": "When ", "name": "they log in", "result": {"status": "failed", "duration": 1500000000, "error_message": "timeout"}},
+          {"keyword": "Then ", "name": "they see the dashboard", "result": {"status": "skipped"}}
+        ]
+      },
+      {
+        "name": "Logout TestRailC12",
+        "type": "scenario",
+        "steps": [
+          {"keyword": "When ", "name": "they log out", "result": {"status": "undefined"}}
+        ]
+      }
+    ]
+  }
+]`
+
+func TestCucumberParser(t *testing.T) {
+	p, err := Lookup("", []byte(cucumberReport))
+	assert.NoError(t, err)
+	assert.Equal(t, "cucumber", p.Name())
+
+	suites, err := p.Parse("report.json", []byte(cucumberReport))
+	assert.NoError(t, err)
+	assert.Len(t, suites, 1)
+	suite := suites[0]
+	assert.Equal(t, "Accounts", suite.Name)
+	assert.Equal(t, 2, suite.Tests)
+	assert.Equal(t, 1, suite.Failures)
+	assert.Equal(t, 2.0, suite.Time)
+
+	login := suite.TestCases[0]
+	assert.Equal(t, "Login TestRailC11", login.Name)
+	assert.Equal(t, "When they log in\ntimeout", login.FailureMessage.Message)
+	assert.Equal(t, []Step{
+		{Content: "Given a user", Status: Passed},
+		{Content: "When they log in", Actual: "timeout", Status: Failed},
+		{Content: "Then they see the dashboard", Status: Skipped},
+	}, TestSteps(login))
+
+	// The background only runs before the scenario after it.
+	logout := suite.TestCases[1]
+	assert.Nil(t, logout.FailureMessage)
+	assert.NotNil(t, logout.Skipped)
+	assert.Len(t, TestSteps(logout), 1)
+
+	updates := Updates{ResultMap: map[int]Update{}}
+	assert.NoError(t, updates.AddSuites("", JUnitTestSuites{Suites: suites}))
+	assert.Equal(t, []int{11, 12}, updates.SortedCaseIDs())
+	assert.Len(t, updates.ResultMap[11].Steps, 3)
+
+	_, err = p.Parse("report.json", []byte(`[{"elements": 1}]`))
+	assert.Error(t, err)
+}
@@ -0,0 +1,228 @@
+package spec
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+
+	"github.com/onsi/ginkgo/reporters"
+)
+
+// Parser reads the test suites of one report format.
+type Parser interface {
+	// Name is the format name used to select the parser explicitly.
+	Name() string
+	// Detect reports whether data looks like a report in this format.
+	Detect(data []byte) bool
+	// Parse reads the test suites of the report. The name is only used in
+	// error messages.
+	Parse(name string, data []byte) ([]reporters.JUnitTestSuite, error)
+}
+
+// DefaultFormat is used for reports no parser detects, so their errors
+// describe what is wrong with them as JUnit XML.
+const DefaultFormat = "junit"
+
+var parsers = map[string]Parser{}
+
+func init() {
+	Register(junitParser{})
+}
+
+// Register adds a parser to the registry. It panics if a parser with the same
+// name is already registered.
+func Register(p Parser) {
+	if _, ok := parsers[p.Name()]; ok {
+		panic(fmt.Sprintf("spec: parser %q registered twice", p.Name()))
+	}
+	parsers[p.Name()] = p
+}
+
+// Formats returns the names of the registered parsers.
+func Formats() []string {
+	names := []string{}
+	for name := range parsers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Lookup returns the parser for format, or the parser that detects data when
+// format is empty, falling back to DefaultFormat. Formats that are not
+// registered are parsed by the plugin for them on PATH.
+func Lookup(format string, data []byte) (Parser, error) {
+	if format != "" {
+		if p, ok := parsers[format]; ok {
+			return p, nil
+		}
+		if p, ok := lookupPlugin(format); ok {
+			return p, nil
+		}
+		return nil, fmt.Errorf("unknown report format %q and no %s%s on PATH", format, PluginPrefix, format)
+	}
+
+	if format := Detect(data); format != "" {
+		return parsers[format], nil
+	}
+	return parsers[DefaultFormat], nil
+}
+
+// Detect returns the format of the registered parser that detects data, or
+// "" if none does.
+func Detect(data []byte) string {
+	for _, name := range Formats() {
+		if parsers[name].Detect(data) {
+			return name
+		}
+	}
+	return ""
+}
+
+// fileParser is implemented by parsers that read the report file themselves,
+// such as plugins, which are stopped when ctx is done.
+type fileParser interface {
+	ParseFile(ctx context.Context, file string) ([]reporters.JUnitTestSuite, error)
+}
+
+// readerParser is implemented by parsers that read the report as it is
+// parsed, instead of needing all of it in memory.
+type readerParser interface {
+	ParseReader(name string, r io.Reader) ([]reporters.JUnitTestSuite, error)
+}
+
+// detectSize is how much of a report file is read to detect its format.
+const detectSize = 64 * 1024
+
+// DetectFile returns the format of the registered parser that detects the
+// content of file, or "" if none does.
+func DetectFile(file string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	head, err := ioutil.ReadAll(io.LimitReader(f, detectSize))
+	if err != nil {
+		return "", err
+	}
+	return Detect(head), nil
+}
+
+// ParseFileAs parses file with the parser for format, detecting the format
+// from the content when it is empty.
+func ParseFileAs(file, format string) ([]reporters.JUnitTestSuite, error) {
+	return ParseFileContext(context.Background(), file, format)
+}
+
+// ParseFileContext is ParseFileAs, stopping parser plugins when ctx is done.
+func ParseFileContext(ctx context.Context, file, format string) ([]reporters.JUnitTestSuite, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReaderSize(f, detectSize)
+	head, err := r.Peek(detectSize)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	p, err := Lookup(format, head)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", file, err)
+	}
+	switch p := p.(type) {
+	case fileParser:
+		suites, err := p.ParseFile(ctx, file)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", file, err)
+		}
+		return suites, nil
+	case readerParser:
+		return p.ParseReader(file, r)
+	}
+
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return p.Parse(file, data)
+}
+
+// ParseBytesAs parses data with the parser for format, detecting the format
+// from the content when it is empty. The name is only used in error messages.
+func ParseBytesAs(name, format string, data []byte) ([]reporters.JUnitTestSuite, error) {
+	return ParseBytesContext(context.Background(), name, format, data)
+}
+
+// ParseBytesContext is ParseBytesAs, stopping parser plugins when ctx is
+// done.
+func ParseBytesContext(ctx context.Context, name, format string, data []byte) ([]reporters.JUnitTestSuite, error) {
+	p, err := Lookup(format, data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", name, err)
+	}
+	if p, ok := p.(fileParser); ok {
+		return parseTempFile(ctx, p, name, data)
+	}
+	return p.Parse(name, data)
+}
+
+// parseTempFile writes data to a temporary file for p to read.
+func parseTempFile(ctx context.Context, p fileParser, name string, data []byte) ([]reporters.JUnitTestSuite, error) {
+	f, err := ioutil.TempFile("", "trailer-report-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	suites, err := p.ParseFile(ctx, f.Name())
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", name, err)
+	}
+	return suites, nil
+}
+
+// junitParser reads JUnit XML reports.
+type junitParser struct{}
+
+func (junitParser) Name() string { return "junit" }
+
+// Detect looks for a testsuite or testsuites root element.
+func (junitParser) Detect(data []byte) bool {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return false
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			return start.Name.Local == "testsuite" || start.Name.Local == "testsuites"
+		}
+	}
+}
+
+func (junitParser) Parse(name string, data []byte) ([]reporters.JUnitTestSuite, error) {
+	return ParseBytes(name, data)
+}
+
+func (junitParser) ParseReader(name string, r io.Reader) ([]reporters.JUnitTestSuite, error) {
+	return ParseReader(name, r)
+}
@@ -0,0 +1,118 @@
+package spec
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/onsi/ginkgo/reporters"
+	"github.com/stretchr/testify/assert"
+)
+
+type tapParser struct{}
+
+func (tapParser) Name() string { return "tap-test" }
+
+func (tapParser) Detect(data []byte) bool { return bytes.HasPrefix(data, []byte("TAP version")) }
+
+func (tapParser) Parse(name string, data []byte) ([]reporters.JUnitTestSuite, error) {
+	return []reporters.JUnitTestSuite{{Name: name, TestCases: []reporters.JUnitTestCase{{Name: "C1 ok"}}}}, nil
+}
+
+func TestLookup(t *testing.T) {
+	Register(tapParser{})
+	defer delete(parsers, "tap-test")
+
+	testcases := []struct {
+		format   string
+		data     string
+		expected string
+	}{
+		{data: `<?xml version="1.0"?><testsuites><testsuite name="a"></testsuite></testsuites>`, expected: "junit"},
+		{data: `<testsuite name="a"><testcase name="C1"></testcase></testsuite>`, expected: "junit"},
+		{data: "TAP version 13\nok 1 C1\n", expected: "tap-test"},
+		{data: "<bad", expected: DefaultFormat},
+		{format: "tap-test", data: "<testsuite/>", expected: "tap-test"},
+	}
+
+	for _, testcase := range testcases {
+		p, err := Lookup(testcase.format, []byte(testcase.data))
+		assert.NoError(t, err)
+		assert.Equal(t, testcase.expected, p.Name(), testcase.data)
+	}
+
+	_, err := Lookup("trx", nil)
+	assert.Error(t, err)
+
+	suites, err := ParseBytesAs("report.tap", "", []byte("TAP version 13\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, "report.tap", suites[0].Name)
+
+	assert.Panics(t, func() { Register(junitParser{}) })
+}
+
+func TestParseFileAsStreams(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spec")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	output := strings.Repeat("verbose output\n", 10000)
+	var report bytes.Buffer
+	report.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<!-- generated -->
+<testsuites>
+  <properties><property name="ci" value="true"/></properties>
+  <testsuite name="accounts" tests="3" failures="1" time="3.5">
+    <properties><property name="go" value="1.21"/></properties>`)
+	for i := 1; i <= 3; i++ {
+		fmt.Fprintf(&report, `
+    <testcase name="C%d login" classname="accounts" time="1">
+      <system-out>%s</system-out>`, i, output)
+		switch i {
+		case 2:
+			report.WriteString(`<failure type="assert">expected 1</failure>`)
+		case 3:
+			report.WriteString(`<skipped/>`)
+		}
+		report.WriteString(`</testcase>`)
+	}
+	report.WriteString(`
+    <system-err>` + output + `</system-err>
+  </testsuite>
+  <testsuite name="billing" tests="0"></testsuite>
+</testsuites>`)
+	file := filepath.Join(dir, "report.xml")
+	assert.NoError(t, ioutil.WriteFile(file, report.Bytes(), 0644))
+
+	suites, err := ParseFileAs(file, "")
+	assert.NoError(t, err)
+	assert.Len(t, suites, 2)
+	assert.Equal(t, "accounts", suites[0].Name)
+	assert.Equal(t, 3, suites[0].Tests)
+	assert.Equal(t, 3.5, suites[0].Time)
+	assert.Len(t, suites[0].TestCases, 3)
+	assert.Equal(t, "C2 login", suites[0].TestCases[1].Name)
+	assert.Equal(t, "expected 1", suites[0].TestCases[1].FailureMessage.Message)
+	assert.NotNil(t, suites[0].TestCases[2].Skipped)
+	for _, tc := range suites[0].TestCases {
+		assert.Empty(t, tc.SystemOut)
+	}
+	assert.Empty(t, suites[1].TestCases)
+
+	for _, body := range []string{
+		`<html><body>502</body></html>`,
+		`<testsuite name="a"></testsuite>`,
+		`<testsuites></testsuites>`,
+		`<testsuites><testsuite name="a"><testcase name="C1">`,
+		`<testsuite name="a" time="soon"><testcase name="C1"/></testsuite>`,
+		``,
+	} {
+		assert.NoError(t, ioutil.WriteFile(file, []byte(body), 0644))
+		_, err := ParseFileAs(file, "")
+		assert.Error(t, err, body)
+	}
+}
@@ -0,0 +1,151 @@
+package spec
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/onsi/ginkgo/reporters"
+)
+
+// PluginPrefix is the prefix of the executables that parse report formats
+// trailer does not know. The format name follows the prefix, so reports
+// given with --format nunit are parsed by trailer-parse-nunit.
+const PluginPrefix = "trailer-parse-"
+
+// PluginResults is the JSON a parser plugin writes to stdout.
+type PluginResults struct {
+	Suites []PluginSuite `json:"suites"`
+}
+
+// PluginSuite is a test suite reported by a parser plugin.
+type PluginSuite struct {
+	Name  string       `json:"name"`
+	Tests []PluginTest `json:"tests"`
+}
+
+// PluginTest is a test reported by a parser plugin. Status is passed, failed
+// or skipped, Time is in seconds and Message holds the failure output.
+type PluginTest struct {
+	Name    string       `json:"name"`
+	Status  string       `json:"status"`
+	Time    float64      `json:"time,omitempty"`
+	Message string       `json:"message,omitempty"`
+	Steps   []PluginStep `json:"steps,omitempty"`
+}
+
+// PluginStep is a step of a test reported by a parser plugin, such as a
+// Robot Framework keyword. Status is passed, failed or skipped.
+type PluginStep struct {
+	Content  string `json:"content"`
+	Expected string `json:"expected,omitempty"`
+	Actual   string `json:"actual,omitempty"`
+	Status   string `json:"status"`
+}
+
+// pluginWaitDelay is how long the output of a killed plugin is still read.
+const pluginWaitDelay = time.Second
+
+// execParser runs a parser plugin with the path of the report as its only
+// argument.
+type execParser struct {
+	format string
+	path   string
+}
+
+// lookupPlugin finds the plugin for format on PATH.
+func lookupPlugin(format string) (Parser, bool) {
+	path, err := exec.LookPath(PluginPrefix + format)
+	if err != nil {
+		return nil, false
+	}
+	return execParser{format: format, path: path}, true
+}
+
+// Plugins returns the formats of the parser plugins on PATH.
+func Plugins() []string {
+	seen := map[string]bool{}
+	formats := []string{}
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		matches, _ := filepath.Glob(filepath.Join(dir, PluginPrefix+"*"))
+		for _, match := range matches {
+			format := strings.TrimPrefix(filepath.Base(match), PluginPrefix)
+			if !seen[format] {
+				seen[format] = true
+				formats = append(formats, format)
+			}
+		}
+	}
+	return formats
+}
+
+func (p execParser) Name() string { return p.format }
+
+// Detect never matches, plugins are only used when their format is given.
+func (p execParser) Detect(data []byte) bool { return false }
+
+// Parse writes data to a temporary file for the plugin to read.
+func (p execParser) Parse(name string, data []byte) ([]reporters.JUnitTestSuite, error) {
+	return parseTempFile(context.Background(), p, name, data)
+}
+
+// ParseFile runs the plugin on file, killing it when ctx is done.
+func (p execParser) ParseFile(ctx context.Context, file string) ([]reporters.JUnitTestSuite, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, p.path, file)
+	// Children of the plugin may keep its output open after it is killed.
+	cmd.WaitDelay = pluginWaitDelay
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%s: %s: %s", filepath.Base(p.path), err, strings.TrimSpace(stderr.String()))
+	}
+
+	var results PluginResults
+	if err := json.Unmarshal(stdout.Bytes(), &results); err != nil {
+		return nil, fmt.Errorf("%s: invalid output: %s", filepath.Base(p.path), err)
+	}
+	return results.JUnit()
+}
+
+var pluginStatuses = map[string]TestStatus{"passed": Passed, "failed": Failed, "skipped": Skipped}
+
+// JUnit converts the plugin results to JUnit test suites.
+func (r PluginResults) JUnit() ([]reporters.JUnitTestSuite, error) {
+	suites := []reporters.JUnitTestSuite{}
+	for _, s := range r.Suites {
+		suite := reporters.JUnitTestSuite{Name: s.Name, Tests: len(s.Tests)}
+		for _, t := range s.Tests {
+			test := reporters.JUnitTestCase{Name: t.Name, Time: t.Time}
+			switch t.Status {
+			case "passed":
+			case "failed":
+				test.FailureMessage = &reporters.JUnitFailureMessage{Message: t.Message}
+				suite.Failures++
+			case "skipped":
+				test.Skipped = &reporters.JUnitSkipped{}
+			default:
+				return nil, fmt.Errorf("test %q has unknown status %q", t.Name, t.Status)
+			}
+			steps := []Step{}
+			for _, step := range t.Steps {
+				status, ok := pluginStatuses[step.Status]
+				if !ok {
+					return nil, fmt.Errorf("step %q of test %q has unknown status %q", step.Content, t.Name, step.Status)
+				}
+				steps = append(steps, Step{Content: step.Content, Expected: step.Expected, Actual: step.Actual, Status: status})
+			}
+			SetSteps(&test, steps)
+			suite.Time += t.Time
+			suite.TestCases = append(suite.TestCases, test)
+		}
+		suites = append(suites, suite)
+	}
+	return suites, nil
+}
@@ -0,0 +1,97 @@
+package spec
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/onsi/ginkgo/reporters"
+	"github.com/stretchr/testify/assert"
+)
+
+const fakePlugin = `#!/bin/sh
+test -f "$1" || exit 1
+echo '{"suites": [{"name": "checkout", "tests": [
+  {"name": "C1 pays", "status": "passed", "time": 1.5},
+  {"name": "C2 refunds", "status": "failed", "time": 2, "message": "refund declined"},
+  {"name": "C3 cancels", "status": "skipped"}
+]}]}'
+`
+
+func TestPluginParser(t *testing.T) {
+	dir, err := ioutil.TempDir("", "plugin")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, PluginPrefix+"fake"), []byte(fakePlugin), 0755))
+	defer os.Setenv("PATH", os.Getenv("PATH"))
+	os.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	assert.Contains(t, Plugins(), "fake")
+
+	report := filepath.Join(dir, "report.bin")
+	assert.NoError(t, ioutil.WriteFile(report, []byte("proprietary"), 0644))
+
+	for _, parse := range []func() ([]reporters.JUnitTestSuite, error){
+		func() ([]reporters.JUnitTestSuite, error) { return ParseFileAs(report, "fake") },
+		func() ([]reporters.JUnitTestSuite, error) {
+			return ParseBytesAs("report.bin", "fake", []byte("proprietary"))
+		},
+	} {
+		suites, err := parse()
+		assert.NoError(t, err)
+		assert.Len(t, suites, 1)
+		assert.Equal(t, "checkout", suites[0].Name)
+		assert.Equal(t, 1, suites[0].Failures)
+		assert.Len(t, suites[0].TestCases, 3)
+		assert.Equal(t, "refund declined", suites[0].TestCases[1].FailureMessage.Message)
+		assert.NotNil(t, suites[0].TestCases[2].Skipped)
+	}
+
+	_, err = ParseFileAs(report, "missing")
+	assert.Error(t, err)
+}
+
+func TestPluginParserContext(t *testing.T) {
+	dir, err := ioutil.TempDir("", "plugin")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, PluginPrefix+"hung"), []byte("#!/bin/sh\nsleep 30\n"), 0755))
+	defer os.Setenv("PATH", os.Getenv("PATH"))
+	os.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	report := filepath.Join(dir, "report.bin")
+	assert.NoError(t, ioutil.WriteFile(report, []byte("proprietary"), 0644))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = ParseFileContext(ctx, report, "hung")
+	assert.Error(t, err)
+	assert.True(t, time.Since(start) < 10*time.Second)
+
+	_, err = ParseBytesContext(ctx, "report.bin", "hung", []byte("proprietary"))
+	assert.Error(t, err)
+}
+
+func TestPluginResultsJUnit(t *testing.T) {
+	_, err := PluginResults{Suites: []PluginSuite{{Tests: []PluginTest{{Name: "C1", Status: "flaky"}}}}}.JUnit()
+	assert.Error(t, err)
+
+	_, err = PluginResults{Suites: []PluginSuite{{Tests: []PluginTest{{Name: "C1", Status: "passed", Steps: []PluginStep{{Content: "Open", Status: "flaky"}}}}}}}.JUnit()
+	assert.Error(t, err)
+
+	suites, err := PluginResults{Suites: []PluginSuite{{Tests: []PluginTest{{Name: "C1", Status: "failed", Steps: []PluginStep{
+		{Content: "Open Browser", Status: "passed"},
+		{Content: "Click Login", Expected: "logged in", Actual: "timeout", Status: "failed"},
+	}}}}}}.JUnit()
+	assert.NoError(t, err)
+	assert.Equal(t, []Step{
+		{Content: "Open Browser", Status: Passed},
+		{Content: "Click Login", Expected: "logged in", Actual: "timeout", Status: Failed},
+	}, TestSteps(suites[0].TestCases[0]))
+}
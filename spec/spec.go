@@ -2,8 +2,11 @@ package spec
 
 import (
 	"fmt"
+	"log/slog"
 	"regexp"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 	"encoding/xml"
 
@@ -31,27 +34,84 @@ type Update struct {
 	Status  TestStatus
 	Message string
 	Elapsed time.Duration
+	// Suite and Test name the JUnit test suite and test case the result
+	// was parsed from, and Failure is its failure message without the
+	// comment Message starts with.
+	Suite   string
+	Test    string
+	Failure string
+	// Steps are the outcomes of the steps of the test, when its report
+	// has them.
+	Steps []Step
 }
 
 type Updates struct {
 	ResultMap map[int]Update
+	// Statuses maps outcomes to TestRail status IDs, DefaultStatusMap is
+	// used when it is left empty.
+	Statuses StatusMap
+	// Version is recorded with every result, such as the build that was
+	// tested.
+	Version string
+	// Marker is appended to the comment of every result, so the results
+	// of an upload can be found again.
+	Marker string
+	// CaseMap gives the cases of tests whose names embed no case ID.
+	CaseMap CaseMap
+	// Unmatched names the tests that have no case ID, whose results are
+	// left out.
+	Unmatched []string
+	// Pruned lists the cases whose results the last upload left out for
+	// not being part of the run.
+	Pruned []int
+	// StepResults sends the steps of results as their step results, for
+	// cases using the separated steps template.
+	StepResults bool
+}
+
+// caseIDRegex matches the TestRail case references embedded in test names.
+var caseIDRegex = regexp.MustCompile("TestRailC([\\d]+)")
+
+// CaseIDs returns the TestRail case IDs referenced in a test name.
+func CaseIDs(name string) ([]int, error) {
+	ids := []int{}
+	for _, id := range caseIDRegex.FindAllStringSubmatch(name, -1) {
+		if len(id) != 2 {
+			return nil, fmt.Errorf("failed to parse case ID")
+		}
+		i, err := strconv.Atoi(id[1])
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert case ID to integer")
+		}
+		ids = append(ids, i)
+	}
+	return ids, nil
 }
 
 func (u *Updates) AddSuites(comment string, suites JUnitTestSuites) error {
 	for _, suite := range suites.Suites {
 		for _, test := range suite.TestCases {
-			regex, err := regexp.Compile("TestRailC([\\d]+)")
+			ids, err := CaseIDs(test.Name)
 			if err != nil {
-				return fmt.Errorf("failed to compile test case regex: %s", err)
+				return err
 			}
-			ids := regex.FindAllStringSubmatch(test.Name, -1)
-			for _, id := range ids {
-				if len(id) != 2 {
-					return fmt.Errorf("failed to parse case ID")
-				}
+			switch {
+			case len(ids) > 0:
+				slog.Debug("Found case IDs in the test name", "suite", suite.Name, "test", test.Name, "cases", ids)
+			case len(u.CaseMap[test.Name]) > 0:
+				ids = u.CaseMap[test.Name]
+				slog.Debug("Found case IDs in the case map", "suite", suite.Name, "test", test.Name, "cases", ids)
+			default:
+				slog.Debug("Skipping test without a case ID", "suite", suite.Name, "test", test.Name)
+				u.Unmatched = append(u.Unmatched, test.Name)
+			}
+			for _, i := range ids {
 				update := Update{
 					Status:  Passed,
 					Elapsed: time.Duration(test.Time) * time.Second,
+					Suite:   suite.Name,
+					Test:    test.Name,
+					Steps:   TestSteps(test),
 				}
 				if test.Skipped != nil {
 					update.Status = Skipped
@@ -59,13 +119,11 @@ func (u *Updates) AddSuites(comment string, suites JUnitTestSuites) error {
 				if test.FailureMessage != nil {
 					update.Status = Failed
 					update.Message = fmt.Sprintf("%s\n\n%s", comment, (*test.FailureMessage).Message)
-				}
-				i, err := strconv.Atoi(id[1])
-				if err != nil {
-					return fmt.Errorf("failed to convert case ID to integer")
+					update.Failure = (*test.FailureMessage).Message
 				}
 				if r, ok := u.ResultMap[i]; ok {
 					if r.Status == Failed {
+						slog.Debug("Keeping the earlier failure of the case", "case", i, "failed", r.Test, "test", test.Name)
 						continue
 					}
 				}
@@ -78,33 +136,95 @@ func (u *Updates) AddSuites(comment string, suites JUnitTestSuites) error {
 	return nil
 }
 
+// CreatePayload returns the results of all cases as one payload.
 func (u *Updates) CreatePayload() (testrail.SendableResultsForCase, error) {
+	return u.PayloadFor(u.SortedCaseIDs()), nil
+}
+
+// SortedCaseIDs returns the IDs of the cases with results in ascending order.
+// Results are sent in case order so that identical updates produce identical
+// requests, which replaying recorded requests relies on.
+func (u *Updates) SortedCaseIDs() []int {
+	caseIDs := make([]int, 0, len(u.ResultMap))
+	for k := range u.ResultMap {
+		caseIDs = append(caseIDs, k)
+	}
+	sort.Ints(caseIDs)
+	return caseIDs
+}
+
+// PayloadFor returns the results of the given cases, so large runs can be
+// sent in batches without building the payload of every case at once. Cases
+// without a result and outcomes mapped to no status are left out.
+func (u *Updates) PayloadFor(caseIDs []int) testrail.SendableResultsForCase {
 	results := testrail.SendableResultsForCase{
-		Results: []testrail.ResultsForCase{},
+		Results: make([]testrail.ResultsForCase, 0, len(caseIDs)),
 	}
 
-	for k, v := range u.ResultMap {
+	statuses := u.Statuses
+	if statuses == (StatusMap{}) {
+		statuses = DefaultStatusMap
+	}
+
+	for _, k := range caseIDs {
+		v, ok := u.ResultMap[k]
+		if !ok {
+			continue
+		}
 		result := testrail.SendableResult{
-			StatusID: 1,
+			StatusID: statuses.ID(v.Status),
+			Version:  u.Version,
+		}
+		if result.StatusID == 0 {
+			continue
 		}
 		timespan := testrail.TimespanFromDuration(v.Elapsed)
 		if timespan != nil {
 			result.Elapsed = *timespan
 		}
 		if v.Status == Failed {
-			result.StatusID = 5
 			result.Comment = v.Message
 		}
-		if v.Status == Skipped {
-			result.StatusID = 3
-		} else {
-			results.Results = append(results.Results, testrail.ResultsForCase{k, result})
+		if u.StepResults {
+			result.CustomStepResults = stepResults(statuses, v.Steps)
 		}
+		if u.Marker != "" {
+			result.Comment = strings.TrimLeft(result.Comment+"\n\n"+u.Marker, "\n")
+		}
+		results.Results = append(results.Results, testrail.ResultsForCase{CaseID: k, SendableResult: result})
 	}
 
-	return results, nil
+	return results
+}
+
+// stepResults returns the step results of steps. Steps whose outcome is
+// mapped to no status are sent as untested, since every step of the case has
+// to be listed.
+func stepResults(statuses StatusMap, steps []Step) []testrail.CustomStepResult {
+	results := make([]testrail.CustomStepResult, 0, len(steps))
+	for _, step := range steps {
+		id := statuses.ID(step.Status)
+		if id == 0 {
+			id = testrail.StatusUntested
+		}
+		results = append(results, testrail.CustomStepResult{Content: step.Content, Expected: step.Expected, Actual: step.Actual, StatusID: id})
+	}
+	return results
 }
 
 func (u *Updates) RemoveResult(i int) {
 	delete(u.ResultMap, i)
 }
+
+// KeepResults removes the results of the cases that are not in caseIDs.
+func (u *Updates) KeepResults(caseIDs []int) {
+	keep := map[int]bool{}
+	for _, id := range caseIDs {
+		keep[id] = true
+	}
+	for id := range u.ResultMap {
+		if !keep[id] {
+			delete(u.ResultMap, id)
+		}
+	}
+}
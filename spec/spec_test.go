@@ -0,0 +1,88 @@
+package spec
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreatePayloadStatuses(t *testing.T) {
+	testcases := []struct {
+		statuses StatusMap
+		expected map[int]int
+	}{
+		{
+			statuses: StatusMap{},
+			expected: map[int]int{1: 1, 2: 5},
+		},
+		{
+			statuses: StatusMap{Passed: 1, Failed: 5, Skipped: 6},
+			expected: map[int]int{1: 1, 2: 5, 3: 6},
+		},
+		{
+			statuses: StatusMap{Failed: 8},
+			expected: map[int]int{2: 8},
+		},
+	}
+
+	for _, testcase := range testcases {
+		updates := Updates{
+			ResultMap: map[int]Update{
+				1: {Status: Passed},
+				2: {Status: Failed, Message: "boom"},
+				3: {Status: Skipped},
+			},
+			Statuses: testcase.statuses,
+		}
+
+		payload, err := updates.CreatePayload()
+		assert.NoError(t, err)
+
+		actual := map[int]int{}
+		for _, result := range payload.Results {
+			actual[result.CaseID] = result.StatusID
+		}
+		assert.Equal(t, testcase.expected, actual)
+	}
+}
+
+func TestCaseIDs(t *testing.T) {
+	testcases := []struct {
+		name string
+		ids  []int
+	}{
+		{name: "login works", ids: []int{}},
+		{name: "TestRailC12 login works", ids: []int{12}},
+		{name: "TestRailC12 TestRailC345 login and logout", ids: []int{12, 345}},
+		{name: "C12 is not a reference", ids: []int{}},
+	}
+
+	for _, testcase := range testcases {
+		ids, err := CaseIDs(testcase.name)
+		assert.NoError(t, err)
+		assert.Equal(t, testcase.ids, ids)
+	}
+}
+
+func TestPayloadStepResults(t *testing.T) {
+	steps := []Step{
+		{Content: "Given a user", Status: Passed},
+		{Content: "When they log in", Actual: "timeout", Status: Failed},
+		{Content: "Then they see the dashboard", Status: Skipped},
+	}
+	updates := Updates{ResultMap: map[int]Update{1: {Status: Failed, Message: "boom", Steps: steps}}}
+
+	payload := updates.PayloadFor([]int{1})
+	assert.Empty(t, payload.Results[0].CustomStepResults)
+
+	updates.StepResults = true
+	payload = updates.PayloadFor([]int{1})
+	assert.Equal(t, "boom", payload.Results[0].Comment)
+	actual := []int{}
+	for _, step := range payload.Results[0].CustomStepResults {
+		actual = append(actual, step.StatusID)
+	}
+	// Skipped steps are not uploaded by default, so they are untested.
+	assert.Equal(t, []int{1, 5, 3}, actual)
+	assert.Equal(t, "timeout", payload.Results[0].CustomStepResults[1].Actual)
+}
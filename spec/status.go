@@ -0,0 +1,50 @@
+package spec
+
+import (
+	"io/ioutil"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// StatusMap maps the outcome of a test to the TestRail status ID its result
+// is uploaded with. A zero ID means results with that outcome are not
+// uploaded at all.
+type StatusMap struct {
+	Passed  int `yaml:"passed"`
+	Failed  int `yaml:"failed"`
+	Skipped int `yaml:"skipped"`
+}
+
+// DefaultStatusMap uses TestRail's built-in Passed and Failed statuses and
+// does not upload skipped tests.
+var DefaultStatusMap = StatusMap{
+	Passed: 1,
+	Failed: 5,
+}
+
+// ID returns the TestRail status ID for the given outcome.
+func (m StatusMap) ID(status TestStatus) int {
+	switch status {
+	case Passed:
+		return m.Passed
+	case Failed:
+		return m.Failed
+	case Skipped:
+		return m.Skipped
+	}
+	return 0
+}
+
+// LoadStatusMap reads a status mapping YAML file. Outcomes missing from the
+// file keep their default status.
+func LoadStatusMap(file string) (StatusMap, error) {
+	m := DefaultStatusMap
+
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return m, err
+	}
+
+	err = yaml.Unmarshal(data, &m)
+	return m, err
+}
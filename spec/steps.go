@@ -0,0 +1,44 @@
+package spec
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/onsi/ginkgo/reporters"
+)
+
+// Step is the outcome of one step of a test, such as a Cucumber step, which
+// can be uploaded as a step result of cases using TestRail's separated steps
+// template.
+type Step struct {
+	Content  string
+	Expected string
+	Actual   string
+	Status   TestStatus
+}
+
+// stepsPrefix marks the steps a parser stored in the system-out of a test.
+// JUnitTestCase has no room for steps, and the JUnit parser never keeps
+// system-out, so it cannot be mistaken for the output of a test.
+const stepsPrefix = "trailer-steps:"
+
+// SetSteps stores the steps of test for AddSuites to read.
+func SetSteps(test *reporters.JUnitTestCase, steps []Step) {
+	if len(steps) == 0 {
+		return
+	}
+	data, _ := json.Marshal(steps)
+	test.SystemOut = stepsPrefix + string(data)
+}
+
+// TestSteps returns the steps a parser stored in test with SetSteps.
+func TestSteps(test reporters.JUnitTestCase) []Step {
+	if !strings.HasPrefix(test.SystemOut, stepsPrefix) {
+		return nil
+	}
+	var steps []Step
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(test.SystemOut, stepsPrefix)), &steps); err != nil {
+		return nil
+	}
+	return steps
+}
@@ -1,39 +1,159 @@
 package spec
 
 import (
+	"bytes"
 	"encoding/xml"
 	"fmt"
-	"io/ioutil"
-	"os"
+	"io"
+	"strconv"
 
 	"github.com/onsi/ginkgo/reporters"
 )
 
-// TODO: add tests and comments
-
+// ParseFile parses a report, detecting its format from the content.
 func ParseFile(file string) ([]reporters.JUnitTestSuite, error) {
-	xmlFile, err := os.Open(file)
+	return ParseFileAs(file, "")
+}
+
+// ParseBytes parses a JUnit XML report holding either a single testsuite or
+// a testsuites element. The name is only used in error messages.
+func ParseBytes(name string, xmlBytes []byte) ([]reporters.JUnitTestSuite, error) {
+	return ParseReader(name, bytes.NewReader(xmlBytes))
+}
+
+// ParseReader parses a JUnit XML report like ParseBytes, decoding one
+// testcase at a time. Reports of hundreds of MB are mostly system-out, which
+// is skipped instead of being kept, so only the results stay in memory.
+func ParseReader(name string, r io.Reader) ([]reporters.JUnitTestSuite, error) {
+	suites, err := decodeSuites(xml.NewDecoder(r))
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to parse any testsuites from xml file: %s: %s", name, err)
+	}
+	return suites, nil
+}
+
+// decodeSuites reads the root element of a report.
+func decodeSuites(d *xml.Decoder) ([]reporters.JUnitTestSuite, error) {
+	for {
+		token, err := d.Token()
+		if err == io.EOF {
+			return nil, fmt.Errorf("no testsuite or testsuites element")
+		}
+		if err != nil {
+			return nil, err
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		switch start.Name.Local {
+		case "testsuite":
+			suite, err := decodeSuite(d, start)
+			if err != nil {
+				return nil, err
+			}
+			if len(suite.TestCases) == 0 {
+				return nil, fmt.Errorf("failed to parse single testsuite from xml file")
+			}
+			return []reporters.JUnitTestSuite{suite}, nil
+		case "testsuites":
+			suites := []reporters.JUnitTestSuite{}
+			err := decodeChildren(d, func(child xml.StartElement) error {
+				if child.Name.Local != "testsuite" {
+					return d.Skip()
+				}
+				suite, err := decodeSuite(d, child)
+				suites = append(suites, suite)
+				return err
+			})
+			if err != nil {
+				return nil, err
+			}
+			if len(suites) == 0 {
+				return nil, fmt.Errorf("failed to parse multiple testsuites from xml file")
+			}
+			return suites, nil
+		default:
+			return nil, fmt.Errorf("expected element type <testsuites> but have <%s>", start.Name.Local)
+		}
 	}
-	defer xmlFile.Close()
+}
 
-	xmlBytes, _ := ioutil.ReadAll(xmlFile)
-	suite, err := UnmarshalSingleTestSuite(xmlBytes)
-	if err == nil {
-		return []reporters.JUnitTestSuite{suite}, nil
+// decodedCase is a testcase without its system-out.
+type decodedCase struct {
+	Name           string                         `xml:"name,attr"`
+	ClassName      string                         `xml:"classname,attr"`
+	FailureMessage *reporters.JUnitFailureMessage `xml:"failure"`
+	Skipped        *reporters.JUnitSkipped        `xml:"skipped"`
+	Time           float64                        `xml:"time,attr"`
+}
+
+// decodeSuite reads the testsuite element start, skipping everything in it
+// but its testcases.
+func decodeSuite(d *xml.Decoder, start xml.StartElement) (reporters.JUnitTestSuite, error) {
+	suite := reporters.JUnitTestSuite{XMLName: start.Name}
+	for _, attr := range start.Attr {
+		var err error
+		switch attr.Name.Local {
+		case "name":
+			suite.Name = attr.Value
+		case "tests":
+			suite.Tests, err = strconv.Atoi(attr.Value)
+		case "failures":
+			suite.Failures, err = strconv.Atoi(attr.Value)
+		case "time":
+			suite.Time, err = strconv.ParseFloat(attr.Value, 64)
+		}
+		if err != nil {
+			return suite, fmt.Errorf("testsuite %q: %s attribute: %s", suite.Name, attr.Name.Local, err)
+		}
 	}
 
-	suites, err := UnmarshalMultipleTestSuites(xmlBytes)
-	if err == nil {
-		return suites, nil
+	err := decodeChildren(d, func(child xml.StartElement) error {
+		if child.Name.Local != "testcase" {
+			return d.Skip()
+		}
+		var tc decodedCase
+		if err := d.DecodeElement(&tc, &child); err != nil {
+			return err
+		}
+		suite.TestCases = append(suite.TestCases, reporters.JUnitTestCase{
+			Name:           tc.Name,
+			ClassName:      tc.ClassName,
+			FailureMessage: tc.FailureMessage,
+			Skipped:        tc.Skipped,
+			Time:           tc.Time,
+		})
+		return nil
+	})
+	return suite, err
+}
+
+// decodeChildren calls fn for each child element of the element being read,
+// which must consume the child, until the element ends.
+func decodeChildren(d *xml.Decoder, fn func(xml.StartElement) error) error {
+	for {
+		token, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			if err := fn(t); err != nil {
+				return err
+			}
+		case xml.EndElement:
+			return nil
+		}
 	}
-	return nil, fmt.Errorf("failed to parse any testsuites from xml file: %s", file)
 }
 
 func UnmarshalSingleTestSuite(xmlBytes []byte) (reporters.JUnitTestSuite, error) {
 	var suite reporters.JUnitTestSuite
-	xml.Unmarshal(xmlBytes, &suite)
+	if err := xml.Unmarshal(xmlBytes, &suite); err != nil {
+		return reporters.JUnitTestSuite{}, err
+	}
 
 	if len(suite.TestCases) == 0 {
 		return reporters.JUnitTestSuite{}, fmt.Errorf("failed to parse single testsuite from xml file")
@@ -44,7 +164,9 @@ func UnmarshalSingleTestSuite(xmlBytes []byte) (reporters.JUnitTestSuite, error)
 
 func UnmarshalMultipleTestSuites(xmlBytes []byte) ([]reporters.JUnitTestSuite, error) {
 	var suites JUnitTestSuites
-	xml.Unmarshal(xmlBytes, &suites)
+	if err := xml.Unmarshal(xmlBytes, &suites); err != nil {
+		return nil, err
+	}
 
 	if len(suites.Suites) == 0 {
 		return suites.Suites, fmt.Errorf("failed to parse multiple testsuites from xml file")
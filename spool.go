@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"regexp"
+
+	"github.com/urfave/cli"
+
+	"github.com/docker/trailer/spec"
+)
+
+// unavailableError marks failures caused by TestRail being unreachable or
+// overloaded rather than by the results themselves, so they can be retried
+// later.
+type unavailableError struct {
+	error
+}
+
+func (e unavailableError) Unwrap() error { return e.error }
+
+// unavailableStatus matches the status of TestRail error responses that
+// indicate an outage or rate limiting.
+var unavailableStatus = regexp.MustCompile(`status: "(5\d\d|429) `)
+
+// markUnavailable returns err as an unavailableError when cause shows that
+// TestRail could not be reached or is overloaded, and err otherwise.
+func markUnavailable(err, cause error) error {
+	switch cause.(type) {
+	case *url.Error, net.Error:
+		return unavailableError{err}
+	}
+	if unavailableStatus.MatchString(cause.Error()) {
+		return unavailableError{err}
+	}
+	return err
+}
+
+// spoolUpload saves results to the spool directory for a later flush.
+func spoolUpload(dir string, runID int, updates spec.Updates) (string, error) {
+	q, err := openQueue(dir)
+	if err != nil {
+		return "", err
+	}
+	return q.push(queuedUpload{RunID: runID, Updates: updates})
+}
+
+// checkpointDir is the spool directory interrupted uploads are saved to when
+// --spool is not set.
+const checkpointDir = ".trailer-spool"
+
+// checkpointUpload saves the results of an upload interrupted by err so they
+// can be uploaded later with flush, and returns err. TestRail may have
+// recorded the results of a request that was cancelled in flight, uploading
+// them again adds duplicate results.
+func checkpointUpload(err error, dir string, runID int, updates spec.Updates) error {
+	if dir == "" {
+		dir = checkpointDir
+	}
+
+	name, spoolErr := spoolUpload(dir, runID, updates)
+	if spoolErr != nil {
+		slog.Error("Failed to save a checkpoint", "error", spoolErr)
+		return err
+	}
+	slog.Warn("Saved the results to resume with flush", "results", len(updates.ResultMap), "spool", dir, "entry", name)
+	return err
+}
+
+func flushCommand() cli.Command {
+	return cli.Command{
+		Name:  "flush",
+		Usage: "Upload the results spooled while TestRail was unreachable",
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:   "spool",
+				Usage:  "spool directory given to upload",
+				EnvVar: "TRAILER_SPOOL",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			if c.String("spool") == "" {
+				return configErrorf("Must set --spool")
+			}
+
+			q, err := openQueue(c.String("spool"))
+			if err != nil {
+				return fmt.Errorf("Error opening spool: %s", err)
+			}
+			if err := q.recover(defaultLease); err != nil {
+				return fmt.Errorf("Error recovering interrupted entries: %s", err)
+			}
+
+			client, err := newClient()
+			if err != nil {
+				return err
+			}
+
+			uploaded, remaining, err := flushQueue(commandContext(c), q, client)
+			statusf("Flushed %d spooled uploads, %d remaining\n", uploaded, remaining)
+			if err != nil {
+				return err
+			}
+
+			return nil
+		},
+	}
+}
+
+// flushQueue makes one pass over the pending entries, oldest first. It stops
+// at the first failure since later entries would most likely fail the same
+// way, returning the failed entry to the queue.
+func flushQueue(ctx context.Context, q *uploadQueue, client testrailAPI) (uploaded, remaining int, err error) {
+	names, err := q.list(queuePending)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	for i := range names {
+		name, u, ok, err := q.claim()
+		if err != nil {
+			return uploaded, len(names) - i, err
+		}
+		if !ok {
+			break
+		}
+
+		stop := q.keepClaimed(name, defaultLease)
+		err = uploadResults(ctx, client, u.RunID, 1, &u.Updates)
+		stop()
+		if err != nil {
+			if err := q.release(name, u, err, 0); err != nil {
+				slog.Error("Error returning spooled upload", "entry", name, "error", err)
+			}
+			return uploaded, len(names) - i, fmt.Errorf("uploading %s: %s", name, err)
+		}
+
+		if err := q.done(name); err != nil {
+			slog.Error("Error removing spooled upload", "entry", name, "error", err)
+		}
+		uploaded++
+	}
+
+	return uploaded, 0, nil
+}
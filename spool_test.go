@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/docker/trailer/pkg/upload"
+)
+
+func TestMarkUnavailable(t *testing.T) {
+	testcases := []struct {
+		cause       error
+		unavailable bool
+	}{
+		{cause: &url.Error{Op: "Post", URL: "https://example.testrail.io", Err: errors.New("connection refused")}, unavailable: true},
+		{cause: errors.New(`response: status: "503 Service Unavailable", body: `), unavailable: true},
+		{cause: errors.New(`response: status: "429 Too Many Requests", body: `), unavailable: true},
+		{cause: errors.New(`response: status: "400 Bad Request", body: {"error":"case C1 unknown"}`), unavailable: false},
+		{cause: errors.New(`response: status: "401 Unauthorized", body: `), unavailable: false},
+	}
+
+	for _, testcase := range testcases {
+		err := markUnavailable(errors.New("failed"), testcase.cause)
+		_, ok := err.(unavailableError)
+		assert.Equal(t, testcase.unavailable, ok, testcase.cause.Error())
+		assert.Equal(t, "failed", err.Error())
+	}
+}
+
+func TestSpoolUnsentBatches(t *testing.T) {
+	s, stop := startFake(t)
+	defer stop()
+
+	dir, err := ioutil.TempDir("", "spool")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	defer func(size int) { upload.BatchSize = size }(upload.BatchSize)
+	upload.BatchSize = 1
+
+	// TestRail becomes unavailable after the first batch of three.
+	target, err := url.Parse(s.URL)
+	assert.NoError(t, err)
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	batches := 0
+	flaky := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.RawQuery, "add_results_for_cases") {
+			batches++
+			if batches > 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+		}
+		proxy.ServeHTTP(w, r)
+	}))
+	defer flaky.Close()
+	os.Setenv("TESTRAIL_URL", flaky.URL)
+
+	s.Lock()
+	s.Cases = append(s.Cases, s.Cases[1])
+	s.Cases[2].ID = 13
+	s.Unlock()
+	r := s.AddRun(1, 2, 11, 12, 13)
+	report := writeReport(t, dir, `<testsuite name="accounts" tests="3">
+  <testcase name="TestRailC11 login" time="1"></testcase>
+  <testcase name="TestRailC12 logout" time="1"></testcase>
+  <testcase name="TestRailC13 signup" time="1"></testcase>
+</testsuite>`)
+	spool := filepath.Join(dir, "spool")
+	assert.NoError(t, run("upload", "--run-id", strconv.Itoa(r.ID), "--spool", spool, report))
+	assert.Equal(t, 2, batches)
+
+	q, err := openQueue(spool)
+	assert.NoError(t, err)
+	name, u, ok, err := q.claim()
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.NoError(t, q.done(name))
+	cases := []int{}
+	for id := range u.Updates.ResultMap {
+		cases = append(cases, id)
+	}
+	sort.Ints(cases)
+	assert.Equal(t, []int{12, 13}, cases)
+}
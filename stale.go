@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/educlos/testrail"
+	"github.com/urfave/cli"
+
+	"github.com/docker/trailer/pkg/download"
+)
+
+// staleCase is a case without results in the runs looked at.
+type staleCase struct {
+	ID      int    `json:"id"`
+	Title   string `json:"title"`
+	Section string `json:"section"`
+	Owner   string `json:"owner,omitempty"`
+}
+
+// staleCases lists the cases of the suite that have no result in the runs,
+// with the owners from the cases file entries when there are any.
+func staleCases(cases []testrail.Case, sections []testrail.Section, h runHistory, entries map[int]download.Entry) []staleCase {
+	tested := map[int]bool{}
+	for _, tests := range h.Tests {
+		for _, test := range tests {
+			if test.StatusID != untestedStatus {
+				tested[test.CaseID] = true
+			}
+		}
+	}
+
+	paths := sectionPaths(sections)
+	stale := []staleCase{}
+	for _, c := range cases {
+		if tested[c.ID] {
+			continue
+		}
+		stale = append(stale, staleCase{
+			ID:      c.ID,
+			Title:   c.Title,
+			Section: sectionKey(paths[c.SectionID]),
+			Owner:   entries[c.ID].Owner,
+		})
+	}
+	return stale
+}
+
+func staleCommand() cli.Command {
+	return cli.Command{
+		Name:  "stale",
+		Usage: "List the cases without results in recent runs",
+		Flags: []cli.Flag{
+			cli.IntFlag{
+				Name:  "project-id, p",
+				Usage: "TestRail project ID of the suite",
+			},
+			cli.IntFlag{
+				Name:  "suite-id, s",
+				Usage: "TestRail suite ID of the cases",
+			},
+			cli.IntFlag{
+				Name:  "last",
+				Usage: "number of recent runs of the suite to look at, 0 for all of them",
+				Value: 20,
+			},
+			cli.IntFlag{
+				Name:  "days",
+				Usage: "only look at the runs created in this many days, 0 for all of them",
+			},
+			cli.StringFlag{
+				Name:  "file, f",
+				Usage: "cases file with the owners of the cases",
+			},
+			outputFlag,
+		},
+		Action: func(c *cli.Context) error {
+			projectID, suiteID, err := requireSuite(c)
+			if err != nil {
+				return err
+			}
+
+			var entries map[int]download.Entry
+			if file := c.String("file"); file != "" {
+				s, err := download.Load(file)
+				if err != nil {
+					return parseErrorf("Error reading cases file: %s", err)
+				}
+				entries = s.Cases
+			}
+
+			client, err := newClient()
+			if err != nil {
+				return err
+			}
+
+			filter := testrail.RequestFilterForRun{SuiteID: []int{suiteID}}
+			if last := c.Int("last"); last > 0 {
+				filter.Limit = &last
+			}
+			if days := c.Int("days"); days > 0 {
+				filter.CreatedAfter = strconv.FormatInt(time.Now().AddDate(0, 0, -days).Unix(), 10)
+			}
+			h, err := recentRuns(client, projectID, filter)
+			if err != nil {
+				return apiErrorf("Error getting runs: %s", err)
+			}
+			cases, err := client.GetCases(projectID, suiteID)
+			if err != nil {
+				return apiErrorf("Error getting cases: %s", err)
+			}
+			sections, err := client.GetSections(projectID, suiteID)
+			if err != nil {
+				return apiErrorf("Error getting sections: %s", err)
+			}
+
+			stale := staleCases(cases, sections, h, entries)
+			rows := [][]string{}
+			for _, s := range stale {
+				rows = append(rows, []string{fmt.Sprintf("C%d", s.ID), s.Title, s.Section, s.Owner})
+			}
+			if err := render(c.String("output"), stale, []string{"CASE", "TITLE", "SECTION", "OWNER"}, rows); err != nil {
+				return fmt.Errorf("Error printing stale cases: %s", err)
+			}
+
+			summaryf(c.String("output"), "\n%d of %d cases have no results in %d runs\n", len(stale), len(cases), len(h.Runs))
+			return nil
+		},
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/educlos/testrail"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/docker/trailer/pkg/download"
+)
+
+func TestStaleCases(t *testing.T) {
+	cases := []testrail.Case{
+		{ID: 1, Title: "Login", SectionID: 2},
+		{ID: 2, Title: "Refund", SectionID: 3},
+		{ID: 3, Title: "Invoice", SectionID: 3},
+	}
+	sections := []testrail.Section{
+		{ID: 1, Name: "Billing"},
+		{ID: 2, Name: "Accounts"},
+		{ID: 3, Name: "Refunds", ParentID: 1},
+	}
+	h := runHistory{Tests: [][]testrail.Test{
+		{{CaseID: 1, StatusID: 1}, {CaseID: 2, StatusID: 3}},
+		{{CaseID: 2, StatusID: 3}, {CaseID: 3, StatusID: 3}},
+	}}
+	entries := map[int]download.Entry{2: {Title: "Refund", Owner: "billing-team"}}
+
+	assert.Equal(t, []staleCase{
+		{ID: 2, Title: "Refund", Section: "Billing > Refunds", Owner: "billing-team"},
+		{ID: 3, Title: "Invoice", Section: "Billing > Refunds"},
+	}, staleCases(cases, sections, h, entries))
+}
+
+func TestStale(t *testing.T) {
+	s, stop := startFake(t)
+	defer stop()
+
+	dir, err := ioutil.TempDir("", "stale")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	s.AddRun(1, 2, 11, 12)
+	s.Lock()
+	s.Tests[0].StatusID = 1
+	s.Unlock()
+
+	file := filepath.Join(dir, "cases.yml")
+	assert.NoError(t, ioutil.WriteFile(file, []byte("project_id: 1\nsuite_id: 2\ncases:\n  12:\n    title: Logout\n    owner: accounts-team\n"), 0644))
+	assert.NoError(t, run("stale", "--project-id", "1", "--suite-id", "2", "--days", "7", "--file", file))
+	out := captureStdout(t, func() {
+		assert.NoError(t, run("stale", "--project-id", "1", "--suite-id", "2", "--days", "7", "--file", file, "--output", "csv"))
+	})
+	assert.Equal(t, "CASE,TITLE,SECTION,OWNER\nC12,Logout,Accounts,accounts-team\n", out)
+	assert.Equal(t, exitConfig, exitCode(run("stale", "--project-id", "1")))
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	yaml "gopkg.in/yaml.v2"
+
+	"github.com/educlos/testrail"
+	"github.com/urfave/cli"
+
+	"github.com/docker/trailer/spec"
+)
+
+func statusesCommand() cli.Command {
+	return cli.Command{
+		Name:  "statuses",
+		Usage: "List the result statuses of the TestRail instance",
+		Flags: []cli.Flag{
+			outputFlag,
+			cli.StringFlag{
+				Name:  "mapping, m",
+				Usage: "write a status mapping YAML for upload --status-map to this file, - for stdout",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			client, err := newClient()
+			if err != nil {
+				return err
+			}
+			statuses, err := client.GetStatuses()
+			if err != nil {
+				return apiErrorf("Error getting statuses: %s", err)
+			}
+
+			if mapping := c.String("mapping"); mapping != "" {
+				data, err := statusMappingYAML(statuses)
+				if err != nil {
+					return apiErrorf("Error creating status mapping: %s", err)
+				}
+
+				if mapping == "-" {
+					os.Stdout.Write(data)
+				} else if err := ioutil.WriteFile(mapping, data, 0644); err != nil {
+					return fmt.Errorf("Error writing status mapping: %s", err)
+				}
+				return nil
+			}
+
+			rows := [][]string{}
+			for _, s := range statuses {
+				rows = append(rows, []string{strconv.Itoa(s.ID), s.Name, s.Label, strconv.FormatBool(s.IsSystem)})
+			}
+
+			err = render(c.String("output"), statuses, []string{"ID", "NAME", "LABEL", "SYSTEM"}, rows)
+			if err != nil {
+				return fmt.Errorf("Error printing statuses: %s", err)
+			}
+
+			return nil
+		},
+	}
+}
+
+// statusMappingYAML builds a status mapping that uses the instance's statuses
+// whose names match an outcome, documenting every available status in a
+// comment header so the file is ready to edit.
+func statusMappingYAML(statuses []testrail.Status) ([]byte, error) {
+	m := spec.DefaultStatusMap
+	for _, s := range statuses {
+		switch strings.ToLower(s.Name) {
+		case "passed":
+			m.Passed = s.ID
+		case "failed":
+			m.Failed = s.ID
+		case "skipped":
+			m.Skipped = s.ID
+		}
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintln(&buf, "# Maps test outcomes to TestRail status IDs. A status of 0 means")
+	fmt.Fprintln(&buf, "# results with that outcome are not uploaded. Available statuses:")
+	w := tabwriter.NewWriter(&buf, 0, 8, 2, ' ', 0)
+	for _, s := range statuses {
+		fmt.Fprintf(w, "#   %d\t%s\t%s\n", s.ID, s.Name, s.Label)
+	}
+	w.Flush()
+
+	data, err := yaml.Marshal(&m)
+	if err != nil {
+		return nil, err
+	}
+	buf.Write(data)
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/urfave/cli"
+
+	"github.com/docker/trailer/spec"
+)
+
+var strictFlag = cli.BoolFlag{
+	Name:  "strict",
+	Usage: "fail if any test has no case ID or any result is left out because its case is not in the run or TestRail rejects it",
+}
+
+// strictCheck returns a partial upload error if any result of updates was
+// left out: tests without a case ID, results for cases outside the run and
+// the rejected results.
+func strictCheck(runID int, updates *spec.Updates, rejected int) error {
+	problems := []string{}
+	if n := len(updates.Unmatched); n > 0 {
+		problems = append(problems, fmt.Sprintf("%d tests have no case ID", n))
+	}
+	if n := len(updates.Pruned); n > 0 {
+		problems = append(problems, fmt.Sprintf("%d results are for cases that are not part of run %d", n, runID))
+	}
+	if rejected > 0 {
+		problems = append(problems, fmt.Sprintf("%d results were rejected by TestRail", rejected))
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	return exitError{code: exitPartial, err: fmt.Errorf("Left out results in strict mode: %s", strings.Join(problems, ", "))}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStrictUpload(t *testing.T) {
+	s, stop := startFake(t)
+	defer stop()
+
+	dir, err := ioutil.TempDir("", "strict")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	runID := strconv.Itoa(s.AddRun(1, 2, 11).ID)
+	matched := writeReport(t, dir, `<testsuite name="accounts" tests="1">
+  <testcase name="TestRailC11 login" time="1"></testcase>
+</testsuite>`)
+	assert.NoError(t, run("upload", "--strict", "--run-id", runID, matched))
+
+	unmatched := writeReport(t, dir, `<testsuite name="accounts" tests="2">
+  <testcase name="TestRailC11 login" time="1"></testcase>
+  <testcase name="TestSomethingElse" time="1"></testcase>
+</testsuite>`)
+	assert.NoError(t, run("upload", "--run-id", runID, unmatched))
+	assert.Equal(t, exitPartial, exitCode(run("upload", "--strict", "--run-id", runID, unmatched)))
+	assert.Equal(t, exitPartial, exitCode(run("upload", "--strict", "--dry", "--run-id", runID, unmatched)))
+
+	// Case 12 is not part of the run.
+	pruned := writeReport(t, dir, `<testsuite name="accounts" tests="2">
+  <testcase name="TestRailC11 login" time="1"></testcase>
+  <testcase name="TestRailC12 logout" time="1"></testcase>
+</testsuite>`)
+	assert.NoError(t, run("upload", "--run-id", runID, pruned))
+	err = run("upload", "--strict", "--run-id", runID, pruned)
+	assert.Equal(t, exitPartial, exitCode(err))
+	assert.Contains(t, err.Error(), "1 results are for cases that are not part of run "+runID)
+}
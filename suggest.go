@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/urfave/cli"
+
+	"github.com/docker/trailer/pkg/download"
+	"github.com/docker/trailer/spec"
+)
+
+var caseMapFlag = cli.StringFlag{
+	Name:  "case-map",
+	Usage: "YAML file mapping the names of tests without a TestRailC<ID> to their case IDs, see suggest",
+}
+
+// testSuggestion is the cases whose titles are closest to the name of a test
+// without a case ID, best first.
+type testSuggestion struct {
+	Test  string      `json:"test"`
+	Cases []caseMatch `json:"cases"`
+}
+
+// testNameNoise are the words of test names that say nothing of what they
+// test.
+var testNameNoise = map[string]bool{"test": true, "tests": true, "should": true, "it": true}
+
+// nameSimilarity scores how well a test name matches a case title, by how
+// well the words of each are found in the other, or by how close they are
+// spelled once their words are run together, as in TestLoginTimeout and
+// "Login times out".
+func nameSimilarity(test, title string) float64 {
+	kept := []string{}
+	for _, w := range words(test) {
+		if !testNameNoise[w] {
+			kept = append(kept, w)
+		}
+	}
+	name := strings.Join(kept, " ")
+	if name == "" {
+		return 0
+	}
+
+	score := (similarity(name, title) + similarity(title, name)) / 2
+	a, b := strings.Join(kept, ""), strings.Join(words(title), "")
+	longest := len(a)
+	if len(b) > longest {
+		longest = len(b)
+	}
+	if spelled := 1 - float64(levenshtein(a, b))/float64(longest); spelled > score {
+		score = spelled
+	}
+	return score
+}
+
+// suggestCases proposes up to limit cases for each of the tests.
+func suggestCases(tests []string, cases []caseMatch, limit int) []testSuggestion {
+	suggestions := []testSuggestion{}
+	for _, test := range tests {
+		s := testSuggestion{Test: test, Cases: []caseMatch{}}
+		for _, c := range cases {
+			c.Score = nameSimilarity(test, c.Title)
+			if c.Score >= minSearchScore {
+				s.Cases = append(s.Cases, c)
+			}
+		}
+		sort.Slice(s.Cases, func(i, j int) bool {
+			if s.Cases[i].Score != s.Cases[j].Score {
+				return s.Cases[i].Score > s.Cases[j].Score
+			}
+			return s.Cases[i].ID < s.Cases[j].ID
+		})
+		if limit > 0 && len(s.Cases) > limit {
+			s.Cases = s.Cases[:limit]
+		}
+		suggestions = append(suggestions, s)
+	}
+	return suggestions
+}
+
+// unmappedTests returns the names of the tests of the reports that neither
+// embed a case ID nor are in caseMap, sorted.
+func unmappedTests(ctx context.Context, files []string, format string, caseMap spec.CaseMap) ([]string, error) {
+	seen := map[string]bool{}
+	for _, file := range files {
+		suites, err := spec.ParseFileContext(ctx, file, format)
+		if err != nil {
+			return nil, err
+		}
+		for _, suite := range suites {
+			for _, test := range suite.TestCases {
+				ids, err := spec.CaseIDs(test.Name)
+				if err != nil {
+					return nil, err
+				}
+				if len(ids) == 0 && len(caseMap[test.Name]) == 0 {
+					seen[test.Name] = true
+				}
+			}
+		}
+	}
+	tests := []string{}
+	for name := range seen {
+		tests = append(tests, name)
+	}
+	sort.Strings(tests)
+	return tests, nil
+}
+
+// caseMapStub writes the best suggestion of each test as a case mapping for
+// upload --case-map, with the others in comments, for someone to review.
+func caseMapStub(suggestions []testSuggestion) []byte {
+	var b bytes.Buffer
+	fmt.Fprintln(&b, "# Suggested TestRail cases of tests without a case ID, generated by")
+	fmt.Fprintln(&b, "# trailer suggest. Check each one, the comments list the closest cases")
+	fmt.Fprintln(&b, "# with their scores. Tests with an empty list are not uploaded.")
+	fmt.Fprintln(&b, "tests:")
+	for _, s := range suggestions {
+		if len(s.Cases) == 0 {
+			fmt.Fprintf(&b, "  %s: []  # no similar case\n", strconv.Quote(s.Test))
+			continue
+		}
+		alternatives := []string{}
+		for _, c := range s.Cases {
+			alternatives = append(alternatives, fmt.Sprintf("C%d %s (%.2f)", c.ID, c.Title, c.Score))
+		}
+		fmt.Fprintf(&b, "  %s: [%d]  # %s\n", strconv.Quote(s.Test), s.Cases[0].ID, strings.Join(alternatives, ", "))
+	}
+	return b.Bytes()
+}
+
+func suggestCommand() cli.Command {
+	return cli.Command{
+		Name:      "suggest",
+		Usage:     "Suggest cases for the tests of reports that have no case ID, by title",
+		ArgsUsage: "[--file cases file | --project-id N] [report files...]",
+		Flags: []cli.Flag{
+			cli.StringSliceFlag{
+				Name:  "reports",
+				Usage: "report to read the tests of, may be repeated or given as arguments",
+			},
+			cli.StringFlag{
+				Name:  "file, f",
+				Usage: "cases file with the cases to suggest",
+			},
+			cli.IntFlag{
+				Name:  "project-id, p",
+				Usage: "TestRail project with the cases to suggest, instead of --file",
+			},
+			cli.IntFlag{
+				Name:  "suite-id, s",
+				Usage: "TestRail suite with the cases to suggest, every suite of the project by default",
+			},
+			cli.IntFlag{
+				Name:  "limit, n",
+				Usage: "suggest at most this many cases per test",
+				Value: 3,
+			},
+			cli.StringFlag{
+				Name:  "mapping, m",
+				Usage: "write a case mapping stub for upload --case-map to this file, - for stdout",
+			},
+			caseMapFlag,
+			formatFlag,
+			outputFlag,
+		},
+		Action: func(c *cli.Context) error {
+			reports := append(c.StringSlice("reports"), c.Args()...)
+			if len(reports) == 0 {
+				return configErrorf("Must specify the reports to suggest cases for")
+			}
+			if c.String("file") == "" && c.Int("project-id") == 0 {
+				return configErrorf("Must set --file or --project-id")
+			}
+
+			var caseMap spec.CaseMap
+			if file := c.String("case-map"); file != "" {
+				var err error
+				if caseMap, err = spec.LoadCaseMap(file); err != nil {
+					return configErrorf("Failed to load case map: %s", err)
+				}
+			}
+			tests, err := unmappedTests(commandContext(c), reports, c.String("format"), caseMap)
+			if err != nil {
+				return parseErrorf("Error reading reports: %s", err)
+			}
+
+			var cases []caseMatch
+			if file := c.String("file"); file != "" {
+				s, err := download.Load(file)
+				if err != nil {
+					return parseErrorf("Error reading cases file: %s", err)
+				}
+				for id, e := range s.Cases {
+					cases = append(cases, caseMatch{ID: id, Title: e.Title})
+				}
+			} else {
+				client, err := newClient()
+				if err != nil {
+					return err
+				}
+				if cases, err = searchableCases(client, c.Int("project-id"), c.Int("suite-id")); err != nil {
+					return apiErrorf("Error getting cases: %s", err)
+				}
+			}
+
+			suggestions := suggestCases(tests, cases, c.Int("limit"))
+			if mapping := c.String("mapping"); mapping != "" {
+				data := caseMapStub(suggestions)
+				if mapping == "-" {
+					os.Stdout.Write(data)
+				} else if err := ioutil.WriteFile(mapping, data, 0644); err != nil {
+					return fmt.Errorf("Error writing case mapping: %s", err)
+				}
+				return nil
+			}
+
+			rows := [][]string{}
+			for _, s := range suggestions {
+				if len(s.Cases) == 0 {
+					rows = append(rows, []string{s.Test, "", "", ""})
+				}
+				for _, m := range s.Cases {
+					rows = append(rows, []string{s.Test, fmt.Sprintf("C%d", m.ID), m.Title, strconv.FormatFloat(m.Score, 'f', 2, 64)})
+				}
+			}
+			if err := render(c.String("output"), suggestions, []string{"TEST", "CASE", "TITLE", "SCORE"}, rows); err != nil {
+				return fmt.Errorf("Error printing suggestions: %s", err)
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSuggestCases(t *testing.T) {
+	cases := []caseMatch{{ID: 11, Title: "Login"}, {ID: 12, Title: "Logout"}, {ID: 13, Title: "Login times out"}, {ID: 14, Title: "Refund"}}
+	suggestions := suggestCases([]string{"TestLoginTimeout", "TestSignup"}, cases, 2)
+	if assert.Len(t, suggestions, 2) {
+		assert.Equal(t, "TestLoginTimeout", suggestions[0].Test)
+		if assert.Len(t, suggestions[0].Cases, 2) {
+			assert.Equal(t, 13, suggestions[0].Cases[0].ID)
+			assert.Equal(t, 11, suggestions[0].Cases[1].ID)
+		}
+		assert.Empty(t, suggestions[1].Cases)
+	}
+
+	assert.Equal(t, `# Suggested TestRail cases of tests without a case ID, generated by
+# trailer suggest. Check each one, the comments list the closest cases
+# with their scores. Tests with an empty list are not uploaded.
+tests:
+  "TestLogin": [11]  # C11 Login (0.90)
+  "TestSignup": []  # no similar case
+`, string(caseMapStub([]testSuggestion{
+		{Test: "TestLogin", Cases: []caseMatch{{ID: 11, Title: "Login", Score: 0.9}}},
+		{Test: "TestSignup", Cases: []caseMatch{}},
+	})))
+}
+
+func TestSuggest(t *testing.T) {
+	s, stop := startFake(t)
+	defer stop()
+
+	dir, err := ioutil.TempDir("", "suggest")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	runID := strconv.Itoa(s.AddRun(1, 2, 11, 12).ID)
+	report := writeReport(t, dir, `<testsuite name="accounts" tests="3">
+  <testcase name="TestRailC11 login" time="1"></testcase>
+  <testcase name="TestLogout" time="1"><failure message="boom">still logged in</failure></testcase>
+  <testcase name="TestSignup" time="1"></testcase>
+</testsuite>`)
+
+	mapping := filepath.Join(dir, "cases.yaml")
+	assert.NoError(t, run("suggest", "--project-id", "1", "--mapping", mapping, report))
+	data, err := ioutil.ReadFile(mapping)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"TestLogout": [12]  # C12 Logout`)
+	assert.Contains(t, string(data), `"TestSignup": []  # no similar case`)
+
+	assert.NoError(t, run("upload", "--run-id", runID, "--case-map", mapping, report))
+	for _, test := range s.Tests {
+		if test.CaseID == 12 {
+			assert.Equal(t, 5, test.StatusID)
+		}
+	}
+
+	// Mapped tests are not suggested again.
+	assert.NoError(t, run("suggest", "--project-id", "1", "--case-map", mapping, "--mapping", mapping, report))
+	data, err = ioutil.ReadFile(mapping)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(data), "TestLogout")
+
+	assert.Equal(t, exitConfig, exitCode(run("suggest", "--project-id", "1")))
+	assert.Equal(t, exitConfig, exitCode(run("suggest", report)))
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/educlos/testrail"
+	"github.com/urfave/cli"
+
+	"github.com/docker/trailer/pkg/download"
+)
+
+// caseConflict is a case whose title changed both locally and in TestRail
+// since the last sync.
+type caseConflict struct {
+	ID     int
+	Base   string
+	Local  string
+	Remote string
+}
+
+// syncPlan lists the changes needed to bring a cases file and TestRail in
+// line with each other.
+type syncPlan struct {
+	// Pull maps case IDs to remote titles to write into the file.
+	Pull map[int]string
+	// Push maps case IDs to local titles to write to TestRail.
+	Push map[int]string
+	// Drop lists cases deleted in TestRail to remove from the file.
+	Drop      []int
+	Conflicts []caseConflict
+}
+
+// planSync performs a three-way comparison of the local titles, the titles at
+// the last sync and the remote cases. Without a base title, the local title
+// is treated as the base when the remote case has not been updated since
+// lastUpdated, and as stale otherwise.
+func planSync(local, base map[int]string, remote []testrail.Case, lastUpdated time.Time) syncPlan {
+	p := syncPlan{Pull: map[int]string{}, Push: map[int]string{}}
+
+	seen := map[int]struct{}{}
+	for _, r := range remote {
+		seen[r.ID] = struct{}{}
+
+		l, inLocal := local[r.ID]
+		b, inBase := base[r.ID]
+
+		switch {
+		case !inLocal && inBase:
+			// Pruned from the file on purpose, leave it out.
+		case !inLocal:
+			p.Pull[r.ID] = r.Title
+		case l == r.Title:
+		case !inBase:
+			if lastUpdated.Before(time.Unix(int64(r.UdpatedOn), 0)) {
+				p.Pull[r.ID] = r.Title
+			} else {
+				p.Push[r.ID] = l
+			}
+		case l == b:
+			p.Pull[r.ID] = r.Title
+		case r.Title == b:
+			p.Push[r.ID] = l
+		default:
+			p.Conflicts = append(p.Conflicts, caseConflict{ID: r.ID, Base: b, Local: l, Remote: r.Title})
+		}
+	}
+
+	for id, l := range local {
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		if b, ok := base[id]; ok && b != l {
+			p.Conflicts = append(p.Conflicts, caseConflict{ID: id, Base: b, Local: l})
+			continue
+		}
+		p.Drop = append(p.Drop, id)
+	}
+
+	sort.Ints(p.Drop)
+	sort.Slice(p.Conflicts, func(i, j int) bool { return p.Conflicts[i].ID < p.Conflicts[j].ID })
+	return p
+}
+
+func syncCommand() cli.Command {
+	return cli.Command{
+		Name:  "sync",
+		Usage: "Pull remote changes into a cases file and push local edits to TestRail",
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "file, f",
+				Usage: "cases file to sync",
+			},
+			cli.StringFlag{
+				Name:  "prefer",
+				Usage: "resolve conflicts in favor of local or remote titles instead of failing",
+			},
+			cli.BoolFlag{
+				Name:  "dry, d",
+				Usage: "print the planned changes without applying them",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			file := c.String("file")
+			if file == "" {
+				return configErrorf("Must specify an input cases file")
+			}
+
+			prefer := c.String("prefer")
+			if prefer != "" && prefer != "local" && prefer != "remote" {
+				return configErrorf("--prefer must be local or remote")
+			}
+
+			unlock, err := download.Lock(file)
+			if err != nil {
+				return fmt.Errorf("Error locking cases file: %s", err)
+			}
+			defer unlock()
+
+			s, err := download.Load(file)
+			if err != nil {
+				return parseErrorf("Error reading cases file: %s", err)
+			}
+			if s.ProjectID == 0 || s.SuiteID == 0 {
+				return configErrorf("Cases file has no project_id and suite_id")
+			}
+			lastUpdated, err := time.Parse(time.RFC3339Nano, s.LastUpdated)
+			if err != nil {
+				return parseErrorf("Error parsing last_updated time: %s", err)
+			}
+
+			client, err := newClient()
+			if err != nil {
+				return err
+			}
+			remote, err := client.GetCases(s.ProjectID, s.SuiteID)
+			if err != nil {
+				return apiErrorf("Error getting cases: %s", err)
+			}
+
+			plan := planSync(s.Titles(), s.Base, remote, lastUpdated)
+
+			for _, conflict := range plan.Conflicts {
+				statusf("conflict C%d: base %q, local %q, remote %q\n", conflict.ID, conflict.Base, conflict.Local, conflict.Remote)
+				switch {
+				case prefer == "local" && conflict.Remote != "":
+					plan.Push[conflict.ID] = conflict.Local
+				case prefer == "remote" && conflict.Remote != "":
+					plan.Pull[conflict.ID] = conflict.Remote
+				case prefer != "":
+					plan.Drop = append(plan.Drop, conflict.ID)
+				}
+			}
+			if len(plan.Conflicts) > 0 && prefer == "" {
+				return fmt.Errorf("%d conflicts, resolve them in the cases file or rerun with --prefer", len(plan.Conflicts))
+			}
+
+			for _, id := range sortedIDs(plan.Pull) {
+				statusf("pull C%d: %s\n", id, plan.Pull[id])
+			}
+			for _, id := range sortedIDs(plan.Push) {
+				statusf("push C%d: %s\n", id, plan.Push[id])
+			}
+			for _, id := range plan.Drop {
+				statusf("drop C%d: deleted in TestRail\n", id)
+			}
+			for _, e := range s.New {
+				statusf("create in section %d: %s\n", e.SectionID, e.Title)
+			}
+
+			if c.Bool("dry") {
+				return nil
+			}
+
+			if s.Base == nil {
+				s.Base = map[int]string{}
+			}
+			for _, r := range remote {
+				if _, ok := s.Cases[r.ID]; ok {
+					s.Base[r.ID] = r.Title
+				}
+			}
+
+			// save writes what was done so far when a change fails, so the
+			// cases already created are not created again by the next sync.
+			save := func(err error) error {
+				if saveErr := download.Save(file, s); saveErr != nil {
+					slog.Error("Failed to save the changes made before the error", "file", file, "error", saveErr)
+				}
+				return err
+			}
+
+			for id, title := range plan.Pull {
+				s.SetTitle(id, title)
+				s.Base[id] = title
+			}
+			for id, title := range plan.Push {
+				if _, err := client.UpdateCase(id, testrail.SendableCase{Title: title}); err != nil {
+					return save(apiErrorf("Error updating case C%d: %s", id, err))
+				}
+				s.Base[id] = title
+			}
+			for _, id := range plan.Drop {
+				delete(s.Cases, id)
+				delete(s.Base, id)
+			}
+
+			pending := []download.Case{}
+			for i, e := range s.New {
+				if e.SectionID == 0 || e.Title == "" {
+					statusf("%s new case without a section_id or title: section_id %d, title %q\n", colored(colorYellow, "skipping"), e.SectionID, e.Title)
+					pending = append(pending, e)
+					continue
+				}
+				created, err := client.AddCase(e.SectionID, e.Sendable())
+				if err != nil {
+					s.New = append(pending, s.New[i:]...)
+					return save(apiErrorf("Error creating case %q: %s", e.Title, err))
+				}
+				statusf("created C%d: %s\n", created.ID, created.Title)
+				s.SetTitle(created.ID, created.Title)
+				s.Base[created.ID] = created.Title
+			}
+			s.New = pending
+
+			if err := download.Save(file, s); err != nil {
+				return fmt.Errorf("Error writing cases file: %s", err)
+			}
+
+			return nil
+		},
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/educlos/testrail"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/docker/trailer/pkg/download"
+)
+
+func TestPlanSync(t *testing.T) {
+	lastSync := time.Unix(1000, 0)
+	before, after := 500, 2000
+
+	local := map[int]string{
+		1: "unchanged",
+		2: "local edit",
+		3: "stale",
+		4: "both edited locally",
+		5: "deleted remotely",
+		7: "no base, local edit",
+		8: "no base, stale",
+	}
+	base := map[int]string{
+		1: "unchanged",
+		2: "original",
+		3: "stale",
+		4: "original",
+		5: "deleted remotely",
+		6: "pruned locally",
+	}
+	remote := []testrail.Case{
+		{ID: 1, Title: "unchanged", UdpatedOn: before},
+		{ID: 2, Title: "original", UdpatedOn: before},
+		{ID: 3, Title: "remote edit", UdpatedOn: after},
+		{ID: 4, Title: "both edited remotely", UdpatedOn: after},
+		{ID: 6, Title: "pruned locally", UdpatedOn: before},
+		{ID: 7, Title: "remote", UdpatedOn: before},
+		{ID: 8, Title: "fresh", UdpatedOn: after},
+		{ID: 9, Title: "new remote case", UdpatedOn: after},
+	}
+
+	p := planSync(local, base, remote, lastSync)
+
+	assert.Equal(t, map[int]string{3: "remote edit", 8: "fresh", 9: "new remote case"}, p.Pull)
+	assert.Equal(t, map[int]string{2: "local edit", 7: "no base, local edit"}, p.Push)
+	assert.Equal(t, []int{5}, p.Drop)
+	assert.Equal(t, []caseConflict{{ID: 4, Base: "original", Local: "both edited locally", Remote: "both edited remotely"}}, p.Conflicts)
+}
+
+func TestSyncSavesCreatedCasesOnError(t *testing.T) {
+	s, stop := startFake(t)
+	defer stop()
+
+	dir, err := ioutil.TempDir("", "sync")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "cases.yml")
+
+	assert.NoError(t, run("download", "--project-id", "1", "--suite-id", "2", "--file", file))
+	suite, err := download.Load(file)
+	assert.NoError(t, err)
+	// Section 99 does not exist, so creating the second case fails.
+	suite.New = []download.Case{
+		{SectionID: 3, Title: "Refund"},
+		{SectionID: 99, Title: "Lost"},
+		{SectionID: 3, Title: "Invoice"},
+	}
+	assert.NoError(t, download.Save(file, suite))
+
+	assert.Equal(t, exitAPI, exitCode(run("sync", "--file", file)))
+
+	suite, err = download.Load(file)
+	assert.NoError(t, err)
+	s.Lock()
+	refund := s.Cases[len(s.Cases)-1]
+	s.Unlock()
+	assert.Equal(t, "Refund", refund.Title)
+	assert.Equal(t, "Refund", suite.Cases[refund.ID].Title)
+	assert.Equal(t, []download.Case{{SectionID: 99, Title: "Lost"}, {SectionID: 3, Title: "Invoice"}}, suite.New)
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/urfave/cli"
+)
+
+var tlsFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:   "ca-cert",
+		Usage:  "PEM file of the CA certificates to trust for TestRail in addition to the system ones",
+		EnvVar: "TRAILER_CA_CERT",
+	},
+	cli.BoolFlag{
+		Name:   "insecure-skip-verify",
+		Usage:  "do not verify the TLS certificate of TestRail",
+		EnvVar: "TRAILER_INSECURE_SKIP_VERIFY",
+	},
+	cli.StringFlag{
+		Name:   "client-cert",
+		Usage:  "PEM file of the client certificate for mutual TLS with TestRail",
+		EnvVar: "TRAILER_CLIENT_CERT",
+	},
+	cli.StringFlag{
+		Name:   "client-key",
+		Usage:  "PEM file of the key of the client certificate",
+		EnvVar: "TRAILER_CLIENT_KEY",
+	},
+}
+
+// tlsConfig returns the TLS configuration for the settings, or nil if they
+// are all left to their defaults.
+func tlsConfig(caCert string, insecure bool, clientCert, clientKey string) (*tls.Config, error) {
+	if caCert == "" && !insecure && clientCert == "" && clientKey == "" {
+		return nil, nil
+	}
+
+	config := &tls.Config{InsecureSkipVerify: insecure}
+
+	if caCert != "" {
+		pem, err := ioutil.ReadFile(caCert)
+		if err != nil {
+			return nil, err
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", caCert)
+		}
+		config.RootCAs = pool
+	}
+
+	if clientCert != "" || clientKey != "" {
+		if clientCert == "" || clientKey == "" {
+			return nil, fmt.Errorf("--client-cert and --client-key must be set together")
+		}
+		cert, err := tls.LoadX509KeyPair(clientCert, clientKey)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
+
+// tlsHintTransport explains certificate verification failures, which are
+// most often caused by a private CA.
+type tlsHintTransport struct {
+	next http.RoundTripper
+}
+
+func (t tlsHintTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+
+	var unknownAuthority x509.UnknownAuthorityError
+	var invalid x509.CertificateInvalidError
+	var hostname x509.HostnameError
+	switch {
+	case errors.As(err, &unknownAuthority):
+		return nil, fmt.Errorf("%s (set --ca-cert to the CA that signed the certificate of %s)", err, req.URL.Host)
+	case errors.As(err, &invalid), errors.As(err, &hostname):
+		return nil, fmt.Errorf("%s (check the certificate of %s, or set --insecure-skip-verify)", err, req.URL.Host)
+	}
+	return resp, err
+}
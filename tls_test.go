@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrivateCA(t *testing.T) {
+	s, stop := startFake(t)
+	defer stop()
+
+	secure := httptest.NewTLSServer(s.Config.Handler)
+	defer secure.Close()
+	os.Setenv("TESTRAIL_URL", secure.URL)
+
+	dir, err := ioutil.TempDir("", "tls")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	ca := filepath.Join(dir, "ca.pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: secure.Certificate().Raw})
+	assert.NoError(t, ioutil.WriteFile(ca, data, 0644))
+
+	err = run("projects", "list")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "set --ca-cert")
+
+	assert.NoError(t, run("--ca-cert", ca, "projects", "list"))
+	assert.NoError(t, run("--insecure-skip-verify", "projects", "list"))
+
+	assert.Equal(t, exitConfig, exitCode(run("--ca-cert", filepath.Join(dir, "missing.pem"), "projects", "list")))
+	assert.Equal(t, exitConfig, exitCode(run("--client-cert", ca, "projects", "list")))
+}
+
+func TestTLSOnlyForTestRail(t *testing.T) {
+	secure := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer secure.Close()
+
+	restore, err := setupTransport(transportOptions{insecure: true})
+	assert.NoError(t, err)
+	defer restore()
+
+	client := &http.Client{}
+	resp, err := client.Get(secure.URL + "/index.php?/api/v2/get_projects")
+	assert.NoError(t, err)
+	resp.Body.Close()
+
+	// Other services, such as Vault or webhooks, are still verified.
+	_, err = client.Get(secure.URL + "/v1/auth/approle/login")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "certificate")
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/urfave/cli"
+)
+
+var traceFlags = []cli.Flag{
+	cli.BoolFlag{
+		Name:   "trace-http",
+		Usage:  "log every HTTP request with its status and latency",
+		EnvVar: "TRAILER_TRACE_HTTP",
+	},
+	cli.BoolFlag{
+		Name:  "trace-http-bodies",
+		Usage: "also log the request and response bodies, with secrets redacted",
+	},
+}
+
+// maxTracedBody is how much of a body is logged.
+const maxTracedBody = 4096
+
+// secretKey matches the JSON keys whose values are redacted from traces.
+var secretKey = regexp.MustCompile(`(?i)password|token|secret|api_?key`)
+
+// setupTracing logs the HTTP requests made by both TestRail clients when
+// enabled. The returned function restores the network transport.
+func setupTracing(enabled, bodies bool) func() {
+	transport := http.DefaultTransport
+	if enabled || bodies {
+		http.DefaultTransport = traceTransport{next: transport, bodies: bodies}
+	}
+	return func() { http.DefaultTransport = transport }
+}
+
+// traceTransport logs the requests going through next. Only the host of
+// requests that do not call the TestRail API is logged, since webhook URLs
+// and bodies hold secrets.
+type traceTransport struct {
+	next   http.RoundTripper
+	bodies bool
+}
+
+func (t traceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	api := isAPIRequest(req)
+	attrs := []interface{}{"method", req.Method, "host", req.URL.Host}
+	if api {
+		attrs = append(attrs, "path", req.URL.RequestURI())
+	}
+
+	if api && t.bodies && req.Body != nil {
+		body, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+		attrs = append(attrs, "request", redact(body))
+	}
+
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	attrs = append(attrs, "duration", time.Since(start))
+	if err != nil {
+		slog.Info("HTTP request failed", append(attrs, "error", err)...)
+		return nil, err
+	}
+	attrs = append(attrs, "status", resp.StatusCode)
+
+	if api && t.bodies {
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+		attrs = append(attrs, "response", redact(body))
+	}
+
+	slog.Info("HTTP request", attrs...)
+	return resp, nil
+}
+
+// redact returns body for logging, truncated and with the values of secret
+// looking keys replaced when it is JSON.
+func redact(body []byte) string {
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err == nil {
+		if data, err := json.Marshal(redactValue(v)); err == nil {
+			body = data
+		}
+	}
+
+	if len(body) > maxTracedBody {
+		return string(body[:maxTracedBody]) + "..."
+	}
+	return string(body)
+}
+
+func redactValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, val := range v {
+			if secretKey.MatchString(k) {
+				v[k] = "REDACTED"
+			} else {
+				v[k] = redactValue(val)
+			}
+		}
+	case []interface{}:
+		for i, val := range v {
+			v[i] = redactValue(val)
+		}
+	}
+	return v
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedact(t *testing.T) {
+	for _, tc := range []struct {
+		body, expected string
+	}{
+		{`{"results":[{"case_id":1}]}`, `{"results":[{"case_id":1}]}`},
+		{`{"email":"a@b","password":"hunter2"}`, `{"email":"a@b","password":"REDACTED"}`},
+		{`[{"user":{"api_key":"k","Token":"t"}}]`, `[{"user":{"Token":"REDACTED","api_key":"REDACTED"}}]`},
+		{`not json`, `not json`},
+	} {
+		assert.Equal(t, tc.expected, redact([]byte(tc.body)))
+	}
+
+	long := redact([]byte(strings.Repeat("x", maxTracedBody+10)))
+	assert.Len(t, long, maxTracedBody+3)
+}
+
+func TestTraceTransport(t *testing.T) {
+	defer slog.SetDefault(slog.Default())
+	out := &bytes.Buffer{}
+	assert.NoError(t, setupLogging("info", "text", out))
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"ok": true}`)
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: traceTransport{next: http.DefaultTransport, bodies: true}}
+	for _, url := range []string{
+		srv.URL + "/index.php?/api/v2/get_projects",
+		srv.URL + "/services/T000/B000/webhook-secret",
+	} {
+		resp, err := client.Post(url, "application/json", strings.NewReader(`{"text": "hook-body"}`))
+		assert.NoError(t, err)
+		resp.Body.Close()
+	}
+
+	assert.Contains(t, out.String(), "get_projects")
+	assert.Equal(t, 2, strings.Count(out.String(), "HTTP request"))
+	assert.NotContains(t, out.String(), "webhook-secret")
+	assert.Equal(t, 1, strings.Count(out.String(), "hook-body"))
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli"
+)
+
+var transportFlags = []cli.Flag{
+	cli.DurationFlag{
+		Name:   "request-timeout",
+		Usage:  "give up on a single TestRail request after this long, 0 to wait forever",
+		Value:  2 * time.Minute,
+		EnvVar: "TRAILER_REQUEST_TIMEOUT",
+	},
+	cli.DurationFlag{
+		Name:   "keep-alive",
+		Usage:  "interval of the TCP keep-alive probes on connections, 0 to disable them",
+		Value:  30 * time.Second,
+		EnvVar: "TRAILER_KEEP_ALIVE",
+	},
+	cli.IntFlag{
+		Name:   "max-idle-conns",
+		Usage:  "idle connections to keep open per host for reuse",
+		Value:  10,
+		EnvVar: "TRAILER_MAX_IDLE_CONNS",
+	},
+}
+
+// transportOptions are the network settings of the TestRail clients.
+type transportOptions struct {
+	caCert     string
+	insecure   bool
+	clientCert string
+	clientKey  string
+
+	requestTimeout time.Duration
+	keepAlive      time.Duration
+	maxIdleConns   int
+}
+
+// setupTransport replaces the network transport used by both TestRail
+// clients, since the testrail package does not let us set its HTTP client.
+// The transport honors HTTPS_PROXY, HTTP_PROXY and NO_PROXY and asks for
+// gzip compressed responses. Only requests to TestRail go through it, the
+// TLS settings in particular are not meant for Vault, webhooks or the other
+// services trailer talks to. The returned function restores the previous
+// transport.
+func setupTransport(opts transportOptions) (func(), error) {
+	transport := http.DefaultTransport
+	restore := func() { http.DefaultTransport = transport }
+
+	base, ok := transport.(*http.Transport)
+	if !ok {
+		return restore, nil
+	}
+
+	config, err := tlsConfig(opts.caCert, opts.insecure, opts.clientCert, opts.clientKey)
+	if err != nil {
+		return restore, configErrorf("Invalid TLS settings: %s", err)
+	}
+
+	base = base.Clone()
+	base.TLSClientConfig = config
+	base.DisableCompression = false
+	base.MaxIdleConnsPerHost = opts.maxIdleConns
+	keepAlive := opts.keepAlive
+	if keepAlive == 0 {
+		keepAlive = -1
+	}
+	base.DialContext = (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: keepAlive}).DialContext
+
+	var next http.RoundTripper = tlsHintTransport{base}
+	if opts.requestTimeout > 0 {
+		next = timeoutTransport{next: next, timeout: opts.requestTimeout}
+	}
+	http.DefaultTransport = testrailTransport{testrail: next, other: transport}
+	return restore, nil
+}
+
+// testrailTransport sends the requests to TestRail through the transport
+// set up for it and the others through the previous one.
+type testrailTransport struct {
+	testrail http.RoundTripper
+	other    http.RoundTripper
+}
+
+func (t testrailTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if isTestRailRequest(req) {
+		return t.testrail.RoundTrip(req)
+	}
+	return t.other.RoundTrip(req)
+}
+
+// isTestRailRequest reports whether req goes to a TestRail instance, whose
+// API and pages are all served by index.php.
+func isTestRailRequest(req *http.Request) bool {
+	return strings.HasSuffix(req.URL.Path, "/index.php")
+}
+
+// timeoutTransport cancels requests, including the reading of their
+// response, after timeout.
+type timeoutTransport struct {
+	next    http.RoundTripper
+	timeout time.Duration
+}
+
+func (t timeoutTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, cancel := context.WithTimeout(req.Context(), t.timeout)
+	resp, err := t.next.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// cancelBody releases the timeout of a request once its body is closed.
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b cancelBody) Close() error {
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
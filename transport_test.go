@@ -0,0 +1,27 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestTimeout(t *testing.T) {
+	_, stop := startFake(t)
+	defer stop()
+
+	done := make(chan struct{})
+	hung := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer hung.Close()
+	defer close(done)
+	os.Setenv("TESTRAIL_URL", hung.URL)
+
+	err := run("--request-timeout", "50ms", "projects", "list")
+	assert.Equal(t, exitAPI, exitCode(err))
+	assert.Contains(t, err.Error(), "deadline exceeded")
+}
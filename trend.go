@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/educlos/testrail"
+	"github.com/urfave/cli"
+
+	"github.com/docker/trailer/spec"
+)
+
+// trendPoint is the pass rate of a run.
+type trendPoint struct {
+	RunID     int    `json:"run_id"`
+	Name      string `json:"name"`
+	CreatedOn string `json:"created_on"`
+	Passed    int    `json:"passed"`
+	Failed    int    `json:"failed"`
+	Untested  int    `json:"untested"`
+	// Other counts the tests with other statuses, such as blocked.
+	Other int `json:"other"`
+	// PassRate is the percentage of the tested cases that passed.
+	PassRate float64 `json:"pass_rate"`
+}
+
+// passRates counts the statuses of the tests of every run, oldest first.
+func passRates(h runHistory) []trendPoint {
+	passed, failed := spec.DefaultStatusMap.ID(spec.Passed), spec.DefaultStatusMap.ID(spec.Failed)
+
+	points := []trendPoint{}
+	for i, run := range h.Runs {
+		p := trendPoint{RunID: run.ID, Name: run.Name, CreatedOn: time.Unix(int64(run.CreatedOn), 0).UTC().Format(time.RFC3339)}
+		for _, test := range h.Tests[i] {
+			switch test.StatusID {
+			case passed:
+				p.Passed++
+			case failed:
+				p.Failed++
+			case untestedStatus:
+				p.Untested++
+			default:
+				p.Other++
+			}
+		}
+		if tested := p.Passed + p.Failed + p.Other; tested > 0 {
+			p.PassRate = 100 * float64(p.Passed) / float64(tested)
+		}
+		points = append(points, p)
+	}
+	return points
+}
+
+// Size of the trend chart and of its margins, in pixels.
+const (
+	chartWidth  = 640
+	chartHeight = 320
+	chartMargin = 40
+)
+
+// trendChart draws the pass rates as a line chart in SVG.
+func trendChart(points []trendPoint) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" font-family="sans-serif" font-size="11">`+"\n", chartWidth, chartHeight)
+
+	plotWidth, plotHeight := chartWidth-2*chartMargin, chartHeight-2*chartMargin
+	y := func(rate float64) float64 {
+		return float64(chartMargin) + float64(plotHeight)*(100-rate)/100
+	}
+	for _, rate := range []float64{0, 50, 100} {
+		fmt.Fprintf(&b, `<line x1="%d" y1="%.1f" x2="%d" y2="%.1f" stroke="#ddd"/>`+"\n", chartMargin, y(rate), chartWidth-chartMargin, y(rate))
+		fmt.Fprintf(&b, `<text x="%d" y="%.1f" text-anchor="end">%.0f%%</text>`+"\n", chartMargin-6, y(rate)+4, rate)
+	}
+
+	x := func(i int) float64 {
+		if len(points) < 2 {
+			return float64(chartMargin + plotWidth/2)
+		}
+		return float64(chartMargin) + float64(plotWidth*i)/float64(len(points)-1)
+	}
+	line := ""
+	for i, p := range points {
+		line += fmt.Sprintf("%.1f,%.1f ", x(i), y(p.PassRate))
+	}
+	fmt.Fprintf(&b, `<polyline points="%s" fill="none" stroke="#2a7ae2" stroke-width="2"/>`+"\n", line)
+	for i, p := range points {
+		fmt.Fprintf(&b, `<circle cx="%.1f" cy="%.1f" r="3" fill="#2a7ae2"><title>%s: %.1f%%</title></circle>`+"\n", x(i), y(p.PassRate), html.EscapeString(p.Name), p.PassRate)
+		fmt.Fprintf(&b, `<text x="%.1f" y="%d" text-anchor="middle">R%d</text>`+"\n", x(i), chartHeight-chartMargin/2, p.RunID)
+	}
+
+	b.WriteString("</svg>\n")
+	return b.Bytes()
+}
+
+func trendCommand() cli.Command {
+	return cli.Command{
+		Name:  "trend",
+		Usage: "Report the pass rates of a series of runs",
+		Flags: append([]cli.Flag{
+			cli.IntFlag{
+				Name:  "project-id, p",
+				Usage: "TestRail project ID of the runs",
+			},
+			cli.IntFlag{
+				Name:  "milestone-id, m",
+				Usage: "only report the runs of this milestone",
+			},
+			cli.IntFlag{
+				Name:  "suite-id, s",
+				Usage: "only report the runs of this suite",
+			},
+			cli.IntFlag{
+				Name:  "last",
+				Usage: "number of recent runs to report, 0 for all of them",
+				Value: 20,
+			},
+			cli.StringFlag{
+				Name:  "svg",
+				Usage: "also write a chart of the pass rates to this SVG file",
+			},
+			outputFlag,
+		}, emailFlags...),
+		Action: func(c *cli.Context) error {
+			if c.Int("project-id") == 0 {
+				return configErrorf("Must set --project-id to a non-zero integer")
+			}
+
+			client, err := newClient()
+			if err != nil {
+				return err
+			}
+
+			filter := testrail.RequestFilterForRun{}
+			if last := c.Int("last"); last > 0 {
+				filter.Limit = &last
+			}
+			if c.Int("milestone-id") != 0 {
+				filter.MilestoneID = []int{c.Int("milestone-id")}
+			}
+			if c.Int("suite-id") != 0 {
+				filter.SuiteID = []int{c.Int("suite-id")}
+			}
+			h, err := recentRuns(client, c.Int("project-id"), filter)
+			if err != nil {
+				return apiErrorf("Error getting runs: %s", err)
+			}
+
+			points := passRates(h)
+			rows := [][]string{}
+			for _, p := range points {
+				rows = append(rows, []string{fmt.Sprint(p.RunID), p.Name, p.CreatedOn, fmt.Sprint(p.Passed), fmt.Sprint(p.Failed), fmt.Sprint(p.Other), fmt.Sprint(p.Untested), fmt.Sprintf("%.1f", p.PassRate)})
+			}
+			if err := render(c.String("output"), points, []string{"RUN", "NAME", "CREATED", "PASSED", "FAILED", "OTHER", "UNTESTED", "PASS RATE"}, rows); err != nil {
+				return fmt.Errorf("Error printing trend: %s", err)
+			}
+
+			if file := c.String("svg"); file != "" {
+				if err := ioutil.WriteFile(file, trendChart(points), 0644); err != nil {
+					return fmt.Errorf("Error writing chart: %s", err)
+				}
+				fmt.Fprintf(os.Stderr, "Wrote chart of %d runs to %s\n", len(points), file)
+			}
+			if err := sendEmail(c, trendEmail, points); err != nil {
+				return fmt.Errorf("Error sending email: %s", err)
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/educlos/testrail"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPassRates(t *testing.T) {
+	h := runHistory{
+		Runs: []testrail.Run{{ID: 1, Name: "nightly", CreatedOn: 0}, {ID: 2, Name: "empty"}},
+		Tests: [][]testrail.Test{
+			{{StatusID: 1}, {StatusID: 1}, {StatusID: 1}, {StatusID: 5}, {StatusID: 3}},
+			{{StatusID: 3}},
+		},
+	}
+	assert.Equal(t, []trendPoint{
+		{RunID: 1, Name: "nightly", CreatedOn: "1970-01-01T00:00:00Z", Passed: 3, Failed: 1, Untested: 1, PassRate: 75},
+		{RunID: 2, Name: "empty", CreatedOn: "1970-01-01T00:00:00Z", Untested: 1},
+	}, passRates(h))
+}
+
+func TestTrend(t *testing.T) {
+	s, stop := startFake(t)
+	defer stop()
+
+	dir, err := ioutil.TempDir("", "trend")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	for i := 0; i < 3; i++ {
+		s.AddRun(1, 2, 11, 12)
+	}
+	s.Lock()
+	s.Runs[1].MilestoneID = 7
+	s.Runs[2].MilestoneID = 7
+	s.Tests[2].StatusID = 1
+	s.Unlock()
+
+	chart := filepath.Join(dir, "trend.svg")
+	assert.NoError(t, run("trend", "--project-id", "1", "--milestone-id", "7", "--output", "csv", "--svg", chart))
+	data, err := ioutil.ReadFile(chart)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "<svg")
+	assert.Equal(t, 2, strings.Count(string(data), "<circle"))
+
+	assert.Equal(t, exitConfig, exitCode(run("trend")))
+}
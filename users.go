@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/urfave/cli"
+)
+
+// group is a TestRail user group, which the testrail package does not wrap.
+type group struct {
+	ID      int    `json:"id"`
+	Name    string `json:"name"`
+	UserIDs []int  `json:"user_ids"`
+}
+
+func usersCommand() cli.Command {
+	return cli.Command{
+		Name:  "users",
+		Usage: "Look up TestRail users",
+		Subcommands: []cli.Command{
+			{
+				Name:  "list",
+				Usage: "List the users of the TestRail instance",
+				Flags: []cli.Flag{
+					cli.BoolFlag{
+						Name:  "all, a",
+						Usage: "include inactive users",
+					},
+					outputFlag,
+				},
+				Action: func(c *cli.Context) error {
+					client, err := newClient()
+					if err != nil {
+						return err
+					}
+					users, err := client.GetUsers()
+					if err != nil {
+						return apiErrorf("Error getting users: %s", err)
+					}
+
+					rows := [][]string{}
+					for _, u := range users {
+						if !u.IsActive && !c.Bool("all") {
+							continue
+						}
+						rows = append(rows, []string{strconv.Itoa(u.ID), u.Name, u.Email, strconv.FormatBool(u.IsActive)})
+					}
+
+					err = render(c.String("output"), users, []string{"ID", "NAME", "EMAIL", "ACTIVE"}, rows)
+					if err != nil {
+						return fmt.Errorf("Error printing users: %s", err)
+					}
+
+					return nil
+				},
+			},
+			{
+				Name:      "lookup",
+				Usage:     "Print the ID of the user with the given email address",
+				ArgsUsage: "[email]",
+				Flags:     []cli.Flag{outputFlag},
+				Action: func(c *cli.Context) error {
+					if c.NArg() != 1 {
+						return configErrorf("Must specify exactly one email address")
+					}
+
+					client, err := newClient()
+					if err != nil {
+						return err
+					}
+					user, err := client.GetUserByEmail(c.Args().First())
+					if err != nil {
+						return apiErrorf("Error looking up user: %s", err)
+					}
+
+					row := []string{strconv.Itoa(user.ID), user.Name, user.Email, strconv.FormatBool(user.IsActive)}
+					err = render(c.String("output"), user, []string{"ID", "NAME", "EMAIL", "ACTIVE"}, [][]string{row})
+					if err != nil {
+						return fmt.Errorf("Error printing user: %s", err)
+					}
+
+					return nil
+				},
+			},
+		},
+	}
+}
+
+func groupsCommand() cli.Command {
+	return cli.Command{
+		Name:  "groups",
+		Usage: "Look up TestRail user groups",
+		Subcommands: []cli.Command{
+			{
+				Name:  "list",
+				Usage: "List the user groups of the TestRail instance",
+				Flags: []cli.Flag{outputFlag},
+				Action: func(c *cli.Context) error {
+					var raw json.RawMessage
+					client, err := newClient()
+					if err != nil {
+						return err
+					}
+					if err := client.send("GET", "get_groups", nil, &raw); err != nil {
+						return apiErrorf("Error getting groups: %s", err)
+					}
+
+					groups, err := decodeGroups(raw)
+					if err != nil {
+						return apiErrorf("Error decoding groups: %s", err)
+					}
+
+					rows := [][]string{}
+					for _, g := range groups {
+						ids := []string{}
+						for _, id := range g.UserIDs {
+							ids = append(ids, strconv.Itoa(id))
+						}
+						rows = append(rows, []string{strconv.Itoa(g.ID), g.Name, strings.Join(ids, ",")})
+					}
+
+					err = render(c.String("output"), groups, []string{"ID", "NAME", "USERS"}, rows)
+					if err != nil {
+						return fmt.Errorf("Error printing groups: %s", err)
+					}
+
+					return nil
+				},
+			},
+		},
+	}
+}
+
+// decodeGroups accepts both the plain array returned by older TestRail
+// versions and the paginated object returned by newer ones.
+func decodeGroups(raw json.RawMessage) ([]group, error) {
+	var groups []group
+	if err := json.Unmarshal(raw, &groups); err == nil {
+		return groups, nil
+	}
+
+	var page struct {
+		Groups []group `json:"groups"`
+	}
+	if err := json.Unmarshal(raw, &page); err != nil {
+		return nil, fmt.Errorf("unexpected response: %s", err)
+	}
+	return page.Groups, nil
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/urfave/cli"
+
+	"github.com/docker/trailer/spec"
+)
+
+// caseReference records where in the reports a case ID was referenced.
+type caseReference struct {
+	File string
+	Test string
+}
+
+func validateCommand() cli.Command {
+	return cli.Command{
+		Name:      "validate",
+		Usage:     "Check JUnit XML reports and their case references before uploading",
+		ArgsUsage: "[input *.xml files...]",
+		Flags: []cli.Flag{
+			cli.IntFlag{
+				Name:  "run-id, r",
+				Usage: "TestRail run ID whose cases the references must belong to",
+			},
+			cli.IntFlag{
+				Name:  "project-id, p",
+				Usage: "TestRail project ID, used with --suite-id instead of --run-id",
+			},
+			cli.IntFlag{
+				Name:  "suite-id, s",
+				Usage: "TestRail suite ID whose cases the references must belong to",
+			},
+			formatFlag,
+		},
+		Action: func(c *cli.Context) error {
+			if c.NArg() == 0 {
+				return configErrorf("Must specify at least one report file")
+			}
+
+			problems := []string{}
+			references := map[int][]caseReference{}
+			tests, unreferenced := 0, 0
+
+			for _, file := range c.Args() {
+				suites, err := spec.ParseFileContext(commandContext(c), file, c.String("format"))
+				if err != nil {
+					problems = append(problems, fmt.Sprintf("malformed report: %s", err))
+					continue
+				}
+
+				for _, suite := range suites {
+					for _, test := range suite.TestCases {
+						tests++
+						ids, err := spec.CaseIDs(test.Name)
+						if err != nil {
+							problems = append(problems, fmt.Sprintf("%s: %q: %s", file, test.Name, err))
+							continue
+						}
+						if len(ids) == 0 {
+							unreferenced++
+						}
+						for _, id := range ids {
+							references[id] = append(references[id], caseReference{File: file, Test: test.Name})
+						}
+					}
+				}
+			}
+
+			ids := []int{}
+			for id := range references {
+				ids = append(ids, id)
+			}
+			sort.Ints(ids)
+
+			for _, id := range ids {
+				if refs := references[id]; len(refs) > 1 {
+					places := []string{}
+					for _, ref := range refs {
+						places = append(places, fmt.Sprintf("%s: %q", ref.File, ref.Test))
+					}
+					problems = append(problems, fmt.Sprintf("case C%d is referenced by %d tests:\n    %s", id, len(refs), strings.Join(places, "\n    ")))
+				}
+			}
+
+			known, target, err := knownCases(c)
+			if err != nil {
+				return err
+			}
+			if known != nil {
+				for _, id := range ids {
+					if _, ok := known[id]; !ok {
+						ref := references[id][0]
+						problems = append(problems, fmt.Sprintf("case C%d referenced by %s: %q does not exist in %s", id, ref.File, ref.Test, target))
+					}
+				}
+			}
+
+			statusf("%d reports, %d tests, %d case references, %d tests without a case reference\n", c.NArg(), tests, len(ids), unreferenced)
+			if len(problems) > 0 {
+				for _, p := range problems {
+					statusf("  - %s\n", p)
+				}
+				return fmt.Errorf("Validation failed with %d problems", len(problems))
+			}
+
+			statusf("All reports are valid\n")
+			return nil
+		},
+	}
+}
+
+// knownCases fetches the case IDs of the run or suite selected by the flags,
+// returning nil when neither is selected. The second value describes the
+// target for error messages.
+func knownCases(c *cli.Context) (map[int]struct{}, string, error) {
+	known := map[int]struct{}{}
+
+	if runID := c.Int("run-id"); runID != 0 {
+		client, err := newClient()
+		if err != nil {
+			return nil, "", err
+		}
+		tests, err := client.GetTests(runID)
+		if err != nil {
+			return nil, "", apiErrorf("Error getting tests of run %d: %s", runID, err)
+		}
+		for _, test := range tests {
+			known[test.CaseID] = struct{}{}
+		}
+		return known, fmt.Sprintf("run %d", runID), nil
+	}
+
+	if c.Int("project-id") != 0 || c.Int("suite-id") != 0 {
+		projectID, suiteID, err := requireSuite(c)
+		if err != nil {
+			return nil, "", err
+		}
+		client, err := newClient()
+		if err != nil {
+			return nil, "", err
+		}
+		cases, err := client.GetCases(projectID, suiteID)
+		if err != nil {
+			return nil, "", apiErrorf("Error getting cases of suite %d: %s", suiteID, err)
+		}
+		for _, cs := range cases {
+			known[cs.ID] = struct{}{}
+		}
+		return known, fmt.Sprintf("suite %d", suiteID), nil
+	}
+
+	return nil, "", nil
+}
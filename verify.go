@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/urfave/cli"
+
+	"github.com/docker/trailer/spec"
+)
+
+var verifyFlag = cli.BoolFlag{
+	Name:  "verify",
+	Usage: "fetch the run after uploading and fail if its tests do not have the uploaded statuses",
+}
+
+// verifyUpload fetches the tests of the run and compares their statuses with
+// the results in updates, which TestRail may have accepted without recording
+// all of them. Results for cases that are not part of the run were never
+// sent and are not checked.
+func verifyUpload(client testrailAPI, runID int, updates *spec.Updates) error {
+	tests, err := freshTests(client, runID)
+	if err != nil {
+		return apiErrorf("Failed to fetch the run to verify the upload: %s", err)
+	}
+	statuses := map[int]int{}
+	for _, test := range tests {
+		statuses[test.CaseID] = test.StatusID
+	}
+
+	sent, diff := 0, []string{}
+	for _, result := range updates.PayloadFor(updates.SortedCaseIDs()).Results {
+		status, ok := statuses[result.CaseID]
+		if !ok {
+			continue
+		}
+		sent++
+		if status != result.StatusID {
+			diff = append(diff, fmt.Sprintf("  C%d: uploaded status %d, run has status %d", result.CaseID, result.StatusID, status))
+		}
+	}
+	if len(diff) > 0 {
+		sort.Strings(diff)
+		return exitError{code: exitMismatch, err: fmt.Errorf("Run %d does not match %d of %d uploaded results:\n%s", runID, len(diff), sent, strings.Join(diff, "\n"))}
+	}
+	statusf("Verified %d results in run %d\n", sent, runID)
+	return nil
+}
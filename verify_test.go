@@ -0,0 +1,38 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUploadVerify(t *testing.T) {
+	s, stop := startFake(t)
+	defer stop()
+
+	dir, err := ioutil.TempDir("", "verify")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	runID := strconv.Itoa(s.AddRun(1, 2, 11, 12).ID)
+	report := writeReport(t, dir, `<testsuite name="accounts" tests="3">
+  <testcase name="TestRailC11 login" time="1"></testcase>
+  <testcase name="TestRailC12 logout" time="2"><failure message="still logged in"></failure></testcase>
+  <testcase name="TestRailC99 removed" time="1"></testcase>
+</testsuite>`)
+
+	assert.NoError(t, run("upload", "--verify", "--run-id", runID, report))
+
+	s.Lock()
+	s.Tests[len(s.Tests)-1].StatusID = 3
+	s.Lose = map[int]bool{12: true}
+	s.Unlock()
+
+	err = run("upload", "--verify", "--run-id", runID, report)
+	assert.Equal(t, exitMismatch, exitCode(err))
+	assert.Contains(t, err.Error(), "does not match 1 of 2 uploaded results")
+	assert.Contains(t, err.Error(), "C12: uploaded status 5, run has status 3")
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli"
+)
+
+// These are set at build time with
+// -ldflags "-X main.version=... -X main.commit=... -X main.date=...".
+var (
+	version = "dev"
+	commit  = "unknown"
+	date    = "unknown"
+)
+
+const latestReleaseURL = "https://api.github.com/repos/docker/trailer/releases/latest"
+
+func versionString() string {
+	return fmt.Sprintf("trailer %s (commit %s, built %s, %s %s/%s)", version, commit, date, runtime.Version(), runtime.GOOS, runtime.GOARCH)
+}
+
+func versionCommand() cli.Command {
+	return cli.Command{
+		Name:  "version",
+		Usage: "Print the version and build information",
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "check",
+				Usage: "check whether a newer release is available",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			fmt.Println(versionString())
+
+			if !c.Bool("check") {
+				return nil
+			}
+
+			latest, err := latestRelease()
+			if err != nil {
+				return apiErrorf("Error checking for the latest release: %s", err)
+			}
+
+			if compareVersions(version, latest) < 0 {
+				fmt.Printf("A newer release is available: %s\n", latest)
+			} else {
+				fmt.Println("This is the latest release")
+			}
+
+			return nil
+		},
+	}
+}
+
+// latestRelease returns the tag of the latest GitHub release.
+func latestRelease() (string, error) {
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Get(latestReleaseURL)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("response: status: %q", resp.Status)
+	}
+
+	var release struct {
+		TagName string `json:"tag_name"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&release); err != nil {
+		return "", err
+	}
+
+	return release.TagName, nil
+}
+
+// compareVersions compares two semantic versions, returning -1, 0 or 1. The
+// leading "v" and any pre-release or build suffix are ignored, and anything
+// that is not a release version, such as a dev build, sorts first.
+func compareVersions(a, b string) int {
+	pa, oka := parseVersion(a)
+	pb, okb := parseVersion(b)
+	switch {
+	case !oka && !okb:
+		return 0
+	case !oka:
+		return -1
+	case !okb:
+		return 1
+	}
+
+	for i := range pa {
+		if pa[i] != pb[i] {
+			if pa[i] < pb[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+func parseVersion(v string) ([3]int, bool) {
+	var parts [3]int
+
+	v = strings.TrimPrefix(v, "v")
+	if i := strings.IndexAny(v, "-+"); i >= 0 {
+		v = v[:i]
+	}
+
+	fields := strings.Split(v, ".")
+	if len(fields) != 3 {
+		return parts, false
+	}
+	for i, field := range fields {
+		n, err := strconv.Atoi(field)
+		if err != nil {
+			return parts, false
+		}
+		parts[i] = n
+	}
+
+	return parts, true
+}
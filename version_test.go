@@ -0,0 +1,28 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompareVersions(t *testing.T) {
+	testcases := []struct {
+		a        string
+		b        string
+		expected int
+	}{
+		{a: "1.2.3", b: "v1.2.3", expected: 0},
+		{a: "v1.2.3", b: "v1.2.4", expected: -1},
+		{a: "v1.10.0", b: "v1.9.9", expected: 1},
+		{a: "v2.0.0", b: "v1.99.0", expected: 1},
+		{a: "v1.2.3-rc.1", b: "v1.2.3", expected: 0},
+		{a: "dev", b: "v0.1.0", expected: -1},
+		{a: "v0.1.0", b: "dev", expected: 1},
+		{a: "dev", b: "dev", expected: 0},
+	}
+
+	for _, testcase := range testcases {
+		assert.Equal(t, testcase.expected, compareVersions(testcase.a, testcase.b), testcase.a+" vs "+testcase.b)
+	}
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/urfave/cli"
+
+	"github.com/docker/trailer/spec"
+)
+
+// reportState tracks a file of the watched directory until it settles.
+type reportState struct {
+	modTime  time.Time
+	size     int64
+	changed  time.Time
+	uploaded bool
+}
+
+// noteReport updates the state of the file name after it changed at now,
+// forgetting it once it is removed. Directories and hidden files, such as
+// the temporary files of editors, are ignored.
+func noteReport(name string, states map[string]*reportState, now time.Time) {
+	info, err := os.Stat(name)
+	if err != nil {
+		delete(states, name)
+		return
+	}
+	if info.IsDir() || strings.HasPrefix(filepath.Base(name), ".") {
+		return
+	}
+
+	state, ok := states[name]
+	if !ok || !state.modTime.Equal(info.ModTime()) || state.size != info.Size() {
+		states[name] = &reportState{modTime: info.ModTime(), size: info.Size(), changed: now}
+	}
+}
+
+// readyReports returns the reports that have stayed unchanged for settle
+// since they were last modified and have not been uploaded in that state
+// yet. Files are reports if they are in format, or when it is empty in a
+// format a parser detects, other files are skipped until they change.
+func readyReports(states map[string]*reportState, settle time.Duration, now time.Time, format string) []string {
+	ready := []string{}
+	for name, state := range states {
+		if state.uploaded || now.Sub(state.changed) < settle {
+			continue
+		}
+		if format == "" {
+			detected, err := spec.DetectFile(name)
+			if err != nil || detected == "" {
+				slog.Debug("Skipping a file that is not a report", "file", name, "error", err)
+				state.uploaded = true
+				continue
+			}
+		}
+		ready = append(ready, name)
+	}
+	sort.Strings(ready)
+	return ready
+}
+
+// scanReports notes every file in dir and returns the reports that are
+// ready, like readyReports.
+func scanReports(dir string, states map[string]*reportState, settle time.Duration, now time.Time, format string) ([]string, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, info := range infos {
+		noteReport(filepath.Join(dir, info.Name()), states, now)
+	}
+	return readyReports(states, settle, now, format), nil
+}
+
+func watchCommand() cli.Command {
+	return cli.Command{
+		Name:  "watch",
+		Usage: "Upload reports to TestRail as they appear in a directory",
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "dir",
+				Usage: "directory to watch for reports",
+				Value: ".",
+			},
+			cli.IntFlag{
+				Name:  "run-id, r",
+				Usage: "TestRail run ID to target for the update",
+			},
+			cli.StringFlag{
+				Name:  "comment, c",
+				Usage: "prefix to use when commenting on TestRail updates",
+			},
+			ciInfoFlag,
+			cli.StringFlag{
+				Name:  "status-map",
+				Usage: "YAML file mapping test outcomes to TestRail status IDs",
+			},
+			cli.IntFlag{
+				Name:  "ignore-failures, i",
+				Usage: "ignore failures and retry this number of times",
+				Value: 1,
+			},
+			cli.DurationFlag{
+				Name:  "interval",
+				Usage: "how often to check whether changed reports have settled",
+				Value: time.Second,
+			},
+			cli.DurationFlag{
+				Name:  "settle",
+				Usage: "how long a report must stay unchanged before it is uploaded",
+				Value: 2 * time.Second,
+			},
+			cli.BoolFlag{
+				Name:  "existing",
+				Usage: "also upload the reports already in the directory on startup",
+			},
+			formatFlag,
+		},
+		Action: func(c *cli.Context) error {
+			runID := c.Int("run-id")
+			if runID == 0 {
+				return configErrorf("Must set --run-id to a non-zero integer")
+			}
+			if c.Duration("interval") <= 0 {
+				return configErrorf("Must set --interval to a positive duration")
+			}
+
+			statuses := spec.StatusMap{}
+			if file := c.String("status-map"); file != "" {
+				var err error
+				if statuses, err = spec.LoadStatusMap(file); err != nil {
+					return configErrorf("Failed to load status map: %s", err)
+				}
+			}
+
+			dir := c.String("dir")
+			format := c.String("format")
+			watcher, err := fsnotify.NewWatcher()
+			if err != nil {
+				return fmt.Errorf("Error watching %s: %s", dir, err)
+			}
+			defer watcher.Close()
+			// The directory is watched before it is read, so no report
+			// written in between is missed.
+			if err := watcher.Add(dir); err != nil {
+				return fmt.Errorf("Error watching %s: %s", dir, err)
+			}
+			states := map[string]*reportState{}
+			if _, err := scanReports(dir, states, 0, time.Now(), format); err != nil {
+				return fmt.Errorf("Error reading %s: %s", dir, err)
+			}
+			if !c.Bool("existing") {
+				for _, state := range states {
+					state.uploaded = true
+				}
+			}
+
+			client, err := newClient()
+			if err != nil {
+				return err
+			}
+			ctx := commandContext(c)
+			ticker := time.NewTicker(c.Duration("interval"))
+			defer ticker.Stop()
+
+			slog.Info("Watching for reports", "dir", dir)
+			for {
+				select {
+				case event := <-watcher.Events:
+					noteReport(event.Name, states, time.Now())
+					continue
+				case err := <-watcher.Errors:
+					slog.Error("Error watching the report directory", "dir", dir, "error", err)
+					continue
+				case <-ticker.C:
+				case <-ctx.Done():
+					slog.Info("Stopped watching for reports", "dir", dir)
+					return nil
+				}
+
+				ready := readyReports(states, c.Duration("settle"), time.Now(), format)
+
+				for _, name := range ready {
+					// A report that fails to parse is probably still being
+					// written, it is retried once it changes again.
+					states[name].uploaded = true
+
+					suites, err := spec.ParseFileContext(ctx, name, format)
+					if err != nil {
+						slog.Warn("Skipping report", "file", name, "error", err)
+						continue
+					}
+
+					updates := spec.Updates{
+						ResultMap: map[int]spec.Update{},
+						Statuses:  statuses,
+					}
+					if err := updates.AddSuites(withCIInfo(c, c.String("comment")), spec.JUnitTestSuites{Suites: suites}); err != nil {
+						slog.Warn("Skipping report", "file", name, "error", err)
+						continue
+					}
+
+					slog.Info("Uploading results", "results", len(updates.ResultMap), "file", name)
+					if err := uploadResults(ctx, client, runID, c.Int("ignore-failures"), &updates); err != nil {
+						slog.Error("Error uploading report", "file", name, "error", err)
+					}
+				}
+			}
+		},
+	}
+}
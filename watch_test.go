@@ -0,0 +1,78 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScanReports(t *testing.T) {
+	dir, err := ioutil.TempDir("", "trailer-watch")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	report := filepath.Join(dir, "report.xml")
+	assert.NoError(t, ioutil.WriteFile(report, []byte("<testsuite>"), 0644))
+	features := filepath.Join(dir, "features.json")
+	assert.NoError(t, ioutil.WriteFile(features, []byte(`[{"name": "Accounts", "elements": []}]`), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "notes.txt"), []byte("ignored"), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, ".report.xml.swp"), []byte("<testsuite>"), 0644))
+
+	states := map[string]*reportState{}
+	start := time.Now()
+
+	ready, err := scanReports(dir, states, time.Second, start, "")
+	assert.NoError(t, err)
+	assert.Empty(t, ready)
+
+	ready, _ = scanReports(dir, states, time.Second, start.Add(500*time.Millisecond), "")
+	assert.Empty(t, ready)
+
+	ready, _ = scanReports(dir, states, time.Second, start.Add(time.Second), "")
+	assert.Equal(t, []string{features, report}, ready)
+
+	states[features].uploaded = true
+	states[report].uploaded = true
+	ready, _ = scanReports(dir, states, time.Second, start.Add(2*time.Second), "")
+	assert.Empty(t, ready)
+
+	assert.NoError(t, ioutil.WriteFile(report, []byte("<testsuite></testsuite>"), 0644))
+	ready, _ = scanReports(dir, states, time.Second, start.Add(3*time.Second), "")
+	assert.Empty(t, ready)
+
+	ready, _ = scanReports(dir, states, time.Second, start.Add(4*time.Second), "")
+	assert.Equal(t, []string{report}, ready)
+}
+
+func TestReadyReportsFormat(t *testing.T) {
+	dir, err := ioutil.TempDir("", "trailer-watch")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// Plugin formats cannot be detected, so every file is a report.
+	results := filepath.Join(dir, "results.tap")
+	assert.NoError(t, ioutil.WriteFile(results, []byte("ok 1 - login"), 0644))
+
+	start := time.Now()
+	states := map[string]*reportState{}
+	ready, err := scanReports(dir, states, 0, start, "")
+	assert.NoError(t, err)
+	assert.Empty(t, ready)
+
+	states = map[string]*reportState{}
+	ready, err = scanReports(dir, states, 0, start, "tap")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{results}, ready)
+
+	assert.NoError(t, os.Remove(results))
+	noteReport(results, states, start)
+	assert.Empty(t, states)
+}
+
+func TestWatchInterval(t *testing.T) {
+	assert.Equal(t, exitConfig, exitCode(run("watch", "--run-id", "1", "--interval", "0")))
+}
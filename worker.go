@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/urfave/cli"
+
+	"github.com/docker/trailer/spec"
+)
+
+var queueFlag = cli.StringFlag{
+	Name:   "queue, Q",
+	Usage:  "directory holding the upload queue",
+	Value:  ".trailer-queue",
+	EnvVar: "TRAILER_QUEUE",
+}
+
+func enqueueCommand() cli.Command {
+	return cli.Command{
+		Name:      "enqueue",
+		Usage:     "Parse JUnit XML reports and queue their results for a worker to upload",
+		ArgsUsage: "[input *.xml files...]",
+		Flags: []cli.Flag{
+			queueFlag,
+			cli.IntFlag{
+				Name:  "run-id, r",
+				Usage: "TestRail run ID to target for the update",
+			},
+			cli.StringFlag{
+				Name:  "comment, c",
+				Usage: "prefix to use when commenting on TestRail updates",
+			},
+			cli.StringFlag{
+				Name:  "status-map",
+				Usage: "YAML file mapping test outcomes to TestRail status IDs",
+			},
+			formatFlag,
+		},
+		Action: func(c *cli.Context) error {
+			runID := c.Int("run-id")
+			if runID == 0 {
+				return configErrorf("Must set --run-id to a non-zero integer")
+			}
+
+			statuses := spec.StatusMap{}
+			if file := c.String("status-map"); file != "" {
+				var err error
+				if statuses, err = spec.LoadStatusMap(file); err != nil {
+					return configErrorf("Failed to load status map: %s", err)
+				}
+			}
+
+			updates, err := parseReports(commandContext(c), c.Args(), c.String("format"), c.String("comment"), statuses, nil)
+			if err != nil {
+				return err
+			}
+
+			q, err := openQueue(c.String("queue"))
+			if err != nil {
+				return fmt.Errorf("Error opening queue: %s", err)
+			}
+
+			name, err := q.push(queuedUpload{RunID: runID, Updates: updates})
+			if err != nil {
+				return fmt.Errorf("Error queueing results: %s", err)
+			}
+
+			statusf("Queued %d results for run %d as %s\n", len(updates.ResultMap), runID, name)
+			return nil
+		},
+	}
+}
+
+func workerCommand() cli.Command {
+	return cli.Command{
+		Name:  "worker",
+		Usage: "Upload queued results to TestRail with rate limiting and retries",
+		Flags: []cli.Flag{
+			queueFlag,
+			cli.DurationFlag{
+				Name:  "rate",
+				Usage: "minimum time between two uploads",
+				Value: time.Second,
+			},
+			cli.DurationFlag{
+				Name:  "poll",
+				Usage: "how often to check an empty queue for new entries",
+				Value: 5 * time.Second,
+			},
+			cli.DurationFlag{
+				Name:  "backoff",
+				Usage: "time to wait after a failed upload, doubled for each consecutive failure",
+				Value: 10 * time.Second,
+			},
+			cli.DurationFlag{
+				Name:  "lease",
+				Usage: "how long an entry claimed by a worker that stopped is left before another worker uploads it",
+				Value: defaultLease,
+			},
+			cli.IntFlag{
+				Name:  "max-attempts",
+				Usage: "move an entry to the failed directory after this many attempts, 0 retries forever",
+				Value: 10,
+			},
+			cli.BoolFlag{
+				Name:  "once",
+				Usage: "exit once the queue is empty instead of waiting for new entries",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			if c.Duration("rate") <= 0 {
+				return configErrorf("Must set --rate to a positive duration")
+			}
+			if c.Duration("lease") <= 0 {
+				return configErrorf("Must set --lease to a positive duration")
+			}
+
+			q, err := openQueue(c.String("queue"))
+			if err != nil {
+				return fmt.Errorf("Error opening queue: %s", err)
+			}
+			if err := q.recover(c.Duration("lease")); err != nil {
+				return fmt.Errorf("Error recovering interrupted entries: %s", err)
+			}
+
+			client, err := newClient()
+			if err != nil {
+				return err
+			}
+
+			processed, failed := drainQueue(commandContext(c), q, client, c.Duration("rate"), c.Duration("poll"), c.Duration("backoff"), c.Duration("lease"), c.Int("max-attempts"), c.Bool("once"))
+			statusf("Uploaded %d queued entries, %d attempts failed\n", processed, failed)
+			return nil
+		},
+	}
+}
+
+// maxBackoff caps the wait between failed uploads.
+const maxBackoff = 10 * time.Minute
+
+// drainQueue uploads queued entries one at a time, waiting at least rate
+// between uploads and backing off after failures. Entries of stopped workers
+// are retried once their lease expires. It only returns when ctx is done, or
+// when once is set and the queue is empty.
+func drainQueue(ctx context.Context, q *uploadQueue, client testrailAPI, rate, poll, backoff, lease time.Duration, maxAttempts int, once bool) (processed, failed int) {
+	limiter := time.NewTicker(rate)
+	defer limiter.Stop()
+
+	delay := backoff
+	for {
+		name, u, ok, err := q.claim()
+		if err != nil {
+			slog.Error("Error claiming queue entry", "entry", name, "error", err)
+		}
+		if !ok {
+			if err := q.recover(lease); err != nil {
+				slog.Error("Error recovering interrupted entries", "error", err)
+			}
+			if once || !sleep(ctx, poll) {
+				return processed, failed
+			}
+			continue
+		}
+
+		// Entries claimed when ctx is done stay in processing, a worker
+		// returns them to pending without counting an attempt once their
+		// lease expires.
+		select {
+		case <-limiter.C:
+		case <-ctx.Done():
+			return processed, failed
+		}
+		stop := q.keepClaimed(name, lease)
+		err = uploadResults(ctx, client, u.RunID, 1, &u.Updates)
+		stop()
+		if err == nil {
+			if err := q.done(name); err != nil {
+				slog.Error("Error removing queue entry", "entry", name, "error", err)
+			}
+			processed++
+			delay = backoff
+			continue
+		}
+
+		if ctx.Err() != nil {
+			return processed, failed
+		}
+
+		failed++
+		slog.Error("Error uploading queue entry", "entry", name, "error", err)
+		if err := q.release(name, u, err, maxAttempts); err != nil {
+			slog.Error("Error returning queue entry", "entry", name, "error", err)
+		}
+		if !sleep(ctx, delay) {
+			return processed, failed
+		}
+		if delay < maxBackoff {
+			delay *= 2
+		}
+	}
+}
+
+// sleep waits for d, returning false if ctx is done first.
+func sleep(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}